@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Audit event operations (stable values for SIEM ingestion)
+const (
+	auditOpCreate       = "client.create"
+	auditOpPatch        = "client.patch"
+	auditOpRotate       = "client.rotate"
+	auditOpDelete       = "client.delete"
+	auditOpRestore      = "client.restore"
+	auditOpVerifySecret = "client.verify_secret"
+	auditOpSync         = "clients.sync"
+)
+
+// Audit event outcomes
+const (
+	auditOutcomeSuccess = "success"
+	auditOutcomePartial = "partial" // sync with some failed operations
+	auditOutcomeFailure = "failure"
+)
+
+// auditAnonymousCaller is recorded when admin auth is disabled
+const auditAnonymousCaller = "anonymous"
+
+// auditExportPageSize bounds how many events the export holds in memory at once
+const auditExportPageSize = 500
+
+// AuditLogger appends audit events. Implementations must be safe for
+// concurrent use: the database table (AUDIT_LOG, *Store) and JSON lines
+// (AUDIT_LOG_PATH, jsonLinesAuditLogger).
+type AuditLogger interface {
+	RecordAuditEvent(ctx context.Context, event *AuditEvent) error
+}
+
+// auditStore is an AuditLogger that can also page through its events in ID
+// order, which /admin/audit/export needs
+type auditStore interface {
+	AuditLogger
+	ListAuditEvents(ctx context.Context, filter auditFilter) ([]AuditEvent, error)
+}
+
+// auditLoggers records each event with every logger, so a file trail and
+// the database table can run side by side
+type auditLoggers []AuditLogger
+
+func (l auditLoggers) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	var errs []error
+	for _, logger := range l {
+		if err := logger.RecordAuditEvent(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// jsonLinesAuditLogger writes each audit event as one JSON line
+type jsonLinesAuditLogger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+}
+
+func newJSONLinesAuditLogger(w io.Writer) *jsonLinesAuditLogger {
+	return &jsonLinesAuditLogger{w: w, enc: json.NewEncoder(w)}
+}
+
+// openAuditLogFile opens AUDIT_LOG_PATH for appending ("-" = stdout). The
+// returned close function is a no-op for stdout.
+func openAuditLogFile(path string) (*jsonLinesAuditLogger, func() error, error) {
+	if path == "-" {
+		return newJSONLinesAuditLogger(os.Stdout), func() error { return nil }, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newJSONLinesAuditLogger(f), f.Close, nil
+}
+
+func (l *jsonLinesAuditLogger) RecordAuditEvent(_ context.Context, event *AuditEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(event)
+}
+
+// auditFilter selects one page of audit events
+type auditFilter struct {
+	// Since and Until bound occurred_at (inclusive, exclusive); zero = unbounded
+	Since time.Time
+	Until time.Time
+	// AfterID continues from the last event of the previous page
+	AfterID int64
+	Limit   int
+}
+
+// recordAudit records the outcome of a mutation, stamping the time, the
+// network, and the caller from the auth middleware. Failures are logged and
+// never fail the request, and the event is written even if the caller has
+// disconnected. No-op when no audit logger is configured.
+func (s *Server) recordAudit(ctx context.Context, nid uuid.UUID, event AuditEvent) {
+	if s.auditLogger == nil {
+		return
+	}
+	event.Timestamp = time.Now().UTC()
+	event.Caller = callerIdentity(ctx)
+	if event.Caller == "" {
+		event.Caller = auditAnonymousCaller
+	}
+	if nid != uuid.Nil {
+		event.NetworkID = nid.String()
+	}
+	if err := s.auditLogger.RecordAuditEvent(context.WithoutCancel(ctx), &event); err != nil {
+		log.Printf("Warning: Failed to record audit event %s for %q: %v", event.Operation, event.ClientID, err)
+	}
+}
+
+// syncAuditEvent summarizes a sync for the audit trail, listing every client
+// it touched
+func syncAuditEvent(mode string, result *SyncResult) AuditEvent {
+	event := AuditEvent{
+		Operation: auditOpSync,
+		Outcome:   auditOutcomeSuccess,
+		Detail: fmt.Sprintf("mode=%s status=%s created=%d updated=%d deleted=%d failed=%d",
+			mode, result.Status, result.CreatedCount, result.UpdatedCount, result.DeletedCount, result.FailedCount),
+	}
+	switch result.Status {
+	case syncStatusPartial:
+		event.Outcome = auditOutcomePartial
+	case syncStatusFailed, syncStatusRolledBack:
+		event.Outcome = auditOutcomeFailure
+	}
+	for _, r := range result.Results {
+		event.ClientIDs = append(event.ClientIDs, r.ClientID)
+	}
+	return event
+}
+
+// parseAuditTime parses an RFC 3339 timestamp or Unix seconds ("" = unbounded)
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp or Unix seconds", value)
+	}
+	return t.UTC(), nil
+}
+
+// swagger:route GET /admin/audit/export clients exportAuditEvents
+//
+// Export audit events.
+//
+// Streams audit events (oldest first) as newline-delimited JSON for SIEM ingestion.
+// since/until take RFC 3339 or Unix seconds. Requires AUDIT_LOG=true and an unscoped API key.
+//
+//	Produces:
+//	- application/x-ndjson
+//
+//	Responses:
+//	  200: auditEventsResponse
+//	  400: errorResponse
+//	  403: errorResponse
+//	  404: errorResponse
+//	  500: errorResponse
+func (s *Server) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.audit == nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "audit log is disabled (set AUDIT_LOG=true)")
+		return
+	}
+	// Events span every network, so network-scoped keys can't read them
+	if networkScope(r.Context()) != "" {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "audit export requires an unscoped API key")
+		return
+	}
+
+	query := r.URL.Query()
+	if format := query.Get("format"); format != "" && format != "jsonl" {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("unsupported format %q (only jsonl)", format))
+		return
+	}
+	since, err := parseAuditTime(query.Get("since"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("since: %v", err))
+		return
+	}
+	until, err := parseAuditTime(query.Get("until"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("until: %v", err))
+		return
+	}
+
+	s.streamAuditEvents(w, r.Context(), auditFilter{Since: since, Until: until, Limit: auditExportPageSize})
+}
+
+// streamAuditEvents writes every event matching filter as JSON lines, one
+// page at a time. Once streaming has started a store error can only end the
+// stream early, so it is logged.
+func (s *Server) streamAuditEvents(w http.ResponseWriter, ctx context.Context, filter auditFilter) {
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	started := false
+
+	for {
+		page, err := s.audit.ListAuditEvents(ctx, filter)
+		if err != nil {
+			log.Printf("Error exporting audit events: %v", err)
+			if !started {
+				writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+			}
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		for i := range page {
+			if err := enc.Encode(&page[i]); err != nil {
+				log.Printf("Error writing audit export: %v", err)
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		if len(page) < filter.Limit {
+			return
+		}
+		filter.AfterID = page[len(page)-1].ID
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// fakeAuditStore keeps events in memory and pages like Store.ListAuditEvents
+type fakeAuditStore struct {
+	mu     sync.Mutex
+	events []AuditEvent
+	pages  int
+}
+
+func (f *fakeAuditStore) RecordAuditEvent(_ context.Context, event *AuditEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	event.ID = int64(len(f.events) + 1)
+	f.events = append(f.events, *event)
+	return nil
+}
+
+func (f *fakeAuditStore) ListAuditEvents(_ context.Context, filter auditFilter) ([]AuditEvent, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pages++
+	var page []AuditEvent
+	for _, e := range f.events {
+		if e.ID <= filter.AfterID ||
+			(!filter.Since.IsZero() && e.Timestamp.Before(filter.Since)) ||
+			(!filter.Until.IsZero() && !e.Timestamp.Before(filter.Until)) {
+			continue
+		}
+		page = append(page, e)
+		if len(page) == filter.Limit {
+			break
+		}
+	}
+	return page, nil
+}
+
+func decodeJSONLines(t *testing.T, body string) []AuditEvent {
+	t.Helper()
+	var events []AuditEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var e AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestAuditExportStreamsEventsInRange(t *testing.T) {
+	store := &fakeAuditStore{}
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, op := range []string{auditOpCreate, auditOpRotate, auditOpDelete, auditOpSync} {
+		store.RecordAuditEvent(context.Background(), &AuditEvent{
+			Timestamp: base.Add(time.Duration(i) * time.Hour),
+			Operation: op,
+			ClientID:  "svc-a",
+		})
+	}
+	s := &Server{audit: store}
+
+	// since is inclusive, until exclusive: events at +1h and +2h
+	url := "/admin/audit/export?format=jsonl&since=" + base.Add(time.Hour).Format(time.RFC3339) +
+		"&until=" + base.Add(3*time.Hour).Format(time.RFC3339)
+	rec := httptest.NewRecorder()
+	s.handleAuditExport(rec, httptest.NewRequest(http.MethodGet, url, nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	got := decodeJSONLines(t, rec.Body.String())
+	want := store.events[1:3]
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %s", len(got), len(want), rec.Body.String())
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Operation != want[i].Operation || !got[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("event %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Stable field names
+	if !strings.Contains(rec.Body.String(), `"operation":"client.rotate"`) {
+		t.Errorf("body missing stable field names: %s", rec.Body.String())
+	}
+}
+
+func TestAuditExportPaginates(t *testing.T) {
+	store := &fakeAuditStore{}
+	for i := 0; i < 5; i++ {
+		store.RecordAuditEvent(context.Background(), &AuditEvent{Timestamp: time.Now(), Operation: auditOpCreate})
+	}
+	s := &Server{audit: store}
+
+	rec := httptest.NewRecorder()
+	s.streamAuditEvents(rec, context.Background(), auditFilter{Limit: 2})
+
+	if got := decodeJSONLines(t, rec.Body.String()); len(got) != 5 {
+		t.Errorf("got %d events, want 5", len(got))
+	}
+	if store.pages != 3 {
+		t.Errorf("store queried %d times, want 3 pages", store.pages)
+	}
+}
+
+func TestAuditExportRejections(t *testing.T) {
+	scoped := httptest.NewRequest(http.MethodGet, "/admin/audit/export", nil)
+	scoped = scoped.WithContext(context.WithValue(scoped.Context(), networkScopeKey{}, "tenant-a"))
+
+	tests := []struct {
+		name   string
+		server *Server
+		req    *http.Request
+		want   int
+	}{
+		{"disabled", &Server{}, httptest.NewRequest(http.MethodGet, "/admin/audit/export", nil), http.StatusNotFound},
+		{"bad format", &Server{audit: &fakeAuditStore{}}, httptest.NewRequest(http.MethodGet, "/admin/audit/export?format=csv", nil), http.StatusBadRequest},
+		{"bad since", &Server{audit: &fakeAuditStore{}}, httptest.NewRequest(http.MethodGet, "/admin/audit/export?since=yesterday", nil), http.StatusBadRequest},
+		{"scoped key", &Server{audit: &fakeAuditStore{}}, scoped, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.server.handleAuditExport(rec, tt.req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAuditTime(t *testing.T) {
+	unix, err := parseAuditTime("1767225600")
+	if err != nil || !unix.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unix seconds = %v, %v", unix, err)
+	}
+	rfc, err := parseAuditTime("2026-01-01T01:00:00+01:00")
+	if err != nil || !rfc.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339 = %v, %v", rfc, err)
+	}
+	if zero, err := parseAuditTime(""); err != nil || !zero.IsZero() {
+		t.Errorf("empty = %v, %v", zero, err)
+	}
+}
+
+func TestRecordAuditIncludesNetworkAndCaller(t *testing.T) {
+	store := &fakeAuditStore{}
+	nid := uuid.Must(uuid.NewV4())
+	event := AuditEvent{Operation: auditOpRotate, ClientID: "svc-a", Outcome: auditOutcomeSuccess}
+	ctx := context.WithValue(context.Background(), callerIdentityKey{}, "admin-key:0123456789abcdef")
+	(&Server{auditLogger: store}).recordAudit(ctx, nid, event)
+	(&Server{auditLogger: store}).recordAudit(context.Background(), uuid.Nil, event)
+	(&Server{}).recordAudit(ctx, nid, event) // disabled: no-op
+
+	if len(store.events) != 2 {
+		t.Fatalf("events = %+v", store.events)
+	}
+	got := store.events[0]
+	if got.NetworkID != nid.String() || got.ClientID != "svc-a" || got.Caller != "admin-key:0123456789abcdef" || got.Timestamp.IsZero() {
+		t.Errorf("event = %+v", got)
+	}
+	if store.events[1].Caller != auditAnonymousCaller || store.events[1].NetworkID != "" {
+		t.Errorf("unauthenticated event = %+v", store.events[1])
+	}
+}
+
+// cancelAwareAuditStore fails like a database call on a cancelled context
+type cancelAwareAuditStore struct {
+	fakeAuditStore
+}
+
+func (f *cancelAwareAuditStore) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.fakeAuditStore.RecordAuditEvent(ctx, event)
+}
+
+func TestRecordAuditOutlivesDisconnectedCaller(t *testing.T) {
+	store := &cancelAwareAuditStore{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), callerIdentityKey{}, "admin-key:0123456789abcdef"))
+	cancel()
+
+	(&Server{auditLogger: store}).recordAudit(ctx, uuid.Nil, AuditEvent{Operation: auditOpDelete, ClientID: "svc-a", Outcome: auditOutcomeFailure})
+	if len(store.events) != 1 || store.events[0].Caller != "admin-key:0123456789abcdef" {
+		t.Errorf("events = %+v, want the failure recorded with its caller", store.events)
+	}
+}
+
+func TestJSONLinesAuditLogger(t *testing.T) {
+	var buf strings.Builder
+	logger := newJSONLinesAuditLogger(&buf)
+	store := &fakeAuditStore{}
+	s := &Server{auditLogger: auditLoggers{logger, store}}
+
+	s.recordAudit(context.Background(), uuid.Nil, AuditEvent{Operation: auditOpDelete, ClientID: "svc-a", Outcome: auditOutcomeFailure, Detail: "hydra status 404"})
+	s.recordAudit(context.Background(), uuid.Nil, syncAuditEvent(syncModeFull, &SyncResult{
+		Status:  syncStatusPartial,
+		Results: []ClientResult{{ClientID: "svc-b"}, {ClientID: "svc-c"}},
+	}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || len(store.events) != 2 {
+		t.Fatalf("lines = %q, db events = %d", lines, len(store.events))
+	}
+	var del, sync AuditEvent
+	if err := json.Unmarshal([]byte(lines[0]), &del); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &sync); err != nil {
+		t.Fatal(err)
+	}
+	if del.Operation != auditOpDelete || del.ClientID != "svc-a" || del.Outcome != auditOutcomeFailure || del.Caller != auditAnonymousCaller {
+		t.Errorf("delete event = %+v", del)
+	}
+	if sync.Outcome != auditOutcomePartial || strings.Join(sync.ClientIDs, ",") != "svc-b,svc-c" {
+		t.Errorf("sync event = %+v", sync)
+	}
+	if strings.Contains(lines[0], `"id"`) {
+		t.Errorf("file events have no ID: %s", lines[0])
+	}
+}
+
+func TestSyncAuditEventOutcome(t *testing.T) {
+	for status, want := range map[string]string{
+		syncStatusSuccess:    auditOutcomeSuccess,
+		syncStatusPartial:    auditOutcomePartial,
+		syncStatusFailed:     auditOutcomeFailure,
+		syncStatusRolledBack: auditOutcomeFailure,
+	} {
+		if got := syncAuditEvent(syncModeUpsert, &SyncResult{Status: status}).Outcome; got != want {
+			t.Errorf("status %s: outcome = %q, want %q", status, got, want)
+		}
+	}
+}
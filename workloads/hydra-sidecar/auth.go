@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// adminPathPrefixes are the routes that require an API key. Probes, the
+// token hook (called by Hydra), /version, and /metrics stay open; the token
+// hook preview shows client metadata and /capabilities shows configuration,
+// so they don't.
+var adminPathPrefixes = []string{"/admin/", "/sync/", "/debug/", "/token-hook/preview/", "/capabilities/"}
+
+// requiresAdminAuth reports whether a request path is an admin or sync route
+func requiresAdminAuth(path string) bool {
+	for _, prefix := range adminPathPrefixes {
+		if strings.HasPrefix(path, prefix) || path == strings.TrimSuffix(prefix, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeys maps each accepted bearer token to the network it may target.
+// An empty network means unrestricted (ADMIN_API_KEY).
+type apiKeys map[string]string
+
+// newAPIKeys combines ADMIN_API_KEY with SCOPED_API_KEYS_JSON, a JSON object
+// of token -> network (UUID or name, matched exactly against X-Network-ID)
+func newAPIKeys(adminKey, scopedJSON string) (apiKeys, error) {
+	keys := apiKeys{}
+	if scopedJSON != "" {
+		var scoped map[string]string
+		if err := json.Unmarshal([]byte(scopedJSON), &scoped); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		for token, network := range scoped {
+			if token == "" || network == "" {
+				return nil, fmt.Errorf("tokens and networks must be non-empty")
+			}
+			keys[token] = network
+		}
+	}
+	if adminKey != "" {
+		if _, ok := keys[adminKey]; ok {
+			return nil, fmt.Errorf("ADMIN_API_KEY is also listed as a scoped key")
+		}
+		keys[adminKey] = ""
+	}
+	return keys, nil
+}
+
+// lookup returns the network scope of a token. Every key is compared in
+// constant time so timing doesn't reveal which keys exist.
+func (k apiKeys) lookup(token string) (network string, ok bool) {
+	for key, scope := range k {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) == 1 {
+			network, ok = scope, true
+		}
+	}
+	return network, ok
+}
+
+type networkScopeKey struct{}
+
+// networkScope returns the network the request's API key is restricted to ("" = any)
+func networkScope(ctx context.Context) string {
+	scope, _ := ctx.Value(networkScopeKey{}).(string)
+	return scope
+}
+
+type callerIdentityKey struct{}
+
+// callerIdentity returns who made an authenticated admin request ("" when
+// admin auth is disabled): "admin-key:" or "scoped-key:" plus the key's
+// fingerprint
+func callerIdentity(ctx context.Context) string {
+	caller, _ := ctx.Value(callerIdentityKey{}).(string)
+	return caller
+}
+
+// keyFingerprint identifies an API key in logs without revealing it: the
+// first 8 bytes of its SHA-256, hex encoded
+func keyFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// adminAuth wraps a handler so admin and sync routes require
+// "Authorization: Bearer <key>": 401 when missing, 403 when wrong. A scoped
+// key may only target its own network: a different X-Network-ID is rejected
+// with 403, and a missing one defaults to the key's network. No keys
+// disables the check.
+func adminAuth(keys apiKeys, next http.Handler) http.Handler {
+	if len(keys) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requiresAdminAuth(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hydra-sidecar"`)
+			writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "missing bearer token")
+			return
+		}
+		scope, ok := keys.lookup(token)
+		if !ok {
+			log.Printf("Rejected %s %s: invalid admin API key", r.Method, r.URL.Path)
+			writeJSONError(w, http.StatusForbidden, errCodeForbidden, "invalid API key")
+			return
+		}
+
+		caller := "admin-key:" + keyFingerprint(token)
+		if scope != "" {
+			caller = "scoped-key:" + keyFingerprint(token)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), callerIdentityKey{}, caller))
+
+		if scope != "" {
+			requested := r.Header.Get(networkIDHeader)
+			if requested != "" && requested != scope {
+				log.Printf("Rejected %s %s: API key scoped to network %q used for %q", r.Method, r.URL.Path, scope, requested)
+				writeJSONError(w, http.StatusForbidden, errCodeForbidden, "API key is not authorized for this network")
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), networkScopeKey{}, scope))
+			r.Header.Set(networkIDHeader, scope)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
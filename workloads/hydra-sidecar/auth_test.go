@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestAdminAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := adminAuth(apiKeys{"s3cret": ""}, ok)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{"admin without token", "/admin/clients", "", http.StatusUnauthorized},
+		{"sync without token", "/sync/clients", "", http.StatusUnauthorized},
+		{"admin with wrong token", "/admin/clients/svc-a", "Bearer nope", http.StatusForbidden},
+		{"admin with basic auth", "/admin/clients/svc-a", "Basic czNjcmV0", http.StatusUnauthorized},
+		{"admin with key", "/admin/clients/svc-a", "Bearer s3cret", http.StatusOK},
+		{"sync with key", "/sync/clients", "bearer s3cret", http.StatusOK},
+		{"debug without token", "/debug/config", "", http.StatusUnauthorized},
+		{"capabilities without token", "/capabilities", "", http.StatusUnauthorized},
+		{"capabilities with key", "/capabilities", "Bearer s3cret", http.StatusOK},
+		{"health is open", "/health", "", http.StatusOK},
+		{"ready is open", "/ready", "", http.StatusOK},
+		{"token hook is open", "/token-hook", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", r.Method, tt.path, rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminAuthDisabledWithoutKey(t *testing.T) {
+	h := adminAuth(nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/svc-a", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status without ADMIN_API_KEY = %d, want 200", rec.Code)
+	}
+}
+
+func TestScopedKeyNetworkMismatch(t *testing.T) {
+	keys, err := newAPIKeys("admin-key", `{"tenant-b-key":"tenant-b"}`)
+	if err != nil {
+		t.Fatalf("newAPIKeys() error = %v", err)
+	}
+
+	var gotScope, gotHeader string
+	h := adminAuth(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotScope = networkScope(r.Context())
+		gotHeader = r.Header.Get(networkIDHeader)
+	}))
+
+	call := func(key, network string) int {
+		r := httptest.NewRequest(http.MethodPost, "/sync/clients", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		if network != "" {
+			r.Header.Set(networkIDHeader, network)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if got := call("tenant-b-key", "tenant-a"); got != http.StatusForbidden {
+		t.Errorf("scoped key targeting another network = %d, want 403", got)
+	}
+	if got := call("tenant-b-key", "tenant-b"); got != http.StatusOK || gotScope != "tenant-b" {
+		t.Errorf("scoped key on its own network = %d (scope %q), want 200 scoped to tenant-b", got, gotScope)
+	}
+	if got := call("tenant-b-key", ""); got != http.StatusOK || gotHeader != "tenant-b" {
+		t.Errorf("scoped key without header = %d (header %q), want 200 defaulted to tenant-b", got, gotHeader)
+	}
+	if got := call("admin-key", "tenant-a"); got != http.StatusOK || gotScope != "" {
+		t.Errorf("admin key = %d (scope %q), want 200 unrestricted", got, gotScope)
+	}
+}
+
+func TestScopedKeyBodyNetworkMismatch(t *testing.T) {
+	keys, _ := newAPIKeys("", `{"tenant-b-key":"tenant-b"}`)
+	s := &Server{}
+
+	var err error
+	h := adminAuth(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The sync body asks for a different network than the key allows
+		_, err = s.networkFor(r, "tenant-a")
+	}))
+	r := httptest.NewRequest(http.MethodPost, "/sync/clients", nil)
+	r.Header.Set("Authorization", "Bearer tenant-b-key")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !errors.Is(err, errNetworkNotAllowed) {
+		t.Errorf("networkFor() error = %v, want errNetworkNotAllowed", err)
+	}
+	rec := httptest.NewRecorder()
+	writeNetworkError(rec, err)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("writeNetworkError() status = %d, want 403", rec.Code)
+	}
+}
+
+func TestScopedKeyCannotReachHydraClients(t *testing.T) {
+	hydraNID := uuid.Must(uuid.NewV4())
+	tenantNID := uuid.Must(uuid.NewV4())
+	var hydraCalls atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hydraCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"client_id":"svc-a"}`))
+	}))
+	t.Cleanup(hydra.Close)
+
+	keys, err := newAPIKeys("admin-key", fmt.Sprintf(`{"tenant-key":%q,"hydra-key":%q}`, tenantNID, hydraNID))
+	if err != nil {
+		t.Fatalf("newAPIKeys() error = %v", err)
+	}
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: hydraNID}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/clients/restore/", s.handleRestoreClient)
+	mux.HandleFunc("/admin/clients/", s.handleClientByID)
+	h := adminAuth(keys, mux)
+
+	call := func(key, method, path string) int {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/admin/clients/svc-a"},
+		{http.MethodDelete, "/admin/clients/svc-a"},
+		{http.MethodPost, "/admin/clients/restore/svc-a"},
+	} {
+		if got := call("tenant-key", tc.method, tc.path); got != http.StatusForbidden {
+			t.Errorf("scoped key %s %s = %d, want 403", tc.method, tc.path, got)
+		}
+	}
+	if n := hydraCalls.Load(); n != 0 {
+		t.Errorf("scoped key requests made %d Hydra calls, want 0", n)
+	}
+
+	// Keys for Hydra's own network still reach it
+	if got := call("hydra-key", http.MethodGet, "/admin/clients/svc-a"); got != http.StatusOK {
+		t.Errorf("key scoped to Hydra's network GET = %d, want 200", got)
+	}
+	if got := call("admin-key", http.MethodGet, "/admin/clients/svc-a"); got != http.StatusOK {
+		t.Errorf("admin key GET = %d, want 200", got)
+	}
+}
+
+func TestNewAPIKeysInvalid(t *testing.T) {
+	for _, raw := range []string{`not json`, `{"key":""}`, `{"admin":"tenant-b"}`} {
+		if _, err := newAPIKeys("admin", raw); err == nil {
+			t.Errorf("newAPIKeys(%q) error = nil, want error", raw)
+		}
+	}
+}
+
+func TestAdminAuthSetsCallerIdentity(t *testing.T) {
+	keys, err := newAPIKeys("s3cret", `{"tenant-b-key":"tenant-b"}`)
+	if err != nil {
+		t.Fatalf("newAPIKeys() error = %v", err)
+	}
+	var caller string
+	h := adminAuth(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller = callerIdentity(r.Context())
+	}))
+
+	for key, want := range map[string]string{
+		"s3cret":       "admin-key:" + keyFingerprint("s3cret"),
+		"tenant-b-key": "scoped-key:" + keyFingerprint("tenant-b-key"),
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/admin/clients", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if caller != want {
+			t.Errorf("caller for %s = %q, want %q", key, caller, want)
+		}
+		if strings.Contains(caller, key) {
+			t.Errorf("caller %q leaks the key", caller)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// defaultAuthMethod is used when no per-grant-type default matches (Hydra's own default)
+const defaultAuthMethod = "client_secret_basic"
+
+// validAuthMethods are the token_endpoint_auth_method values Hydra accepts
+var validAuthMethods = map[string]bool{
+	"client_secret_basic": true,
+	"client_secret_post":  true,
+	"private_key_jwt":     true,
+	"none":                true,
+}
+
+// authMethodDefaults maps grant types to the token_endpoint_auth_method given
+// to clients that don't set one (AUTH_METHOD_DEFAULTS_JSON)
+type authMethodDefaults map[string]string
+
+// parseAuthMethodDefaults parses a JSON object of grant type -> auth method
+func parseAuthMethodDefaults(raw string) (authMethodDefaults, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var defaults authMethodDefaults
+	if err := json.Unmarshal([]byte(raw), &defaults); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	for grantType, method := range defaults {
+		if !validAuthMethods[method] {
+			return nil, fmt.Errorf("grant type %q: unsupported token_endpoint_auth_method %q", grantType, method)
+		}
+	}
+	return defaults, nil
+}
+
+// forGrantTypes returns the default auth method for a client with the given
+// grant types: the default of the first grant type that has one, else
+// client_secret_basic
+func (d authMethodDefaults) forGrantTypes(grantTypes []string) string {
+	for _, grantType := range grantTypes {
+		if method, ok := d[grantType]; ok {
+			return method
+		}
+	}
+	return defaultAuthMethod
+}
+
+// applyAuthMethodDefault sets token_endpoint_auth_method on a create request
+// body that doesn't specify one. Without grant_types the client gets Hydra's
+// default of authorization_code, so that grant type's default applies. Other
+// fields pass through untouched. Returns the (possibly rewritten) body.
+func applyAuthMethodDefault(body []byte, d authMethodDefaults) ([]byte, error) {
+	if len(d) == 0 {
+		return body, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+
+	if raw, ok := fields["token_endpoint_auth_method"]; ok {
+		var method string
+		if err := json.Unmarshal(raw, &method); err == nil && method != "" {
+			return body, nil
+		}
+	}
+
+	grantTypes := []string{"authorization_code"}
+	if raw, ok := fields["grant_types"]; ok {
+		var requested []string
+		if err := json.Unmarshal(raw, &requested); err != nil {
+			return nil, fmt.Errorf("grant_types must be an array of strings")
+		}
+		if len(requested) > 0 {
+			grantTypes = requested
+		}
+	}
+
+	method, err := json.Marshal(d.forGrantTypes(grantTypes))
+	if err != nil {
+		return nil, err
+	}
+	fields["token_endpoint_auth_method"] = method
+	return json.Marshal(fields)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAuthMethodDefaultsForGrantTypes(t *testing.T) {
+	d, err := parseAuthMethodDefaults(`{"authorization_code":"none","client_credentials":"client_secret_post"}`)
+	if err != nil {
+		t.Fatalf("parseAuthMethodDefaults() error = %v", err)
+	}
+
+	tests := []struct {
+		grantTypes []string
+		want       string
+	}{
+		{[]string{"authorization_code"}, "none"},
+		{[]string{"authorization_code", "refresh_token"}, "none"},
+		{[]string{"refresh_token", "client_credentials"}, "client_secret_post"},
+		{[]string{"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "client_secret_basic"},
+		{nil, "client_secret_basic"},
+	}
+	for _, tt := range tests {
+		if got := d.forGrantTypes(tt.grantTypes); got != tt.want {
+			t.Errorf("forGrantTypes(%v) = %q, want %q", tt.grantTypes, got, tt.want)
+		}
+	}
+
+	var none authMethodDefaults
+	if got := none.forGrantTypes([]string{"authorization_code"}); got != "client_secret_basic" {
+		t.Errorf("unconfigured default = %q, want client_secret_basic", got)
+	}
+}
+
+func TestParseAuthMethodDefaultsRejectsUnknownMethod(t *testing.T) {
+	if _, err := parseAuthMethodDefaults(`{"authorization_code":"basic"}`); err == nil {
+		t.Error("expected error for unsupported auth method")
+	}
+	if _, err := parseAuthMethodDefaults(`[]`); err == nil {
+		t.Error("expected error for non-object JSON")
+	}
+}
+
+func TestApplyAuthMethodDefault(t *testing.T) {
+	d := authMethodDefaults{"authorization_code": "none", "client_credentials": "client_secret_post"}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"no grant types uses authorization_code", `{"client_name":"spa"}`, "none"},
+		{"by grant type", `{"grant_types":["client_credentials"]}`, "client_secret_post"},
+		{"explicit method kept", `{"grant_types":["authorization_code"],"token_endpoint_auth_method":"client_secret_basic"}`, "client_secret_basic"},
+		{"unmatched grant type", `{"grant_types":["refresh_token"]}`, "client_secret_basic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := applyAuthMethodDefault([]byte(tt.body), d)
+			if err != nil {
+				t.Fatalf("applyAuthMethodDefault() error = %v", err)
+			}
+			var fields struct {
+				Method string `json:"token_endpoint_auth_method"`
+			}
+			if err := json.Unmarshal(out, &fields); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if fields.Method != tt.want {
+				t.Errorf("token_endpoint_auth_method = %q, want %q", fields.Method, tt.want)
+			}
+		})
+	}
+
+	// Without configured defaults the body is left for Hydra to default
+	body := []byte(`{"client_name":"svc"}`)
+	if out, _ := applyAuthMethodDefault(body, nil); string(out) != string(body) {
+		t.Errorf("body rewritten without defaults: %s", out)
+	}
+}
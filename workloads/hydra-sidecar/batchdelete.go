@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+)
+
+// maxBatchDeleteSize caps the client IDs one delete-batch request may name
+const maxBatchDeleteSize = 1000
+
+// errNotInNetwork marks a batch delete target missing from the selected network
+var errNotInNetwork = errors.New("client not found in network")
+
+// clientLookup reports which of a set of clients exist in a network
+type clientLookup interface {
+	GetHashedSecrets(ctx context.Context, clientIDs []string, nid uuid.UUID) (map[string]string, error)
+}
+
+// swagger:route POST /admin/clients/delete-batch clients batchDeleteClients
+//
+// Delete a batch of clients.
+//
+// Deletes each listed client from Hydra, in parallel up to SYNC_CONCURRENCY. Only clients
+// in the selected network (network_id, else X-Network-ID, else the default network) are
+// deleted; others are reported as failed. One failure, including a 404, doesn't stop the
+// rest. Deletes that succeeded are not rolled back.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: batchDeleteResultResponse
+//	  400: errorResponse
+//	  403: errorResponse
+//	  500: errorResponse
+func (s *Server) handleBatchDeleteClients(w http.ResponseWriter, r *http.Request) {
+	s.serveBatchDeleteClients(w, r, s.store)
+}
+
+// serveBatchDeleteClients implements handleBatchDeleteClients against the given store
+func (s *Server) serveBatchDeleteClients(w http.ResponseWriter, r *http.Request, db clientLookup) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req BatchDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBodyError(w, err, "invalid JSON")
+		return
+	}
+	ids := dedupeClientIDs(req.ClientIDs)
+	if len(ids) == 0 {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "client_ids is empty")
+		return
+	}
+	if len(ids) > maxBatchDeleteSize {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest,
+			fmt.Sprintf("client_ids has %d entries, at most %d allowed", len(ids), maxBatchDeleteSize))
+		return
+	}
+
+	nid, err := s.networkFor(r, req.NetworkID)
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+	present, err := db.GetHashedSecrets(r.Context(), ids, nid)
+	if err != nil {
+		log.Printf("Error looking up clients for batch delete: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	errs := make([]error, len(ids))
+	forEachBounded(len(ids), s.syncConcurrency, func(i int) {
+		if _, ok := present[ids[i]]; !ok {
+			errs[i] = errNotInNetwork
+			return
+		}
+		errs[i] = s.deleteHydraClient(r.Context(), ids[i])
+	})
+
+	result := &BatchDeleteResult{Results: make([]ClientResult, 0, len(ids))}
+	for i, id := range ids {
+		if err := errs[i]; err != nil {
+			errStr := err.Error()
+			result.Results = append(result.Results, ClientResult{ClientID: id, Operation: syncOpDelete, Status: "failed", Error: &errStr})
+			result.FailedCount++
+			s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: id, Outcome: auditOutcomeFailure, Detail: errStr})
+			continue
+		}
+		result.Results = append(result.Results, ClientResult{ClientID: id, Operation: syncOpDelete, Status: "deleted"})
+		result.DeletedCount++
+		s.clientCache.Invalidate(id)
+		s.forgetSnapshot(r.Context(), id)
+		s.metrics.ClientOperation(clientOpDeleted)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: id, Outcome: auditOutcomeSuccess})
+	}
+	switch {
+	case result.FailedCount == 0:
+		result.Status = syncStatusSuccess
+	case result.DeletedCount == 0:
+		result.Status = syncStatusFailed
+	default:
+		result.Status = syncStatusPartial
+	}
+
+	log.Printf("Batch delete completed (%s): deleted=%d, failed=%d", result.Status, result.DeletedCount, result.FailedCount)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding batch delete result: %v", err)
+	}
+}
+
+// deleteHydraClient deletes one client through the Hydra Admin API
+// (retried on transient failures, see doHydra). A 404 wraps errClientNotFound.
+func (s *Server) deleteHydraClient(ctx context.Context, clientID string) error {
+	if s.config.SoftDeleteEnabled {
+		return s.softDeleteHydraClient(ctx, clientID)
+	}
+	req, err := s.newHydraRequest(ctx, http.MethodDelete, "/admin/clients/"+clientID, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.doHydra(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return errClientNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("Hydra returned %d: %s", resp.StatusCode, body)
+}
+
+// dedupeClientIDs drops empty and repeated IDs, keeping first-seen order
+func dedupeClientIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
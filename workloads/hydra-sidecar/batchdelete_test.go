@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+// fakeClientLookup reports the clients stored per network
+type fakeClientLookup map[uuid.UUID][]string
+
+func (f fakeClientLookup) GetHashedSecrets(_ context.Context, ids []string, nid uuid.UUID) (map[string]string, error) {
+	found := make(map[string]string)
+	for _, stored := range f[nid] {
+		for _, id := range ids {
+			if id == stored {
+				found[id] = "hash"
+			}
+		}
+	}
+	return found, nil
+}
+
+// newFakeHydraDelete answers DELETE /admin/clients/{id} with 204, or 404 for
+// IDs in missing, and records the IDs it was asked to delete
+func newFakeHydraDelete(t *testing.T, missing ...string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var deleted []string
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/admin/clients/")
+		mu.Lock()
+		deleted = append(deleted, id)
+		mu.Unlock()
+		for _, m := range missing {
+			if id == m {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(hydra.Close)
+	return hydra, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), deleted...)
+	}
+}
+
+func batchDelete(t *testing.T, s *Server, db clientLookup, body string) BatchDeleteResult {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.serveBatchDeleteClients(rec, httptest.NewRequest(http.MethodPost, "/admin/clients/delete-batch", strings.NewReader(body)), db)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var result BatchDeleteResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return result
+}
+
+func TestBatchDeleteContinuesPastNotFound(t *testing.T) {
+	nid := uuid.Must(uuid.NewV4())
+	hydra, _ := newFakeHydraDelete(t, "gone")
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: nid, syncConcurrency: 2}
+	db := fakeClientLookup{nid: {"a", "gone", "b"}}
+
+	result := batchDelete(t, s, db, `{"client_ids":["a","gone","b","a"]}`)
+	if result.Status != syncStatusPartial || result.DeletedCount != 2 || result.FailedCount != 1 {
+		t.Fatalf("result = %+v, want partial with 2 deleted and 1 failed", result)
+	}
+	want := []string{"deleted", "failed", "deleted"}
+	if len(result.Results) != len(want) {
+		t.Fatalf("results = %+v, want one per unique ID", result.Results)
+	}
+	for i, r := range result.Results {
+		if r.Status != want[i] || r.Operation != syncOpDelete {
+			t.Errorf("results[%d] = %+v, want %s delete", i, r, want[i])
+		}
+	}
+	if e := result.Results[1].Error; e == nil || !strings.Contains(*e, "not found") {
+		t.Errorf("gone error = %v, want not found", e)
+	}
+}
+
+func TestBatchDeleteSkipsClientsOutsideNetwork(t *testing.T) {
+	netA := uuid.Must(uuid.NewV4())
+	netB := uuid.Must(uuid.NewV4())
+	hydra, deleted := newFakeHydraDelete(t)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: netA}
+	db := fakeClientLookup{netA: {"mine"}, netB: {"theirs"}}
+
+	result := batchDelete(t, s, db, `{"client_ids":["mine","theirs"]}`)
+	if result.Status != syncStatusPartial || result.DeletedCount != 1 || result.FailedCount != 1 {
+		t.Fatalf("result = %+v, want partial with 1 deleted and 1 failed", result)
+	}
+	if got := deleted(); len(got) != 1 || got[0] != "mine" {
+		t.Errorf("Hydra deletes = %v, want only mine", got)
+	}
+
+	result = batchDelete(t, s, db, `{"client_ids":["theirs"],"network_id":"`+netB.String()+`"}`)
+	if result.Status != syncStatusSuccess || result.DeletedCount != 1 {
+		t.Errorf("result = %+v, want theirs deleted in network_id", result)
+	}
+}
+
+func TestBatchDeleteRejectsBadRequests(t *testing.T) {
+	s := &Server{networkID: uuid.Must(uuid.NewV4())}
+	ids := make([]string, maxBatchDeleteSize+1)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("client-%d", i)
+	}
+	tooMany, _ := json.Marshal(BatchDeleteRequest{ClientIDs: ids})
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"get", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"invalid JSON", http.MethodPost, "{", http.StatusBadRequest},
+		{"empty", http.MethodPost, `{"client_ids":[""]}`, http.StatusBadRequest},
+		{"too many", http.MethodPost, string(tooMany), http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.serveBatchDeleteClients(rec, httptest.NewRequest(tc.method, "/admin/clients/delete-batch", strings.NewReader(tc.body)), fakeClientLookup{})
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+		})
+	}
+}
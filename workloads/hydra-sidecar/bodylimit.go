@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// bodyLimitFor picks a route's request body limit: MAX_SYNC_REQUEST_BODY_BYTES
+// for /sync/ routes, whose batches are legitimately large, and
+// MAX_REQUEST_BODY_BYTES for everything else
+func bodyLimitFor(cfg Config, route string) int64 {
+	if strings.HasPrefix(route, "/sync/") {
+		return cfg.MaxSyncRequestBodyBytes
+	}
+	return cfg.MaxRequestBodyBytes
+}
+
+// limitBody caps the bytes next can read from the request body (0 = no limit).
+// Reads past the limit fail with *http.MaxBytesError; see writeBodyError.
+func limitBody(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	if limit <= 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next(w, r)
+	}
+}
+
+// writeBodyError answers a request body that couldn't be read or decoded:
+// 413 when it ran past the route's limit, else 400 with message
+func writeBodyError(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge,
+			fmt.Sprintf("request body exceeds the %d-byte limit", tooLarge.Limit))
+		return
+	}
+	writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, message)
+}
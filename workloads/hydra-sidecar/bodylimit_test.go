@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// paddedJSON returns a JSON object of about size bytes
+func paddedJSON(size int) string {
+	return `{"clients":[],"pad":"` + strings.Repeat("x", size) + `"}`
+}
+
+func TestRequestBodyLimits(t *testing.T) {
+	cfg := Config{HealthPath: "/health", ReadyPath: "/ready", MaxRequestBodyBytes: 64, MaxSyncRequestBodyBytes: 1024}
+	mux := newMux(cfg, &Server{})
+
+	for _, tc := range []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"sync within its limit", "/sync/clients", paddedJSON(200), http.StatusBadRequest},
+		{"sync over its limit", "/sync/clients", paddedJSON(2000), http.StatusRequestEntityTooLarge},
+		{"admin over the general limit", "/admin/clients/rotate/svc-a", paddedJSON(200), http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if tc.wantStatus != http.StatusRequestEntityTooLarge {
+				return
+			}
+			var apiErr APIError
+			if err := json.NewDecoder(rec.Body).Decode(&apiErr); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if apiErr.Error != errCodePayloadTooLarge || !strings.Contains(apiErr.ErrorDescription, "-byte limit") {
+				t.Errorf("body = %+v, want payload_too_large naming the limit", apiErr)
+			}
+		})
+	}
+}
+
+func TestLimitBodyZeroIsUnlimited(t *testing.T) {
+	called := false
+	h := limitBody(0, func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		called = true
+	})
+	h(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/sync/clients", strings.NewReader(paddedJSON(1<<20))))
+	if !called {
+		t.Error("handler not called")
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errHydraCircuitOpen is returned, without calling Hydra, while the circuit
+// breaker is open
+var errHydraCircuitOpen = errors.New("Hydra Admin API circuit breaker is open")
+
+// Circuit breaker states, as reported by /ready?verbose=true
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half_open"
+)
+
+// circuitBreaker stops calls to Hydra Admin after a run of consecutive
+// failures (connection errors, timeouts, and 5xx), so a degraded Hydra isn't
+// buried under waiting and retrying requests. After the cooldown a single
+// probe call is let through: success closes the circuit, failure reopens it.
+// It is shared by every handler through the Hydra HTTP client's transport.
+// Methods are safe to call on a nil *circuitBreaker (disabled).
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	// A half-open probe is in flight
+	probing bool
+	now     func() time.Time
+}
+
+// newCircuitBreaker opens after threshold consecutive failures and probes
+// again after cooldown. A threshold of 0 disables the breaker (nil).
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: circuitClosed, now: time.Now}
+}
+
+// allow reports whether a call may go to Hydra, moving an open circuit whose
+// cooldown has passed to half-open and admitting that call as the probe
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return errHydraCircuitOpen
+		}
+		log.Printf("Hydra circuit breaker half-open, probing Hydra Admin")
+		b.state = circuitHalfOpen
+		b.probing = true
+	case circuitHalfOpen:
+		if b.probing {
+			return errHydraCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record notes the outcome of an allowed call
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitHalfOpen:
+		b.probing = false
+		if failed {
+			log.Printf("Hydra circuit breaker probe failed, open for another %s", b.cooldown)
+			b.state, b.openedAt = circuitOpen, b.now()
+			return
+		}
+		log.Printf("Hydra circuit breaker closed, Hydra Admin is answering again")
+		b.state, b.failures = circuitClosed, 0
+	case circuitClosed:
+		if !failed {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.threshold {
+			log.Printf("Hydra circuit breaker open after %d consecutive failures, failing fast for %s", b.failures, b.cooldown)
+			b.state, b.openedAt = circuitOpen, b.now()
+		}
+	}
+	// Calls admitted before the circuit opened don't change an open circuit
+}
+
+// release ends an allowed call without a verdict on Hydra (the caller gave
+// up), freeing the probe slot if it was the half-open probe
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == circuitHalfOpen {
+		b.probing = false
+	}
+}
+
+// State returns "closed", "open", or "half_open" ("closed" when disabled).
+// An open circuit whose cooldown has passed still reads "open" until the next
+// call probes Hydra.
+func (b *circuitBreaker) State() string {
+	if b == nil {
+		return circuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Transport wraps next so every Hydra Admin API call passes the breaker
+func (b *circuitBreaker) Transport(next http.RoundTripper) http.RoundTripper {
+	if b == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &breakerTransport{breaker: b, next: next}
+}
+
+// breakerTransport is the http.RoundTripper returned by Transport
+type breakerTransport struct {
+	breaker *circuitBreaker
+	next    http.RoundTripper
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.allow(); err != nil {
+		return nil, err
+	}
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil && errors.Is(req.Context().Err(), context.Canceled):
+		// Cancelled by the caller, which says nothing about Hydra; deadlines
+		// (including the client timeout) still count as failures
+		t.breaker.release()
+	case err != nil || resp.StatusCode >= 500:
+		t.breaker.record(true)
+	default:
+		t.breaker.record(false)
+	}
+	return resp, err
+}
+
+// circuitStateValue maps a state to the hydra_sidecar_hydra_circuit_state gauge
+func circuitStateValue(state string) float64 {
+	switch state {
+	case circuitHalfOpen:
+		return 1
+	case circuitOpen:
+		return 2
+	}
+	return 0
+}
+
+// writeHydraCallError answers an admin request whose Hydra call failed: 503
+// right away while the circuit breaker is open, else 502 with message
+func writeHydraCallError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, errHydraCircuitOpen) {
+		writeJSONError(w, http.StatusServiceUnavailable, errCodeUnavailable,
+			"Hydra Admin API is unavailable (circuit breaker open); retry later")
+		return
+	}
+	writeJSONError(w, http.StatusBadGateway, errCodeUpstream, message)
+}
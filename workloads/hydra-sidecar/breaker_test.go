@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	b := newCircuitBreaker(2, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	// A success resets the run of failures
+	b.record(true)
+	b.record(false)
+	b.record(true)
+	if b.State() != circuitClosed {
+		t.Fatalf("state = %s after non-consecutive failures, want closed", b.State())
+	}
+	b.record(true)
+	if b.State() != circuitOpen || !errors.Is(b.allow(), errHydraCircuitOpen) {
+		t.Fatalf("state = %s after 2 consecutive failures, want open and refusing calls", b.State())
+	}
+
+	// After the cooldown one probe goes through; a failed probe reopens
+	now = now.Add(10 * time.Second)
+	if err := b.allow(); err != nil || b.State() != circuitHalfOpen {
+		t.Fatalf("after cooldown: allow = %v, state = %s, want the probe admitted", err, b.State())
+	}
+	if err := b.allow(); !errors.Is(err, errHydraCircuitOpen) {
+		t.Errorf("second call while probing = %v, want refused", err)
+	}
+	b.record(true)
+	if b.State() != circuitOpen || !errors.Is(b.allow(), errHydraCircuitOpen) {
+		t.Fatalf("state = %s after a failed probe, want open again", b.State())
+	}
+
+	// A probe without a verdict frees the slot; a successful one closes
+	now = now.Add(10 * time.Second)
+	b.allow()
+	b.release()
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after released probe = %v, want the next probe admitted", err)
+	}
+	b.record(false)
+	if b.State() != circuitClosed || b.allow() != nil {
+		t.Errorf("state = %s after a successful probe, want closed", b.State())
+	}
+
+	var disabled *circuitBreaker
+	if newCircuitBreaker(0, time.Second) != nil || disabled.allow() != nil || disabled.State() != circuitClosed {
+		t.Error("a zero threshold should disable the breaker")
+	}
+}
+
+func TestCircuitBreakerTransport(t *testing.T) {
+	var hits atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer hydra.Close()
+
+	b := newCircuitBreaker(3, time.Hour)
+	httpClient := hydra.Client()
+	httpClient.Transport = b.Transport(httpClient.Transport)
+	call := func(ctx context.Context) error {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, hydra.URL, nil)
+		resp, err := httpClient.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// 4xx means Hydra is answering, so it doesn't count
+	status.Store(http.StatusNotFound)
+	for range 5 {
+		call(context.Background())
+	}
+	if b.State() != circuitClosed {
+		t.Fatalf("state = %s after 404s, want closed", b.State())
+	}
+
+	// A cancelled caller says nothing about Hydra either
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 5 {
+		call(ctx)
+	}
+	if b.State() != circuitClosed {
+		t.Fatalf("state = %s after cancelled calls, want closed", b.State())
+	}
+
+	status.Store(http.StatusInternalServerError)
+	before := hits.Load()
+	for range 5 {
+		call(context.Background())
+	}
+	if got := hits.Load() - before; got != 3 {
+		t.Errorf("Hydra saw %d of 5 calls, want 3 before the circuit opened", got)
+	}
+	if err := call(context.Background()); !errors.Is(err, errHydraCircuitOpen) {
+		t.Errorf("call while open = %v, want errHydraCircuitOpen", err)
+	}
+}
+
+// openBreakerServer returns a server whose circuit breaker is already open,
+// in front of a Hydra that counts the calls reaching it
+func openBreakerServer(t *testing.T) (*Server, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"client_id":"svc-a","metadata":{"org_id":"acme"}}`))
+	}))
+	t.Cleanup(hydra.Close)
+
+	b := newCircuitBreaker(1, time.Hour)
+	b.record(true)
+	httpClient := hydra.Client()
+	httpClient.Transport = b.Transport(httpClient.Transport)
+	return &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    httpClient,
+		hydraBreaker:  b,
+		hydraRetry:    retryPolicy{attempts: 3, baseDelay: time.Second},
+		retryBudget:   newRetryBudget(10, 0),
+		networkID:     uuid.Must(uuid.NewV4()),
+	}, &hits
+}
+
+func TestOpenCircuitFailsFast(t *testing.T) {
+	s, hits := openBreakerServer(t)
+
+	// Admin calls answer 503
+	rec := httptest.NewRecorder()
+	s.getClient(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/svc-a", nil), "svc-a")
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "circuit breaker open") {
+		t.Errorf("get: status = %d, body = %s, want 503", rec.Code, rec.Body.String())
+	}
+
+	// Retried calls give up at once instead of backing off
+	start := time.Now()
+	req, _ := s.newHydraRequest(context.Background(), http.MethodGet, "/admin/clients", nil)
+	if _, err := s.doHydra(req); !errors.Is(err, errHydraCircuitOpen) {
+		t.Errorf("doHydra = %v, want errHydraCircuitOpen", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("doHydra took %s, want an immediate answer", elapsed)
+	}
+	if s.retryBudget.Remaining() != 10 {
+		t.Errorf("retry budget = %d, want untouched", s.retryBudget.Remaining())
+	}
+
+	// The token hook falls back to no metadata claims
+	claims := tokenHookClaims(t, s, "svc-a")
+	if _, ok := claims["org_id"]; ok {
+		t.Errorf("claims = %v, want no metadata while the circuit is open", claims)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("Hydra saw %d calls while the circuit was open, want 0", hits.Load())
+	}
+
+	// Unless TOKEN_HOOK_FAIL_CLOSED refuses the token instead
+	s.failClosed = true
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("fail-closed token hook status = %d, want 503", rec.Code)
+	}
+}
+
+func TestCircuitStateInReadinessAndMetrics(t *testing.T) {
+	s, _ := openBreakerServer(t)
+	s.metrics = NewMetrics(nil, s.hydraBreaker)
+
+	rec := httptest.NewRecorder()
+	s.serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready?verbose=true", nil), fakePinger{})
+	var diag ReadinessDiagnostics
+	if err := json.NewDecoder(rec.Body).Decode(&diag); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !diag.HydraCircuit.Enabled || diag.HydraCircuit.State != circuitOpen {
+		t.Errorf("hydra_circuit = %+v, want enabled and open", diag.HydraCircuit)
+	}
+
+	rec = httptest.NewRecorder()
+	s.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "hydra_sidecar_hydra_circuit_state 2") {
+		t.Errorf("metrics missing open circuit gauge:\n%s", rec.Body.String())
+	}
+}
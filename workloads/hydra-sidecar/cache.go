@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// clientInfoCache is a TTL cache of Hydra client info for the token hook,
+// safe for concurrent use. A nil cache is disabled: lookups always miss.
+type clientInfoCache struct {
+	mu  sync.RWMutex
+	ttl time.Duration
+	// staleMaxAge is how long past its TTL an entry is kept for GetStale
+	staleMaxAge time.Duration
+	entries     map[string]clientInfoEntry
+	lastSweep   time.Time
+	now         func() time.Time
+}
+
+type clientInfoEntry struct {
+	info      *ClientInfo
+	expiresAt time.Time
+}
+
+// newClientInfoCache creates a cache; a TTL of 0 or less disables caching.
+// Expired entries are kept staleMaxAge past their TTL for GetStale.
+func newClientInfoCache(ttl, staleMaxAge time.Duration) *clientInfoCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &clientInfoCache{
+		ttl:         ttl,
+		staleMaxAge: staleMaxAge,
+		entries:     make(map[string]clientInfoEntry),
+		lastSweep:   time.Now(),
+		now:         time.Now,
+	}
+}
+
+// Get returns cached client info if present and not expired.
+// The returned value is shared and must not be modified.
+func (c *clientInfoCache) Get(clientID string) (*ClientInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[clientID]
+	if !ok || !c.now().Before(e.expiresAt) {
+		return nil, false
+	}
+	return e.info, true
+}
+
+// GetStale returns cached client info up to staleMaxAge past its TTL, for
+// serving when a fresh fetch fails. Sweeps keep entries that long, so they
+// outlast a Hydra outage however many other clients are fetched meanwhile.
+func (c *clientInfoCache) GetStale(clientID string) (*ClientInfo, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	e, ok := c.entries[clientID]
+	if !ok || !c.now().Before(e.expiresAt.Add(c.staleMaxAge)) {
+		return nil, false
+	}
+	return e.info, true
+}
+
+// Put caches client info for the TTL. Entries more than staleMaxAge past
+// their TTL are swept at most once per TTL so clients that stop requesting
+// tokens don't accumulate.
+func (c *clientInfoCache) Put(clientID string, info *ClientInfo) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Sub(c.lastSweep) >= c.ttl {
+		for id, e := range c.entries {
+			if !now.Before(e.expiresAt.Add(c.staleMaxAge)) {
+				delete(c.entries, id)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[clientID] = clientInfoEntry{info: info, expiresAt: now.Add(c.ttl)}
+}
+
+// Invalidate drops a client's cached info after it changes
+func (c *clientInfoCache) Invalidate(clientID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, clientID)
+}
+
+// Clear drops all cached info (e.g. after a bulk sync)
+func (c *clientInfoCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]clientInfoEntry)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestClientInfoCacheExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newClientInfoCache(30*time.Second, 0)
+	c.now = func() time.Time { return now }
+	c.lastSweep = now
+
+	c.Put("svc-a", &ClientInfo{ClientSecretExpiresAt: 42})
+	if info, ok := c.Get("svc-a"); !ok || info.ClientSecretExpiresAt != 42 {
+		t.Fatalf("Get() = %+v, %v; want cached info", info, ok)
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, ok := c.Get("svc-a"); ok {
+		t.Error("Get() returned entry past its TTL")
+	}
+
+	// Sweep on Put drops expired entries
+	c.Put("svc-b", &ClientInfo{})
+	if _, ok := c.entries["svc-a"]; ok {
+		t.Error("expired entry not swept")
+	}
+}
+
+func TestClientInfoCacheKeepsStaleEntriesThroughLongOutage(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newClientInfoCache(30*time.Second, time.Hour)
+	c.now = func() time.Time { return now }
+	c.lastSweep = now
+
+	c.Put("svc-a", &ClientInfo{ClientSecretExpiresAt: 42})
+
+	// svc-a's lookups fail for 10 minutes (20x the TTL) while other clients
+	// are still fetched, sweeping the cache on each Put
+	for i := 0; i < 20; i++ {
+		now = now.Add(30 * time.Second)
+		c.Put("svc-b", &ClientInfo{})
+	}
+	if _, ok := c.Get("svc-a"); ok {
+		t.Error("Get() returned entry past its TTL")
+	}
+	if info, ok := c.GetStale("svc-a"); !ok || info.ClientSecretExpiresAt != 42 {
+		t.Fatalf("GetStale() = %+v, %v; want the entry kept for the stale max age", info, ok)
+	}
+
+	// Past the stale max age the entry is no longer served, and then swept
+	now = time.Unix(0, 0).Add(30*time.Second + time.Hour)
+	if _, ok := c.GetStale("svc-a"); ok {
+		t.Error("GetStale() returned entry past the stale max age")
+	}
+	c.Put("svc-b", &ClientInfo{})
+	if _, ok := c.entries["svc-a"]; ok {
+		t.Error("entry past the stale max age not swept")
+	}
+}
+
+func TestClientInfoCacheDisabled(t *testing.T) {
+	c := newClientInfoCache(0, 0)
+	c.Put("svc-a", &ClientInfo{})
+	if _, ok := c.Get("svc-a"); ok {
+		t.Error("disabled cache returned a hit")
+	}
+	c.Invalidate("svc-a")
+	c.Clear()
+}
+
+func TestTokenHookUsesClientInfoCache(t *testing.T) {
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.Method {
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"metadata":{"org_id":"acme"}}`))
+		}
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		clientCache:   newClientInfoCache(time.Minute, 0),
+		networkID:     uuid.Must(uuid.NewV4()),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callTokenHook(t, s, "svc-a")
+		}()
+	}
+	wg.Wait()
+	before := hits.Load()
+
+	for i := 0; i < 5; i++ {
+		if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK {
+			t.Fatalf("token hook status = %d, want 200", rec.Code)
+		}
+	}
+	if got := hits.Load(); got != before {
+		t.Errorf("cached token hooks made %d Hydra calls, want 0", got-before)
+	}
+
+	// Deleting the client through the sidecar drops its cache entry
+	rec := httptest.NewRecorder()
+	s.handleClientByID(rec, httptest.NewRequest(http.MethodDelete, "/admin/clients/svc-a", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want 204", rec.Code)
+	}
+	if _, ok := s.clientCache.Get("svc-a"); ok {
+		t.Error("cache entry survived delete")
+	}
+}
+
+func TestTokenHookServesStaleEntryWhenHydraDown(t *testing.T) {
+	var down atomic.Bool
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"metadata":{"org_id":"acme"}}`))
+	}))
+	defer hydra.Close()
+
+	now := time.Unix(0, 0)
+	cache := newClientInfoCache(30*time.Second, time.Hour)
+	cache.now = func() time.Time { return now }
+	cache.lastSweep = now
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		clientCache:   cache,
+		serveStale:    true,
+	}
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK {
+		t.Fatalf("warm-up status = %d, want 200", rec.Code)
+	}
+
+	// Entry expires and Hydra goes down
+	now = now.Add(time.Minute)
+	down.Store(true)
+
+	rec := callTokenHook(t, s, "svc-a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var resp TokenHookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	claims := resp.Session.AccessToken
+	if claims["org_id"] != "acme" || claims[staleClaimName] != true {
+		t.Errorf("claims = %v, want stale org_id acme", claims)
+	}
+
+	// Without the mode the hook falls back to no metadata claims
+	s.serveStale = false
+	rec = callTokenHook(t, s, "svc-a")
+	resp = TokenHookResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if _, ok := resp.Session.AccessToken["org_id"]; ok {
+		t.Errorf("claims = %v, want no metadata without SERVE_STALE_ON_ERROR", resp.Session.AccessToken)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// swagger:route GET /capabilities health capabilities
+//
+// Capability manifest.
+//
+// Lists optional features with whether each is enabled and its key (non-secret) settings,
+// so automation can adapt to the deployment without reading its configuration. Requires an
+// API key, since the settings describe the configuration.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: capabilitiesResponse
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.capabilities()); err != nil {
+		log.Printf("Error encoding capabilities: %v", err)
+	}
+}
+
+// capabilities builds the manifest from the effective configuration.
+// Secrets are reported only as enabled/disabled, never by value.
+func (s *Server) capabilities() Capabilities {
+	cfg := s.config
+	return Capabilities{Features: map[string]Capability{
+		"admin_auth":           {Enabled: cfg.AdminAPIKey != "" || cfg.ScopedAPIKeysJSON != ""},
+		"token_hook_signature": {Enabled: cfg.TokenHookSecret != ""},
+		"tls": {
+			Enabled:  cfg.TLSCertFile != "",
+			Settings: map[string]any{"client_certificates": cfg.TLSClientCAFile != ""},
+		},
+		"cors": {
+			Enabled:  cfg.CORSAllowedOrigins != "",
+			Settings: map[string]any{"allowed_origins": splitList(cfg.CORSAllowedOrigins)},
+		},
+		"metadata_cache": {
+			Enabled:  cfg.MetadataCacheTTL > 0,
+			Settings: map[string]any{"ttl": cfg.MetadataCacheTTL.String()},
+		},
+		"usage_tracking": {
+			Enabled:  s.usage != nil,
+			Settings: map[string]any{"flush_interval": cfg.UsageFlushInterval.String()},
+		},
+		"hydra_admin_auth": {
+			Enabled:  cfg.HydraAdminAuthHeader != "",
+			Settings: map[string]any{"header": cfg.HydraAdminAuthHeader},
+		},
+		"hydra_retry": {
+			Enabled:  cfg.HydraRetryAttempts > 1,
+			Settings: map[string]any{"attempts": cfg.HydraRetryAttempts, "base_delay": cfg.HydraRetryBaseDelay.String()},
+		},
+		"hydra_circuit_breaker": {
+			Enabled:  cfg.HydraCircuitFailures > 0,
+			Settings: map[string]any{"failures": cfg.HydraCircuitFailures, "cooldown": cfg.HydraCircuitCooldown.String()},
+		},
+		"retry_budget": {
+			Enabled:  cfg.RetryBudgetCapacity > 0,
+			Settings: map[string]any{"capacity": cfg.RetryBudgetCapacity, "refill_per_sec": cfg.RetryBudgetRefillPerSec},
+		},
+		"client_id_pattern": {
+			Enabled:  s.clientIDPattern != nil,
+			Settings: map[string]any{"pattern": cfg.ClientIDPattern},
+		},
+		"metadata_schema": {
+			Enabled:  s.metadataSchema != nil,
+			Settings: map[string]any{"keys": sortedKeys(s.metadataSchema)},
+		},
+		"claim_templates": {
+			Enabled:  s.claimTemplates != nil,
+			Settings: map[string]any{"claims": sortedKeys(s.claimTemplates)},
+		},
+		"default_claims": {
+			Enabled:  s.defaultClaims != nil,
+			Settings: map[string]any{"claims": sortedKeys(s.defaultClaims)},
+		},
+		"cache_warmup": {
+			Enabled:  cfg.CacheWarmup && cfg.MetadataCacheTTL > 0,
+			Settings: map[string]any{"size": cfg.CacheWarmupSize},
+		},
+		"last_known_good": {
+			Enabled:  s.snapshots != nil,
+			Settings: map[string]any{"max_age": cfg.LastKnownGoodMaxAge.String()},
+		},
+		"token_hook_fail_closed": {Enabled: cfg.TokenHookFailClosed},
+		"serve_stale_on_error": {
+			Enabled:  cfg.ServeStaleOnError && cfg.MetadataCacheTTL > 0,
+			Settings: map[string]any{"max_age": cfg.ServeStaleMaxAge.String()},
+		},
+		"token_hook_error_format": {
+			Enabled:  cfg.TokenHookDenyError != defaultDenyError || cfg.TokenHookErrorExtraFields,
+			Settings: map[string]any{"deny_error": cfg.TokenHookDenyError, "extra_fields": cfg.TokenHookErrorExtraFields},
+		},
+		"token_hook_retry_on_5xx": {Enabled: cfg.TokenHookRetryOn5xx},
+		"allowed_scope_claim":     {Enabled: cfg.TokenHookInjectAllowedScopes},
+		"token_hook_rate_limit": {
+			Enabled:  cfg.TokenHookRateLimit > 0,
+			Settings: map[string]any{"per_second": cfg.TokenHookRateLimit, "mode": cfg.TokenHookRateLimitMode},
+		},
+		"verify_secret": {
+			Enabled:  true,
+			Settings: map[string]any{"per_second": cfg.VerifySecretRateLimit},
+		},
+		"tier_rate_limits": {
+			Enabled:  s.tierRateLimits != nil,
+			Settings: map[string]any{"tiers": sortedKeys(s.tierRateLimits)},
+		},
+		"tier_rpm_limits": {
+			Enabled:  s.tierRPMLimits != nil,
+			Settings: map[string]any{"limits": map[string]int(s.tierRPMLimits)},
+		},
+		"env_claim": {
+			Enabled:  cfg.TokenHookEnvClaim != "",
+			Settings: map[string]any{"env": cfg.TokenHookEnvClaim},
+		},
+		"claim_filter": {
+			Enabled:  cfg.ClaimAllowlist != "" || cfg.ClaimDenylist != "",
+			Settings: map[string]any{"allow": splitList(cfg.ClaimAllowlist), "deny": splitList(cfg.ClaimDenylist)},
+		},
+		"claim_coerce": {
+			Enabled:  s.claimCoercions != nil,
+			Settings: map[string]any{"coercions": map[string]string(s.claimCoercions)},
+		},
+		"claim_enrichment": {
+			Enabled: s.claimEnricher != nil,
+			Settings: map[string]any{"url": sanitizeDatabaseURL(cfg.ClaimEnrichmentURL), "timeout": cfg.ClaimEnrichmentTimeout.String(),
+				"precedence": cfg.ClaimEnrichmentPrecedence},
+		},
+		"flatten_claims": {
+			Enabled:  cfg.FlattenClaims != claimValuesAsIs,
+			Settings: map[string]any{"mode": cfg.FlattenClaims},
+		},
+		"entitlements_claim": {
+			Enabled:  s.claimSigner != nil,
+			Settings: map[string]any{"key_id": entitlementsKeyID(s.claimSigner), "keys": splitList(cfg.EntitlementsClaimKeys)},
+		},
+		"max_claims_bytes": {
+			Enabled:  cfg.MaxClaimsBytes > 0,
+			Settings: map[string]any{"max_bytes": cfg.MaxClaimsBytes, "mode": cfg.MaxClaimsMode},
+		},
+		"claim_namespace": {
+			Enabled:  cfg.ClaimNamespace != "",
+			Settings: map[string]any{"namespace": claimNamespace(cfg.ClaimNamespace)},
+		},
+		"auth_method_defaults": {
+			Enabled:  s.authMethodDefaults != nil,
+			Settings: map[string]any{"defaults": map[string]string(s.authMethodDefaults)},
+		},
+		"client_lifetime": {
+			Enabled:  cfg.MaxClientLifetime > 0,
+			Settings: map[string]any{"max": cfg.MaxClientLifetime.String(), "mode": cfg.MaxClientLifetimeMode},
+		},
+		"expiry_jitter": {
+			Enabled:  cfg.ExpiryJitterSeconds > 0,
+			Settings: map[string]any{"seconds": cfg.ExpiryJitterSeconds},
+		},
+		"hash_parameters": {
+			Enabled:  cfg.BcryptCost > 0 || cfg.Pbkdf2Iterations > 0,
+			Settings: map[string]any{"bcrypt_cost": cfg.BcryptCost, "pbkdf2_iterations": cfg.Pbkdf2Iterations},
+		},
+		"min_secret_length": {
+			Enabled:  cfg.MinSecretLength > 0,
+			Settings: map[string]any{"min_length": cfg.MinSecretLength, "mode": cfg.MinSecretLengthMode},
+		},
+		"atomic_sync": {
+			Enabled:  true,
+			Settings: map[string]any{"max_failures": cfg.SyncMaxFailures},
+		},
+		"sync_delete_guard": {
+			Enabled:  cfg.MaxSyncDeleteRatio > 0,
+			Settings: map[string]any{"max_delete_ratio": cfg.MaxSyncDeleteRatio},
+		},
+		"soft_delete": {Enabled: cfg.SoftDeleteEnabled},
+		"request_body_limits": {
+			Enabled:  cfg.MaxRequestBodyBytes > 0 || cfg.MaxSyncRequestBodyBytes > 0,
+			Settings: map[string]any{"max_bytes": cfg.MaxRequestBodyBytes, "max_sync_bytes": cfg.MaxSyncRequestBodyBytes},
+		},
+		"concurrent_sync": {
+			Enabled:  cfg.SyncConcurrency > 1,
+			Settings: map[string]any{"concurrency": cfg.SyncConcurrency},
+		},
+		"idempotency_keys": {
+			Enabled:  s.idempotency != nil,
+			Settings: map[string]any{"ttl": cfg.IdempotencyKeyTTL.String()},
+		},
+		"clock_skew_tolerance": {
+			Enabled:  cfg.ClockSkewTolerance > 0,
+			Settings: map[string]any{"tolerance": cfg.ClockSkewTolerance.String()},
+		},
+		"audit_log": {
+			Enabled:  s.auditLogger != nil,
+			Settings: map[string]any{"database": s.audit != nil, "path": cfg.AuditLogPath},
+		},
+		"upsert_sync":           {Enabled: true},
+		"readiness_check_hydra": {Enabled: cfg.ReadinessCheckHydra},
+		"multi_network":         {Enabled: true},
+		"metrics":               {Enabled: s.metrics != nil},
+		"tracing":               {Enabled: cfg.OTLPEndpoint != ""},
+	}}
+}
+
+// sortedKeys returns a map's keys in order (empty, not nil, for stable JSON)
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
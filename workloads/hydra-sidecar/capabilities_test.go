@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapabilitiesReflectConfig(t *testing.T) {
+	schema, err := parseMetadataSchema(`{"tier":{"type":"string"},"org_id":{"type":"string"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		config: Config{
+			AdminAPIKey:       "s3cret",
+			MetadataCacheTTL:  45 * time.Second,
+			TokenHookEnvClaim: "prod",
+		},
+		metadataSchema: schema,
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleCapabilities(rec, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+
+	var got Capabilities
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	for name, want := range map[string]bool{
+		"admin_auth":           true,
+		"metadata_cache":       true,
+		"env_claim":            true,
+		"metadata_schema":      true,
+		"token_hook_signature": false,
+		"usage_tracking":       false,
+		"claim_templates":      false,
+	} {
+		if got.Features[name].Enabled != want {
+			t.Errorf("%s enabled = %t, want %t", name, got.Features[name].Enabled, want)
+		}
+	}
+
+	if ttl := got.Features["metadata_cache"].Settings["ttl"]; ttl != "45s" {
+		t.Errorf("metadata_cache ttl = %v, want 45s", ttl)
+	}
+	if keys, _ := json.Marshal(got.Features["metadata_schema"].Settings["keys"]); string(keys) != `["org_id","tier"]` {
+		t.Errorf("metadata_schema keys = %s, want [org_id tier]", keys)
+	}
+}
+
+func TestCapabilitiesOmitSecrets(t *testing.T) {
+	s := &Server{config: Config{AdminAPIKey: "admin-key-value", TokenHookSecret: "hook-secret-value"}}
+	rec := httptest.NewRecorder()
+	s.handleCapabilities(rec, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+
+	for _, secret := range []string{"admin-key-value", "hook-secret-value"} {
+		if strings.Contains(rec.Body.String(), secret) {
+			t.Errorf("capabilities leaked %q", secret)
+		}
+	}
+}
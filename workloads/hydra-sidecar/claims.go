@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// envClaimName is the access token claim set from TOKEN_HOOK_ENV_CLAIM
+const envClaimName = "env"
+
+// allowedScopeClaimName carries the client's full scope allowance
+// (TOKEN_HOOK_INJECT_ALLOWED_SCOPES), space-separated like Hydra's scope field
+const allowedScopeClaimName = "allowed_scope"
+
+// staleClaimName marks tokens built from stale cached client info (SERVE_STALE_ON_ERROR)
+const staleClaimName = "stale"
+
+// staleClaimsClaimName marks tokens built from a persisted last-known-good
+// snapshot of the client's info (LAST_KNOWN_GOOD_MAX_AGE)
+const staleClaimsClaimName = "stale_claims"
+
+// claimNamespace returns the CLAIM_NAMESPACE prefix for metadata-derived
+// claims, ending in exactly one slash ("" when no namespace is configured)
+func claimNamespace(ns string) string {
+	ns = strings.TrimRight(ns, "/")
+	if ns == "" {
+		return ""
+	}
+	return ns + "/"
+}
+
+// parseDefaultClaims parses DEFAULT_CLAIMS, a JSON object of claims every
+// token gets unless the client's metadata sets them (nil when unset)
+func parseDefaultClaims(raw string) (map[string]any, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var claims map[string]any
+	if err := json.Unmarshal([]byte(raw), &claims); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if claims == nil {
+		return nil, fmt.Errorf("must be a JSON object")
+	}
+	for _, key := range []string{claimsScopeMapKey, softDeletedAtKey} {
+		if _, ok := claims[key]; ok {
+			return nil, fmt.Errorf("%q is reserved metadata, not a claim", key)
+		}
+	}
+	return claims, nil
+}
+
+// withDefaultClaims layers a client's metadata over DEFAULT_CLAIMS. A default
+// applies only where the metadata has no value (absent or null) and remote
+// claims don't set it either, so it never outranks either source.
+func withDefaultClaims(metadata, defaults, remote map[string]any) map[string]any {
+	if len(defaults) == 0 {
+		return metadata
+	}
+	merged := make(map[string]any, len(defaults)+len(metadata))
+	for key, value := range defaults {
+		if _, set := remote[key]; !set {
+			merged[key] = value
+		}
+	}
+	for key, value := range metadata {
+		if _, hasDefault := merged[key]; hasDefault && value == nil {
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// defaultDenyError is the token hook error code for denied tokens (OAuth 2.0)
+const defaultDenyError = "access_denied"
+
+// hookErrorFormat shapes token hook error bodies to match what the deployed
+// Hydra version expects
+type hookErrorFormat struct {
+	// Error code for denied tokens (empty = access_denied)
+	denyError string
+	// Add error_hint and status_code, as in Hydra's own error responses
+	extraFields bool
+}
+
+// denyCode returns the error code for a denied token
+func (f hookErrorFormat) denyCode() string {
+	if f.denyError == "" {
+		return defaultDenyError
+	}
+	return f.denyError
+}
+
+// claimsScopeMapKey is the metadata entry mapping claim keys to the scope that
+// must be granted for them to be injected. It is never injected itself.
+const claimsScopeMapKey = "claims_scope_map"
+
+// metadataClaims returns the metadata entries to inject as claims for a token
+// with the given granted scopes. Claims listed in claims_scope_map are only
+// included when their scope was granted; unlisted claims always are. A
+// malformed map fails closed: no metadata claims are injected.
+func metadataClaims(metadata map[string]any, scopes []string, clientID string) map[string]any {
+	scopeMap := map[string]any{}
+	if raw, ok := metadata[claimsScopeMapKey]; ok {
+		m, ok := raw.(map[string]any)
+		if !ok {
+			log.Printf("Warning: %s for client %s is not an object, injecting no metadata claims", claimsScopeMapKey, clientID)
+			return nil
+		}
+		scopeMap = m
+	}
+
+	granted := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		granted[scope] = true
+	}
+
+	claims := make(map[string]any, len(metadata))
+	for key, value := range metadata {
+		if key == claimsScopeMapKey || key == softDeletedAtKey {
+			continue
+		}
+		if required, scoped := scopeMap[key]; scoped {
+			// A non-string scope can never be granted, so the claim is withheld
+			if scope, ok := required.(string); !ok || !granted[scope] {
+				continue
+			}
+		}
+		claims[key] = value
+	}
+	return claims
+}
+
+// claimFilter limits which metadata keys become claims (CLAIM_ALLOWLIST,
+// CLAIM_DENYLIST). The zero value permits every key.
+type claimFilter struct {
+	// Keys that may be injected (nil = all)
+	allow map[string]bool
+	// Keys never injected, applied after allow
+	deny map[string]bool
+}
+
+// newClaimFilter builds a filter from comma-separated allow and deny lists
+func newClaimFilter(allowList, denyList string) claimFilter {
+	var f claimFilter
+	if keys := splitList(allowList); len(keys) > 0 {
+		f.allow = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			f.allow[k] = true
+		}
+	}
+	if keys := splitList(denyList); len(keys) > 0 {
+		f.deny = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			f.deny[k] = true
+		}
+	}
+	return f
+}
+
+// permits reports whether a metadata key may be injected as a claim
+func (f claimFilter) permits(key string) bool {
+	if f.allow != nil && !f.allow[key] {
+		return false
+	}
+	return !f.deny[key]
+}
+
+// Claim types for CLAIM_COERCE
+const (
+	claimTypeInt   = "int"
+	claimTypeFloat = "float"
+	claimTypeBool  = "bool"
+)
+
+// claimCoercions maps metadata keys to the type their claim values are
+// converted to (CLAIM_COERCE), e.g. "max_seats" -> "int"
+type claimCoercions map[string]string
+
+// parseClaimCoercions parses CLAIM_COERCE, a comma-separated list of
+// key:type pairs such as "max_seats:int,trial:bool" (empty = no coercion)
+func parseClaimCoercions(raw string) (claimCoercions, error) {
+	items := splitList(raw)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	coercions := make(claimCoercions, len(items))
+	for _, item := range items {
+		key, typ, ok := strings.Cut(item, ":")
+		key, typ = strings.TrimSpace(key), strings.TrimSpace(typ)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not key:type", item)
+		}
+		switch typ {
+		case claimTypeInt, claimTypeFloat, claimTypeBool:
+		default:
+			return nil, fmt.Errorf("unknown type %q for %s (want int, float, or bool)", typ, key)
+		}
+		if _, dup := coercions[key]; dup {
+			return nil, fmt.Errorf("%s is listed more than once", key)
+		}
+		coercions[key] = typ
+	}
+	return coercions, nil
+}
+
+// apply converts the configured claims in place. A value that can't be
+// converted, such as "fifty" for an int, is logged and left as it is.
+func (c claimCoercions) apply(claims map[string]any, clientID string) {
+	for key, typ := range c {
+		value, ok := claims[key]
+		if !ok {
+			continue
+		}
+		coerced, err := coerceClaimValue(value, typ)
+		if err != nil {
+			log.Printf("Warning: claim %s for client %s not coerced to %s: %v", key, clientID, typ, err)
+			continue
+		}
+		claims[key] = coerced
+	}
+}
+
+// coerceClaimValue converts a decoded JSON value to typ. Strings are parsed;
+// numbers become ints only when whole; values already of the type pass through.
+func coerceClaimValue(value any, typ string) (any, error) {
+	switch typ {
+	case claimTypeInt:
+		switch v := value.(type) {
+		case string:
+			return strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		case float64:
+			if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+				return nil, fmt.Errorf("%v is not a whole number", v)
+			}
+			return int64(v), nil
+		}
+	case claimTypeFloat:
+		switch v := value.(type) {
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err == nil && (math.IsInf(f, 0) || math.IsNaN(f)) {
+				err = fmt.Errorf("%q is not a finite number", v)
+			}
+			return f, err
+		case float64:
+			return v, nil
+		}
+	case claimTypeBool:
+		switch v := value.(type) {
+		case string:
+			return strconv.ParseBool(strings.TrimSpace(v))
+		case bool:
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("can't convert %T", value)
+}
+
+// Oversized claim modes for MAX_CLAIMS_MODE
+const (
+	// Drop the largest metadata claims until the claims fit
+	claimsSizeModeDrop = "drop"
+	// Refuse the token with 413
+	claimsSizeModeReject = "reject"
+)
+
+// sidecarClaimNames are claims the sidecar sets itself, which MAX_CLAIMS_BYTES
+// never drops even when metadata holds a key of the same name
+var sidecarClaimNames = map[string]bool{
+	envClaimName:          true,
+	allowedScopeClaimName: true,
+	staleClaimName:        true,
+	staleClaimsClaimName:  true,
+	rateLimitClaimName:    true,
+	rateLimitRPMClaimName: true,
+	entitlementsClaimName: true,
+}
+
+// claimsSize returns the serialized size of claims in bytes
+func claimsSize(claims map[string]any) int {
+	encoded, err := json.Marshal(claims)
+	if err != nil {
+		// The response encoder reports the same error
+		return 0
+	}
+	return len(encoded)
+}
+
+// dropLargestClaims deletes droppable claims, largest serialized key and value
+// first, until claims serialize to at most maxBytes or nothing droppable is
+// left. It returns the dropped keys in drop order and the final size.
+func dropLargestClaims(claims map[string]any, droppable []string, maxBytes int) ([]string, int) {
+	sizes := make(map[string]int, len(droppable))
+	for _, key := range droppable {
+		if value, ok := claims[key]; ok {
+			sizes[key] = claimsSize(map[string]any{key: value})
+		}
+	}
+	keys := make([]string, 0, len(sizes))
+	for key := range sizes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if sizes[keys[i]] != sizes[keys[j]] {
+			return sizes[keys[i]] > sizes[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	var dropped []string
+	size := claimsSize(claims)
+	for _, key := range keys {
+		if size <= maxBytes {
+			break
+		}
+		delete(claims, key)
+		dropped = append(dropped, key)
+		size = claimsSize(claims)
+	}
+	return dropped, size
+}
+
+// Metadata claim value modes for FLATTEN_CLAIMS
+const (
+	// Copy values unchanged, objects and arrays included
+	claimValuesAsIs = "off"
+	// Flatten objects and arrays into dotted-key scalar claims
+	claimValuesFlatten = "flatten"
+	// Drop object and array values with a warning
+	claimValuesStrict = "strict"
+)
+
+// shapeClaimValues applies the FLATTEN_CLAIMS mode to metadata claims.
+// Flattening turns {"address": {"city": "x"}} into "address.city" and arrays
+// into indexed keys ("roles.0"); empty objects and arrays produce no claim. A
+// top-level key always wins over a flattened key with the same name.
+func shapeClaimValues(claims map[string]any, mode, clientID string) map[string]any {
+	if mode != claimValuesFlatten && mode != claimValuesStrict {
+		return claims
+	}
+
+	shaped := make(map[string]any, len(claims))
+	var nested []string
+	for key, value := range claims {
+		switch value.(type) {
+		case map[string]any, []any:
+			nested = append(nested, key)
+		default:
+			shaped[key] = value
+		}
+	}
+	sort.Strings(nested)
+
+	for _, key := range nested {
+		if mode == claimValuesStrict {
+			log.Printf("Warning: metadata %q for client %s is not a scalar, not injecting it (FLATTEN_CLAIMS=strict)", key, clientID)
+			continue
+		}
+		flattenClaim(shaped, key, claims[key])
+	}
+	return shaped
+}
+
+// flattenClaim adds value under key, descending into objects and arrays.
+// Keys already present are kept.
+func flattenClaim(claims map[string]any, key string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			flattenClaim(claims, key+"."+k, v[k])
+		}
+	case []any:
+		for i, item := range v {
+			flattenClaim(claims, key+"."+strconv.Itoa(i), item)
+		}
+	default:
+		if _, exists := claims[key]; !exists {
+			claims[key] = value
+		}
+	}
+}
+
+// claimTemplates maps claim names to parsed templates (CLAIM_TEMPLATES_JSON)
+type claimTemplates map[string]*template.Template
+
+// parseClaimTemplates parses a JSON object of claim name -> text/template.
+//
+// Templates are sandboxed: no custom functions are registered, and the data
+// they execute against is plain JSON (maps, slices, scalars) so the builtin
+// "call" has nothing to invoke. missingkey=error makes references to absent
+// metadata fail instead of rendering "<no value>".
+func parseClaimTemplates(raw string) (claimTemplates, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var defs map[string]string
+	if err := json.Unmarshal([]byte(raw), &defs); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	templates := make(claimTemplates, len(defs))
+	for claim, text := range defs {
+		tmpl, err := template.New(claim).Option("missingkey=error").Funcs(template.FuncMap{}).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("claim %q: %w", claim, err)
+		}
+		templates[claim] = tmpl
+	}
+	return templates, nil
+}
+
+// render evaluates each template against the client's metadata. Metadata keys
+// are top-level fields ({{.org_name}}); .client_id and .scopes are also
+// available unless metadata defines keys with those names. A template that
+// fails (e.g. references a missing field) is skipped so the hook fails safe.
+func (ct claimTemplates) render(metadata map[string]any, clientID string, scopes []string) map[string]any {
+	if len(ct) == 0 {
+		return nil
+	}
+
+	data := make(map[string]any, len(metadata)+2)
+	for k, v := range metadata {
+		data[k] = v
+	}
+	if _, ok := data["client_id"]; !ok {
+		data["client_id"] = clientID
+	}
+	if _, ok := data["scopes"]; !ok {
+		data["scopes"] = scopes
+	}
+
+	claims := make(map[string]any, len(ct))
+	for claim, tmpl := range ct {
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err != nil {
+			log.Printf("Warning: claim template %q failed for client %s, skipping: %v", claim, clientID, err)
+			continue
+		}
+		claims[claim] = sb.String()
+	}
+	return claims
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestClaimTemplatesRender(t *testing.T) {
+	ct, err := parseClaimTemplates(`{"display":"{{.org_name}} ({{.tier}})","org_ref":"{{.missing_field}}"}`)
+	if err != nil {
+		t.Fatalf("parseClaimTemplates() error = %v", err)
+	}
+
+	claims := ct.render(map[string]any{"org_name": "Acme", "tier": "pro"}, "svc-a", []string{"read"})
+
+	if got := claims["display"]; got != "Acme (pro)" {
+		t.Errorf("display = %v, want %q", got, "Acme (pro)")
+	}
+	if _, ok := claims["org_ref"]; ok {
+		t.Errorf("org_ref rendered as %v, want it skipped for missing field", claims["org_ref"])
+	}
+}
+
+func TestClaimTemplatesScopesAndClientID(t *testing.T) {
+	ct, err := parseClaimTemplates(`{"who":"{{.client_id}}:{{index .scopes 0}}"}`)
+	if err != nil {
+		t.Fatalf("parseClaimTemplates() error = %v", err)
+	}
+
+	claims := ct.render(nil, "svc-a", []string{"read", "write"})
+	if got := claims["who"]; got != "svc-a:read" {
+		t.Errorf("who = %v, want %q", got, "svc-a:read")
+	}
+}
+
+func TestParseClaimTemplatesInvalid(t *testing.T) {
+	if _, err := parseClaimTemplates(`{"bad":"{{.org_name"}`); err == nil {
+		t.Error("expected parse error for malformed template")
+	}
+	if _, err := parseClaimTemplates(`{"bad":"{{exec \"rm\"}}"}`); err == nil {
+		t.Error("expected parse error for undefined function")
+	}
+	if _, err := parseClaimTemplates(`not json`); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestTokenHookStampsEnvClaim(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","env":"dev"}}`)
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		envClaim:      "prod",
+	}
+
+	rec := callTokenHook(t, s, "svc-a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token hook status = %d, want 200", rec.Code)
+	}
+
+	var resp TokenHookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got := resp.Session.AccessToken["env"]; got != "prod" {
+		t.Errorf("env claim = %v, want %q", got, "prod")
+	}
+	if got := resp.Session.AccessToken["org_id"]; got != "acme" {
+		t.Errorf("org_id claim = %v, want metadata still injected", got)
+	}
+}
+
+func TestTokenHookInjectsAllowedScopes(t *testing.T) {
+	hydra := newFakeHydra(t, `{"scope":"read write admin","metadata":{"org_id":"acme","allowed_scope":"everything"}}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), injectAllowedScopes: true}
+
+	// callTokenHook grants only "read"; the claim carries the full allowance
+	rec := callTokenHook(t, s, "svc-a")
+	var resp TokenHookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got := resp.Session.AccessToken[allowedScopeClaimName]; got != "read write admin" {
+		t.Errorf("allowed_scope = %v, want the client's configured scopes", got)
+	}
+
+	// Disabled: metadata passes through untouched
+	s.injectAllowedScopes = false
+	rec = callTokenHook(t, s, "svc-a")
+	resp = TokenHookResponse{}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if got := resp.Session.AccessToken[allowedScopeClaimName]; got != "everything" {
+		t.Errorf("allowed_scope without TOKEN_HOOK_INJECT_ALLOWED_SCOPES = %v, want metadata value", got)
+	}
+}
+
+func TestClaimFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow, deny string
+		want        map[string]bool
+	}{
+		{"unset copies all", "", "", map[string]bool{"org_id": true, "tier": true, "notes": true}},
+		{"allow list only", "org_id, tier", "", map[string]bool{"org_id": true, "tier": true, "notes": false}},
+		{"deny list only", "", "notes", map[string]bool{"org_id": true, "tier": true, "notes": false}},
+		{"deny after allow", "org_id,tier", "tier", map[string]bool{"org_id": true, "tier": false, "notes": false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newClaimFilter(tt.allow, tt.deny)
+			for key, want := range tt.want {
+				if got := f.permits(key); got != want {
+					t.Errorf("permits(%q) = %t, want %t", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenHookAppliesClaimAllowlist(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","tier":"pro","billing_account":"ba-42","notes":"vip"}}`)
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		claimFilter:   newClaimFilter("org_id,tier,notes", "notes"),
+	}
+
+	rec := callTokenHook(t, s, "svc-a")
+	var resp TokenHookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims := resp.Session.AccessToken
+	if claims["org_id"] != "acme" || claims["tier"] != "pro" {
+		t.Errorf("claims = %v, want allow-listed org_id and tier", claims)
+	}
+	for _, key := range []string{"billing_account", "notes"} {
+		if _, ok := claims[key]; ok {
+			t.Errorf("claim %q injected, want it filtered out", key)
+		}
+	}
+}
+
+func TestShapeClaimValues(t *testing.T) {
+	claims := map[string]any{
+		"org_id":       "acme",
+		"address":      map[string]any{"city": "Oslo", "geo": map[string]any{"lat": 59.9}},
+		"roles":        []any{"admin", "ops"},
+		"empty":        []any{},
+		"address.city": "override",
+	}
+	tests := []struct {
+		mode string
+		want map[string]any
+	}{
+		{claimValuesAsIs, claims},
+		{"", claims},
+		{claimValuesFlatten, map[string]any{
+			"org_id":          "acme",
+			"address.city":    "override",
+			"address.geo.lat": 59.9,
+			"roles.0":         "admin",
+			"roles.1":         "ops",
+		}},
+		{claimValuesStrict, map[string]any{"org_id": "acme", "address.city": "override"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if got := shapeClaimValues(claims, tt.mode, "svc-a"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("shapeClaimValues(%q) = %v, want %v", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenHookFlattensClaims(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","address":{"city":"Oslo"},"notes":{"x":1}}}`)
+	s := &Server{
+		hydraAdminURL:  hydra.URL,
+		httpClient:     hydra.Client(),
+		claimFilter:    newClaimFilter("", "notes"),
+		claimNamespace: claimNamespace("https://ourco.io"),
+		config:         Config{FlattenClaims: claimValuesFlatten},
+	}
+
+	want := map[string]any{"https://ourco.io/org_id": "acme", "https://ourco.io/address.city": "Oslo"}
+	if claims := tokenHookClaims(t, s, "svc-a"); !reflect.DeepEqual(claims, want) {
+		t.Errorf("claims = %v, want %v", claims, want)
+	}
+}
+
+func TestParseClaimCoercions(t *testing.T) {
+	got, err := parseClaimCoercions(" max_seats:int, price : float,trial:bool ")
+	if err != nil {
+		t.Fatalf("parseClaimCoercions() error = %v", err)
+	}
+	want := claimCoercions{"max_seats": "int", "price": "float", "trial": "bool"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseClaimCoercions() = %v, want %v", got, want)
+	}
+
+	if got, err := parseClaimCoercions(""); got != nil || err != nil {
+		t.Errorf("parseClaimCoercions(\"\") = %v, %v; want nil, nil", got, err)
+	}
+	for _, raw := range []string{"max_seats", ":int", "max_seats:string", "a:int,a:float"} {
+		if _, err := parseClaimCoercions(raw); err == nil {
+			t.Errorf("parseClaimCoercions(%q) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestClaimCoercionsApply(t *testing.T) {
+	coercions := claimCoercions{
+		"max_seats": "int", "quota": "int", "fraction": "int",
+		"price": "float", "trial": "bool", "enabled": "bool", "bad": "int", "missing": "int",
+	}
+	claims := map[string]any{
+		"max_seats": "50",
+		"quota":     float64(1000),
+		"fraction":  2.5,
+		"price":     " 9.99",
+		"trial":     "true",
+		"enabled":   false,
+		"bad":       "fifty",
+		"org_id":    "acme",
+	}
+	coercions.apply(claims, "svc-a")
+
+	want := map[string]any{
+		"max_seats": int64(50),
+		"quota":     int64(1000),
+		"fraction":  2.5, // not whole, left as is
+		"price":     9.99,
+		"trial":     true,
+		"enabled":   false,
+		"bad":       "fifty", // unparseable, left as is
+		"org_id":    "acme",
+	}
+	if !reflect.DeepEqual(claims, want) {
+		t.Errorf("claims = %v, want %v", claims, want)
+	}
+}
+
+func TestTokenHookCoercesClaims(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"max_seats":"50","trial":"false","tier":"pro","price":"n/a"}}`)
+	coercions, _ := parseClaimCoercions("max_seats:int,trial:bool,price:float")
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), claimCoercions: coercions}
+
+	// Decoded from JSON, so numbers come back as float64
+	want := map[string]any{"max_seats": float64(50), "trial": false, "tier": "pro", "price": "n/a"}
+	if claims := tokenHookClaims(t, s, "svc-a"); !reflect.DeepEqual(claims, want) {
+		t.Errorf("claims = %v, want %v", claims, want)
+	}
+}
+
+func TestDropLargestClaims(t *testing.T) {
+	claims := map[string]any{
+		"env":    "prod",
+		"org_id": "acme",
+		"notes":  strings.Repeat("n", 200),
+		"bio":    strings.Repeat("b", 100),
+	}
+	dropped, size := dropLargestClaims(claims, []string{"org_id", "notes", "bio"}, 60)
+
+	if want := []string{"notes", "bio"}; !reflect.DeepEqual(dropped, want) {
+		t.Errorf("dropped = %v, want %v", dropped, want)
+	}
+	if want := map[string]any{"env": "prod", "org_id": "acme"}; !reflect.DeepEqual(claims, want) {
+		t.Errorf("claims = %v, want %v", claims, want)
+	}
+	if size != claimsSize(claims) || size > 60 {
+		t.Errorf("size = %d, want %d within 60", size, claimsSize(claims))
+	}
+
+	// Claims that can't be dropped stay even when the limit is still exceeded
+	dropped, size = dropLargestClaims(claims, []string{"org_id"}, 10)
+	if !reflect.DeepEqual(dropped, []string{"org_id"}) || claims["env"] != "prod" || size <= 10 {
+		t.Errorf("dropped = %v, claims = %v, size = %d", dropped, claims, size)
+	}
+}
+
+func TestTokenHookMaxClaimsBytes(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","notes":"`+strings.Repeat("x", 500)+`","env":"`+strings.Repeat("e", 300)+`"}}`)
+
+	t.Run("drop", func(t *testing.T) {
+		s := &Server{
+			hydraAdminURL: hydra.URL,
+			httpClient:    hydra.Client(),
+			envClaim:      "prod",
+			config:        Config{MaxClaimsBytes: 100, MaxClaimsMode: claimsSizeModeDrop},
+		}
+		want := map[string]any{"org_id": "acme", "env": "prod"}
+		if claims := tokenHookClaims(t, s, "svc-a"); !reflect.DeepEqual(claims, want) {
+			t.Errorf("claims = %v, want %v", claims, want)
+		}
+	})
+
+	t.Run("under limit", func(t *testing.T) {
+		s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), config: Config{MaxClaimsBytes: 4096}}
+		if claims := tokenHookClaims(t, s, "svc-a"); len(claims) != 3 {
+			t.Errorf("claims = %v, want all 3 metadata claims", claims)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		s := &Server{
+			hydraAdminURL: hydra.URL,
+			httpClient:    hydra.Client(),
+			config:        Config{MaxClaimsBytes: 100, MaxClaimsMode: claimsSizeModeReject},
+		}
+		rec := callTokenHook(t, s, "svc-a")
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status = %d, want 413: %s", rec.Code, rec.Body)
+		}
+		var resp TokenHookErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Error != "invalid_request" {
+			t.Errorf("body = %+v (%v), want invalid_request", resp, err)
+		}
+	})
+}
+
+func TestMetadataClaimsScopeMap(t *testing.T) {
+	metadata := map[string]any{
+		"org_id":          "acme",
+		"billing_account": "ba-42",
+		"audit_level":     "full",
+		"claims_scope_map": map[string]any{
+			"billing_account": "billing:read",
+			"audit_level":     "audit",
+		},
+	}
+
+	claims := metadataClaims(metadata, []string{"openid", "billing:read"}, "svc-a")
+
+	if claims["org_id"] != "acme" {
+		t.Errorf("unscoped org_id = %v, want acme", claims["org_id"])
+	}
+	if claims["billing_account"] != "ba-42" {
+		t.Errorf("billing_account = %v, want it injected for granted scope", claims["billing_account"])
+	}
+	if _, ok := claims["audit_level"]; ok {
+		t.Error("audit_level injected without the audit scope")
+	}
+	if _, ok := claims[claimsScopeMapKey]; ok {
+		t.Error("claims_scope_map itself was injected")
+	}
+}
+
+func TestMetadataClaimsWithoutScopeMap(t *testing.T) {
+	claims := metadataClaims(map[string]any{"org_id": "acme", "tier": "pro"}, nil, "svc-a")
+	if len(claims) != 2 {
+		t.Errorf("claims = %v, want all metadata", claims)
+	}
+}
+
+func TestMetadataClaimsMalformedScopeMapFailsClosed(t *testing.T) {
+	claims := metadataClaims(map[string]any{"org_id": "acme", "claims_scope_map": "billing:read"}, []string{"billing:read"}, "svc-a")
+	if len(claims) != 0 {
+		t.Errorf("claims = %v, want none for a malformed scope map", claims)
+	}
+
+	// A non-string required scope withholds just that claim
+	claims = metadataClaims(map[string]any{
+		"org_id":           "acme",
+		"billing_account":  "ba-42",
+		"claims_scope_map": map[string]any{"billing_account": 7},
+	}, []string{"7"}, "svc-a")
+	if _, ok := claims["billing_account"]; ok || claims["org_id"] != "acme" {
+		t.Errorf("claims = %v, want only org_id", claims)
+	}
+}
+
+func TestTokenHookFiltersScopedClaims(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","billing_account":"ba-42","claims_scope_map":{"billing_account":"billing:read"}}}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+
+	// callTokenHook grants only the "read" scope
+	rec := callTokenHook(t, s, "svc-a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var resp TokenHookResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims := resp.Session.AccessToken
+	if claims["org_id"] != "acme" {
+		t.Errorf("org_id = %v, want acme", claims["org_id"])
+	}
+	if _, ok := claims["billing_account"]; ok {
+		t.Error("billing_account injected without billing:read")
+	}
+	if _, ok := claims[claimsScopeMapKey]; ok {
+		t.Error("claims_scope_map injected")
+	}
+}
+
+func TestClaimNamespace(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                   "",
+		"/":                  "",
+		"https://ourco.io":   "https://ourco.io/",
+		"https://ourco.io/":  "https://ourco.io/",
+		"https://ourco.io//": "https://ourco.io/",
+	} {
+		if got := claimNamespace(in); got != want {
+			t.Errorf("claimNamespace(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTokenHookNamespacesMetadataClaims(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","env":"spoofed"}}`)
+	templates, err := parseClaimTemplates(`{"who":"{{.client_id}}"}`)
+	if err != nil {
+		t.Fatalf("parseClaimTemplates() error = %v", err)
+	}
+	s := &Server{
+		hydraAdminURL:  hydra.URL,
+		httpClient:     hydra.Client(),
+		claimNamespace: claimNamespace("https://ourco.io"),
+		claimTemplates: templates,
+		envClaim:       "prod",
+	}
+
+	rec := callTokenHook(t, s, "svc-a")
+	var resp TokenHookResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	claims := resp.Session.AccessToken
+
+	want := map[string]any{
+		"https://ourco.io/org_id": "acme",
+		"https://ourco.io/env":    "spoofed",
+		"who":                     "svc-a",
+		"env":                     "prod",
+	}
+	if len(claims) != len(want) {
+		t.Errorf("claims = %v, want %v", claims, want)
+	}
+	for k, v := range want {
+		if claims[k] != v {
+			t.Errorf("claim %q = %v, want %v", k, claims[k], v)
+		}
+	}
+}
+
+func TestTokenHookDenyErrorFormat(t *testing.T) {
+	hydra := newFakeHydra(t, `{"client_secret_expires_at":1}`)
+
+	tests := []struct {
+		name   string
+		format hookErrorFormat
+		want   map[string]any
+	}{
+		{"default", hookErrorFormat{}, map[string]any{
+			"error": "access_denied", "error_description": "client has expired",
+		}},
+		{"configured code", hookErrorFormat{denyError: "invalid_client"}, map[string]any{
+			"error": "invalid_client", "error_description": "client has expired",
+		}},
+		{"extra fields", hookErrorFormat{denyError: "unauthorized_client", extraFields: true}, map[string]any{
+			"error": "unauthorized_client", "error_description": "client has expired",
+			"error_hint":  "client_secret_expires_at has passed; rotate the secret or extend the expiry",
+			"status_code": float64(http.StatusForbidden),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), hookErrors: tt.format}
+			rec := callTokenHook(t, s, "svc-a")
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want 403", rec.Code)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(body, tt.want) {
+				t.Errorf("body = %v, want %v", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDefaultClaims(t *testing.T) {
+	claims, err := parseDefaultClaims(`{"tier":"free","org_id":"unassigned"}`)
+	if err != nil || claims["tier"] != "free" {
+		t.Fatalf("claims = %v, err = %v", claims, err)
+	}
+	if claims, err := parseDefaultClaims(""); claims != nil || err != nil {
+		t.Errorf("unset: claims = %v, err = %v, want none", claims, err)
+	}
+	for _, raw := range []string{`["tier"]`, `null`, `{"claims_scope_map":{}}`, `{`} {
+		if _, err := parseDefaultClaims(raw); err == nil {
+			t.Errorf("%s accepted", raw)
+		}
+	}
+}
+
+func TestTokenHookDefaultClaims(t *testing.T) {
+	defaults := map[string]any{"tier": "free", "org_id": "unassigned", "region": "eu"}
+
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","region":null}}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), defaultClaims: defaults}
+	claims := tokenHookClaims(t, s, "svc-a")
+	// Metadata wins, a null metadata value doesn't, and missing keys are filled
+	if claims["org_id"] != "acme" || claims["tier"] != "free" || claims["region"] != "eu" {
+		t.Errorf("claims = %v, want metadata over defaults", claims)
+	}
+
+	// A failed lookup still gets every default
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer missing.Close()
+	s = &Server{hydraAdminURL: missing.URL, httpClient: missing.Client(), defaultClaims: defaults}
+	if claims := tokenHookClaims(t, s, "svc-a"); len(claims) != 3 || claims["org_id"] != "unassigned" {
+		t.Errorf("claims without metadata = %v, want the defaults", claims)
+	}
+
+	// Remote claims outrank defaults even when metadata takes precedence
+	if merged := withDefaultClaims(nil, defaults, map[string]any{"tier": "pro"}); merged["tier"] != nil || merged["org_id"] != "unassigned" {
+		t.Errorf("merged = %v, want the remote tier left to the enrichment merge", merged)
+	}
+}
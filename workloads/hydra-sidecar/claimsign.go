@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// entitlementsClaimName is the claim holding the signed entitlements JWS
+// (CLAIM_SIGNING_KEY_FILE). It replaces any same-named metadata claim.
+const entitlementsClaimName = "entitlements"
+
+// claimSigner signs selected metadata into a compact JWS for the
+// entitlements claim. A nil signer leaves the claim out.
+type claimSigner struct {
+	key crypto.Signer
+	// JWS algorithm for key: RS256, ES256, ES384, or EdDSA
+	alg string
+	// Key ID in the JWS header
+	kid string
+	// Metadata keys signed into the payload (nil = every injected metadata claim)
+	keys map[string]bool
+}
+
+// loadClaimSigner reads a PEM private key (PKCS#8, PKCS#1 RSA, or SEC1 EC)
+// from keyFile. An empty kid defaults to the key's RFC 7638 JWK thumbprint.
+// keyList is the comma-separated metadata keys to sign. An empty keyFile
+// disables signing.
+func loadClaimSigner(keyFile, kid, keyList string) (*claimSigner, error) {
+	if keyFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, err := jwsAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	if kid == "" {
+		if kid, err = jwkThumbprint(key.Public()); err != nil {
+			return nil, err
+		}
+	}
+
+	signer := &claimSigner{key: key, alg: alg, kid: kid}
+	if keys := splitList(keyList); len(keys) > 0 {
+		signer.keys = make(map[string]bool, len(keys))
+		for _, k := range keys {
+			signer.keys[k] = true
+		}
+	}
+	return signer, nil
+}
+
+// parsePrivateKeyPEM decodes the first PEM block of data as a private key
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q (want PRIVATE KEY, RSA PRIVATE KEY, or EC PRIVATE KEY)", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// jwsAlgorithm picks the JWS algorithm for a private key
+func jwsAlgorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return "", fmt.Errorf("RSA key is %d bits, want at least 2048", k.N.BitLen())
+		}
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		}
+		return "", fmt.Errorf("unsupported EC curve %s (want P-256 or P-384)", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return "EdDSA", nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
+
+// jwkThumbprint returns the RFC 7638 SHA-256 thumbprint of a public key,
+// base64url-encoded
+func jwkThumbprint(pub crypto.PublicKey) (string, error) {
+	// Members in lexicographic order, as RFC 7638 requires
+	var canonical string
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`,
+			base64URL(big.NewInt(int64(k.E)).Bytes()), base64URL(k.N.Bytes()))
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`,
+			k.Curve.Params().Name, base64URL(k.X.FillBytes(make([]byte, size))), base64URL(k.Y.FillBytes(make([]byte, size))))
+	case ed25519.PublicKey:
+		canonical = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":%q}`, base64URL(k))
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64URL(sum[:]), nil
+}
+
+// entitlements signs the selected metadata claims for clientID into a
+// compact JWS, or returns "" when none are selected
+func (cs *claimSigner) entitlements(claims map[string]any, clientID string, now time.Time) (string, error) {
+	payload := make(map[string]any, len(claims)+2)
+	for key, value := range claims {
+		if cs.keys == nil || cs.keys[key] {
+			payload[key] = value
+		}
+	}
+	if len(payload) == 0 {
+		return "", nil
+	}
+	payload["sub"] = clientID
+	payload["iat"] = now.Unix()
+	return cs.sign(payload)
+}
+
+// sign encodes payload as a compact JWS: header.payload.signature
+func (cs *claimSigner) sign(payload any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": cs.alg, "kid": cs.kid, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64URL(header) + "." + base64URL(body)
+
+	var sig []byte
+	switch k := cs.key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		sig, err = signECDSA(k, []byte(signingInput))
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signingInput))
+	default:
+		err = fmt.Errorf("unsupported key type %T", cs.key)
+	}
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64URL(sig), nil
+}
+
+// signECDSA returns the JWS form of an ECDSA signature: r and s as fixed-size
+// big-endian integers, concatenated
+func signECDSA(k *ecdsa.PrivateKey, signingInput []byte) ([]byte, error) {
+	var digest []byte
+	if k.Curve == elliptic.P384() {
+		sum := sha512.Sum384(signingInput)
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256(signingInput)
+		digest = sum[:]
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+	if err != nil {
+		return nil, err
+	}
+	size := (k.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	return sig, nil
+}
+
+// base64URL encodes b as unpadded base64url, as JOSE requires
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// entitlementsKeyID reports the signer's key ID for /capabilities ("" = disabled)
+func entitlementsKeyID(cs *claimSigner) string {
+	if cs == nil {
+		return ""
+	}
+	return cs.kid
+}
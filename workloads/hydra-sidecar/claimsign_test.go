@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyPEM writes key to a temp file as a PEM block of the given type
+func writeKeyPEM(t *testing.T, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return path
+}
+
+// verifyJWS checks a compact JWS against pub and returns its header and payload
+func verifyJWS(t *testing.T, token string, pub crypto.PublicKey) (map[string]string, map[string]any) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWS has %d segments, want 3: %q", len(parts), token)
+	}
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode segment %q: %v", s, err)
+		}
+		return b
+	}
+	signingInput := []byte(parts[0] + "." + parts[1])
+	sig := decode(parts[2])
+
+	var ok bool
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signingInput)
+		ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		var digest []byte
+		if k.Curve == elliptic.P384() {
+			sum := sha512.Sum384(signingInput)
+			digest = sum[:]
+		} else {
+			sum := sha256.Sum256(signingInput)
+			digest = sum[:]
+		}
+		size := len(sig) / 2
+		ok = ecdsa.Verify(k, digest, new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:]))
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, signingInput, sig)
+	}
+	if !ok {
+		t.Fatalf("signature does not verify")
+	}
+
+	var header map[string]string
+	if err := json.Unmarshal(decode(parts[0]), &header); err != nil {
+		t.Fatalf("header: %v", err)
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(decode(parts[1]), &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	return header, payload
+}
+
+func TestClaimSignerKeyTypes(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	sec1, _ := x509.MarshalECPrivateKey(p256)
+	pkcs8 := func(key any) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	tests := []struct {
+		name      string
+		blockType string
+		der       []byte
+		pub       crypto.PublicKey
+		alg       string
+	}{
+		{"rsa pkcs1", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), &rsaKey.PublicKey, "RS256"},
+		{"rsa pkcs8", "PRIVATE KEY", pkcs8(rsaKey), &rsaKey.PublicKey, "RS256"},
+		{"p256 sec1", "EC PRIVATE KEY", sec1, &p256.PublicKey, "ES256"},
+		{"p384 pkcs8", "PRIVATE KEY", pkcs8(p384), &p384.PublicKey, "ES384"},
+		{"ed25519 pkcs8", "PRIVATE KEY", pkcs8(edKey), edKey.Public(), "EdDSA"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := loadClaimSigner(writeKeyPEM(t, tt.blockType, tt.der), "", "")
+			if err != nil {
+				t.Fatalf("loadClaimSigner: %v", err)
+			}
+			now := time.Unix(1700000000, 0)
+			token, err := signer.entitlements(map[string]any{"plan": "pro", "seats": 5}, "svc-a", now)
+			if err != nil {
+				t.Fatalf("entitlements: %v", err)
+			}
+
+			header, payload := verifyJWS(t, token, tt.pub)
+			wantKid, _ := jwkThumbprint(tt.pub)
+			if header["alg"] != tt.alg || header["kid"] != wantKid || header["typ"] != "JWT" {
+				t.Errorf("header = %v, want alg %s and kid %s", header, tt.alg, wantKid)
+			}
+			if payload["plan"] != "pro" || payload["seats"] != float64(5) || payload["sub"] != "svc-a" || payload["iat"] != float64(now.Unix()) {
+				t.Errorf("payload = %v", payload)
+			}
+		})
+	}
+}
+
+func TestClaimSignerRejectsBadKeys(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p224, _ := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	p224DER, _ := x509.MarshalECPrivateKey(p224)
+
+	for name, path := range map[string]string{
+		"missing file": filepath.Join(t.TempDir(), "missing.pem"),
+		"not pem":      writeKeyPEM(t, "CERTIFICATE", []byte("nope")),
+		"small rsa":    writeKeyPEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(small)),
+		"p224":         writeKeyPEM(t, "EC PRIVATE KEY", p224DER),
+	} {
+		if _, err := loadClaimSigner(path, "", ""); err == nil {
+			t.Errorf("%s: loadClaimSigner succeeded, want error", name)
+		}
+	}
+
+	signer, err := loadClaimSigner("", "", "")
+	if signer != nil || err != nil {
+		t.Errorf("no key file = (%v, %v), want signing disabled", signer, err)
+	}
+}
+
+func TestJWKThumbprintRFC7638(t *testing.T) {
+	// The example key from RFC 7638, section 3.1
+	n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := jwkThumbprint(&rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+		t.Errorf("thumbprint = %s, want %s", got, want)
+	}
+}
+
+func TestTokenHookEntitlementsClaim(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalECPrivateKey(key)
+	signer, err := loadClaimSigner(writeKeyPEM(t, "EC PRIVATE KEY", der), "sidecar-2026", "plan,features")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","plan":"pro","features":["sso","audit"],"entitlements":"forged"}}`)
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		claimSigner:   signer,
+		config:        Config{FlattenClaims: claimValuesFlatten},
+	}
+
+	claims := tokenHookClaims(t, s, "svc-a")
+	token, ok := claims[entitlementsClaimName].(string)
+	if !ok {
+		t.Fatalf("entitlements claim = %v, want a JWS", claims[entitlementsClaimName])
+	}
+	header, payload := verifyJWS(t, token, &key.PublicKey)
+	if header["kid"] != "sidecar-2026" {
+		t.Errorf("kid = %q, want sidecar-2026", header["kid"])
+	}
+	// Only the selected keys are signed, and nested values aren't flattened
+	if _, ok := payload["org_id"]; ok {
+		t.Errorf("payload includes unselected org_id: %v", payload)
+	}
+	if payload["plan"] != "pro" || payload["sub"] != "svc-a" {
+		t.Errorf("payload = %v", payload)
+	}
+	if features, _ := payload["features"].([]any); len(features) != 2 {
+		t.Errorf("features = %v, want both entries", payload["features"])
+	}
+	// The plain claims are still injected as usual
+	if claims["org_id"] != "acme" || claims["features.0"] != "sso" {
+		t.Errorf("claims = %v", claims)
+	}
+}
+
+func TestTokenHookWithoutSigningKey(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","entitlements":"as-is"}}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+
+	claims := tokenHookClaims(t, s, "svc-a")
+	if claims[entitlementsClaimName] != "as-is" {
+		t.Errorf("entitlements = %v, want the metadata value unchanged", claims[entitlementsClaimName])
+	}
+}
@@ -0,0 +1,238 @@
+// Package client is a Go client for the hydra-sidecar admin and sync API.
+//
+// The types mirror the sidecar's JSON models; the sidecar's own models live in
+// package main and can't be imported.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	hydra "github.com/ory/hydra/v2/client"
+)
+
+// ClientData is an OAuth2 client with the sidecar's client_secret_hash.
+// Create and rotate responses carry the plaintext secret in Secret (shown
+// once) and its hash in ClientSecretHash; sync requests need ClientSecretHash.
+type ClientData struct {
+	hydra.Client
+
+	ClientSecretHash string `json:"client_secret_hash,omitempty"`
+	// HashAlgorithm declares ClientSecretHash's format in sync requests
+	// ("pbkdf2" or "bcrypt"); empty means the sidecar's HASHER_ALGORITHM
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
+}
+
+// SyncClientsRequest is the body of a bulk sync
+type SyncClientsRequest struct {
+	Clients []ClientData `json:"clients"`
+	// Network UUID or name (empty = the sidecar's default network)
+	NetworkID string `json:"network_id,omitempty"`
+}
+
+// SyncOptions are the query parameters of a bulk sync
+type SyncOptions struct {
+	// "full" (the default when empty) or "upsert"
+	Mode string
+	// Apply the batch in one transaction, rolled back on failure
+	Atomic bool
+	// Apply a full sync even past the sidecar's MAX_SYNC_DELETE_RATIO
+	Force bool
+	// Keep the last entry for a repeated client ID instead of rejecting the sync
+	LastWins bool
+}
+
+// SyncResult is the response of a bulk sync
+type SyncResult struct {
+	// "success", "partial", "failed", or "rolled_back"
+	Status           string         `json:"status"`
+	RolledBack       bool           `json:"rolled_back,omitempty"`
+	CreatedCount     int            `json:"created_count"`
+	UpdatedCount     int            `json:"updated_count"`
+	DeletedCount     int            `json:"deleted_count"`
+	FailedCount      int            `json:"failed_count"`
+	DurationMS       float64        `json:"duration_ms"`
+	ClientsPerSecond float64        `json:"clients_per_second"`
+	Results          []ClientResult `json:"results"`
+}
+
+// ClientResult is the outcome of one client in a sync
+type ClientResult struct {
+	ClientID string `json:"client_id"`
+	// "upsert" or "delete"
+	Operation string `json:"operation,omitempty"`
+	// "created", "updated", "deleted", or "failed"
+	Status               string  `json:"status"`
+	Error                *string `json:"error,omitempty"`
+	HashAlgorithmChanged bool    `json:"hash_algorithm_changed,omitempty"`
+}
+
+// Error is a non-2xx response from the sidecar, or from Hydra passed through it
+type Error struct {
+	StatusCode int `json:"-"`
+	// Error code, e.g. "not_found" or "conflict"
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	// Clients a sync refused by MAX_SYNC_DELETE_RATIO would have deleted, or
+	// that failed validation in a sync request
+	ClientIDs []string `json:"client_ids,omitempty"`
+	// Every failure of a sync request rejected by validation
+	Errors []ValidationError `json:"errors,omitempty"`
+}
+
+// ValidationError is one failure in a sync request rejected with 400
+type ValidationError struct {
+	// Empty for an entry without a client_id
+	ClientID string `json:"client_id"`
+	Error    string `json:"error"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("hydra-sidecar returned %d: %s", e.StatusCode, e.Description)
+	}
+	return fmt.Sprintf("hydra-sidecar returned %d %s: %s", e.StatusCode, e.Code, e.Description)
+}
+
+// IsNotFound reports whether err is a 404 from the sidecar or Hydra
+func IsNotFound(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode == http.StatusNotFound
+}
+
+// Client calls one sidecar. The zero HTTPClient uses http.DefaultClient.
+type Client struct {
+	// Sidecar address, e.g. http://hydra-sidecar:8080
+	BaseURL string
+	// Sent as "Authorization: Bearer" when set (ADMIN_API_KEY or a scoped key)
+	Token      string
+	HTTPClient *http.Client
+}
+
+// New returns a client for the sidecar at baseURL; token may be empty
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Token: token}
+}
+
+// CreateClient registers a client. The response holds the plaintext secret,
+// which is never returned again.
+func (c *Client) CreateClient(ctx context.Context, data ClientData) (*ClientData, error) {
+	var created ClientData
+	if err := c.do(ctx, http.MethodPost, "/admin/clients", data, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetClient fetches a client by ID
+func (c *Client) GetClient(ctx context.Context, clientID string) (*ClientData, error) {
+	var data ClientData
+	if err := c.do(ctx, http.MethodGet, "/admin/clients/"+url.PathEscape(clientID), nil, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// DeleteClient deletes a client by ID
+func (c *Client) DeleteClient(ctx context.Context, clientID string) error {
+	return c.do(ctx, http.MethodDelete, "/admin/clients/"+url.PathEscape(clientID), nil, nil)
+}
+
+// RotateSecret issues a new secret for the client, expiring at the given
+// Unix time (0 = never)
+func (c *Client) RotateSecret(ctx context.Context, clientID string, expiresAt int64) (*ClientData, error) {
+	var body any
+	if expiresAt > 0 {
+		body = map[string]int64{"client_secret_expires_at": expiresAt}
+	}
+	var rotated ClientData
+	if err := c.do(ctx, http.MethodPost, "/admin/clients/rotate/"+url.PathEscape(clientID), body, &rotated); err != nil {
+		return nil, err
+	}
+	return &rotated, nil
+}
+
+// SyncClients reconciles the network's clients against req. Per-client
+// failures are reported in the result, not as an error.
+func (c *Client) SyncClients(ctx context.Context, req SyncClientsRequest, opts SyncOptions) (*SyncResult, error) {
+	query := url.Values{}
+	if opts.Mode != "" {
+		query.Set("mode", opts.Mode)
+	}
+	if opts.Atomic {
+		query.Set("atomic", "true")
+	}
+	if opts.Force {
+		query.Set("force", "true")
+	}
+	if opts.LastWins {
+		query.Set("duplicates", "last_wins")
+	}
+	path := "/sync/clients"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var result SyncResult
+	if err := c.do(ctx, http.MethodPost, path, req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// do sends body (if non-nil) as JSON and decodes a 2xx response into out
+// (if non-nil). Other responses become an *Error.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(raw, apiErr) != nil || (apiErr.Code == "" && apiErr.Description == "") {
+			apiErr.Description = strings.TrimSpace(string(raw))
+		}
+		return apiErr
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
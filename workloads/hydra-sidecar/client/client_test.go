@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	hydra "github.com/ory/hydra/v2/client"
+)
+
+func TestClientSendsTokenAndDecodes(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Authorization"))
+		switch r.Method {
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodPost:
+			if r.URL.Path == "/sync/clients" {
+				var req SyncClientsRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Clients) != 1 || req.Clients[0].ClientSecretHash != "$2a$hash" {
+					t.Errorf("sync body = %+v (%v), want one client with its hash", req, err)
+				}
+				w.Write([]byte(`{"status":"success","created_count":1,"results":[{"client_id":"svc-a","operation":"upsert","status":"created"}]}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"client_id":"svc-a","client_secret":"plain","client_secret_hash":"$2a$hash"}`))
+		default:
+			w.Write([]byte(`{"client_id":"svc-a","client_secret_hash":"$2a$hash"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL+"/", "k3y")
+	ctx := context.Background()
+	created, err := c.CreateClient(ctx, ClientData{Client: hydra.Client{ID: "svc-a"}})
+	if err != nil || created.Secret != "plain" || created.ClientSecretHash != "$2a$hash" {
+		t.Fatalf("CreateClient = %+v, %v", created, err)
+	}
+	if got, err := c.GetClient(ctx, "svc-a"); err != nil || got.ID != "svc-a" {
+		t.Fatalf("GetClient = %+v, %v", got, err)
+	}
+	if _, err := c.RotateSecret(ctx, "svc-a", 0); err != nil {
+		t.Fatalf("RotateSecret: %v", err)
+	}
+	if err := c.DeleteClient(ctx, "svc-a"); err != nil {
+		t.Fatalf("DeleteClient: %v", err)
+	}
+	result, err := c.SyncClients(ctx, SyncClientsRequest{Clients: []ClientData{{Client: hydra.Client{ID: "svc-a"}, ClientSecretHash: "$2a$hash"}}},
+		SyncOptions{Mode: "upsert", Atomic: true})
+	if err != nil || result.Status != "success" || result.CreatedCount != 1 || len(result.Results) != 1 {
+		t.Fatalf("SyncClients = %+v, %v", result, err)
+	}
+
+	want := []string{
+		"POST /admin/clients Bearer k3y",
+		"GET /admin/clients/svc-a Bearer k3y",
+		"POST /admin/clients/rotate/svc-a Bearer k3y",
+		"DELETE /admin/clients/svc-a Bearer k3y",
+		"POST /sync/clients?atomic=true&mode=upsert Bearer k3y",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("requests = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/clients/gone":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not_found","error_description":"Unable to locate the resource"}`))
+		case "/sync/clients":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":"conflict","error_description":"sync would delete 2 of 3","client_ids":["b","c"],"existing_count":3}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("bad gateway"))
+		}
+	}))
+	defer srv.Close()
+	c := New(srv.URL, "")
+	ctx := context.Background()
+
+	if _, err := c.GetClient(ctx, "gone"); !IsNotFound(err) {
+		t.Errorf("GetClient error = %v, want not found", err)
+	}
+
+	_, err := c.SyncClients(ctx, SyncClientsRequest{}, SyncOptions{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || apiErr.Code != "conflict" || len(apiErr.ClientIDs) != 2 {
+		t.Errorf("SyncClients error = %#v, want the 409 with client_ids", err)
+	}
+
+	err = c.DeleteClient(ctx, "other")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Description != "bad gateway" {
+		t.Errorf("DeleteClient error = %#v, want a 502 with the plain text body", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/ory/hydra/v2/client"
+)
+
+// knownGrantTypes are the grant_types values Hydra supports
+var knownGrantTypes = map[string]bool{
+	"authorization_code": true,
+	"implicit":           true,
+	"refresh_token":      true,
+	"client_credentials": true,
+	"urn:ietf:params:oauth:grant-type:jwt-bearer":  true,
+	"urn:ietf:params:oauth:grant-type:device_code": true,
+}
+
+// clientFieldError is a create request field Hydra would reject
+type clientFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *clientFieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// validateClientFields checks a create request body before it is forwarded,
+// so malformed clients get a field-level 400 instead of a Hydra error:
+// grant_types must be non-empty and known, token_endpoint_auth_method (if
+// set) must be one Hydra accepts, and each redirect URI must be an absolute
+// URL without a fragment (RFC 6749 section 3.1.2).
+func validateClientFields(body []byte) error {
+	var c client.Client
+	if err := json.Unmarshal(body, &c); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return &clientFieldError{Field: typeErr.Field, Message: fmt.Sprintf("must be %s, got %s", typeErr.Type, typeErr.Value)}
+		}
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	if len(c.GrantTypes) == 0 {
+		return &clientFieldError{Field: "grant_types", Message: "is required"}
+	}
+	for _, grantType := range c.GrantTypes {
+		if !knownGrantTypes[grantType] {
+			return &clientFieldError{Field: "grant_types", Message: fmt.Sprintf("unknown grant type %q", grantType)}
+		}
+	}
+
+	// Empty gets AUTH_METHOD_DEFAULTS_JSON or Hydra's client_secret_basic
+	if method := c.TokenEndpointAuthMethod; method != "" && !validAuthMethods[method] {
+		return &clientFieldError{Field: "token_endpoint_auth_method", Message: fmt.Sprintf("unsupported method %q", method)}
+	}
+
+	for i, raw := range c.RedirectURIs {
+		if err := checkRedirectURI(raw); err != nil {
+			return &clientFieldError{Field: fmt.Sprintf("redirect_uris[%d]", i), Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// compileClientIDPattern compiles CLIENT_ID_PATTERN anchored at both ends, so
+// it must match the whole client ID (empty = no pattern)
+func compileClientIDPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// checkClientIDPattern rejects a create request whose client_id doesn't match
+// pattern. A request without client_id lets Hydra generate one, so it passes.
+func checkClientIDPattern(body []byte, pattern *regexp.Regexp) error {
+	if pattern == nil {
+		return nil
+	}
+	var req struct {
+		ClientID string `json:"client_id"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if req.ClientID != "" && !pattern.MatchString(req.ClientID) {
+		return &clientFieldError{Field: "client_id", Message: fmt.Sprintf("%q does not match the required pattern %s", req.ClientID, pattern)}
+	}
+	return nil
+}
+
+// checkRedirectURI reports why a redirect URI is unusable, or nil
+func checkRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q", raw)
+	}
+	if !u.IsAbs() {
+		return fmt.Errorf("%q is not an absolute URL", raw)
+	}
+	// Private-use schemes of native apps (com.example.app:/cb) have no host
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	if u.Fragment != "" || u.RawFragment != "" {
+		return fmt.Errorf("%q must not contain a fragment", raw)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateClientFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string // substring; empty = valid
+	}{
+		{"valid", `{"grant_types":["client_credentials"],"token_endpoint_auth_method":"client_secret_post"}`, ""},
+		{"default auth method", `{"grant_types":["authorization_code","refresh_token"],"redirect_uris":["https://app.example.com/cb","com.example.app:/cb"]}`, ""},
+		{"missing grant types", `{"client_name":"svc"}`, "grant_types: is required"},
+		{"empty grant types", `{"grant_types":[]}`, "grant_types: is required"},
+		{"unknown grant type", `{"grant_types":["client_credential"]}`, `grant_types: unknown grant type "client_credential"`},
+		{"bad auth method", `{"grant_types":["client_credentials"],"token_endpoint_auth_method":"secret"}`, `token_endpoint_auth_method: unsupported method "secret"`},
+		{"relative redirect", `{"grant_types":["authorization_code"],"redirect_uris":["https://ok.example.com","/cb"]}`, "redirect_uris[1]: "},
+		{"redirect without host", `{"grant_types":["authorization_code"],"redirect_uris":["https:///cb"]}`, "has no host"},
+		{"redirect with fragment", `{"grant_types":["authorization_code"],"redirect_uris":["https://app.example.com/cb#x"]}`, "must not contain a fragment"},
+		{"wrong type", `{"grant_types":"client_credentials"}`, "grant_types: must be"},
+		{"invalid JSON", `{`, "invalid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateClientFields([]byte(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateClientFields() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateClientFields() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateClientRejectsInvalidFieldsBeforeHydra(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Hydra called with an invalid client: %s %s", r.Method, r.URL.Path)
+	}))
+	defer hydra.Close()
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+
+	rec := httptest.NewRecorder()
+	s.handleCreateClient(rec, httptest.NewRequest(http.MethodPost, "/admin/clients",
+		strings.NewReader(`{"grant_types":["client_credentials"],"redirect_uris":["not a url"]}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "redirect_uris[0]") {
+		t.Errorf("got %d %s, want 400 naming redirect_uris[0]", rec.Code, rec.Body)
+	}
+}
+
+func TestCheckClientIDPattern(t *testing.T) {
+	pattern, err := compileClientIDPattern(`org-[0-9a-f]{8}`)
+	if err != nil {
+		t.Fatalf("compileClientIDPattern() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"matching", `{"client_id":"org-0123abcd"}`, false},
+		{"generated by Hydra", `{"grant_types":["client_credentials"]}`, false},
+		{"empty ID", `{"client_id":""}`, false},
+		{"mismatch", `{"client_id":"svc-a"}`, true},
+		{"partial match", `{"client_id":"xorg-0123abcd"}`, true},
+		{"trailing text", `{"client_id":"org-0123abcd-extra"}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkClientIDPattern([]byte(tt.body), pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkClientIDPattern() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := checkClientIDPattern([]byte(`{"client_id":"anything"}`), nil); err != nil {
+		t.Errorf("no pattern: checkClientIDPattern() = %v, want nil", err)
+	}
+	if _, err := compileClientIDPattern(`org-(`); err == nil {
+		t.Error("compileClientIDPattern accepted an invalid regex")
+	}
+}
+
+func TestCreateClientRejectsClientIDPatternMismatchBeforeHydra(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Hydra called for a client ID outside the pattern: %s %s", r.Method, r.URL.Path)
+	}))
+	defer hydra.Close()
+	pattern, _ := compileClientIDPattern(`org-[0-9a-f-]{36}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), clientIDPattern: pattern}
+
+	rec := httptest.NewRecorder()
+	s.handleCreateClient(rec, httptest.NewRequest(http.MethodPost, "/admin/clients",
+		strings.NewReader(`{"client_id":"billing-svc","grant_types":["client_credentials"]}`)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "client_id: ") {
+		t.Errorf("got %d %s, want 400 naming client_id", rec.Code, rec.Body)
+	}
+}
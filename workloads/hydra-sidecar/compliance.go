@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// swagger:route GET /admin/clients/noncompliant clients listNoncompliantClients
+//
+// List clients missing required metadata.
+//
+// Returns clients that lack any of the metadata keys in ?require= (comma-separated).
+// A key with a null value counts as missing.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: noncompliantClientsResponse
+//	  400: errorResponse
+//	  500: errorResponse
+func (s *Server) handleNoncompliantClients(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	required := splitList(r.URL.Query().Get("require"))
+	if len(required) == 0 {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "require query parameter is required")
+		return
+	}
+
+	nid, err := s.networkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	rows, err := s.store.GetClientsMissingMetadata(r.Context(), nid, required)
+	if err != nil {
+		log.Printf("Error querying noncompliant clients: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	report := NoncompliantClientsReport{
+		Required: required,
+		Clients:  findNoncompliant(rows, required),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// findNoncompliant returns the clients missing any required metadata key,
+// with the keys each one lacks
+func findNoncompliant(rows []ClientMetadata, required []string) []NoncompliantClient {
+	result := make([]NoncompliantClient, 0)
+	for _, row := range rows {
+		var metadata map[string]any
+		if len(row.Metadata) > 0 {
+			if err := json.Unmarshal([]byte(row.Metadata), &metadata); err != nil {
+				log.Printf("Warning: client %s has invalid metadata JSON: %v", row.ID, err)
+			}
+		}
+
+		var missing []string
+		for _, key := range required {
+			if metadata[key] == nil {
+				missing = append(missing, key)
+			}
+		}
+		if len(missing) > 0 {
+			result = append(result, NoncompliantClient{ClientID: row.ID, MissingKeys: missing})
+		}
+	}
+	return result
+}
+
+// splitList splits a comma-separated list, trimming blanks
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ory/x/sqlxx"
+)
+
+func TestFindNoncompliant(t *testing.T) {
+	rows := []ClientMetadata{
+		{ID: "compliant", Metadata: sqlxx.JSONRawMessage(`{"org_id":"acme","tier":"pro"}`)},
+		{ID: "missing-tier", Metadata: sqlxx.JSONRawMessage(`{"org_id":"acme"}`)},
+		{ID: "null-org", Metadata: sqlxx.JSONRawMessage(`{"org_id":null,"tier":"free"}`)},
+		{ID: "no-metadata"},
+	}
+
+	got := findNoncompliant(rows, []string{"org_id", "tier"})
+	want := []NoncompliantClient{
+		{ClientID: "missing-tier", MissingKeys: []string{"tier"}},
+		{ClientID: "null-org", MissingKeys: []string{"org_id"}},
+		{ClientID: "no-metadata", MissingKeys: []string{"org_id", "tier"}},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findNoncompliant() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" org_id, ,tier ")
+	if !reflect.DeepEqual(got, []string{"org_id", "tier"}) {
+		t.Errorf("splitList() = %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// configFileSettings holds the CONFIG_FILE values by env var name, and
+// configKeysRead the names loadConfig asked for, so unknown file keys (typos)
+// can be rejected. Both are reset by each loadConfig call.
+var (
+	configFileSettings map[string]string
+	configKeysRead     map[string]bool
+)
+
+// loadConfigFile reads a JSON object of settings keyed by env var name, e.g.
+// {"HYDRA_ADMIN_URL": "http://hydra:4445", "USAGE_TRACKING": true}. Strings
+// are used as-is; numbers, booleans, objects, and arrays as their JSON text,
+// so JSON-valued options like CLAIM_TEMPLATES_JSON can be written inline. An
+// empty path loads nothing.
+func loadConfigFile(path string) (map[string]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: want a JSON object of settings: %w", path, err)
+	}
+	settings := make(map[string]string, len(raw))
+	for key, value := range raw {
+		var s string
+		switch {
+		case bytes.Equal(value, []byte("null")):
+			continue
+		case json.Unmarshal(value, &s) == nil:
+			settings[key] = s
+		default:
+			settings[key] = string(value)
+		}
+	}
+	return settings, nil
+}
+
+// lookupSetting returns key's env var, or its CONFIG_FILE value when the
+// env var is unset or empty
+func lookupSetting(key string) string {
+	if configKeysRead != nil {
+		configKeysRead[key] = true
+	}
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return configFileSettings[key]
+}
+
+// checkConfigFileKeys rejects CONFIG_FILE keys that loadConfig never read
+func checkConfigFileKeys(path string) error {
+	var unknown []string
+	for key := range configFileSettings {
+		if !configKeysRead[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return fmt.Errorf("%s: unknown settings %s", path, strings.Join(unknown, ", "))
+	}
+	return nil
+}
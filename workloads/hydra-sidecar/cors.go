@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CORS response headers for browser-based admin tooling
+const (
+	corsAllowMethods  = "GET, POST, PATCH, DELETE, OPTIONS"
+	corsAllowHeaders  = "Authorization, Content-Type, " + networkIDHeader + ", " + idempotencyKeyHeader
+	corsExposeHeaders = "Link, " + idempotentReplayedHeader // next page of GET /admin/clients, replayed creates
+	corsMaxAge        = "600"
+)
+
+// corsOrigins is the set of origins allowed to call the admin routes from a
+// browser (CORS_ALLOWED_ORIGINS). "*" allows any origin.
+type corsOrigins map[string]bool
+
+// newCORSOrigins parses a comma-separated origin list; nil disables CORS
+func newCORSOrigins(list string) corsOrigins {
+	origins := splitList(list)
+	if len(origins) == 0 {
+		return nil
+	}
+	o := make(corsOrigins, len(origins))
+	for _, origin := range origins {
+		o[strings.TrimSuffix(origin, "/")] = true
+	}
+	return o
+}
+
+// allows reports whether a request Origin may read admin responses
+func (o corsOrigins) allows(origin string) bool {
+	return origin != "" && (o["*"] || o[origin])
+}
+
+// corsMiddleware adds CORS headers to admin and sync responses for allowed
+// origins and answers their preflight requests. It wraps adminAuth because
+// browsers send preflights without the Authorization header. The allowed
+// origin is echoed rather than answered with "*", so caches must vary on
+// Origin. Nil origins disables CORS.
+func corsMiddleware(origins corsOrigins, next http.Handler) http.Handler {
+	if origins == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !requiresAdminAuth(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if !origins.allows(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		next.ServeHTTP(w, r)
+	})
+}
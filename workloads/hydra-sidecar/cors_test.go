@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORSPreflightBypassesAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := corsMiddleware(newCORSOrigins("https://admin.example.com, http://localhost:3000/"), adminAuth(apiKeys{"s3cret": ""}, ok))
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodOptions, "/admin/clients", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		r.Header.Set("Access-Control-Request-Headers", "authorization,content-type,idempotency-key")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := preflight("http://localhost:3000")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("Allow-Origin = %q, want the echoed origin", got)
+	}
+	if rec.Header().Get("Access-Control-Allow-Methods") == "" || rec.Header().Get("Access-Control-Allow-Headers") == "" {
+		t.Errorf("preflight headers = %v", rec.Header())
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(got, "Idempotency-Key") {
+		t.Errorf("Allow-Headers = %q, want Idempotency-Key allowed", got)
+	}
+
+	// Unlisted origins fall through to auth and get no CORS headers
+	rec = preflight("https://evil.example.com")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("unlisted origin: %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := corsMiddleware(newCORSOrigins("*"), adminAuth(apiKeys{"s3cret": ""}, ok))
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/clients", nil)
+	r.Header.Set("Origin", "https://admin.example.com")
+	r.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.com" {
+		t.Errorf("got %d %v, want 200 with the origin echoed", rec.Code, rec.Header())
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "Link, Idempotent-Replayed" {
+		t.Errorf("Expose-Headers = %q, want Link and Idempotent-Replayed", got)
+	}
+
+	// Non-admin routes are untouched
+	r = httptest.NewRequest(http.MethodPost, "/token-hook", nil)
+	r.Header.Set("Origin", "https://admin.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("token hook got CORS headers: %v", rec.Header())
+	}
+}
+
+func TestCORSDisabledByDefault(t *testing.T) {
+	if newCORSOrigins("") != nil {
+		t.Fatal("empty CORS_ALLOWED_ORIGINS should disable CORS")
+	}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := corsMiddleware(nil, ok)
+	r := httptest.NewRequest(http.MethodGet, "/admin/clients", nil)
+	r.Header.Set("Origin", "https://admin.example.com")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	if len(rec.Header()) != 0 {
+		t.Errorf("headers = %v, want none", rec.Header())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gofrs/uuid"
+)
+
+// clientCounter counts the clients in a network
+type clientCounter interface {
+	CountClients(ctx context.Context, nid uuid.UUID) (int, error)
+}
+
+// swagger:route GET /admin/clients/count clients countClients
+//
+// Count clients in a network.
+//
+// Returns the number of clients in the network named by ?network_id= (else X-Network-ID,
+// else the default network) without listing them.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: clientCountResponse
+//	  403: errorResponse
+//	  500: errorResponse
+func (s *Server) handleCountClients(w http.ResponseWriter, r *http.Request) {
+	s.serveCountClients(w, r, s.store)
+}
+
+// serveCountClients implements handleCountClients against the given store
+func (s *Server) serveCountClients(w http.ResponseWriter, r *http.Request, db clientCounter) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	nid, err := s.networkFor(r, r.URL.Query().Get("network_id"))
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	count, err := db.CountClients(r.Context(), nid)
+	if err != nil {
+		log.Printf("Error counting clients: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(ClientCount{Count: count, NetworkID: nid.String()}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
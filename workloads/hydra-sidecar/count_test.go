@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+// fakeClientCounter returns a fixed count per network
+type fakeClientCounter map[uuid.UUID]int
+
+func (f fakeClientCounter) CountClients(_ context.Context, nid uuid.UUID) (int, error) {
+	count, ok := f[nid]
+	if !ok {
+		return 0, errors.New("unknown network")
+	}
+	return count, nil
+}
+
+func TestServeCountClients(t *testing.T) {
+	defaultNID := uuid.Must(uuid.NewV4())
+	otherNID := uuid.Must(uuid.NewV4())
+	s := &Server{networkID: defaultNID}
+	db := fakeClientCounter{defaultNID: 3, otherNID: 7}
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		want   ClientCount
+	}{
+		{"default network", "/admin/clients/count", ClientCount{Count: 3, NetworkID: defaultNID.String()}},
+		{"network_id param", "/admin/clients/count?network_id=" + otherNID.String(), ClientCount{Count: 7, NetworkID: otherNID.String()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.serveCountClients(rec, httptest.NewRequest(http.MethodGet, tc.target, nil), db)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+			}
+			var got ClientCount
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("body = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServeCountClientsErrors(t *testing.T) {
+	s := &Server{networkID: uuid.Must(uuid.NewV4())}
+
+	rec := httptest.NewRecorder()
+	s.serveCountClients(rec, httptest.NewRequest(http.MethodPost, "/admin/clients/count", nil), fakeClientCounter{})
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.serveCountClients(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/count", nil), fakeClientCounter{})
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("store failure status = %d, want 500", rec.Code)
+	}
+}
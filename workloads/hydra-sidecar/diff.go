@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gofrs/uuid"
+)
+
+// diffStore is the read-only subset of Store used to compute sync drift
+type diffStore interface {
+	GetAllClientIDs(ctx context.Context, nid uuid.UUID) ([]string, error)
+	GetClientSecretHashes(ctx context.Context, nid uuid.UUID) (map[string]string, error)
+}
+
+// swagger:route POST /sync/clients/diff clients syncClientsDiff
+//
+// Report drift between a desired client set and the database.
+//
+// Accepts the same body as /sync/clients and reports which client IDs are missing from the
+// database, extra in the database, or stored with a different secret hash. Nothing is written.
+// The network is selected like /sync/clients: network_id in the body, else X-Network-ID,
+// else the default network.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: syncDiffResponse
+//	  400: errorResponse
+//	  500: errorResponse
+func (s *Server) handleSyncDiff(w http.ResponseWriter, r *http.Request) {
+	s.serveSyncDiff(w, r, s.store)
+}
+
+// serveSyncDiff implements handleSyncDiff against the given store
+func (s *Server) serveSyncDiff(w http.ResponseWriter, r *http.Request, db diffStore) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var req SyncClientsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		log.Printf("Error decoding sync diff request: %v", err)
+		writeBodyError(w, err, "invalid JSON")
+		return
+	}
+
+	nid, err := s.networkFor(r, req.NetworkID)
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	diff, err := diffClients(r.Context(), db, req.Clients, nid)
+	if err != nil {
+		log.Printf("Error computing sync diff: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error during diff")
+		return
+	}
+	log.Printf("Sync diff completed: missing=%d, extra=%d, hash_mismatch=%d",
+		len(diff.Missing), len(diff.Extra), len(diff.HashMismatch))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(diff); err != nil {
+		log.Printf("Error encoding sync diff: %v", err)
+	}
+}
+
+// diffClients compares the desired clients against the network's stored
+// clients. Desired hashes come from client_secret_hash, as in a sync.
+func diffClients(ctx context.Context, db diffStore, desired []ClientData, nid uuid.UUID) (*SyncDiff, error) {
+	existingIDs, err := db.GetAllClientIDs(ctx, nid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing clients: %w", err)
+	}
+	storedHashes, err := db.GetClientSecretHashes(ctx, nid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing client secrets: %w", err)
+	}
+
+	existing := make(map[string]bool, len(existingIDs))
+	for _, id := range existingIDs {
+		existing[id] = true
+	}
+
+	diff := &SyncDiff{Missing: []string{}, Extra: []string{}, HashMismatch: []string{}}
+	wanted := make(map[string]bool, len(desired))
+	for _, c := range desired {
+		wanted[c.ID] = true
+		switch {
+		case !existing[c.ID]:
+			diff.Missing = append(diff.Missing, c.ID)
+		case storedHashes[c.ID] != c.ClientSecretHash:
+			diff.HashMismatch = append(diff.HashMismatch, c.ID)
+		}
+	}
+	for _, id := range existingIDs {
+		if !wanted[id] {
+			diff.Extra = append(diff.Extra, id)
+		}
+	}
+
+	sort.Strings(diff.Missing)
+	sort.Strings(diff.Extra)
+	sort.Strings(diff.HashMismatch)
+	diff.InSync = len(diff.Missing) == 0 && len(diff.Extra) == 0 && len(diff.HashMismatch) == 0
+	return diff, nil
+}
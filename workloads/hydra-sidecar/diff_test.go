@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/ory/hydra/v2/client"
+)
+
+func TestSyncDiffReportsDrift(t *testing.T) {
+	w := newFakeClientWriter()
+	w.clients["same"] = client.Client{ID: "same", Secret: "hash-same"}
+	w.clients["changed"] = client.Client{ID: "changed", Secret: "hash-old"}
+	w.clients["extra"] = client.Client{ID: "extra", Secret: "hash-extra"}
+
+	s := &Server{networkID: uuid.Must(uuid.NewV4())}
+	body := `{"clients":[
+		{"client_id":"same","client_secret_hash":"hash-same"},
+		{"client_id":"changed","client_secret_hash":"hash-new"},
+		{"client_id":"missing","client_secret_hash":"hash-missing"}]}`
+	rec := httptest.NewRecorder()
+	s.serveSyncDiff(rec, httptest.NewRequest(http.MethodPost, "/sync/clients/diff", strings.NewReader(body)), w)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	var diff SyncDiff
+	if err := json.Unmarshal(rec.Body.Bytes(), &diff); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := SyncDiff{Missing: []string{"missing"}, Extra: []string{"extra"}, HashMismatch: []string{"changed"}}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff = %+v, want %+v", diff, want)
+	}
+
+	// Nothing was written
+	if len(w.clients) != 3 || w.clients["changed"].Secret != "hash-old" {
+		t.Errorf("store mutated: %+v", w.clients)
+	}
+}
+
+func TestSyncDiffInSync(t *testing.T) {
+	w := newFakeClientWriter()
+	w.clients["a"] = client.Client{ID: "a", Secret: "hash-a"}
+
+	diff, err := diffClients(t.Context(), w, []ClientData{{Client: client.Client{ID: "a"}, ClientSecretHash: "hash-a"}}, uuid.Nil)
+	if err != nil {
+		t.Fatalf("diffClients() error = %v", err)
+	}
+	if !diff.InSync || len(diff.Missing)+len(diff.Extra)+len(diff.HashMismatch) != 0 {
+		t.Errorf("diff = %+v, want in sync", diff)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "context"
+
+// startSync registers an in-flight sync and returns the context it runs
+// under: the request's values, cancelled when shutdown begins but not when the
+// caller disconnects, so a sync is only ever stopped at a phase boundary. Call
+// done when the sync returns.
+func (s *Server) startSync(ctx context.Context) (syncCtx context.Context, done func()) {
+	syncCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if s.shuttingDown == nil {
+		return syncCtx, cancel
+	}
+
+	s.syncsMu.Lock()
+	defer s.syncsMu.Unlock()
+	if s.shuttingDown.Err() != nil {
+		// Too late to be drained; the sync stops at its first checkpoint
+		cancel()
+		return syncCtx, cancel
+	}
+	s.syncs.Add(1)
+	stop := context.AfterFunc(s.shuttingDown, cancel)
+	return syncCtx, func() {
+		stop()
+		cancel()
+		s.syncs.Done()
+	}
+}
+
+// waitForSyncs waits until every in-flight sync has returned, or ctx ends,
+// and reports whether they all returned. Call it after shuttingDown is
+// cancelled, so no sync can register while it waits.
+func (s *Server) waitForSyncs(ctx context.Context) bool {
+	// Let a registration already holding the lock finish its Add first
+	s.syncsMu.Lock()
+	s.syncsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.syncs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
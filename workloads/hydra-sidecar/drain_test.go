@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWaitForSyncsDrainsInFlightSyncs(t *testing.T) {
+	shuttingDown, beginShutdown := context.WithCancel(context.Background())
+	s := &Server{shuttingDown: shuttingDown}
+
+	reqCtx, disconnect := context.WithCancel(context.Background())
+	syncCtx, done := s.startSync(reqCtx)
+	disconnect()
+	if syncCtx.Err() != nil {
+		t.Fatal("caller disconnect cancelled the sync")
+	}
+
+	beginShutdown()
+	select {
+	case <-syncCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not cancel the sync")
+	}
+
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if s.waitForSyncs(timeout) {
+		t.Error("waitForSyncs returned true with a sync still running")
+	}
+
+	done()
+	if !s.waitForSyncs(context.Background()) {
+		t.Error("waitForSyncs returned false after the sync finished")
+	}
+
+	// A sync starting after shutdown began is cancelled and not waited for
+	lateCtx, lateDone := s.startSync(context.Background())
+	defer lateDone()
+	if lateCtx.Err() == nil {
+		t.Error("sync started after shutdown is not cancelled")
+	}
+	if !s.waitForSyncs(context.Background()) {
+		t.Error("waitForSyncs waited for a sync started after shutdown")
+	}
+}
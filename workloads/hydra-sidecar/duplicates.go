@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// clientNetworkStore lists client IDs with the network each one lives in
+type clientNetworkStore interface {
+	GetCrossNetworkClientIDs(ctx context.Context) ([]ClientNetwork, error)
+}
+
+// swagger:route GET /admin/clients/cross-network-duplicates clients listCrossNetworkDuplicates
+//
+// List client IDs registered in more than one network.
+//
+// Scans every network, so it requires an unscoped API key.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: crossNetworkDuplicatesResponse
+//	  403: errorResponse
+//	  500: errorResponse
+func (s *Server) handleCrossNetworkDuplicates(w http.ResponseWriter, r *http.Request) {
+	s.serveCrossNetworkDuplicates(w, r, s.store)
+}
+
+// serveCrossNetworkDuplicates implements handleCrossNetworkDuplicates against the given store
+func (s *Server) serveCrossNetworkDuplicates(w http.ResponseWriter, r *http.Request, db clientNetworkStore) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	// The report spans every network, so network-scoped keys can't read it
+	if networkScope(r.Context()) != "" {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, "cross-network report requires an unscoped API key")
+		return
+	}
+
+	rows, err := db.GetCrossNetworkClientIDs(r.Context())
+	if err != nil {
+		log.Printf("Error querying cross-network duplicates: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	report := CrossNetworkDuplicatesReport{Duplicates: findCrossNetworkDuplicates(rows)}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// findCrossNetworkDuplicates groups rows sorted by client ID and keeps the
+// IDs seen in more than one distinct network
+func findCrossNetworkDuplicates(rows []ClientNetwork) []CrossNetworkDuplicate {
+	result := make([]CrossNetworkDuplicate, 0)
+	for i := 0; i < len(rows); {
+		id := rows[i].ID
+		var networks []string
+		seen := make(map[string]bool)
+		for ; i < len(rows) && rows[i].ID == id; i++ {
+			nid := rows[i].NID.String()
+			if !seen[nid] {
+				seen[nid] = true
+				networks = append(networks, nid)
+			}
+		}
+		if len(networks) > 1 {
+			result = append(result, CrossNetworkDuplicate{ClientID: id, Networks: networks})
+		}
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+// fakeNetworkClients holds client IDs per network, standing in for hydra_client
+type fakeNetworkClients map[uuid.UUID][]string
+
+func (f fakeNetworkClients) GetCrossNetworkClientIDs(context.Context) ([]ClientNetwork, error) {
+	var rows []ClientNetwork
+	for nid, ids := range f {
+		for _, id := range ids {
+			rows = append(rows, ClientNetwork{ID: id, NID: nid})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].ID != rows[j].ID {
+			return rows[i].ID < rows[j].ID
+		}
+		return rows[i].NID.String() < rows[j].NID.String()
+	})
+	return rows, nil
+}
+
+func TestCrossNetworkDuplicatesReportsSharedID(t *testing.T) {
+	netA := uuid.Must(uuid.FromString("00000000-0000-0000-0000-00000000000a"))
+	netB := uuid.Must(uuid.FromString("00000000-0000-0000-0000-00000000000b"))
+	db := fakeNetworkClients{
+		netA: {"shared", "only-a"},
+		netB: {"shared", "only-b"},
+	}
+
+	rec := httptest.NewRecorder()
+	(&Server{}).serveCrossNetworkDuplicates(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/cross-network-duplicates", nil), db)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var report CrossNetworkDuplicatesReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(report.Duplicates) != 1 {
+		t.Fatalf("duplicates = %+v, want only shared", report.Duplicates)
+	}
+	got := report.Duplicates[0]
+	if got.ClientID != "shared" || len(got.Networks) != 2 || got.Networks[0] != netA.String() || got.Networks[1] != netB.String() {
+		t.Errorf("duplicate = %+v, want shared in %s and %s", got, netA, netB)
+	}
+}
+
+func TestCrossNetworkDuplicatesRejectsScopedKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/admin/clients/cross-network-duplicates", nil)
+	req = req.WithContext(context.WithValue(req.Context(), networkScopeKey{}, "tenant-a"))
+
+	rec := httptest.NewRecorder()
+	(&Server{}).serveCrossNetworkDuplicates(rec, req, fakeNetworkClients{})
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", rec.Code)
+	}
+}
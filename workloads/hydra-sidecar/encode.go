@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// encoderPool reuses JSON encoders and their buffers for hot-path responses.
+// Buffers that grew past maxRetained are dropped instead of pooled so one
+// large response doesn't pin memory. A nil pool encodes without reuse.
+type encoderPool struct {
+	pool        sync.Pool
+	maxRetained int
+}
+
+type pooledEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// newEncoderPool creates a pool; maxRetained <= 0 disables pooling
+func newEncoderPool(maxRetained int) *encoderPool {
+	if maxRetained <= 0 {
+		return nil
+	}
+	p := &encoderPool{maxRetained: maxRetained}
+	p.pool.New = func() any {
+		pe := &pooledEncoder{}
+		pe.enc = json.NewEncoder(&pe.buf)
+		return pe
+	}
+	return p
+}
+
+// writeJSON encodes v fully before writing anything, so an encoding error is
+// answered with a 500 instead of a truncated 200. The returned error is for
+// logging only; the response has already been handled.
+func (p *encoderPool) writeJSON(w http.ResponseWriter, v any) error {
+	if p == nil {
+		data, err := json.Marshal(v)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(append(data, '\n'))
+		return err
+	}
+
+	pe := p.pool.Get().(*pooledEncoder)
+	defer func() {
+		if pe.buf.Cap() <= p.maxRetained {
+			pe.buf.Reset()
+			p.pool.Put(pe)
+		}
+	}()
+
+	if err := pe.enc.Encode(v); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err := w.Write(pe.buf.Bytes())
+	return err
+}
+
+// Error codes in APIError bodies
+const (
+	errCodeInvalidRequest   = "invalid_request"
+	errCodeUnauthorized     = "unauthorized"
+	errCodeForbidden        = "forbidden"
+	errCodeNotFound         = "not_found"
+	errCodeMethodNotAllowed = "method_not_allowed"
+	errCodeConflict         = "conflict"
+	errCodePayloadTooLarge  = "payload_too_large"
+	errCodeTooManyRequests  = "too_many_requests"
+	errCodeInternal         = "internal_error"
+	errCodeUpstream         = "upstream_error" // Hydra unreachable or misbehaving
+	errCodeUnavailable      = "unavailable"    // shutting down
+)
+
+// writeJSONError answers with an APIError body. Every 4xx/5xx the sidecar
+// produces itself goes through here; Hydra's own error bodies are passed
+// through unchanged and probes stay plain text.
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIError{Error: code, ErrorDescription: message})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestEncoderPoolConcurrent(t *testing.T) {
+	p := newEncoderPool(64 << 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			want := fmt.Sprintf("svc-%d", i)
+			resp := TokenHookResponse{}
+			resp.Session.AccessToken = map[string]any{"client": want}
+
+			rec := httptest.NewRecorder()
+			if err := p.writeJSON(rec, resp); err != nil {
+				t.Errorf("writeJSON() error = %v", err)
+				return
+			}
+			var got TokenHookResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Errorf("decode: %v (body %q)", err, rec.Body.String())
+				return
+			}
+			if got.Session.AccessToken["client"] != want {
+				t.Errorf("client = %v, want %s (buffer shared across requests?)", got.Session.AccessToken["client"], want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestEncoderPoolEncodeError(t *testing.T) {
+	for _, p := range []*encoderPool{newEncoderPool(1024), newEncoderPool(0)} {
+		rec := httptest.NewRecorder()
+		if err := p.writeJSON(rec, map[string]any{"bad": math.NaN()}); err == nil {
+			t.Error("writeJSON() error = nil, want encode error")
+		}
+		if rec.Code != http.StatusInternalServerError {
+			t.Errorf("status = %d, want 500", rec.Code)
+		}
+	}
+}
+
+// discardWriter is a ResponseWriter that allocates nothing per write
+type discardWriter struct{ h http.Header }
+
+func (d *discardWriter) Header() http.Header         { return d.h }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(int)             {}
+
+func benchmarkTokenHookResponse() TokenHookResponse {
+	resp := TokenHookResponse{}
+	resp.Session.AccessToken = map[string]any{"org_id": "acme", "tier": "pro", "env": "prod"}
+	return resp
+}
+
+func BenchmarkTokenHookEncodePooled(b *testing.B) {
+	p := newEncoderPool(64 << 10)
+	resp := benchmarkTokenHookResponse()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := &discardWriter{h: http.Header{}}
+		for pb.Next() {
+			if err := p.writeJSON(w, resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkTokenHookEncodeFresh is the previous approach: a new encoder per response
+func BenchmarkTokenHookEncodeFresh(b *testing.B) {
+	resp := benchmarkTokenHookResponse()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		w := &discardWriter{h: http.Header{}}
+		for pb.Next() {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestHandlerErrorsAreJSON(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		req      *http.Request
+		status   int
+		wantCode string
+	}{
+		{"wrong method", s.handleCreateClient, httptest.NewRequest(http.MethodPut, "/admin/clients", nil), http.StatusMethodNotAllowed, errCodeMethodNotAllowed},
+		{"missing client_id", s.handleRotateClient, httptest.NewRequest(http.MethodPost, "/admin/clients/rotate/", nil), http.StatusBadRequest, errCodeInvalidRequest},
+		{"usage disabled", func(w http.ResponseWriter, r *http.Request) { s.getClientUsage(w, r, "svc-a") },
+			httptest.NewRequest(http.MethodGet, "/admin/clients/svc-a/usage", nil), http.StatusNotFound, errCodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler(rec, tt.req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var body APIError
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode %q: %v", rec.Body.String(), err)
+			}
+			if body.Error != tt.wantCode || body.ErrorDescription == "" {
+				t.Errorf("body = %+v, want error %q with a description", body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestDeleteClientPassesThroughHydraErrorAsJSON(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error":"conflict"}`))
+	}))
+	t.Cleanup(hydra.Close)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: uuid.Must(uuid.NewV4())}
+
+	rec := httptest.NewRecorder()
+	s.deleteClient(rec, httptest.NewRequest(http.MethodDelete, "/admin/clients/c1", nil), "c1")
+	if rec.Code != http.StatusConflict || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("status = %d, Content-Type = %q, want 409 application/json", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Which side wins when CLAIM_ENRICHMENT_URL returns a claim the metadata also has
+const (
+	enrichmentPrecedenceMetadata = "metadata"
+	enrichmentPrecedenceRemote   = "remote"
+)
+
+// maxEnrichmentBody caps how much of an enrichment response is read
+const maxEnrichmentBody = 64 << 10
+
+// claimEnricher fetches extra claims for a client from CLAIM_ENRICHMENT_URL
+// (e.g. live subscription status kept outside Hydra). It fails open: any
+// error logs a warning and the token is issued without remote claims.
+// A nil enricher fetches nothing.
+type claimEnricher struct {
+	url        string
+	timeout    time.Duration
+	remoteWins bool
+	httpClient *http.Client
+}
+
+// newClaimEnricher returns nil when url is empty (enrichment disabled)
+func newClaimEnricher(url string, timeout time.Duration, precedence string) *claimEnricher {
+	if url == "" {
+		return nil
+	}
+	return &claimEnricher{
+		url:        url,
+		timeout:    timeout,
+		remoteWins: precedence == enrichmentPrecedenceRemote,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// start fetches clientID's remote claims in the background, so the call
+// overlaps the Hydra lookup, and returns a func that waits for them
+// (nil on failure)
+func (e *claimEnricher) start(ctx context.Context, clientID string) func() map[string]any {
+	if e == nil || clientID == "" {
+		return func() map[string]any { return nil }
+	}
+	done := make(chan map[string]any, 1)
+	go func() {
+		claims, err := e.fetch(ctx, clientID)
+		if err != nil {
+			log.Printf("Warning: Claim enrichment failed for client %s: %v, continuing without remote claims", clientID, err)
+		}
+		done <- claims
+	}()
+	return func() map[string]any { return <-done }
+}
+
+// fetch POSTs {"client_id": ...} to the enrichment URL and decodes the JSON
+// object it answers with
+func (e *claimEnricher) fetch(ctx context.Context, clientID string) (map[string]any, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]string{"client_id": clientID})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var claims map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEnrichmentBody)).Decode(&claims); err != nil {
+		return nil, fmt.Errorf("response is not a JSON object: %w", err)
+	}
+	return claims, nil
+}
+
+// merge combines metadata claims with remote claims, keeping the metadata
+// value for a claim both have unless CLAIM_ENRICHMENT_PRECEDENCE=remote
+func (e *claimEnricher) merge(metadata, remote map[string]any) map[string]any {
+	if len(remote) == 0 {
+		return metadata
+	}
+	merged := make(map[string]any, len(metadata)+len(remote))
+	for key, value := range remote {
+		merged[key] = value
+	}
+	for key, value := range metadata {
+		if _, both := remote[key]; both && e.remoteWins {
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
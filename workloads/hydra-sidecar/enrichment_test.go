@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newFakeEnrichment answers every POST with body after delay, recording the
+// client IDs it was asked about
+func newFakeEnrichment(t *testing.T, status int, body string, delay time.Duration) (*httptest.Server, *[]string) {
+	t.Helper()
+	var asked []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ClientID string `json:"client_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		asked = append(asked, req.ClientID)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &asked
+}
+
+func TestTokenHookClaimEnrichment(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","plan":"free"}}`)
+	remote, asked := newFakeEnrichment(t, http.StatusOK, `{"plan":"pro","subscription":"active"}`, 0)
+
+	for precedence, wantPlan := range map[string]string{
+		enrichmentPrecedenceMetadata: "free",
+		enrichmentPrecedenceRemote:   "pro",
+	} {
+		s := &Server{
+			hydraAdminURL:  hydra.URL,
+			httpClient:     hydra.Client(),
+			claimEnricher:  newClaimEnricher(remote.URL, time.Second, precedence),
+			claimNamespace: "https://acme.example/",
+		}
+		claims := tokenHookClaims(t, s, "svc-a")
+		// Remote claims go through the same steps as metadata, namespacing included
+		if claims["https://acme.example/subscription"] != "active" || claims["https://acme.example/org_id"] != "acme" {
+			t.Errorf("%s: claims = %v, want metadata and remote claims", precedence, claims)
+		}
+		if got := claims["https://acme.example/plan"]; got != wantPlan {
+			t.Errorf("%s: plan = %v, want %s", precedence, got, wantPlan)
+		}
+	}
+	if len(*asked) != 2 || (*asked)[0] != "svc-a" {
+		t.Errorf("enrichment asked about %v, want svc-a twice", *asked)
+	}
+}
+
+func TestTokenHookClaimEnrichmentFailsOpen(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme"}}`)
+	failing, _ := newFakeEnrichment(t, http.StatusInternalServerError, `{"plan":"pro"}`, 0)
+	notObject, _ := newFakeEnrichment(t, http.StatusOK, `["plan"]`, 0)
+	slow, _ := newFakeEnrichment(t, http.StatusOK, `{"plan":"pro"}`, time.Second)
+
+	for name, remote := range map[string]*httptest.Server{"error status": failing, "not an object": notObject, "too slow": slow} {
+		s := &Server{
+			hydraAdminURL: hydra.URL,
+			httpClient:    hydra.Client(),
+			claimEnricher: newClaimEnricher(remote.URL, 50*time.Millisecond, enrichmentPrecedenceMetadata),
+		}
+		start := time.Now()
+		claims := tokenHookClaims(t, s, "svc-a")
+		if claims["org_id"] != "acme" || claims["plan"] != nil {
+			t.Errorf("%s: claims = %v, want metadata only", name, claims)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("%s: token hook took %s, want the enrichment timeout to cut it short", name, elapsed)
+		}
+	}
+}
+
+func TestTokenHookClaimEnrichmentWithoutHydra(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer hydra.Close()
+	remote, _ := newFakeEnrichment(t, http.StatusOK, `{"subscription":"active"}`, 0)
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		claimEnricher: newClaimEnricher(remote.URL, time.Second, enrichmentPrecedenceMetadata),
+	}
+	if claims := tokenHookClaims(t, s, "svc-a"); claims["subscription"] != "active" {
+		t.Errorf("claims = %v, want the remote claim without Hydra metadata", claims)
+	}
+}
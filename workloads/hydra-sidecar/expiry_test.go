@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// newExpiryHydra serves GET/PATCH /admin/clients/svc-a with a stored expiry
+func newExpiryHydra(t *testing.T, stored int64) (*httptest.Server, func() (int64, int)) {
+	t.Helper()
+	var mu sync.Mutex
+	patches := 0
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPatch {
+			var body struct {
+				ClientSecretExpiresAt int64 `json:"client_secret_expires_at"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			stored = body.ClientSecretExpiresAt
+			patches++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"client_id": "svc-a", "client_secret_expires_at": stored})
+	}))
+	t.Cleanup(hydra.Close)
+	return hydra, func() (int64, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return stored, patches
+	}
+}
+
+func TestEnsureClientExpirationConfirmsPersistedValue(t *testing.T) {
+	hydra, state := newExpiryHydra(t, 1735689600)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+
+	if err := s.ensureClientExpiration(context.Background(), "svc-a", 1735689600); err != nil {
+		t.Fatalf("ensureClientExpiration() error = %v", err)
+	}
+	if _, patches := state(); patches != 0 {
+		t.Errorf("patched %d times, want 0 when Hydra kept the expiry", patches)
+	}
+}
+
+func TestEnsureClientExpirationPatchesDroppedValue(t *testing.T) {
+	hydra, state := newExpiryHydra(t, 0)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+
+	if err := s.ensureClientExpiration(context.Background(), "svc-a", 1735689600); err != nil {
+		t.Fatalf("ensureClientExpiration() error = %v", err)
+	}
+	if stored, patches := state(); stored != 1735689600 || patches != 1 {
+		t.Errorf("stored %d after %d patches, want 1735689600 after 1", stored, patches)
+	}
+}
+
+func TestSecretExpiresAt(t *testing.T) {
+	tests := map[string]int64{
+		`{"client_secret_expires_at":1735689600}`: 1735689600,
+		`{"client_name":"svc"}`:                   0,
+		`{"client_secret_expires_at":"soon"}`:     0,
+		``:                                        0,
+	}
+	for body, want := range tests {
+		if got := secretExpiresAt([]byte(body)); got != want {
+			t.Errorf("secretExpiresAt(%s) = %d, want %d", body, got, want)
+		}
+	}
+}
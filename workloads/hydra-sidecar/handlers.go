@@ -1,19 +1,27 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/ory/hydra/v2/client"
 	"github.com/ory/x/sqlxx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Server holds the HTTP server dependencies
@@ -21,8 +29,127 @@ type Server struct {
 	store           *Store
 	hydraAdminURL   string
 	hasherAlgorithm string
+	bcryptCost      int // expected bcrypt cost (0 = not checked)
+	pbkdf2Iter      int // expected pbkdf2 iterations (0 = not checked)
 	networkID       uuid.UUID
 	httpClient      *http.Client
+
+	// Guards networkID, the default network (uuid.Nil = not yet resolved)
+	networkMu sync.RWMutex
+
+	// usage records token issuance per client (nil when tracking is disabled)
+	usage *UsageRecorder
+
+	// Maximum client lifetime enforced on create (0 = unlimited)
+	maxClientLifetime  time.Duration
+	clientLifetimeMode string
+
+	// Computed claims from CLAIM_TEMPLATES_JSON
+	claimTemplates claimTemplates
+
+	// Per-grant-type token_endpoint_auth_method defaults (nil = client_secret_basic)
+	authMethodDefaults authMethodDefaults
+
+	// Minimum plaintext secret length accepted from Hydra on rotation
+	minSecretLength     int
+	minSecretLengthMode string
+
+	// Effective configuration (served redacted by /debug/config)
+	config Config
+	// Hydra version detected at startup (empty if unknown)
+	hydraVersion string
+	// When the process started, for /info uptime
+	startedAt time.Time
+
+	// Shared secret for verifying token hook signatures (empty = no verification)
+	hookSecret string
+
+	// Per-call retries of Hydra Admin API requests, capped across all requests by the budget
+	hydraRetry  retryPolicy
+	retryBudget *retryBudget
+
+	// Stops Hydra Admin calls while Hydra is failing (nil = disabled); wraps httpClient's transport
+	hydraBreaker *circuitBreaker
+
+	// Allowed metadata keys, types, and enums (nil = no validation)
+	metadataSchema metadataSchema
+	// Client IDs a create may request (nil = CLIENT_ID_PATTERN unset)
+	clientIDPattern *regexp.Regexp
+
+	// Failed operations tolerated by an atomic sync before it rolls back
+	syncMaxFailures int
+	// Clients a best-effort sync upserts or deletes in parallel
+	syncConcurrency int
+
+	// Token hook client info cache (nil = disabled)
+	clientCache *clientInfoCache
+	// Serve expired cache entries when fetching from Hydra fails
+	serveStale bool
+
+	// Inject the client's scope field as the allowed_scope claim
+	injectAllowedScopes bool
+
+	// DEFAULT_CLAIMS, under every client's metadata (nil = none)
+	defaultClaims map[string]any
+
+	// Metadata tier -> rate_limit claim (nil = no rate limit claims)
+	tierRateLimits tierRateLimits
+	// Normalized tier -> rate_limit_rpm claim (nil = no tier normalization)
+	tierRPMLimits tierRPMLimits
+
+	// Deployment environment stamped into every token as the "env" claim (empty = none)
+	envClaim string
+	// Prefix for metadata-derived claim keys, ending in "/" (empty = no namespacing)
+	claimNamespace string
+	// Metadata keys allowed into (or kept out of) tokens
+	claimFilter claimFilter
+	// Metadata claims converted to int, float, or bool (nil = CLAIM_COERCE unset)
+	claimCoercions claimCoercions
+	// Signs selected metadata into the entitlements claim (nil = CLAIM_SIGNING_KEY_FILE unset)
+	claimSigner *claimSigner
+	// Fetches extra claims from CLAIM_ENRICHMENT_URL (nil = unset)
+	claimEnricher *claimEnricher
+	// Per-client token hook rate limit (nil = TOKEN_HOOK_RATE_LIMIT unset)
+	hookLimiter *clientRateLimiter
+	// Per-client limit on POST /admin/clients/{id}/verify-secret
+	verifyLimiter *clientRateLimiter
+
+	// Prometheus collectors (nil = metrics disabled)
+	metrics *Metrics
+
+	// Reused token hook response encoders (nil = no pooling)
+	responsePool *encoderPool
+
+	// Tolerated clock skew when comparing client expiry against now
+	clockSkew time.Duration
+
+	// Reject token hook calls (503) when Hydra times out instead of omitting metadata claims
+	failClosed bool
+	// Fetch client info once more when Hydra answers 5xx, before falling back
+	retryOn5xx bool
+	// Shape of token hook error bodies
+	hookErrors hookErrorFormat
+
+	// Unix nanoseconds of the last sync with status success (0 = none yet)
+	lastSuccessfulSync atomic.Int64
+
+	// Audit event storage for /admin/audit/export (nil = AUDIT_LOG disabled)
+	audit auditStore
+
+	// Where mutations are audited: the audit table and/or AUDIT_LOG_PATH (nil = disabled)
+	auditLogger AuditLogger
+
+	// Idempotency-Key storage for client creation (nil = IDEMPOTENCY_KEY_TTL disabled)
+	idempotency idempotencyStore
+
+	// Last-known-good client info for the token hook (nil = LAST_KNOWN_GOOD_MAX_AGE disabled)
+	snapshots clientSnapshotStore
+
+	// In-flight syncs, drained on shutdown; shuttingDown is cancelled when
+	// shutdown begins (nil = never) so they stop at their next phase boundary
+	syncs        sync.WaitGroup
+	syncsMu      sync.Mutex
+	shuttingDown context.Context
 }
 
 // swagger:route POST /token-hook hooks tokenHook
@@ -31,6 +158,12 @@ type Server struct {
 //
 // Called by Hydra during token issuance to inject client metadata into JWT claims.
 // Rejects expired clients with 403 Forbidden.
+// With TOKEN_HOOK_FAIL_CLOSED, a Hydra timeout is rejected with 503 instead of issuing
+// the token without metadata claims.
+// When TOKEN_HOOK_SECRET is set, the X-Hydra-Signature header must carry the
+// hex HMAC-SHA256 of the raw request body, or the request is rejected with 401.
+// With MAX_CLAIMS_BYTES, oversized claims lose their largest metadata fields, or with
+// MAX_CLAIMS_MODE=reject the token is rejected with 413.
 //
 //	Consumes:
 //	- application/json
@@ -41,18 +174,35 @@ type Server struct {
 //	Responses:
 //	  200: tokenHookResponseWrapper
 //	  400: errorResponse
+//	  401: errorResponse
 //	  403: tokenHookErrorResponseWrapper
+//	  413: tokenHookErrorResponseWrapper
+//	  503: tokenHookErrorResponseWrapper
 //
 func (s *Server) handleTokenHook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	// Read the raw body first so the signature covers the exact bytes sent
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		writeBodyError(w, err, "could not read request body")
+		return
+	}
+
+	if s.hookSecret != "" && !verifyHookSignature(body, r.Header.Get(hookSignatureHeader), s.hookSecret) {
+		log.Printf("Rejected token hook request with invalid signature")
+		writeJSONError(w, http.StatusUnauthorized, errCodeUnauthorized, "invalid request signature")
 		return
 	}
 
 	var req TokenHookRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		log.Printf("Error decoding request: %v", err)
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "invalid JSON")
 		return
 	}
 
@@ -62,60 +212,349 @@ func (s *Server) handleTokenHook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Token hook called for client_id: %s", clientID)
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("client_id", clientID))
+
+	// TOKEN_HOOK_RATE_LIMIT keeps one client's token flood off Hydra Admin
+	throttled := !s.hookLimiter.allow(clientID, time.Now())
+	if throttled {
+		s.metrics.TokenHookRateLimited()
+		if s.config.TokenHookRateLimitMode == hookRateLimitModeReject {
+			log.Printf("Client %s is over TOKEN_HOOK_RATE_LIMIT, rejecting the token", clientID)
+			w.Header().Set("Retry-After", "1")
+			s.writeTokenHookError(w, http.StatusTooManyRequests, "temporarily_unavailable",
+				"too many token requests for this client", "client exceeded TOKEN_HOOK_RATE_LIMIT; slow down")
+			return
+		}
+	}
 
-	// Fetch client info (metadata + expiration) from Hydra Admin API
-	clientInfo, err := s.fetchClientInfo(clientID)
+	var (
+		clientInfo   *ClientInfo
+		source       clientInfoSource
+		remoteClaims = func() map[string]any { return nil }
+	)
+	if throttled {
+		// Only what's already cached; without it the token gets no metadata claims
+		log.Printf("Client %s is over TOKEN_HOOK_RATE_LIMIT, skipping the Hydra lookup", clientID)
+		clientInfo, _ = s.clientCache.Get(clientID)
+	} else {
+		// CLAIM_ENRICHMENT_URL is asked in parallel with the Hydra lookup
+		remoteClaims = s.claimEnricher.start(r.Context(), clientID)
+		// Fetch client info (metadata + expiration), cached for METADATA_CACHE_TTL
+		clientInfo, source, err = s.clientInfo(r.Context(), clientID)
+	}
+	if err != nil && s.failClosed && (errors.Is(err, errHydraTimeout) || errors.Is(err, errHydraCircuitOpen)) {
+		// TOKEN_HOOK_FAIL_CLOSED: refuse rather than mint a token without metadata claims
+		log.Printf("Failed to fetch client info for %s: %v, failing closed", clientID, err)
+		s.writeTokenHookError(w, http.StatusServiceUnavailable, "temporarily_unavailable",
+			"client metadata is temporarily unavailable", "Hydra Admin API timed out; retry the token request")
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to fetch client info for %s: %v, using fallback", clientID, err)
 		clientInfo = nil
 	}
 
-	// Check if client has expired
+	// A soft-deleted or expired client gets no token
+	if hint := s.tokenDenial(clientID, clientInfo); hint != "" {
+		s.writeTokenHookError(w, http.StatusForbidden, s.hookErrors.denyCode(), "client has expired", hint)
+		return
+	}
+
+	customClaims, metadataKeys := s.buildTokenClaims(clientID, clientInfo, source, req.Request.Scopes, remoteClaims())
+
+	// MAX_CLAIMS_BYTES keeps large metadata from producing tokens the gateway rejects
+	if size, ok := s.limitClaimsSize(clientID, customClaims, metadataKeys); !ok {
+		log.Printf("Rejecting token for client %s: claims are %d bytes, over MAX_CLAIMS_BYTES (%d)", clientID, size, s.config.MaxClaimsBytes)
+		s.writeTokenHookError(w, http.StatusRequestEntityTooLarge, "invalid_request",
+			"token claims are too large", "client metadata exceeds MAX_CLAIMS_BYTES; trim it or raise the limit")
+		return
+	}
+
+	// Build response
+	resp := TokenHookResponse{}
+	resp.Session.AccessToken = customClaims
+
+	// Encode via pooled buffers to keep allocations off the hot path
+	if err := s.responsePool.writeJSON(w, resp); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+
+	// Record issuance (batched, flushed to the store in the background)
+	if s.usage != nil && clientID != "" {
+		s.usage.Record(clientID, time.Now())
+	}
+}
+
+// tokenDenial returns why the token hook refuses a token for a soft-deleted
+// or expired client, as the error hint, or "" to issue it
+func (s *Server) tokenDenial(clientID string, clientInfo *ClientInfo) string {
+	// A soft-deleted client is treated as expired
+	if clientInfo != nil && s.config.SoftDeleteEnabled {
+		if deletedAt, deleted := softDeletedAt(clientInfo.Metadata); deleted {
+			log.Printf("Client %s was deleted at %s", clientID, deletedAt)
+			return "client was deleted; restore it to issue tokens"
+		}
+	}
+
+	// Check if client has expired (allowing for CLOCK_SKEW_TOLERANCE)
 	if clientInfo != nil && clientInfo.ClientSecretExpiresAt > 0 {
-		if time.Now().Unix() > clientInfo.ClientSecretExpiresAt {
+		expired, withinSkew := checkClientExpiry(clientInfo.ClientSecretExpiresAt, time.Now(), s.clockSkew)
+		if withinSkew {
+			log.Printf("Warning: Client %s expiry decision within clock skew window (expired_at: %d, tolerance: %s, rejected: %t)",
+				clientID, clientInfo.ClientSecretExpiresAt, s.clockSkew, expired)
+		}
+		if expired {
 			log.Printf("Client %s has expired (expired_at: %d)", clientID, clientInfo.ClientSecretExpiresAt)
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(TokenHookErrorResponse{
-				Error:            "access_denied",
-				ErrorDescription: "client has expired",
-			})
-			return
+			return "client_secret_expires_at has passed; rotate the secret or extend the expiry"
 		}
 	}
 
+	return ""
+}
+
+// buildTokenClaims builds the token hook's claims for a client from its
+// metadata, remote claims, and the configured claim features. metadataKeys
+// are the metadata-derived claims, which MAX_CLAIMS_BYTES may drop.
+func (s *Server) buildTokenClaims(clientID string, clientInfo *ClientInfo, source clientInfoSource, scopes []string, remote map[string]any) (customClaims map[string]any, metadataKeys []string) {
 	// Build custom claims from client metadata - copy all metadata items
-	customClaims := make(map[string]interface{})
+	customClaims = make(map[string]interface{})
+	var entitlements string
+
+	// DEFAULT_CLAIMS sit under the client's metadata and any remote claims
+	var metadata map[string]any
+	if clientInfo != nil {
+		metadata = clientInfo.Metadata
+	}
+	metadata = withDefaultClaims(metadata, s.defaultClaims, remote)
 
-	if clientInfo != nil && clientInfo.Metadata != nil {
-		// Copy all metadata items to JWT claims
-		for key, value := range clientInfo.Metadata {
+	// Copy metadata items to JWT claims, minus scoped claims whose scope wasn't granted
+	var claims map[string]any
+	if metadata != nil {
+		claims = metadataClaims(metadata, scopes, clientID)
+	}
+	// Remote claims are merged in as metadata, so the steps below apply to them too
+	if remote != nil {
+		claims = s.claimEnricher.merge(claims, remote)
+	}
+
+	if len(claims) > 0 {
+		// CLAIM_ALLOWLIST and CLAIM_DENYLIST keep internal fields (billing IDs, notes) out of tokens
+		for key := range claims {
+			if !s.claimFilter.permits(key) {
+				delete(claims, key)
+			}
+		}
+		// CLAIM_COERCE turns numeric and boolean strings into typed claims
+		s.claimCoercions.apply(claims, clientID)
+		// Signed before shaping, so nested values keep their structure
+		if s.claimSigner != nil {
+			signed, err := s.claimSigner.entitlements(claims, clientID, time.Now())
+			if err != nil {
+				log.Printf("Error signing entitlements for client %s: %v", clientID, err)
+			}
+			entitlements = signed
+		}
+		// FLATTEN_CLAIMS turns object and array values into scalar claims, or drops them
+		claims = shapeClaimValues(claims, s.config.FlattenClaims, clientID)
+		for key, value := range claims {
+			// CLAIM_NAMESPACE applies only to metadata; template and env claims stay as configured
+			customClaims[s.claimNamespace+key] = value
+			metadataKeys = append(metadataKeys, s.claimNamespace+key)
+		}
+		log.Printf("Injecting %d metadata fields for client: %s", len(claims), clientID)
+	}
+
+	// Computed claims from templates (override same-named metadata claims)
+	if s.claimTemplates != nil {
+		for key, value := range s.claimTemplates.render(metadata, clientID, scopes) {
 			customClaims[key] = value
 		}
-		log.Printf("Injecting %d metadata fields for client: %s", len(clientInfo.Metadata), clientID)
 	}
 
-	// Build response
-	resp := TokenHookResponse{}
-	resp.Session.AccessToken = customClaims
+	// Allowed scopes come from Hydra's scope field, never from metadata
+	if s.injectAllowedScopes {
+		delete(customClaims, allowedScopeClaimName)
+		if clientInfo != nil && clientInfo.Scope != "" {
+			customClaims[allowedScopeClaimName] = clientInfo.Scope
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		log.Printf("Error encoding response: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
-		return
+	// Tier rate limit replaces any same-named metadata claim so clients can't raise their own limit
+	if s.tierRateLimits != nil {
+		if limit, ok := s.tierRateLimits.forMetadata(metadata); ok {
+			customClaims[rateLimitClaimName] = limit
+		} else {
+			delete(customClaims, rateLimitClaimName)
+		}
+	}
+
+	// Tier normalized to a known value replaces the metadata tier, and its
+	// requests-per-minute limit replaces any same-named metadata claim
+	if s.tierRPMLimits != nil {
+		tier, valid := normalizeTier(metadata)
+		if !valid {
+			log.Printf("Warning: Client %s has unknown tier %v, using %q", clientID, metadata[rateLimitTierKey], tier)
+		}
+		customClaims[s.claimNamespace+rateLimitTierKey] = tier
+		customClaims[rateLimitRPMClaimName] = s.tierRPMLimits[tier]
+	}
+
+	// Environment stamp always wins so metadata can't impersonate another environment
+	if s.envClaim != "" {
+		customClaims[envClaimName] = s.envClaim
+	}
+	// Only the sidecar's signature may fill the entitlements claim
+	if s.claimSigner != nil {
+		delete(customClaims, entitlementsClaimName)
+		if entitlements != "" {
+			customClaims[entitlementsClaimName] = entitlements
+		}
+	}
+	switch source {
+	case infoFromStaleCache:
+		customClaims[staleClaimName] = true
+	case infoFromLastKnownGood:
+		customClaims[staleClaimsClaimName] = true
+	}
+
+	return customClaims, metadataKeys
+}
+
+// limitClaimsSize applies MAX_CLAIMS_BYTES to claims, dropping metadata
+// claims in place. ok is false, with the claims' size, when
+// MAX_CLAIMS_MODE=reject refuses them instead.
+func (s *Server) limitClaimsSize(clientID string, customClaims map[string]any, metadataKeys []string) (size int, ok bool) {
+	if maxBytes := s.config.MaxClaimsBytes; maxBytes > 0 {
+		if size := claimsSize(customClaims); size > maxBytes {
+			if s.config.MaxClaimsMode == claimsSizeModeReject {
+				return size, false
+			}
+			dropped, after := dropLargestClaims(customClaims, s.droppableClaims(metadataKeys), maxBytes)
+			log.Printf("Warning: Claims for client %s were %d bytes, over MAX_CLAIMS_BYTES (%d); dropped %v, now %d bytes",
+				clientID, size, maxBytes, dropped, after)
+			if after > maxBytes {
+				log.Printf("Warning: Claims for client %s are still %d bytes with every metadata claim dropped", clientID, after)
+			}
+		}
+	}
+	return 0, true
+}
+
+// clientInfo returns client info from the cache, fetching it from Hydra on a
+// miss. With TOKEN_HOOK_RETRY_ON_5XX a fetch that ends in a Hydra 5xx is
+// tried once more, budget permitting. With SERVE_STALE_ON_ERROR a failed
+// fetch falls back to an expired entry, then with LAST_KNOWN_GOOD_MAX_AGE to
+// the client's persisted snapshot; a client Hydra reports as missing never does.
+func (s *Server) clientInfo(ctx context.Context, clientID string) (info *ClientInfo, source clientInfoSource, err error) {
+	if info, ok := s.clientCache.Get(clientID); ok {
+		return info, infoFromHydra, nil
+	}
+	info, err = s.fetchClientInfo(ctx, clientID)
+	if errors.Is(err, errHydra5xx) {
+		s.metrics.TokenHookBackend5xx()
+		if s.retryOn5xx && s.retryBudget.TryAcquire() {
+			log.Printf("Hydra returned an error for client %s: %v, retrying once", clientID, err)
+			info, err = s.fetchClientInfo(ctx, clientID)
+			if errors.Is(err, errHydra5xx) {
+				s.metrics.TokenHookBackend5xx()
+			}
+		}
+	}
+	if err != nil {
+		if errors.Is(err, errClientNotFound) {
+			return nil, infoFromHydra, err
+		}
+		if s.serveStale {
+			if info, ok := s.clientCache.GetStale(clientID); ok {
+				log.Printf("Failed to fetch client info for %s: %v, serving stale cache entry", clientID, err)
+				return info, infoFromStaleCache, nil
+			}
+		}
+		if info, ok := s.lastKnownGood(ctx, clientID, err); ok {
+			return info, infoFromLastKnownGood, nil
+		}
+		return nil, infoFromHydra, err
+	}
+	s.clientCache.Put(clientID, info)
+	s.saveSnapshot(ctx, clientID, info)
+	return info, infoFromHydra, nil
+}
+
+// Client info lookup failures that callers handle differently
+var (
+	errClientNotFound = errors.New("client not found in Hydra")
+	errHydraTimeout   = errors.New("Hydra Admin API timed out")
+	errHydra5xx       = errors.New("Hydra Admin API server error")
+)
+
+// isTimeout reports whether err is a deadline or client timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// droppableClaims returns the metadata claim keys MAX_CLAIMS_BYTES may drop:
+// those not replaced by a template or a claim the sidecar sets itself
+func (s *Server) droppableClaims(metadataKeys []string) []string {
+	var droppable []string
+	for _, key := range metadataKeys {
+		if _, templated := s.claimTemplates[key]; templated || sidecarClaimNames[key] {
+			continue
+		}
+		if s.tierRPMLimits != nil && key == s.claimNamespace+rateLimitTierKey {
+			continue
+		}
+		droppable = append(droppable, key)
 	}
+	return droppable
+}
+
+// writeTokenHookError writes a token hook error in the shape configured by
+// TOKEN_HOOK_DENY_ERROR and TOKEN_HOOK_ERROR_EXTRA_FIELDS
+func (s *Server) writeTokenHookError(w http.ResponseWriter, status int, code, description, hint string) {
+	resp := TokenHookErrorResponse{
+		Error:            code,
+		ErrorDescription: description,
+	}
+	if s.hookErrors.extraFields {
+		resp.ErrorHint = hint
+		resp.StatusCode = status
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
 }
 
 // fetchClientInfo fetches client metadata and expiration from Hydra Admin API
-func (s *Server) fetchClientInfo(clientID string) (*ClientInfo, error) {
-	url := fmt.Sprintf("%s/admin/clients/%s", s.hydraAdminURL, clientID)
-	resp, err := s.httpClient.Get(url)
+// (retried on transient failures, see doHydra). Timeouts wrap errHydraTimeout,
+// a 5xx left after retries wraps errHydra5xx, and a 404 wraps errClientNotFound.
+func (s *Server) fetchClientInfo(ctx context.Context, clientID string) (_ *ClientInfo, err error) {
+	ctx, span := tracer.Start(ctx, "fetchClientInfo", trace.WithAttributes(attribute.String("client_id", clientID)))
+	defer func() { endSpan(span, err) }()
+
+	req, err := s.newHydraRequest(ctx, http.MethodGet, "/admin/clients/"+clientID, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.doHydra(req)
 	if err != nil {
+		if isTimeout(err) {
+			return nil, fmt.Errorf("%w: %v", errHydraTimeout, err)
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", errClientNotFound, clientID)
+	}
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("%w: %d", errHydra5xx, resp.StatusCode)
+	}
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("failed to fetch client: %d", resp.StatusCode)
 	}
@@ -128,11 +567,139 @@ func (s *Server) fetchClientInfo(clientID string) (*ClientInfo, error) {
 	return &c, nil
 }
 
+// handleClients dispatches /admin/clients by method
+func (s *Server) handleClients(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listClients(w, r)
+	case http.MethodPost:
+		s.handleCreateClient(w, r)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+	}
+}
+
+// swagger:route GET /admin/clients clients listClients
+//
+// List OAuth2 clients.
+//
+// Proxies Hydra's paginated client list, enriching each client with client_secret_hash
+// (one batch query). page_size and page_token pass through to Hydra, and Hydra's Link
+// header is preserved for the next/previous pages.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: clientListResponse
+//	  400: errorResponse
+//	  502: errorResponse
+//	  503: errorResponse
+//
+func (s *Server) listClients(w http.ResponseWriter, r *http.Request) {
+	nid, err := s.hydraNetworkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	// Pass pagination through to Hydra
+	query := url.Values{}
+	for _, param := range []string{"page_size", "page_token"} {
+		if v := r.URL.Query().Get(param); v != "" {
+			query.Set(param, v)
+		}
+	}
+	hydraPath := "/admin/clients"
+	if len(query) > 0 {
+		hydraPath += "?" + query.Encode()
+	}
+
+	hydraReq, err := s.newHydraRequest(r.Context(), http.MethodGet, hydraPath, nil)
+	if err != nil {
+		log.Printf("Error creating Hydra request: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	hydraResp, err := s.doHydra(hydraReq)
+	if err != nil {
+		log.Printf("Error calling Hydra: %v", err)
+		writeHydraCallError(w, err, "failed to list clients from Hydra")
+		return
+	}
+	defer hydraResp.Body.Close()
+
+	hydraBody, err := io.ReadAll(hydraResp.Body)
+	if err != nil {
+		log.Printf("Error reading Hydra response: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	// If Hydra returned an error, pass it through
+	if hydraResp.StatusCode >= 400 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(hydraResp.StatusCode)
+		w.Write(hydraBody)
+		return
+	}
+
+	var clients []ClientData
+	if err := json.Unmarshal(hydraBody, &clients); err != nil {
+		log.Printf("Error parsing Hydra response: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	// Enrich with hashes in a single query
+	ids := make([]string, len(clients))
+	for i, c := range clients {
+		ids[i] = c.ID
+	}
+	hashes, err := s.store.GetHashedSecrets(r.Context(), ids, nid)
+	if err != nil {
+		log.Printf("Warning: Could not retrieve hashed secrets: %v", err)
+		// Still return the list, just without hashes
+	}
+	for i := range clients {
+		clients[i].ClientSecretHash = hashes[clients[i].ID]
+	}
+
+	for _, header := range []string{"Link", "X-Total-Count"} {
+		if v := hydraResp.Header.Get(header); v != "" {
+			w.Header().Set(header, v)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(clients); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
 // swagger:route POST /admin/clients clients createClient
 //
 // Create OAuth2 client.
 //
 // Proxies client creation to Hydra Admin API and returns the response enriched with client_secret_hash.
+// The network for the client_secret_hash lookup is selected by X-Network-ID (default network if unset).
+// Before calling Hydra, grant_types must be non-empty and known, token_endpoint_auth_method (if set)
+// supported, and redirect_uris absolute URLs without fragments (400 naming the field otherwise).
+// When METADATA_SCHEMA_JSON is set, metadata is validated against it (400 on violation).
+// When MAX_CLIENT_LIFETIME is set, client_secret_expires_at defaults to the maximum and
+// later expiries are rejected (or clamped with MAX_CLIENT_LIFETIME_MODE=clamp).
+// Without token_endpoint_auth_method, the default for the client's grant types from
+// AUTH_METHOD_DEFAULTS_JSON is applied.
+// A requested client_secret_expires_at is confirmed by re-fetching the client (and patched
+// in if Hydra dropped it); the response's client_secret_expires_at echoes the confirmed value.
+//
+// Demo only: ?mask_secret=true returns client_secret partially masked (first/last 4 chars)
+// for screen-shared sessions. The plaintext cannot be recovered afterwards.
+//
+// With an Idempotency-Key header (and IDEMPOTENCY_KEY_TTL above 0), a repeat of the same
+// request by the same API key and network within the TTL returns the first 201 body, minus
+// client_secret, with Idempotent-Replayed: true. A key still in flight gets 409, and a key
+// reused with a different body gets 422.
 //
 // Response fields:
 //   - client_secret: Plaintext secret (show to user, NEVER store)
@@ -147,11 +714,15 @@ func (s *Server) fetchClientInfo(clientID string) (*ClientInfo, error) {
 //	Responses:
 //	  201: clientDataResponse
 //	  400: errorResponse
+//	  409: errorResponse
+//	  413: errorResponse
+//	  422: errorResponse
 //	  502: errorResponse
+//	  503: errorResponse
 //
 func (s *Server) handleCreateClient(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 
@@ -159,24 +730,79 @@ func (s *Server) handleCreateClient(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		log.Printf("Error reading request body: %v", err)
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		writeBodyError(w, err, "could not read request body")
+		return
+	}
+	// As sent, for Idempotency-Key matching (lifetime and auth method defaults change body)
+	requestBody := body
+
+	// Catch fields Hydra would reject before any Hydra round trip
+	if err := validateClientFields(body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Enforce the CLIENT_ID_PATTERN naming convention on caller-chosen IDs
+	if err := checkClientIDPattern(body, s.clientIDPattern); err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Validate metadata against METADATA_SCHEMA_JSON
+	if err := s.metadataSchema.validateClientBody(body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Resolve the network before creating anything in Hydra
+	nid, err := s.hydraNetworkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	// Replay a create already made under this Idempotency-Key, or reserve it.
+	// The key is freed again unless a client is created.
+	idem, done := s.beginIdempotentCreate(w, r, nid, requestBody)
+	if done {
+		return
+	}
+	defer idem.release(r.Context())
+
+	// Enforce maximum client lifetime (inject, clamp, or reject expiry)
+	body, err = applyClientLifetime(body, s.maxClientLifetime, s.clientLifetimeMode, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Spread expiries of clients created together (EXPIRY_JITTER_SECONDS)
+	body, err = applyExpiryJitter(body, s.config.ExpiryJitterSeconds, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Default token_endpoint_auth_method by grant type (AUTH_METHOD_DEFAULTS_JSON)
+	body, err = applyAuthMethodDefault(body, s.authMethodDefaults)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
 		return
 	}
 
 	// Forward to Hydra Admin API
-	hydraURL := fmt.Sprintf("%s/admin/clients", s.hydraAdminURL)
-	hydraReq, err := http.NewRequest(http.MethodPost, hydraURL, bytes.NewReader(body))
+	hydraReq, err := s.newHydraRequest(r.Context(), http.MethodPost, "/admin/clients", body)
 	if err != nil {
 		log.Printf("Error creating Hydra request: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
-	hydraReq.Header.Set("Content-Type", "application/json")
 
-	hydraResp, err := s.httpClient.Do(hydraReq)
+	hydraResp, err := s.doHydra(hydraReq)
 	if err != nil {
 		log.Printf("Error calling Hydra: %v", err)
-		http.Error(w, "Failed to create client in Hydra", http.StatusBadGateway)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpCreate, Outcome: auditOutcomeFailure, Detail: "hydra unavailable"})
+		writeHydraCallError(w, err, "failed to create client in Hydra")
 		return
 	}
 	defer hydraResp.Body.Close()
@@ -185,12 +811,14 @@ func (s *Server) handleCreateClient(w http.ResponseWriter, r *http.Request) {
 	hydraBody, err := io.ReadAll(hydraResp.Body)
 	if err != nil {
 		log.Printf("Error reading Hydra response: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
 	// If Hydra returned an error, pass it through
 	if hydraResp.StatusCode >= 400 {
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpCreate, Outcome: auditOutcomeFailure,
+			Detail: fmt.Sprintf("hydra status %d", hydraResp.StatusCode)})
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(hydraResp.StatusCode)
 		w.Write(hydraBody)
@@ -201,12 +829,22 @@ func (s *Server) handleCreateClient(w http.ResponseWriter, r *http.Request) {
 	var clientData ClientData
 	if err := json.Unmarshal(hydraBody, &clientData); err != nil {
 		log.Printf("Error parsing Hydra response: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
+	// Confirm Hydra kept the requested expiry (given, or set by MAX_CLIENT_LIFETIME)
+	if expiresAt := secretExpiresAt(body); expiresAt > 0 {
+		if err := s.ensureClientExpiration(r.Context(), clientData.ID, expiresAt); err != nil {
+			log.Printf("Warning: Could not confirm expiration for %s: %v", clientData.ID, err)
+			// Continue anyway - the client was created successfully
+		} else {
+			clientData.SecretExpiresAt = int(expiresAt)
+		}
+	}
+
 	// Get the hashed secret from the database
-	hashedSecret, err := s.store.GetHashedSecret(r.Context(), clientData.ID, s.networkID)
+	hashedSecret, err := s.store.GetHashedSecret(r.Context(), clientData.ID, nid)
 	if err != nil {
 		log.Printf("Warning: Could not retrieve hashed secret for %s: %v", clientData.ID, err)
 		// Still return the response, just without the hash
@@ -215,6 +853,15 @@ func (s *Server) handleCreateClient(w http.ResponseWriter, r *http.Request) {
 	// Add the hash to the response
 	clientData.ClientSecretHash = hashedSecret
 
+	s.metrics.ClientOperation(clientOpCreated)
+	s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpCreate, ClientID: clientData.ID, Outcome: auditOutcomeSuccess})
+	idem.complete(r.Context(), clientData)
+
+	// Demo-only: mask the plaintext secret for screen sharing (hash is still returned)
+	if r.URL.Query().Get("mask_secret") == "true" {
+		clientData.Secret = maskSecret(clientData.Secret)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(hydraResp.StatusCode)
 	if err := json.NewEncoder(w).Encode(clientData); err != nil {
@@ -235,34 +882,58 @@ func (s *Server) handleCreateClient(w http.ResponseWriter, r *http.Request) {
 //	  200: clientDataResponse
 //	  404: errorResponse
 //	  502: errorResponse
+//	  503: errorResponse
 //
 func (s *Server) handleClientByID(w http.ResponseWriter, r *http.Request) {
 	// Extract client_id from path: /admin/clients/{client_id}
 	clientID := strings.TrimPrefix(r.URL.Path, "/admin/clients/")
 	if clientID == "" {
-		http.Error(w, "Bad request: missing client_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "missing client_id")
+		return
+	}
+
+	// GET /admin/clients/{client_id}/usage
+	if id, ok := strings.CutSuffix(clientID, "/usage"); ok && id != "" {
+		s.getClientUsage(w, r, id)
+		return
+	}
+
+	// POST /admin/clients/{client_id}/verify-secret
+	if id, ok := strings.CutSuffix(clientID, "/verify-secret"); ok && id != "" {
+		s.verifyClientSecret(w, r, id)
 		return
 	}
 
 	switch r.Method {
 	case http.MethodGet:
 		s.getClient(w, r, clientID)
+	case http.MethodPatch:
+		s.patchClient(w, r, clientID)
 	case http.MethodDelete:
 		s.deleteClient(w, r, clientID)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 	}
 }
 
 // getClient retrieves a client from Hydra
-func (s *Server) getClient(w http.ResponseWriter, _ *http.Request, clientID string) {
+func (s *Server) getClient(w http.ResponseWriter, r *http.Request, clientID string) {
+	if _, err := s.hydraNetworkFor(r, ""); err != nil {
+		writeNetworkError(w, err)
+		return
+	}
 	log.Printf("Getting client: %s", clientID)
 
-	hydraURL := fmt.Sprintf("%s/admin/clients/%s", s.hydraAdminURL, clientID)
-	hydraResp, err := s.httpClient.Get(hydraURL)
+	hydraReq, err := s.newHydraRequest(r.Context(), http.MethodGet, "/admin/clients/"+clientID, nil)
+	if err != nil {
+		log.Printf("Error creating Hydra request: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+	hydraResp, err := s.doHydra(hydraReq)
 	if err != nil {
 		log.Printf("Error calling Hydra: %v", err)
-		http.Error(w, "Failed to get client from Hydra", http.StatusBadGateway)
+		writeHydraCallError(w, err, "failed to get client from Hydra")
 		return
 	}
 	defer hydraResp.Body.Close()
@@ -270,7 +941,7 @@ func (s *Server) getClient(w http.ResponseWriter, _ *http.Request, clientID stri
 	body, _ := io.ReadAll(hydraResp.Body)
 
 	if hydraResp.StatusCode == http.StatusNotFound {
-		http.Error(w, "Client not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "client not found")
 		return
 	}
 
@@ -279,6 +950,216 @@ func (s *Server) getClient(w http.ResponseWriter, _ *http.Request, clientID stri
 	w.Write(body)
 }
 
+// swagger:route GET /admin/clients/{client_id}/usage clients getClientUsage
+//
+// Get client token issuance history.
+//
+// Returns the number of tokens issued to the client via the token hook and when the last one was issued.
+// Includes activity not yet flushed to the database. Requires USAGE_TRACKING=true.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: clientUsageResponse
+//	  404: errorResponse
+//	  500: errorResponse
+//
+func (s *Server) getClientUsage(w http.ResponseWriter, r *http.Request, clientID string) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if s.usage == nil {
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "usage tracking is disabled")
+		return
+	}
+
+	nid, err := s.networkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	usage, err := s.store.GetClientUsage(r.Context(), clientID, nid)
+	if err != nil {
+		log.Printf("Error getting usage for %s: %v", clientID, err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	// Merge in activity that hasn't been flushed yet
+	if d, ok := s.usage.Pending(clientID); ok {
+		usage.IssuedCount += d.Count
+		if usage.LastIssuedAt == nil || d.LastIssuedAt.After(*usage.LastIssuedAt) {
+			last := d.LastIssuedAt
+			usage.LastIssuedAt = &last
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(usage); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
+// swagger:route PATCH /admin/clients/{client_id} clients patchClient
+//
+// Patch OAuth2 client.
+//
+// Applies a JSON Patch (RFC 6902, a JSON array) or a JSON Merge Patch (RFC 7396, a JSON
+// object or Content-Type application/merge-patch+json) to a client in Hydra. Merge patches
+// are translated to JSON Patch against the current client, since Hydra only accepts RFC 6902.
+// When METADATA_SCHEMA_JSON is set, changes to /metadata or /metadata/{key} are validated first.
+// When MAX_CLIENT_LIFETIME is set, a new client_secret_expires_at is limited as on create.
+// On success the updated client is re-fetched and returned with client_secret_hash (network
+// selected by X-Network-ID). Hydra's 4xx error bodies are passed through unchanged.
+//
+//	Consumes:
+//	- application/json
+//	- application/json-patch+json
+//	- application/merge-patch+json
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: clientDataResponse
+//	  400: errorResponse
+//	  404: errorResponse
+//	  502: errorResponse
+//	  503: errorResponse
+//
+func (s *Server) patchClient(w http.ResponseWriter, r *http.Request, clientID string) {
+	s.servePatchClient(w, r, clientID, s.store)
+}
+
+// servePatchClient implements patchClient against the given hash store
+func (s *Server) servePatchClient(w http.ResponseWriter, r *http.Request, clientID string, secrets secretHashLookup) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		writeBodyError(w, err, "could not read request body")
+		return
+	}
+
+	// Resolve the network for the hash lookup before changing anything
+	nid, err := s.hydraNetworkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	// Translate a merge patch into JSON Patch against the current client
+	if isMergePatch(r.Header.Get("Content-Type"), body) {
+		var patch map[string]any
+		if err := json.Unmarshal(body, &patch); err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("invalid JSON Merge Patch: %v", err))
+			return
+		}
+		status, currentBody, err := s.getHydraClient(r.Context(), clientID)
+		if err != nil {
+			log.Printf("Error calling Hydra: %v", err)
+			writeHydraCallError(w, err, "failed to fetch client from Hydra")
+			return
+		}
+		if status != http.StatusOK {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(currentBody)
+			return
+		}
+		var current map[string]any
+		if err := json.Unmarshal(currentBody, &current); err != nil {
+			log.Printf("Error parsing Hydra response: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+			return
+		}
+		ops, err := mergePatchToJSONPatch(current, patch)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+			return
+		}
+		if body, err = json.Marshal(ops); err != nil {
+			log.Printf("Error encoding JSON Patch: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+			return
+		}
+	}
+
+	// Enforce maximum client lifetime on a new client_secret_expires_at
+	body, err = applyPatchLifetime(body, s.maxClientLifetime, s.clientLifetimeMode, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	// Validate metadata changes against METADATA_SCHEMA_JSON
+	if err := s.metadataSchema.validatePatch(body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	log.Printf("Patching client: %s", clientID)
+
+	hydraReq, err := s.newHydraRequest(r.Context(), http.MethodPatch, "/admin/clients/"+clientID, body)
+	if err != nil {
+		log.Printf("Error creating Hydra request: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	hydraResp, err := s.doHydra(hydraReq)
+	if err != nil {
+		log.Printf("Error calling Hydra: %v", err)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpPatch, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "hydra unavailable"})
+		writeHydraCallError(w, err, "failed to patch client in Hydra")
+		return
+	}
+	defer hydraResp.Body.Close()
+
+	respBody, _ := io.ReadAll(hydraResp.Body)
+	s.clientCache.Invalidate(clientID)
+
+	// Pass Hydra's errors through unchanged
+	if hydraResp.StatusCode >= 400 {
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpPatch, ClientID: clientID, Outcome: auditOutcomeFailure,
+			Detail: fmt.Sprintf("hydra status %d", hydraResp.StatusCode)})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(hydraResp.StatusCode)
+		w.Write(respBody)
+		return
+	}
+	s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpPatch, ClientID: clientID, Outcome: auditOutcomeSuccess})
+
+	// Re-fetch so the response reflects the stored client, falling back to the PATCH response
+	if status, fetched, err := s.getHydraClient(r.Context(), clientID); err == nil && status == http.StatusOK {
+		respBody = fetched
+	} else {
+		log.Printf("Warning: Could not re-fetch patched client %s (status %d): %v", clientID, status, err)
+	}
+
+	var clientData ClientData
+	if err := json.Unmarshal(respBody, &clientData); err != nil {
+		log.Printf("Error parsing Hydra response: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	hashedSecret, err := secrets.GetHashedSecret(r.Context(), clientID, nid)
+	if err != nil {
+		log.Printf("Warning: Could not retrieve hashed secret for %s: %v", clientID, err)
+		// Still return the response, just without the hash
+	}
+	clientData.ClientSecretHash = hashedSecret
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(clientData); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
+
 // swagger:route DELETE /admin/clients/{client_id} clients deleteClient
 //
 // Delete OAuth2 client.
@@ -287,25 +1168,36 @@ func (s *Server) getClient(w http.ResponseWriter, _ *http.Request, clientID stri
 //
 //	Responses:
 //	  204: noContent
+//	  403: errorResponse
 //	  404: errorResponse
 //	  502: errorResponse
+//	  503: errorResponse
 //
-func (s *Server) deleteClient(w http.ResponseWriter, _ *http.Request, clientID string) {
+func (s *Server) deleteClient(w http.ResponseWriter, r *http.Request, clientID string) {
+	nid, err := s.hydraNetworkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+	if s.config.SoftDeleteEnabled {
+		s.softDeleteClient(w, r, nid, clientID)
+		return
+	}
 	log.Printf("Deleting client: %s", clientID)
 
 	// Forward delete to Hydra Admin API
-	hydraURL := fmt.Sprintf("%s/admin/clients/%s", s.hydraAdminURL, clientID)
-	hydraReq, err := http.NewRequest(http.MethodDelete, hydraURL, nil)
+	hydraReq, err := s.newHydraRequest(r.Context(), http.MethodDelete, "/admin/clients/"+clientID, nil)
 	if err != nil {
 		log.Printf("Error creating Hydra request: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
-	hydraResp, err := s.httpClient.Do(hydraReq)
+	hydraResp, err := s.doHydra(hydraReq)
 	if err != nil {
 		log.Printf("Error calling Hydra: %v", err)
-		http.Error(w, "Failed to delete client in Hydra", http.StatusBadGateway)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "hydra unavailable"})
+		writeHydraCallError(w, err, "failed to delete client in Hydra")
 		return
 	}
 	defer hydraResp.Body.Close()
@@ -313,18 +1205,26 @@ func (s *Server) deleteClient(w http.ResponseWriter, _ *http.Request, clientID s
 	// Pass through Hydra's response status
 	if hydraResp.StatusCode == http.StatusNoContent || hydraResp.StatusCode == http.StatusOK {
 		log.Printf("Client %s deleted successfully", clientID)
+		s.clientCache.Invalidate(clientID)
+		s.forgetSnapshot(r.Context(), clientID)
+		s.metrics.ClientOperation(clientOpDeleted)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: clientID, Outcome: auditOutcomeSuccess})
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: clientID, Outcome: auditOutcomeFailure,
+		Detail: fmt.Sprintf("hydra status %d", hydraResp.StatusCode)})
+
 	if hydraResp.StatusCode == http.StatusNotFound {
-		http.Error(w, "Client not found", http.StatusNotFound)
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "client not found")
 		return
 	}
 
 	// Pass through other errors
 	body, _ := io.ReadAll(hydraResp.Body)
 	log.Printf("Hydra returned error %d: %s", hydraResp.StatusCode, string(body))
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(hydraResp.StatusCode)
 	w.Write(body)
 }
@@ -335,6 +1235,10 @@ func (s *Server) deleteClient(w http.ResponseWriter, _ *http.Request, clientID s
 //
 // Rotates the client secret and returns the new secret along with its hash.
 // Optionally accepts client_secret_expires_at to set expiration for the new secret.
+// The network for the client_secret_hash lookup is selected by X-Network-ID (default network if unset).
+// If the new secret is shorter than MIN_SECRET_LENGTH it is logged, or rejected with 502
+// when MIN_SECRET_LENGTH_MODE=fail. A client_secret_expires_at beyond MAX_CLIENT_LIFETIME is
+// rejected with 400 (or clamped with MAX_CLIENT_LIFETIME_MODE=clamp) before rotating.
 //
 // Response fields:
 //   - client_secret: New plaintext secret (show to user, NEVER store)
@@ -351,17 +1255,18 @@ func (s *Server) deleteClient(w http.ResponseWriter, _ *http.Request, clientID s
 //	  400: errorResponse
 //	  404: errorResponse
 //	  502: errorResponse
+//	  503: errorResponse
 //
 func (s *Server) handleRotateClient(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 
 	// Extract client_id from path: /admin/clients/rotate/{client_id}
 	clientID := strings.TrimPrefix(r.URL.Path, "/admin/clients/rotate/")
 	if clientID == "" {
-		http.Error(w, "Bad request: missing client_id", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "missing client_id")
 		return
 	}
 
@@ -370,7 +1275,23 @@ func (s *Server) handleRotateClient(w http.ResponseWriter, r *http.Request) {
 	if r.Body != nil && r.ContentLength > 0 {
 		if err := json.NewDecoder(r.Body).Decode(&rotateReq); err != nil {
 			log.Printf("Error decoding rotate request: %v", err)
-			http.Error(w, "Bad request: invalid JSON", http.StatusBadRequest)
+			writeBodyError(w, err, "invalid JSON")
+			return
+		}
+	}
+
+	// Resolve the network before rotating anything in Hydra
+	nid, err := s.hydraNetworkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	// Enforce maximum client lifetime on the new expiry before rotating
+	if rotateReq.ClientSecretExpiresAt > 0 {
+		rotateReq.ClientSecretExpiresAt, err = clampClientExpiry(rotateReq.ClientSecretExpiresAt, s.maxClientLifetime, s.clientLifetimeMode, time.Now())
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
 			return
 		}
 	}
@@ -378,33 +1299,38 @@ func (s *Server) handleRotateClient(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Rotating secret for client: %s", clientID)
 
 	// Call Hydra Admin API to rotate secret
-	hydraURL := fmt.Sprintf("%s/admin/clients/%s/rotate", s.hydraAdminURL, clientID)
-	hydraReq, err := http.NewRequest(http.MethodPost, hydraURL, nil)
+	hydraReq, err := s.newHydraRequest(r.Context(), http.MethodPost, "/admin/clients/"+clientID+"/rotate", nil)
 	if err != nil {
 		log.Printf("Error creating Hydra request: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 	hydraReq.Header.Set("Content-Type", "application/json")
 
-	hydraResp, err := s.httpClient.Do(hydraReq)
+	hydraResp, err := s.doHydra(hydraReq)
 	if err != nil {
 		log.Printf("Error calling Hydra: %v", err)
-		http.Error(w, "Failed to rotate client secret in Hydra", http.StatusBadGateway)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRotate, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "hydra unavailable"})
+		writeHydraCallError(w, err, "failed to rotate client secret in Hydra")
 		return
 	}
 	defer hydraResp.Body.Close()
 
+	// Rotation (and any expiry update below) changes the client; drop cached info once done
+	defer s.clientCache.Invalidate(clientID)
+
 	// Read Hydra response
 	hydraBody, err := io.ReadAll(hydraResp.Body)
 	if err != nil {
 		log.Printf("Error reading Hydra response: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
 	// If Hydra returned an error, pass it through
 	if hydraResp.StatusCode >= 400 {
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRotate, ClientID: clientID, Outcome: auditOutcomeFailure,
+			Detail: fmt.Sprintf("hydra status %d", hydraResp.StatusCode)})
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(hydraResp.StatusCode)
 		w.Write(hydraBody)
@@ -415,13 +1341,26 @@ func (s *Server) handleRotateClient(w http.ResponseWriter, r *http.Request) {
 	var clientData ClientData
 	if err := json.Unmarshal(hydraBody, &clientData); err != nil {
 		log.Printf("Error parsing Hydra response: %v", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
 		return
 	}
 
-	// If client_secret_expires_at was provided, update the client via PATCH
+	// Guard against misconfigured Hydra secret generation
+	if err := checkSecretLength(clientData.Secret, s.minSecretLength); err != nil {
+		if s.minSecretLengthMode == secretLengthModeFail {
+			log.Printf("Error: Hydra returned a weak secret for client %s: %v", clientID, err)
+			s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRotate, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "weak secret"})
+			writeJSONError(w, http.StatusBadGateway, errCodeUpstream, "Hydra returned a secret below the minimum length")
+			return
+		}
+		log.Printf("Warning: Hydra returned a weak secret for client %s: %v", clientID, err)
+	}
+
+	// If client_secret_expires_at was provided, update the client via PATCH,
+	// spread by EXPIRY_JITTER_SECONDS so clients rotated together don't expire together
 	if rotateReq.ClientSecretExpiresAt > 0 {
-		if err := s.updateClientExpiration(clientID, rotateReq.ClientSecretExpiresAt); err != nil {
+		rotateReq.ClientSecretExpiresAt = jitterExpiry(rotateReq.ClientSecretExpiresAt, s.config.ExpiryJitterSeconds, time.Now())
+		if err := s.updateClientExpiration(r.Context(), clientID, rotateReq.ClientSecretExpiresAt); err != nil {
 			log.Printf("Warning: Failed to update client expiration: %v", err)
 			// Continue anyway - the secret was rotated successfully
 		} else {
@@ -431,7 +1370,7 @@ func (s *Server) handleRotateClient(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the hashed secret from the database
-	hashedSecret, err := s.store.GetHashedSecret(r.Context(), clientData.ID, s.networkID)
+	hashedSecret, err := s.store.GetHashedSecret(r.Context(), clientData.ID, nid)
 	if err != nil {
 		log.Printf("Warning: Could not retrieve hashed secret for %s: %v", clientData.ID, err)
 		// Still return the response, just without the hash
@@ -441,6 +1380,8 @@ func (s *Server) handleRotateClient(w http.ResponseWriter, r *http.Request) {
 	clientData.ClientSecretHash = hashedSecret
 
 	log.Printf("Client %s secret rotated successfully", clientID)
+	s.metrics.ClientOperation(clientOpRotated)
+	s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRotate, ClientID: clientID, Outcome: auditOutcomeSuccess})
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(hydraResp.StatusCode)
@@ -450,7 +1391,7 @@ func (s *Server) handleRotateClient(w http.ResponseWriter, r *http.Request) {
 }
 
 // updateClientExpiration updates the client_secret_expires_at via PATCH to Hydra
-func (s *Server) updateClientExpiration(clientID string, expiresAt int64) error {
+func (s *Server) updateClientExpiration(ctx context.Context, clientID string, expiresAt int64) error {
 	patchBody := map[string]interface{}{
 		"client_secret_expires_at": expiresAt,
 	}
@@ -459,14 +1400,12 @@ func (s *Server) updateClientExpiration(clientID string, expiresAt int64) error
 		return fmt.Errorf("failed to marshal patch body: %w", err)
 	}
 
-	hydraURL := fmt.Sprintf("%s/admin/clients/%s", s.hydraAdminURL, clientID)
-	req, err := http.NewRequest(http.MethodPatch, hydraURL, bytes.NewReader(bodyBytes))
+	req, err := s.newHydraRequest(ctx, http.MethodPatch, "/admin/clients/"+clientID, bodyBytes)
 	if err != nil {
 		return fmt.Errorf("failed to create PATCH request: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := s.httpClient.Do(req)
+	resp, err := s.doHydra(req)
 	if err != nil {
 		return fmt.Errorf("failed to call Hydra: %w", err)
 	}
@@ -480,11 +1419,90 @@ func (s *Server) updateClientExpiration(clientID string, expiresAt int64) error
 	return nil
 }
 
+// ensureClientExpiration re-fetches a newly created client and confirms Hydra
+// persisted client_secret_expires_at, patching it in if Hydra dropped it
+func (s *Server) ensureClientExpiration(ctx context.Context, clientID string, expiresAt int64) error {
+	info, err := s.fetchClientInfo(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to re-fetch client: %w", err)
+	}
+	if info.ClientSecretExpiresAt == expiresAt {
+		return nil
+	}
+
+	log.Printf("Hydra stored client_secret_expires_at %d for %s instead of %d, patching",
+		info.ClientSecretExpiresAt, clientID, expiresAt)
+	return s.updateClientExpiration(ctx, clientID, expiresAt)
+}
+
+// secretExpiresAt returns the client_secret_expires_at of a client request
+// body (0 when absent or not a number)
+func secretExpiresAt(body []byte) int64 {
+	var fields struct {
+		ClientSecretExpiresAt int64 `json:"client_secret_expires_at"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return 0
+	}
+	return fields.ClientSecretExpiresAt
+}
+
+// swagger:route POST /sync/clients/upsert clients syncClientsUpsert
+//
+// Upsert-only client sync.
+//
+// The same as POST /sync/clients?mode=upsert: creates and updates the given clients and never
+// deletes, so deleted_count is always 0. Use it to push incremental changes without sending the
+// complete set of clients. Takes the same body and query parameters as /sync/clients, except
+// that ?mode= other than upsert is rejected with 400.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: syncResultResponse
+//	  400: syncValidationErrorResponse
+//	  413: errorResponse
+//	  500: errorResponse
+//	  503: errorResponse
+func (s *Server) handleSyncClientsUpsert(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if mode := query.Get("mode"); mode != "" && mode != syncModeUpsert {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("mode must be %q on /sync/clients/upsert", syncModeUpsert))
+		return
+	}
+	query.Set("mode", syncModeUpsert)
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = query.Encode()
+	s.handleSyncClients(w, r)
+}
+
 // swagger:route POST /sync/clients clients syncClients
 //
 // Bulk sync OAuth2 clients.
 //
 // Performs full reconciliation of clients - creates new, updates existing, deletes removed.
+// Failures in either phase are reported per client (with the phase in "operation") and the
+// overall "status" is "success", "partial", or "failed".
+// With ?atomic=true the batch runs in one transaction and is rolled back (status "rolled_back")
+// if any delete fails or more than SYNC_MAX_FAILURES operations fail.
+// With ?mode=upsert the delete phase is skipped: only the given clients are created or updated.
+// A full sync that would delete more than MAX_SYNC_DELETE_RATIO of the network's clients is
+// refused with 409, listing the client IDs it would have deleted, unless ?force=true is set.
+// Repeats of a client_id are collapsed when identical; repeats that differ are rejected with
+// 400 listing the conflicting client IDs, unless ?duplicates=last_wins keeps the last of each.
+// A body larger than MAX_SYNC_REQUEST_BODY_BYTES is rejected with 413.
+// A sync running when the sidecar shuts down stops before its next phase and answers 503;
+// a best-effort sync keeps the phases it completed and reports them in "result" (status
+// "partial"), an atomic one is rolled back.
+// With Accept: text/event-stream the response is a stream of Server-Sent Events instead: a
+// "progress" event (syncProgress) as each client's upsert or delete finishes, then a "result"
+// event with the syncResult, or an "error" event if the sync fails after the stream began.
+// Reconciliation is scoped to one network: network_id in the body, else the X-Network-ID
+// header, else the default network.
 //
 // Request field behavior:
 //   - client_secret: Must contain the stored hash (from client_secret_hash in creation response)
@@ -495,58 +1513,65 @@ func (s *Server) updateClientExpiration(clientID string, expiresAt int64) error
 //
 //	Produces:
 //	- application/json
+//	- text/event-stream
 //
 //	Responses:
 //	  200: syncResultResponse
-//	  400: errorResponse
+//	  400: syncValidationErrorResponse
+//	  409: syncDeleteRefusedResponse
+//	  413: errorResponse
 //	  500: errorResponse
+//	  503: syncInterruptedResponse
 //
 func (s *Server) handleSyncClients(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = syncModeFull
+	}
+	if mode != syncModeFull && mode != syncModeUpsert {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("mode must be %q or %q", syncModeFull, syncModeUpsert))
+		return
+	}
+	lastWins, err := parseSyncDuplicates(r.URL.Query().Get("duplicates"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
 		return
 	}
 
 	var req SyncClientsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		log.Printf("Error decoding sync request: %v", err)
-		http.Error(w, "Bad request: invalid JSON", http.StatusBadRequest)
+		writeBodyError(w, err, "invalid JSON")
 		return
 	}
 
 	if len(req.Clients) == 0 {
-		http.Error(w, "Bad request: clients array is empty", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "clients array is empty")
 		return
 	}
 
-	// Validate all hashes match configured algorithm
-	for _, c := range req.Clients {
-		// Warn if client_secret is populated in sync request.
-		// In API responses, client_secret contains the plaintext (shown once at creation).
-		// For sync, callers should use client_secret_hash which contains the stored hash.
-		// We ignore client_secret here to prevent accidental plaintext submission.
-		if c.Secret != "" {
-			log.Printf("Warning: client %s has client_secret populated in sync request, ignoring (use client_secret_hash)", c.ID)
-		}
-		// Validate the hash from client_secret_hash field
-		if err := s.validateHash(c.ClientSecretHash); err != nil {
-			http.Error(w, fmt.Sprintf("Bad request: client %s: %v", c.ID, err), http.StatusBadRequest)
-			return
-		}
+	// Check the whole batch before touching the store, reporting every failure at once
+	clients, conflicts, failures := s.validateSyncClients(req.Clients, lastWins)
+	if len(failures) > 0 {
+		writeSyncValidationErrors(w, failures)
+		return
+	}
+	if len(conflicts) > 0 {
+		log.Printf("Warning: sync request has conflicting entries for %d client IDs, keeping the last of each: %s",
+			len(conflicts), strings.Join(conflicts, ", "))
 	}
+	req.Clients = clients
 
-	// Ensure we have a network ID
-	nid := s.networkID
-	if nid == uuid.Nil {
-		// Try to get it again
-		var err error
-		nid, err = s.store.GetDefaultNetworkID(r.Context())
-		if err != nil {
-			log.Printf("Error getting network ID: %v", err)
-			http.Error(w, "Internal error: no network ID available", http.StatusInternalServerError)
-			return
-		}
-		s.networkID = nid
+	// Resolve the target network (body, X-Network-ID header, or default)
+	nid, err := s.networkFor(r, req.NetworkID)
+	if err != nil {
+		writeNetworkError(w, err)
+		return
 	}
 
 	// Convert ClientData to client.Client structs with defaults
@@ -569,46 +1594,167 @@ func (s *Server) handleSyncClients(w http.ResponseWriter, r *http.Request) {
 			hydraClients[i].GrantTypes = sqlxx.StringSliceJSONFormat{"client_credentials"}
 		}
 
-		// Set default token endpoint auth method if not provided
+		// Set default token endpoint auth method if not provided (per grant type, see AUTH_METHOD_DEFAULTS_JSON)
 		if hydraClients[i].TokenEndpointAuthMethod == "" {
-			hydraClients[i].TokenEndpointAuthMethod = "client_secret_basic"
+			hydraClients[i].TokenEndpointAuthMethod = s.authMethodDefaults.forGrantTypes(hydraClients[i].GrantTypes)
 		}
 	}
 
-	// Perform sync
-	result, err := s.store.SyncClients(r.Context(), hydraClients, nid)
+	// Perform sync (?atomic=true applies all-or-nothing, ?mode=upsert never deletes)
+	opts := SyncOptions{
+		Mode:           mode,
+		Atomic:         r.URL.Query().Get("atomic") == "true",
+		MaxFailures:    s.syncMaxFailures,
+		Concurrency:    s.syncConcurrency,
+		MaxDeleteRatio: s.config.MaxSyncDeleteRatio,
+		Force:          r.URL.Query().Get("force") == "true",
+	}
+	// Accept: text/event-stream streams each client's result, then the SyncResult
+	var events *syncEventStream
+	if wantsEventStream(r) {
+		events = newSyncEventStream(w)
+		opts.Progress = events.progress
+	}
+	syncCtx, done := s.startSync(r.Context())
+	defer done()
+	result, err := s.store.SyncClients(syncCtx, hydraClients, nid, opts)
+	if errors.Is(err, errSyncInterrupted) {
+		log.Printf("Sync interrupted: %v", err)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpSync, Outcome: auditOutcomeFailure, Detail: fmt.Sprintf("mode=%s interrupted by shutdown", mode)})
+		if result != nil {
+			// Applied upserts may have changed clients the cache holds
+			s.clientCache.Clear()
+		}
+		events.interrupted(w, SyncInterruptedError{
+			APIError: APIError{Error: errCodeUnavailable,
+				ErrorDescription: err.Error() + "; completed phases were kept unless the sync was atomic, retry the sync"},
+			Result: result,
+		})
+		return
+	}
+	var refused *deleteRatioError
+	if errors.As(err, &refused) {
+		log.Printf("Refusing sync: %v", err)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpSync, Outcome: auditOutcomeFailure, Detail: fmt.Sprintf("mode=%s refused: would delete %d of %d", mode, len(refused.ClientIDs), refused.Existing)})
+		writeSyncDeleteRefused(w, refused)
+		return
+	}
 	if err != nil {
 		log.Printf("Error syncing clients: %v", err)
-		http.Error(w, "Internal error during sync", http.StatusInternalServerError)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpSync, Outcome: auditOutcomeFailure, Detail: fmt.Sprintf("mode=%s error", mode)})
+		events.fail(w, http.StatusInternalServerError, errCodeInternal, "internal error during sync")
 		return
 	}
 
-	log.Printf("Sync completed: created=%d, updated=%d, deleted=%d, failed=%d",
-		result.CreatedCount, result.UpdatedCount, result.DeletedCount, result.FailedCount)
+	// Sync writes directly to the database, so any cached client may be stale
+	s.clientCache.Clear()
+	s.metrics.SyncCompleted(result)
+	s.recordSuccessfulSync(result, time.Now())
+	s.recordAudit(r.Context(), nid, syncAuditEvent(mode, result))
+
+	log.Printf("Sync completed (%s, mode=%s): created=%d, updated=%d, deleted=%d, failed=%d",
+		result.Status, mode, result.CreatedCount, result.UpdatedCount, result.DeletedCount, result.FailedCount)
 
+	if events != nil {
+		events.send(syncEventResult, result)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(result); err != nil {
 		log.Printf("Error encoding sync result: %v", err)
 	}
 }
 
+// writeSyncDeleteRefused answers a sync refused by MAX_SYNC_DELETE_RATIO with
+// 409 and the client IDs it would have deleted
+func writeSyncDeleteRefused(w http.ResponseWriter, e *deleteRatioError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(SyncDeleteRefusedError{
+		APIError:      APIError{Error: errCodeConflict, ErrorDescription: e.Error()},
+		ClientIDs:     e.ClientIDs,
+		ExistingCount: e.Existing,
+	})
+}
+
+// writeSyncInterrupted answers a sync stopped by shutdown with 503 and the
+// results of the phases it completed
+func writeSyncInterrupted(w http.ResponseWriter, body SyncInterruptedError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeSyncValidationErrors answers a sync batch that failed validation with
+// 400, every failure, and the client IDs they concern
+func writeSyncValidationErrors(w http.ResponseWriter, failures []SyncClientError) {
+	clientIDs := []string{}
+	seen := make(map[string]bool, len(failures))
+	for _, f := range failures {
+		if f.ClientID != "" && !seen[f.ClientID] {
+			seen[f.ClientID] = true
+			clientIDs = append(clientIDs, f.ClientID)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(SyncValidationError{
+		APIError: APIError{Error: errCodeInvalidRequest, ErrorDescription: fmt.Sprintf(
+			"clients array has %d validation errors; nothing was synced", len(failures))},
+		ClientIDs: clientIDs,
+		Errors:    failures,
+	})
+}
+
 // validateHash checks if the hash format matches the configured algorithm
 func (s *Server) validateHash(hash string) error {
+	return s.validateHashAs(hash, s.hasherAlgorithm)
+}
+
+// validateHashAs checks if the hash format matches algorithm
+func (s *Server) validateHashAs(hash, algorithm string) error {
 	if hash == "" {
 		return fmt.Errorf("client_secret (hash) is required")
 	}
 
-	switch s.hasherAlgorithm {
+	switch algorithm {
 	case "pbkdf2":
 		if !isPbkdf2Hash(hash) {
 			return fmt.Errorf("expected PBKDF2 hash format ($pbkdf2-sha...), got: %s", detectHashFormat(hash))
 		}
+		if err := checkPbkdf2Structure(hash); err != nil {
+			return err
+		}
+		if s.pbkdf2Iter > 0 {
+			iter, err := pbkdf2Iterations(hash)
+			if err != nil {
+				return err
+			}
+			if iter != s.pbkdf2Iter {
+				return fmt.Errorf("PBKDF2 hash uses %d iterations, expected %d (PBKDF2_ITERATIONS)", iter, s.pbkdf2Iter)
+			}
+		}
 	case "bcrypt":
 		if !isBcryptHash(hash) {
 			return fmt.Errorf("expected BCrypt hash format ($2a$...), got: %s", detectHashFormat(hash))
 		}
+		if err := checkBcryptStructure(hash); err != nil {
+			return err
+		}
+		if s.bcryptCost > 0 {
+			cost, err := bcryptCost(hash)
+			if err != nil {
+				return err
+			}
+			if cost != s.bcryptCost {
+				return fmt.Errorf("BCrypt hash uses cost %d, expected %d (BCRYPT_COST)", cost, s.bcryptCost)
+			}
+		}
 	default:
-		return fmt.Errorf("unknown hasher algorithm: %s", s.hasherAlgorithm)
+		return fmt.Errorf("unknown hasher algorithm: %s", algorithm)
 	}
 
 	return nil
@@ -624,6 +1770,56 @@ func isBcryptHash(hash string) bool {
 	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
 }
 
+// bcryptCost parses the cost factor from a "$2a$<cost>$..." hash
+func bcryptCost(hash string) (int, error) {
+	parts := strings.SplitN(hash, "$", 4)
+	if len(parts) < 4 {
+		return 0, fmt.Errorf("malformed BCrypt hash: missing cost")
+	}
+	cost, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, fmt.Errorf("malformed BCrypt hash: invalid cost %q", parts[2])
+	}
+	return cost, nil
+}
+
+// pbkdf2Iterations parses the iteration count from a
+// "$pbkdf2-sha256$i=<iterations>,l=<length>$..." hash
+func pbkdf2Iterations(hash string) (int, error) {
+	parts := strings.SplitN(hash, "$", 4)
+	if len(parts) < 4 {
+		return 0, fmt.Errorf("malformed PBKDF2 hash: missing parameters")
+	}
+	for _, param := range strings.Split(parts[2], ",") {
+		if value, ok := strings.CutPrefix(param, "i="); ok {
+			iter, err := strconv.Atoi(value)
+			if err != nil {
+				return 0, fmt.Errorf("malformed PBKDF2 hash: invalid iterations %q", value)
+			}
+			return iter, nil
+		}
+	}
+	return 0, fmt.Errorf("malformed PBKDF2 hash: missing iterations")
+}
+
+// hashAlgorithm names the algorithm of a hash: "pbkdf2", "bcrypt", or "unknown"
+func hashAlgorithm(hash string) string {
+	switch {
+	case isPbkdf2Hash(hash):
+		return "pbkdf2"
+	case isBcryptHash(hash):
+		return "bcrypt"
+	default:
+		return "unknown"
+	}
+}
+
+// hashAlgorithmChanged reports whether a new hash uses a different algorithm
+// than the stored one (false when nothing is stored yet)
+func hashAlgorithmChanged(stored, next string) bool {
+	return stored != "" && hashAlgorithm(stored) != hashAlgorithm(next)
+}
+
 // detectHashFormat returns a description of the hash format for error messages
 func detectHashFormat(hash string) string {
 	if isPbkdf2Hash(hash) {
@@ -639,6 +1835,23 @@ func detectHashFormat(hash string) string {
 }
 
 
+// swagger:route GET /metrics health metrics
+//
+// Prometheus metrics.
+//
+// Token hook, client operation, and sync counters; token hook and Hydra Admin API
+// latency histograms; the shared retry budget; Go runtime and process metrics.
+//
+//	Produces:
+//	- text/plain
+//
+//	Responses:
+//	  200: metricsResponse
+//
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.metrics.Handler().ServeHTTP(w, r)
+}
+
 // swagger:route GET /health health healthCheck
 //
 // Health check (liveness probe).
@@ -660,25 +1873,20 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 //
 // Readiness check (readiness probe).
 //
-// Returns OK if the database connection is healthy.
+// Returns OK if the database connection is healthy and, with READINESS_CHECK_HYDRA,
+// Hydra Admin answers /health/alive. Otherwise returns 503 with the failed
+// dependencies ("database", "hydra").
+// With ?verbose=true, returns JSON diagnostics instead: database and Hydra latency, last
+// successful sync time, default network ID status, and Hydra retry budget state.
 //
 //	Produces:
 //	- text/plain
+//	- application/json
 //
 //	Responses:
 //	  200: healthResponse
-//	  503: errorResponse
+//	  503: readinessFailureResponse
 //
 func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
-
-	if err := s.store.Ping(ctx); err != nil {
-		log.Printf("Readiness check failed: %v", err)
-		http.Error(w, "Database not ready", http.StatusServiceUnavailable)
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	s.serveReady(w, r, s.store)
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pbkdf2DigestSizes maps the SHA variant in a "$pbkdf2-<variant>$" header to
+// its digest size in bytes
+var pbkdf2DigestSizes = map[string]int{
+	"sha1":   20,
+	"sha224": 28,
+	"sha256": 32,
+	"sha384": 48,
+	"sha512": 64,
+}
+
+// pbkdf2MinSaltBytes is the shortest salt accepted (RFC 8018 asks for at least eight octets)
+const pbkdf2MinSaltBytes = 8
+
+// Lengths of the segments after "$2a$<cost>$" in a BCrypt hash
+const (
+	bcryptSaltChars = 22
+	bcryptHashChars = 31
+)
+
+// bcryptAlphabet is the base64 alphabet BCrypt encodes its salt and hash with
+const bcryptAlphabet = "./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// malformedHashError reports which segment of a hash is corrupt
+type malformedHashError struct {
+	Format  string // "PBKDF2" or "BCrypt"
+	Segment string // e.g. "salt", "digest"
+	Reason  string
+}
+
+func (e *malformedHashError) Error() string {
+	return fmt.Sprintf("malformed %s hash: %s %s", e.Format, e.Segment, e.Reason)
+}
+
+// checkPbkdf2Structure verifies a "$pbkdf2-<sha>$i=..,l=..$<salt>$<digest>"
+// hash: both segments must be base64 and the digest as long as the SHA
+// variant's output (and l= when given), so a damaged copy is caught at sync
+// rather than when Hydra verifies a secret against it
+func checkPbkdf2Structure(hash string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 5 || parts[0] != "" {
+		return &malformedHashError{Format: "PBKDF2", Segment: "layout", Reason: fmt.Sprintf("has %d segments, want $pbkdf2-<sha>$<params>$<salt>$<digest>", len(parts)-1)}
+	}
+
+	variant := strings.TrimPrefix(parts[1], "pbkdf2-")
+	digestSize, ok := pbkdf2DigestSizes[variant]
+	if !ok {
+		return &malformedHashError{Format: "PBKDF2", Segment: "header", Reason: fmt.Sprintf("names unknown variant %q", variant)}
+	}
+
+	salt, err := decodeHashSegment(parts[3])
+	if err != nil {
+		return &malformedHashError{Format: "PBKDF2", Segment: "salt", Reason: "is not valid base64"}
+	}
+	if len(salt) < pbkdf2MinSaltBytes {
+		return &malformedHashError{Format: "PBKDF2", Segment: "salt", Reason: fmt.Sprintf("is %d bytes, want at least %d", len(salt), pbkdf2MinSaltBytes)}
+	}
+
+	digest, err := decodeHashSegment(parts[4])
+	if err != nil {
+		return &malformedHashError{Format: "PBKDF2", Segment: "digest", Reason: "is not valid base64"}
+	}
+	if len(digest) != digestSize {
+		return &malformedHashError{Format: "PBKDF2", Segment: "digest", Reason: fmt.Sprintf("is %d bytes, want %d for %s", len(digest), digestSize, variant)}
+	}
+	for _, param := range strings.Split(parts[2], ",") {
+		if value, ok := strings.CutPrefix(param, "l="); ok && value != strconv.Itoa(len(digest)) {
+			return &malformedHashError{Format: "PBKDF2", Segment: "digest", Reason: fmt.Sprintf("is %d bytes, but the header says l=%s", len(digest), value)}
+		}
+	}
+	return nil
+}
+
+// decodeHashSegment decodes standard base64 with or without padding, as
+// written by Hydra (unpadded) and by most other PBKDF2 tooling
+func decodeHashSegment(segment string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+// checkBcryptStructure verifies a "$2a$<cost>$<22-char salt><31-char hash>"
+// hash: a two-digit cost followed by 53 characters of BCrypt's base64 alphabet
+func checkBcryptStructure(hash string) error {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "" {
+		return &malformedHashError{Format: "BCrypt", Segment: "layout", Reason: fmt.Sprintf("has %d segments, want $2a$<cost>$<salt and hash>", len(parts)-1)}
+	}
+	if cost := parts[2]; len(cost) != 2 || strings.Trim(cost, "0123456789") != "" {
+		return &malformedHashError{Format: "BCrypt", Segment: "cost", Reason: fmt.Sprintf("%q is not two digits", cost)}
+	}
+
+	rest := parts[3]
+	if len(rest) != bcryptSaltChars+bcryptHashChars {
+		return &malformedHashError{Format: "BCrypt", Segment: "salt and hash", Reason: fmt.Sprintf("are %d characters, want %d (%d-char salt, %d-char hash)",
+			len(rest), bcryptSaltChars+bcryptHashChars, bcryptSaltChars, bcryptHashChars)}
+	}
+	if i := strings.IndexFunc(rest, func(r rune) bool { return !strings.ContainsRune(bcryptAlphabet, r) }); i >= 0 {
+		segment := "salt"
+		if i >= bcryptSaltChars {
+			segment = "hash"
+		}
+		return &malformedHashError{Format: "BCrypt", Segment: segment, Reason: fmt.Sprintf("contains %q, which is not in the BCrypt alphabet", rest[i])}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateHashChecksBcryptCost(t *testing.T) {
+	s := &Server{hasherAlgorithm: "bcrypt", bcryptCost: 12}
+
+	if err := s.validateHash("$2a$12$abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0"); err != nil {
+		t.Errorf("matching cost rejected: %v", err)
+	}
+	err := s.validateHash("$2a$10$abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0")
+	if err == nil || !strings.Contains(err.Error(), "cost 10, expected 12") {
+		t.Errorf("mismatched cost error = %v, want cost mismatch", err)
+	}
+	if err := s.validateHash("$2a$xx$abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0"); err == nil {
+		t.Error("malformed cost accepted")
+	}
+}
+
+func TestValidateHashChecksPbkdf2Iterations(t *testing.T) {
+	s := &Server{hasherAlgorithm: "pbkdf2", pbkdf2Iter: 25000}
+
+	if err := s.validateHash("$pbkdf2-sha256$i=25000,l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGQ"); err != nil {
+		t.Errorf("matching iterations rejected: %v", err)
+	}
+	err := s.validateHash("$pbkdf2-sha256$i=10000,l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGQ")
+	if err == nil || !strings.Contains(err.Error(), "10000 iterations, expected 25000") {
+		t.Errorf("mismatched iterations error = %v, want iteration mismatch", err)
+	}
+	if err := s.validateHash("$pbkdf2-sha256$l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGQ"); err == nil {
+		t.Error("hash without iterations accepted")
+	}
+}
+
+func TestValidateHashSkipsUnsetParameters(t *testing.T) {
+	s := &Server{hasherAlgorithm: "pbkdf2"}
+	if err := s.validateHash("$pbkdf2-sha256$i=1,l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGQ"); err != nil {
+		t.Errorf("iterations checked with PBKDF2_ITERATIONS unset: %v", err)
+	}
+}
+
+func TestCheckPbkdf2Structure(t *testing.T) {
+	const salt = "c2FsdHNhbHRzYWx0c2FsdA"
+	digest32 := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	digest64 := base64.RawStdEncoding.EncodeToString(make([]byte, 64))
+
+	for _, tc := range []struct {
+		name        string
+		hash        string
+		wantSegment string
+	}{
+		{"sha256", "$pbkdf2-sha256$i=25000,l=32$" + salt + "$" + digest32, ""},
+		{"sha512", "$pbkdf2-sha512$i=25000,l=64$" + salt + "$" + digest64, ""},
+		{"padded base64", "$pbkdf2-sha256$i=25000$" + salt + "==$" + digest32 + "=", ""},
+		{"missing digest", "$pbkdf2-sha256$i=25000,l=32$" + salt, "layout"},
+		{"unknown variant", "$pbkdf2-md5$i=25000,l=32$" + salt + "$" + digest32, "header"},
+		{"corrupt salt", "$pbkdf2-sha256$i=25000,l=32$c2Fsd!Nh$" + digest32, "salt"},
+		{"short salt", "$pbkdf2-sha256$i=25000,l=32$c2FsdA$" + digest32, "salt"},
+		{"corrupt digest", "$pbkdf2-sha256$i=25000,l=32$" + salt + "$" + digest32[:20] + "*" + digest32[21:], "digest"},
+		{"truncated digest", "$pbkdf2-sha256$i=25000,l=32$" + salt + "$" + digest32[:40], "digest"},
+		{"digest for wrong variant", "$pbkdf2-sha512$i=25000,l=32$" + salt + "$" + digest32, "digest"},
+		{"length param mismatch", "$pbkdf2-sha256$i=25000,l=16$" + salt + "$" + digest32, "digest"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assertHashSegment(t, checkPbkdf2Structure(tc.hash), tc.wantSegment)
+		})
+	}
+}
+
+func TestCheckBcryptStructure(t *testing.T) {
+	const salt = "abcdefghijklmnopqrstuv"
+	const hash = "wxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0"
+
+	for _, tc := range []struct {
+		name        string
+		hash        string
+		wantSegment string
+	}{
+		{"valid", "$2a$10$" + salt + hash, ""},
+		{"2b with dots and slashes", "$2b$12$./" + salt[2:] + hash, ""},
+		{"no cost", "$2a$" + salt + hash, "layout"},
+		{"three-digit cost", "$2a$100$" + salt + hash, "cost"},
+		{"truncated", "$2a$10$" + salt + hash[:30], "salt and hash"},
+		{"bad salt character", "$2a$10$abc+efghijklmnopqrstuv" + hash, "salt"},
+		{"bad hash character", "$2a$10$" + salt + "wxyz=BCDEFGHIJKLMNOPQRSTUVWXYZ0", "hash"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assertHashSegment(t, checkBcryptStructure(tc.hash), tc.wantSegment)
+		})
+	}
+}
+
+// assertHashSegment checks that err blames wantSegment, or is nil when
+// wantSegment is empty
+func assertHashSegment(t *testing.T, err error, wantSegment string) {
+	t.Helper()
+	if wantSegment == "" {
+		if err != nil {
+			t.Errorf("valid hash rejected: %v", err)
+		}
+		return
+	}
+	var malformed *malformedHashError
+	if !errors.As(err, &malformed) || malformed.Segment != wantSegment {
+		t.Errorf("error = %v, want malformed %s", err, wantSegment)
+	}
+}
+
+func TestValidateHashRejectsCorruptSegments(t *testing.T) {
+	s := &Server{hasherAlgorithm: "pbkdf2"}
+	err := s.validateHash("$pbkdf2-sha256$i=25000,l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGlnZXN0")
+	if err == nil || !strings.Contains(err.Error(), "malformed PBKDF2 hash: digest") {
+		t.Errorf("truncated digest error = %v, want malformed digest", err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// What the token hook does for a client over TOKEN_HOOK_RATE_LIMIT
+const (
+	// Issue the token without a Hydra lookup (cached claims or none)
+	hookRateLimitModeFallback = "fallback"
+	// Refuse the token with 429
+	hookRateLimitModeReject = "reject"
+)
+
+// hookLimiterSweepInterval is how often idle buckets are evicted
+const hookLimiterSweepInterval = time.Minute
+
+// clientRateLimiter is an in-memory token bucket per client ID for the
+// token hook (TOKEN_HOOK_RATE_LIMIT), so one misbehaving client can't flood
+// the sidecar and Hydra Admin. Each bucket holds up to burst tokens and
+// refills at rate per second. A bucket idle long enough to refill completely
+// is the same as a new one, so such buckets are evicted and the map only
+// holds recently active clients. A nil limiter allows everything.
+type clientRateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[string]*clientBucket
+	lastSweep time.Time
+}
+
+// clientBucket is one client's tokens as of last
+type clientBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newClientRateLimiter allows rate requests per second per client, in bursts
+// of up to rate rounded up (at least 1). A rate of 0 disables the limiter (nil).
+func newClientRateLimiter(rate float64) *clientRateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &clientRateLimiter{
+		rate:    rate,
+		burst:   math.Max(1, math.Ceil(rate)),
+		buckets: make(map[string]*clientBucket),
+	}
+}
+
+// allow takes a token from clientID's bucket, reporting false if it is empty
+func (l *clientRateLimiter) allow(clientID string, now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= hookLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[clientID]
+	if !ok {
+		b = &clientBucket{tokens: l.burst, last: now}
+		l.buckets[clientID] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep evicts buckets that have refilled completely (mu held)
+func (l *clientRateLimiter) sweep(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for id, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, id)
+		}
+	}
+	l.lastSweep = now
+}
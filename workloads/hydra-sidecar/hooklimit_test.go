@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientRateLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newClientRateLimiter(2)
+
+	// A burst of 2, then empty until it refills
+	for i := range 2 {
+		if !l.allow("svc-a", now) {
+			t.Fatalf("call %d refused within the burst", i+1)
+		}
+	}
+	if l.allow("svc-a", now) {
+		t.Error("third call in the same instant allowed, want refused")
+	}
+	// Buckets are per client
+	if !l.allow("svc-b", now) {
+		t.Error("svc-b refused because of svc-a")
+	}
+	if !l.allow("svc-a", now.Add(500*time.Millisecond)) || l.allow("svc-a", now.Add(500*time.Millisecond)) {
+		t.Error("after 500ms at 2/s, want exactly one more call")
+	}
+
+	var disabled *clientRateLimiter
+	if newClientRateLimiter(0) != nil || !disabled.allow("svc-a", now) {
+		t.Error("a zero rate should disable the limiter")
+	}
+}
+
+func TestClientRateLimiterEvictsIdleBuckets(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newClientRateLimiter(1)
+	for _, id := range []string{"svc-a", "svc-b", "svc-c"} {
+		l.allow(id, now)
+	}
+
+	// svc-a stays active; the others go idle past a full refill
+	now = now.Add(hookLimiterSweepInterval)
+	l.allow("svc-a", now.Add(-time.Millisecond))
+	l.allow("svc-a", now)
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d after the sweep, want only svc-a", len(l.buckets))
+	}
+	if _, ok := l.buckets["svc-a"]; !ok {
+		t.Error("active client svc-a was evicted")
+	}
+}
+
+func TestTokenHookRateLimit(t *testing.T) {
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"metadata":{"org_id":"acme"}}`))
+	}))
+	defer hydra.Close()
+	newServer := func(mode string) *Server {
+		return &Server{
+			hydraAdminURL: hydra.URL,
+			httpClient:    hydra.Client(),
+			hookLimiter:   newClientRateLimiter(1),
+			config:        Config{TokenHookRateLimitMode: mode},
+		}
+	}
+
+	// Fallback: the throttled token is issued without calling Hydra
+	s := newServer(hookRateLimitModeFallback)
+	if claims := tokenHookClaims(t, s, "svc-a"); claims["org_id"] != "acme" {
+		t.Fatalf("first call claims = %v, want metadata", claims)
+	}
+	if claims := tokenHookClaims(t, s, "svc-a"); claims["org_id"] != nil {
+		t.Errorf("throttled claims = %v, want no metadata", claims)
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Hydra saw %d calls, want 1", hits.Load())
+	}
+
+	// With the client cached, the throttled token keeps its metadata
+	s = newServer(hookRateLimitModeFallback)
+	s.clientCache = newClientInfoCache(time.Minute, 0)
+	tokenHookClaims(t, s, "svc-a")
+	if claims := tokenHookClaims(t, s, "svc-a"); claims["org_id"] != "acme" {
+		t.Errorf("throttled claims with cache = %v, want cached metadata", claims)
+	}
+
+	// Reject: 429 with Retry-After
+	s = newServer(hookRateLimitModeReject)
+	callTokenHook(t, s, "svc-a")
+	rec := callTokenHook(t, s, "svc-a")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("reject: status = %d, Retry-After = %q, want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
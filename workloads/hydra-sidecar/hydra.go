@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// newHydraRequest builds a request for the Hydra Admin API path (e.g.
+// "/admin/clients"). Every outbound Hydra call goes through here, so the
+// HYDRA_ADMIN_AUTH_HEADER credential applies to all of them. A non-nil body
+// is sent as JSON from a bytes.Reader, which doHydra can replay on retry.
+func (s *Server) newHydraRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.hydraAdminURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if header := s.config.HydraAdminAuthHeader; header != "" {
+		req.Header.Set(header, s.config.HydraAdminAuthValue)
+	}
+	return req, nil
+}
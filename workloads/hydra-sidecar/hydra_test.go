@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestHydraRequestsCarryAuthHeader(t *testing.T) {
+	var got []string
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Hydra-Auth"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"client_id":"c1","version":"v2.3.0"}`))
+	}))
+	t.Cleanup(hydra.Close)
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		config:        Config{HydraAdminAuthHeader: "X-Hydra-Auth", HydraAdminAuthValue: "Bearer t0ken"},
+		networkID:     uuid.Must(uuid.NewV4()),
+	}
+	if _, err := s.fetchClientInfo(context.Background(), "c1"); err != nil {
+		t.Fatalf("fetchClientInfo: %v", err)
+	}
+	if _, err := s.fetchHydraVersion(context.Background()); err != nil {
+		t.Fatalf("fetchHydraVersion: %v", err)
+	}
+	s.getClient(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/clients/c1", nil), "c1")
+	if err := s.deleteHydraClient(context.Background(), "c1"); err != nil {
+		t.Fatalf("deleteHydraClient: %v", err)
+	}
+
+	want := []string{
+		"GET /admin/clients/c1 Bearer t0ken",
+		"GET /version Bearer t0ken",
+		"GET /admin/clients/c1 Bearer t0ken",
+		"DELETE /admin/clients/c1 Bearer t0ken",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Hydra saw %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHydraCallsStopWhenCallerDisconnects(t *testing.T) {
+	received, released := make(chan struct{}), make(chan struct{})
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-r.Context().Done()
+		close(released)
+	}))
+	t.Cleanup(hydra.Close)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: uuid.Must(uuid.NewV4())}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodDelete, "/admin/clients/c1", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		s.deleteClient(rec, req, "c1")
+		close(done)
+	}()
+	<-received
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deleteClient kept waiting on Hydra after the caller disconnected")
+	}
+	select {
+	case <-released:
+	case <-time.After(5 * time.Second):
+		t.Error("Hydra request was not cancelled")
+	}
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", rec.Code)
+	}
+}
+
+func TestNewHydraRequest(t *testing.T) {
+	s := &Server{hydraAdminURL: "http://hydra:4445"}
+
+	req, err := s.newHydraRequest(context.Background(), http.MethodPost, "/admin/clients", []byte(`{}`))
+	if err != nil {
+		t.Fatalf("newHydraRequest: %v", err)
+	}
+	if req.URL.String() != "http://hydra:4445/admin/clients" || req.Header.Get("Content-Type") != "application/json" || req.GetBody == nil {
+		t.Errorf("request = %s %s, Content-Type %q, want a replayable JSON POST", req.Method, req.URL, req.Header.Get("Content-Type"))
+	}
+	if len(req.Header) != 1 {
+		t.Errorf("headers = %v, want only Content-Type without HYDRA_ADMIN_AUTH_HEADER", req.Header)
+	}
+
+	req, err = s.newHydraRequest(context.Background(), http.MethodGet, "/admin/clients/c1", nil)
+	if err != nil {
+		t.Fatalf("newHydraRequest: %v", err)
+	}
+	if req.Body != nil || req.Header.Get("Content-Type") != "" {
+		t.Errorf("bodyless request has body %v and Content-Type %q", req.Body, req.Header.Get("Content-Type"))
+	}
+}
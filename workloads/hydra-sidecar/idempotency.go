@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// Idempotency-Key support for client creation
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	idempotencyPendingMessage = "a request with this Idempotency-Key is still in progress"
+)
+
+// idempotencyRecord is one row of hydra_sidecar_idempotency_keys. Keys are
+// scoped to the caller's API key and network, so one caller can't replay
+// another's response. Response is empty while the create is in flight.
+type idempotencyRecord struct {
+	Key         string    `db:"idempotency_key"`
+	Caller      string    `db:"caller"`
+	NID         uuid.UUID `db:"nid"`
+	RequestHash string    `db:"request_hash"`
+	ClientID    string    `db:"client_id"`
+	Response    string    `db:"response"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// idempotencyStore is the subset of Store that persists idempotency keys
+type idempotencyStore interface {
+	// ReserveIdempotencyKey inserts rec unless an unexpired record holds its
+	// key, in which case that record is returned instead
+	ReserveIdempotencyKey(ctx context.Context, rec *idempotencyRecord, expiredBefore time.Time) (*idempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, rec *idempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, rec *idempotencyRecord) error
+}
+
+// idempotentCreate is a reserved Idempotency-Key for one create request. Until
+// complete is called, release frees the key so a retry can create the client.
+// A nil *idempotentCreate (no key sent, or the feature is off) is a no-op.
+type idempotentCreate struct {
+	store     idempotencyStore
+	rec       *idempotencyRecord
+	completed bool
+}
+
+// beginIdempotentCreate handles the request's Idempotency-Key, if any. It
+// answers the request itself (returning done) when the key replays an earlier
+// create, is still in flight, or was used with a different body; otherwise it
+// reserves the key for this request.
+func (s *Server) beginIdempotentCreate(w http.ResponseWriter, r *http.Request, nid uuid.UUID, body []byte) (idem *idempotentCreate, done bool) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" || s.idempotency == nil {
+		return nil, false
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "Idempotency-Key is longer than 255 characters")
+		return nil, true
+	}
+
+	sum := sha256.Sum256(body)
+	now := time.Now().UTC()
+	rec := &idempotencyRecord{
+		Key:         key,
+		Caller:      callerIdentity(r.Context()),
+		NID:         nid,
+		RequestHash: hex.EncodeToString(sum[:]),
+		CreatedAt:   now,
+	}
+	existing, err := s.idempotency.ReserveIdempotencyKey(r.Context(), rec, now.Add(-s.config.IdempotencyKeyTTL))
+	if err != nil {
+		log.Printf("Error reserving idempotency key: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return nil, true
+	}
+	if existing == nil {
+		return &idempotentCreate{store: s.idempotency, rec: rec}, false
+	}
+
+	switch {
+	case existing.RequestHash != rec.RequestHash:
+		writeJSONError(w, http.StatusUnprocessableEntity, errCodeInvalidRequest, "Idempotency-Key was already used with a different request body")
+	case existing.Response == "":
+		writeJSONError(w, http.StatusConflict, errCodeConflict, idempotencyPendingMessage)
+	default:
+		log.Printf("Replaying create of client %s for idempotency key", existing.ClientID)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(idempotentReplayedHeader, "true")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(existing.Response))
+	}
+	return nil, true
+}
+
+// complete stores the created client as the key's response. The plaintext
+// secret is dropped, so only the first response ever carries it.
+func (idem *idempotentCreate) complete(ctx context.Context, created ClientData) {
+	if idem == nil {
+		return
+	}
+	idem.completed = true
+
+	created.Secret = ""
+	response, err := json.Marshal(created)
+	if err != nil {
+		log.Printf("Warning: Could not encode idempotent response for %s: %v", created.ID, err)
+		return
+	}
+	idem.rec.ClientID = created.ID
+	idem.rec.Response = string(response)
+	// Detached from the request so a disconnecting caller still gets the key recorded
+	if err := idem.store.CompleteIdempotencyKey(context.WithoutCancel(ctx), idem.rec); err != nil {
+		// The key stays pending (409 on retry) until it expires, never a duplicate
+		log.Printf("Warning: Could not record idempotency key for %s: %v", created.ID, err)
+	}
+}
+
+// release frees the key if the create didn't complete
+func (idem *idempotentCreate) release(ctx context.Context) {
+	if idem == nil || idem.completed {
+		return
+	}
+	if err := idem.store.ReleaseIdempotencyKey(context.WithoutCancel(ctx), idem.rec); err != nil {
+		log.Printf("Warning: Could not release idempotency key: %v", err)
+	}
+}
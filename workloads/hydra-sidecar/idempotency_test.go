@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ory/hydra/v2/client"
+)
+
+// fakeIdempotencyStore keeps idempotency records in memory, keyed like the table
+type fakeIdempotencyStore struct {
+	records map[string]*idempotencyRecord
+}
+
+func idempotencyRowKey(rec *idempotencyRecord) string {
+	return rec.Key + "|" + rec.Caller + "|" + rec.NID.String()
+}
+
+func (f *fakeIdempotencyStore) ReserveIdempotencyKey(_ context.Context, rec *idempotencyRecord, expiredBefore time.Time) (*idempotencyRecord, error) {
+	if f.records == nil {
+		f.records = make(map[string]*idempotencyRecord)
+	}
+	if existing, ok := f.records[idempotencyRowKey(rec)]; ok && !existing.CreatedAt.Before(expiredBefore) {
+		copied := *existing
+		return &copied, nil
+	}
+	copied := *rec
+	f.records[idempotencyRowKey(rec)] = &copied
+	return nil, nil
+}
+
+func (f *fakeIdempotencyStore) CompleteIdempotencyKey(_ context.Context, rec *idempotencyRecord) error {
+	copied := *rec
+	f.records[idempotencyRowKey(rec)] = &copied
+	return nil
+}
+
+func (f *fakeIdempotencyStore) ReleaseIdempotencyKey(_ context.Context, rec *idempotencyRecord) error {
+	delete(f.records, idempotencyRowKey(rec))
+	return nil
+}
+
+func newIdempotentServer(store idempotencyStore) *Server {
+	return &Server{idempotency: store, config: Config{IdempotencyKeyTTL: time.Hour}}
+}
+
+func createRequest(key string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/admin/clients", nil)
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	return req
+}
+
+func TestIdempotentCreateReplaysWithoutSecret(t *testing.T) {
+	store := &fakeIdempotencyStore{}
+	s := newIdempotentServer(store)
+	nid := uuid.Must(uuid.NewV4())
+	body := []byte(`{"grant_types":["client_credentials"]}`)
+
+	idem, done := s.beginIdempotentCreate(httptest.NewRecorder(), createRequest("k1"), nid, body)
+	if done || idem == nil {
+		t.Fatalf("first request: done = %v, idem = %v, want a reservation", done, idem)
+	}
+	idem.complete(context.Background(), ClientData{Client: client.Client{ID: "c1", Secret: "plaintext"}, ClientSecretHash: "hash"})
+	idem.release(context.Background())
+
+	rec := httptest.NewRecorder()
+	if _, done := s.beginIdempotentCreate(rec, createRequest("k1"), nid, body); !done {
+		t.Fatal("repeat request was not answered from the stored response")
+	}
+	if rec.Code != http.StatusCreated || rec.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("replay status = %d, %s = %q, want 201 and true", rec.Code, idempotentReplayedHeader, rec.Header().Get(idempotentReplayedHeader))
+	}
+	var replayed ClientData
+	if err := json.NewDecoder(rec.Body).Decode(&replayed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if replayed.ID != "c1" || replayed.ClientSecretHash != "hash" || replayed.Secret != "" {
+		t.Errorf("replayed = %+v, want c1 with its hash and no secret", replayed)
+	}
+}
+
+func TestIdempotentCreateRejectsReuse(t *testing.T) {
+	store := &fakeIdempotencyStore{}
+	s := newIdempotentServer(store)
+	nid := uuid.Must(uuid.NewV4())
+	body := []byte(`{"grant_types":["client_credentials"]}`)
+
+	if _, done := s.beginIdempotentCreate(httptest.NewRecorder(), createRequest("k1"), nid, body); done {
+		t.Fatal("first request was not reserved")
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{"in flight", string(body), http.StatusConflict},
+		{"different body", `{"grant_types":["authorization_code"]}`, http.StatusUnprocessableEntity},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if _, done := s.beginIdempotentCreate(rec, createRequest("k1"), nid, []byte(tc.body)); !done {
+				t.Fatal("request was not answered")
+			}
+			if rec.Code != tc.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestIdempotentCreateReleasesFailedCreate(t *testing.T) {
+	store := &fakeIdempotencyStore{}
+	s := newIdempotentServer(store)
+	nid := uuid.Must(uuid.NewV4())
+	body := []byte(`{}`)
+
+	idem, _ := s.beginIdempotentCreate(httptest.NewRecorder(), createRequest("k1"), nid, body)
+	idem.release(context.Background())
+
+	if idem, done := s.beginIdempotentCreate(httptest.NewRecorder(), createRequest("k1"), nid, body); done || idem == nil {
+		t.Errorf("retry after a failed create: done = %v, want a new reservation", done)
+	}
+}
+
+func TestIdempotentCreateScopesKeys(t *testing.T) {
+	store := &fakeIdempotencyStore{}
+	s := newIdempotentServer(store)
+	body := []byte(`{}`)
+
+	idem, _ := s.beginIdempotentCreate(httptest.NewRecorder(), createRequest("k1"), uuid.Must(uuid.NewV4()), body)
+	idem.complete(context.Background(), ClientData{Client: client.Client{ID: "c1"}})
+
+	if _, done := s.beginIdempotentCreate(httptest.NewRecorder(), createRequest("k1"), uuid.Must(uuid.NewV4()), body); done {
+		t.Error("same key in another network replayed the first network's response")
+	}
+	other := createRequest("k1")
+	other = other.WithContext(context.WithValue(other.Context(), callerIdentityKey{}, "scoped-key:0123456789abcdef"))
+	if _, done := s.beginIdempotentCreate(httptest.NewRecorder(), other, idem.rec.NID, body); done {
+		t.Error("same key from another caller replayed the first caller's response")
+	}
+}
+
+func TestIdempotentCreateIgnoredWhenUnsetOrDisabled(t *testing.T) {
+	nid := uuid.Must(uuid.NewV4())
+	if idem, done := newIdempotentServer(&fakeIdempotencyStore{}).beginIdempotentCreate(httptest.NewRecorder(), createRequest(""), nid, nil); idem != nil || done {
+		t.Error("request without Idempotency-Key was reserved")
+	}
+	if idem, done := (&Server{}).beginIdempotentCreate(httptest.NewRecorder(), createRequest("k1"), nid, nil); idem != nil || done {
+		t.Error("Idempotency-Key was handled with the feature disabled")
+	}
+
+	rec := httptest.NewRecorder()
+	if _, done := newIdempotentServer(&fakeIdempotencyStore{}).beginIdempotentCreate(rec, createRequest(strings.Repeat("k", 256)), nid, nil); !done || rec.Code != http.StatusBadRequest {
+		t.Errorf("overlong key: done = %v, status = %d, want 400", done, rec.Code)
+	}
+}
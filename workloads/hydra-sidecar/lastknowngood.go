@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// clientInfoSource says where the token hook's client info came from
+type clientInfoSource int
+
+const (
+	infoFromHydra         clientInfoSource = iota // fresh fetch or unexpired cache entry
+	infoFromStaleCache                            // expired in-memory entry (SERVE_STALE_ON_ERROR)
+	infoFromLastKnownGood                         // persisted snapshot (LAST_KNOWN_GOOD_MAX_AGE)
+)
+
+// clientSnapshotStore persists the last client info fetched from Hydra, so
+// the token hook can outlast a Hydra outage and a sidecar restart
+type clientSnapshotStore interface {
+	SaveClientSnapshot(ctx context.Context, clientID string, info *ClientInfo, fetchedAt time.Time) error
+	// GetClientSnapshot returns nil info when no snapshot was fetched after fetchedAfter
+	GetClientSnapshot(ctx context.Context, clientID string, fetchedAfter time.Time) (*ClientInfo, time.Time, error)
+	DeleteClientSnapshot(ctx context.Context, clientID string) error
+}
+
+// saveSnapshot records info fetched from Hydra as the client's last known
+// good. Failures only cost the fallback, so they are logged, not returned.
+func (s *Server) saveSnapshot(ctx context.Context, clientID string, info *ClientInfo) {
+	if s.snapshots == nil {
+		return
+	}
+	if err := s.snapshots.SaveClientSnapshot(ctx, clientID, info, time.Now().UTC()); err != nil {
+		log.Printf("Warning: Could not save last-known-good client info for %s: %v", clientID, err)
+	}
+}
+
+// lastKnownGood returns the client's snapshot if one was fetched within
+// LAST_KNOWN_GOOD_MAX_AGE
+func (s *Server) lastKnownGood(ctx context.Context, clientID string, fetchErr error) (*ClientInfo, bool) {
+	if s.snapshots == nil {
+		return nil, false
+	}
+	now := time.Now().UTC()
+	info, fetchedAt, err := s.snapshots.GetClientSnapshot(ctx, clientID, now.Add(-s.config.LastKnownGoodMaxAge))
+	if err != nil {
+		log.Printf("Warning: Could not load last-known-good client info for %s: %v", clientID, err)
+		return nil, false
+	}
+	if info == nil {
+		return nil, false
+	}
+	s.metrics.TokenHookLastKnownGood()
+	log.Printf("WARNING: Hydra unavailable for client %s (%v), serving last-known-good client info fetched %s ago; claims carry %s: true",
+		clientID, fetchErr, now.Sub(fetchedAt).Round(time.Second), staleClaimsClaimName)
+	return info, true
+}
+
+// forgetSnapshot drops a deleted client's snapshot so it is never served
+func (s *Server) forgetSnapshot(ctx context.Context, clientID string) {
+	if s.snapshots == nil {
+		return
+	}
+	if err := s.snapshots.DeleteClientSnapshot(ctx, clientID); err != nil {
+		log.Printf("Warning: Could not delete last-known-good client info for %s: %v", clientID, err)
+	}
+}
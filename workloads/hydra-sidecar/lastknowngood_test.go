@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// fakeSnapshotStore keeps client snapshots in memory like hydra_sidecar_client_snapshots
+type fakeSnapshotStore struct {
+	infos     map[string]*ClientInfo
+	fetchedAt map[string]time.Time
+}
+
+func newFakeSnapshotStore() *fakeSnapshotStore {
+	return &fakeSnapshotStore{infos: make(map[string]*ClientInfo), fetchedAt: make(map[string]time.Time)}
+}
+
+func (f *fakeSnapshotStore) SaveClientSnapshot(_ context.Context, clientID string, info *ClientInfo, fetchedAt time.Time) error {
+	f.infos[clientID] = info
+	f.fetchedAt[clientID] = fetchedAt
+	return nil
+}
+
+func (f *fakeSnapshotStore) GetClientSnapshot(_ context.Context, clientID string, fetchedAfter time.Time) (*ClientInfo, time.Time, error) {
+	info, ok := f.infos[clientID]
+	if !ok || !f.fetchedAt[clientID].After(fetchedAfter) {
+		return nil, time.Time{}, nil
+	}
+	return info, f.fetchedAt[clientID], nil
+}
+
+func (f *fakeSnapshotStore) DeleteClientSnapshot(_ context.Context, clientID string) error {
+	delete(f.infos, clientID)
+	delete(f.fetchedAt, clientID)
+	return nil
+}
+
+func tokenHookClaims(t *testing.T, s *Server, clientID string) map[string]interface{} {
+	t.Helper()
+	rec := callTokenHook(t, s, clientID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var resp TokenHookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return resp.Session.AccessToken
+}
+
+func TestTokenHookServesLastKnownGood(t *testing.T) {
+	var down atomic.Bool
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"metadata":{"org_id":"acme"}}`))
+	}))
+	defer hydra.Close()
+
+	snapshots := newFakeSnapshotStore()
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		snapshots:     snapshots,
+		config:        Config{LastKnownGoodMaxAge: time.Hour},
+	}
+	if claims := tokenHookClaims(t, s, "svc-a"); claims[staleClaimsClaimName] != nil {
+		t.Fatalf("claims = %v, want no stale_claims from a live fetch", claims)
+	}
+	if snapshots.infos["svc-a"] == nil {
+		t.Fatal("live fetch was not saved as a snapshot")
+	}
+
+	// Hydra goes down: the snapshot is served and marked
+	down.Store(true)
+	claims := tokenHookClaims(t, s, "svc-a")
+	if claims["org_id"] != "acme" || claims[staleClaimsClaimName] != true {
+		t.Errorf("claims = %v, want org_id acme with stale_claims", claims)
+	}
+
+	// A snapshot older than LAST_KNOWN_GOOD_MAX_AGE is not served
+	snapshots.fetchedAt["svc-a"] = time.Now().Add(-2 * time.Hour)
+	if claims := tokenHookClaims(t, s, "svc-a"); claims["org_id"] != nil || claims[staleClaimsClaimName] != nil {
+		t.Errorf("claims = %v, want no metadata from an expired snapshot", claims)
+	}
+}
+
+func TestLastKnownGoodNeverServesNotFound(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer hydra.Close()
+
+	snapshots := newFakeSnapshotStore()
+	snapshots.SaveClientSnapshot(context.Background(), "gone", &ClientInfo{Metadata: map[string]any{"org_id": "acme"}}, time.Now())
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		snapshots:     snapshots,
+		config:        Config{LastKnownGoodMaxAge: time.Hour},
+	}
+	if info, source, err := s.clientInfo(context.Background(), "gone"); err == nil || info != nil || source != infoFromHydra {
+		t.Errorf("clientInfo = %v, %v, %v, want the 404 error", info, source, err)
+	}
+}
+
+func TestBatchDeleteForgetsSnapshot(t *testing.T) {
+	nid := uuid.Must(uuid.NewV4())
+	hydra, _ := newFakeHydraDelete(t)
+	snapshots := newFakeSnapshotStore()
+	snapshots.SaveClientSnapshot(context.Background(), "a", &ClientInfo{}, time.Now())
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: nid, snapshots: snapshots}
+
+	batchDelete(t, s, fakeClientLookup{nid: {"a"}}, `{"client_ids":["a"]}`)
+	if _, ok := snapshots.infos["a"]; ok {
+		t.Error("deleted client's snapshot was kept")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestListClientsPassesPaginationThrough(t *testing.T) {
+	const link = `</admin/clients?page_size=2&page_token=abc>; rel="next"`
+	var gotQuery string
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		w.Header().Set("Link", link)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[]`))
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		networkID:     uuid.Must(uuid.NewV4()),
+	}
+	rec := httptest.NewRecorder()
+	s.handleClients(rec, httptest.NewRequest(http.MethodGet, "/admin/clients?page_size=2&page_token=xyz&other=1", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if gotQuery != "page_size=2&page_token=xyz" {
+		t.Errorf("hydra query = %q", gotQuery)
+	}
+	if got := rec.Header().Get("Link"); got != link {
+		t.Errorf("Link = %q, want %q", got, link)
+	}
+	var clients []ClientData
+	if err := json.Unmarshal(rec.Body.Bytes(), &clients); err != nil || len(clients) != 0 {
+		t.Errorf("body = %s (err %v)", rec.Body.String(), err)
+	}
+}
+
+func TestListClientsPassesHydraErrorsThrough(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		networkID:     uuid.Must(uuid.NewV4()),
+	}
+	rec := httptest.NewRecorder()
+	s.handleClients(rec, httptest.NewRequest(http.MethodGet, "/admin/clients?page_size=-1", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleClientsRejectsOtherMethods(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&Server{}).handleClients(rec, httptest.NewRequest(http.MethodPut, "/admin/clients", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want 405", rec.Code)
+	}
+}
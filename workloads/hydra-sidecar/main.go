@@ -18,94 +18,978 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/gofrs/uuid"
 )
 
 // Config holds the sidecar configuration
 type Config struct {
 	Port            string
-	DatabaseURL     string
+	DatabaseURL     string `debug:"redact"`
 	HydraAdminURL   string
 	HasherAlgorithm string
+
+	// Read replica for hash lookups, client ID lists, and counts (empty = primary)
+	DatabaseReadURL string `debug:"redact"`
+
+	// Apply the embedded sidecar migrations at startup
+	RunMigrations bool
+
+	// HTTP server timeouts: reading a whole request, writing a response, and keep-alive idle
+	ServerReadTimeout  time.Duration
+	ServerWriteTimeout time.Duration
+	ServerIdleTimeout  time.Duration
+
+	// Header attached to every Hydra Admin API request, for Hydra behind an auth proxy (empty = none)
+	HydraAdminAuthHeader string
+	HydraAdminAuthValue  string `debug:"redact"`
+
+	// Expected hash parameters (0 = not checked)
+	BcryptCost       int
+	Pbkdf2Iterations int
+
+	// Probe paths (some platforms expect /healthz and /readyz)
+	HealthPath       string
+	ReadyPath        string
+	LegacyProbePaths bool
+
+	// Readiness also requires Hydra Admin to answer /health/alive
+	ReadinessCheckHydra bool
+
+	// Token issuance tracking (batched writes to a sidecar-owned table)
+	UsageTracking      bool
+	UsageFlushInterval time.Duration
+
+	// Maximum client lifetime policy for created clients
+	MaxClientLifetime     time.Duration
+	MaxClientLifetimeMode string
+
+	// Spread secret expiries by up to this many seconds per client (0 = off)
+	ExpiryJitterSeconds int
+
+	// Claim name -> Go text/template evaluated against client metadata
+	ClaimTemplatesJSON string
+
+	// Minimum length of rotated secrets returned by Hydra
+	MinSecretLength     int
+	MinSecretLengthMode string
+
+	// Store query timing logs
+	DBQueryLogging bool
+	DBSlowQueryMS  int
+
+	// Database connection pool limits
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+
+	// Shared secret for token hook HMAC verification
+	TokenHookSecret string `debug:"redact"`
+
+	// Hydra Admin API retries, capped by a shared retry budget (token bucket)
+	HydraRetryAttempts      int
+	HydraRetryBaseDelay     time.Duration
+	RetryBudgetCapacity     int
+	RetryBudgetRefillPerSec float64
+
+	// Hydra Admin circuit breaker: open after this many consecutive failures (0 = off)
+	HydraCircuitFailures int
+	HydraCircuitCooldown time.Duration
+
+	// JSON object of metadata key -> {"type": ..., "enum": [...]}
+	MetadataSchemaJSON string
+
+	// Regex a caller-chosen client_id must match on create (empty = any)
+	ClientIDPattern string
+
+	// Failed operations tolerated by an atomic sync before it rolls back
+	SyncMaxFailures int
+	// Clients a best-effort sync upserts or deletes in parallel
+	SyncConcurrency int
+	// Largest fraction of a network's clients a full sync may delete without ?force=true (0 = no limit)
+	MaxSyncDeleteRatio float64
+
+	// How often to retry the default network ID lookup when it failed at startup (0 = only on demand)
+	NetworkRefreshInterval time.Duration
+
+	// Mark deleted clients in their metadata instead of deleting them, so they can be restored
+	SoftDeleteEnabled bool
+
+	// How long the token hook caches client info from Hydra (0 = no caching)
+	MetadataCacheTTL time.Duration
+	// Preload the cache at startup with up to CacheWarmupSize recently active clients
+	CacheWarmup     bool
+	CacheWarmupSize int
+
+	// Environment name stamped into every token as the "env" claim
+	TokenHookEnvClaim string
+
+	// Prefix for metadata-derived claim keys, e.g. https://ourco.io (empty = none)
+	ClaimNamespace string
+
+	// PEM private key signing the entitlements claim, its JWS key ID (empty = JWK thumbprint),
+	// and the comma-separated metadata keys it covers (empty = all injected metadata)
+	ClaimSigningKeyFile   string
+	ClaimSigningKeyID     string
+	EntitlementsClaimKeys string
+
+	// Comma-separated key:type pairs converting metadata claims to int, float, or bool
+	ClaimCoerce string
+
+	// Service POSTed each token hook's client ID, answering extra claims (empty = off);
+	// redacted since it may carry credentials
+	ClaimEnrichmentURL        string `debug:"redact"`
+	ClaimEnrichmentTimeout    time.Duration
+	ClaimEnrichmentPrecedence string
+
+	// Comma-separated metadata keys injected as claims (empty = all), minus the deny list
+	ClaimAllowlist string
+	ClaimDenylist  string
+
+	// How object and array metadata values become claims: off (as is), flatten, or strict (dropped)
+	FlattenClaims string
+
+	// Largest serialized token hook claims (0 = no limit), and whether larger
+	// claims lose their biggest metadata fields (drop) or fail with 413 (reject)
+	MaxClaimsBytes int
+	MaxClaimsMode  string
+
+	// Largest token hook response buffer kept for reuse (0 = no pooling)
+	ResponseBufferMaxBytes int
+
+	// Largest request body accepted, and the larger one for /sync/ routes (0 = no limit)
+	MaxRequestBodyBytes     int64
+	MaxSyncRequestBodyBytes int64
+
+	// Grace period past client_secret_expires_at before the token hook rejects a client
+	ClockSkewTolerance time.Duration
+
+	// Return 503 from the token hook when Hydra times out, instead of issuing tokens without metadata claims
+	TokenHookFailClosed bool
+
+	// Retry the token hook's client info fetch once when Hydra answers 5xx
+	TokenHookRetryOn5xx bool
+
+	// Token hook calls allowed per second per client ID (0 = unlimited)
+	TokenHookRateLimit float64
+	// What a client over TOKEN_HOOK_RATE_LIMIT gets: "fallback" (token without a Hydra lookup) or "reject" (429)
+	TokenHookRateLimitMode string
+
+	// Secret checks allowed per second per client on POST /admin/clients/{id}/verify-secret
+	VerifySecretRateLimit float64
+
+	// Add the client's configured scopes to every token as the allowed_scope claim
+	TokenHookInjectAllowedScopes bool
+
+	// Serve expired METADATA_CACHE_TTL entries (with a stale claim) when Hydra can't be reached
+	ServeStaleOnError bool
+	// How long past METADATA_CACHE_TTL an entry is kept for SERVE_STALE_ON_ERROR
+	ServeStaleMaxAge time.Duration
+
+	// Oldest persisted client info snapshot the token hook serves when Hydra can't be reached (0 = off)
+	LastKnownGoodMaxAge time.Duration
+
+	// Token hook error body: code for denied tokens, and whether to add error_hint/status_code
+	TokenHookDenyError        string
+	TokenHookErrorExtraFields bool
+
+	// JSON object of grant type -> default token_endpoint_auth_method for create and sync
+	AuthMethodDefaultsJSON string
+
+	// OTLP/HTTP trace collector endpoint (empty = tracing disabled)
+	OTLPEndpoint string
+
+	// JSON object of claims every token gets unless the client's metadata sets them
+	DefaultClaimsJSON string
+
+	// JSON object of metadata tier -> {"count", "time_window"} injected as the rate_limit claim
+	TierRateLimitsJSON string
+	// JSON object of free/pro/enterprise -> requests per minute injected as the rate_limit_rpm claim
+	TierRPMLimitsJSON string
+
+	// Record create/rotate/delete/sync in hydra_sidecar_audit_events
+	AuditLog bool
+
+	// Also append audit events as JSON lines to this file ("-" = stdout, empty = off)
+	AuditLogPath string
+
+	// How long a create's Idempotency-Key is remembered (0 = Idempotency-Key ignored)
+	IdempotencyKeyTTL time.Duration
+
+	// Bearer token required on /admin, /sync, and /debug routes (empty = no auth)
+	AdminAPIKey string `debug:"redact"`
+	// JSON object of API key -> the one network it may target
+	ScopedAPIKeysJSON string `debug:"redact"`
+
+	// Comma-separated browser origins allowed to call admin routes ("*" = any, empty = CORS off)
+	CORSAllowedOrigins string
+
+	// Serve HTTPS with this certificate and key (empty = plaintext HTTP)
+	TLSCertFile string
+	TLSKeyFile  string
+	// Require client certificates signed by this CA bundle (mTLS, empty = no client certs)
+	TLSClientCAFile string
 }
 
 func loadConfig() Config {
+	// Optional JSON settings file; env vars override its values
+	configPath := os.Getenv("CONFIG_FILE")
+	settings, err := loadConfigFile(configPath)
+	if err != nil {
+		log.Fatalf("CONFIG_FILE: %v", err)
+	}
+	configFileSettings, configKeysRead = settings, map[string]bool{}
+
 	cfg := Config{
 		Port:            getEnv("PORT", "8080"),
 		DatabaseURL:     getEnv("DATABASE_URL", ""),
+		DatabaseReadURL: getEnv("DATABASE_READ_URL", ""),
 		HydraAdminURL:   getEnv("HYDRA_ADMIN_URL", "http://localhost:4445"),
 		HasherAlgorithm: getEnv("HASHER_ALGORITHM", "pbkdf2"),
+
+		RunMigrations: getEnvBool("RUN_MIGRATIONS", false),
+
+		ServerReadTimeout:  getEnvDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+		ServerWriteTimeout: getEnvDuration("SERVER_WRITE_TIMEOUT", 60*time.Second),
+		ServerIdleTimeout:  getEnvDuration("SERVER_IDLE_TIMEOUT", 120*time.Second),
+
+		HydraAdminAuthHeader: getEnv("HYDRA_ADMIN_AUTH_HEADER", ""),
+		HydraAdminAuthValue:  getEnv("HYDRA_ADMIN_AUTH_VALUE", ""),
+
+		BcryptCost:       getEnvInt("BCRYPT_COST", 0),
+		Pbkdf2Iterations: getEnvInt("PBKDF2_ITERATIONS", 0),
+
+		HealthPath:       getEnv("HEALTH_PATH", "/health"),
+		ReadyPath:        getEnv("READY_PATH", "/ready"),
+		LegacyProbePaths: getEnvBool("LEGACY_PROBE_PATHS", false),
+
+		ReadinessCheckHydra: getEnvBool("READINESS_CHECK_HYDRA", true),
+
+		UsageTracking:      getEnvBool("USAGE_TRACKING", false),
+		UsageFlushInterval: getEnvDuration("USAGE_FLUSH_INTERVAL", 30*time.Second),
+
+		MaxClientLifetime:     getEnvDuration("MAX_CLIENT_LIFETIME", 0),
+		MaxClientLifetimeMode: getEnv("MAX_CLIENT_LIFETIME_MODE", lifetimeModeReject),
+
+		ExpiryJitterSeconds: getEnvInt("EXPIRY_JITTER_SECONDS", 0),
+
+		ClaimTemplatesJSON: getEnv("CLAIM_TEMPLATES_JSON", ""),
+
+		MinSecretLength:     getEnvInt("MIN_SECRET_LENGTH", 0),
+		MinSecretLengthMode: getEnv("MIN_SECRET_LENGTH_MODE", secretLengthModeWarn),
+
+		DBQueryLogging: getEnvBool("DB_QUERY_LOGGING", false),
+		DBSlowQueryMS:  getEnvInt("DB_SLOW_QUERY_MS", 0),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 20),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+
+		TokenHookSecret: getEnv("TOKEN_HOOK_SECRET", ""),
+
+		HydraRetryAttempts:      getEnvInt("HYDRA_RETRY_ATTEMPTS", 3),
+		HydraRetryBaseDelay:     getEnvDuration("HYDRA_RETRY_BASE_DELAY", 100*time.Millisecond),
+		RetryBudgetCapacity:     getEnvInt("RETRY_BUDGET_CAPACITY", 20),
+		RetryBudgetRefillPerSec: getEnvFloat("RETRY_BUDGET_REFILL_PER_SEC", 2),
+
+		HydraCircuitFailures: getEnvInt("HYDRA_CIRCUIT_FAILURES", 5),
+		HydraCircuitCooldown: getEnvDuration("HYDRA_CIRCUIT_COOLDOWN", 30*time.Second),
+
+		MetadataSchemaJSON: getEnv("METADATA_SCHEMA_JSON", ""),
+
+		ClientIDPattern: getEnv("CLIENT_ID_PATTERN", ""),
+
+		SyncMaxFailures:    getEnvInt("SYNC_MAX_FAILURES", 0),
+		SyncConcurrency:    getEnvInt("SYNC_CONCURRENCY", 4),
+		MaxSyncDeleteRatio: getEnvFloat("MAX_SYNC_DELETE_RATIO", 0),
+
+		NetworkRefreshInterval: getEnvDuration("NETWORK_REFRESH_INTERVAL", 10*time.Second),
+
+		SoftDeleteEnabled: getEnvBool("SOFT_DELETE_ENABLED", false),
+
+		MetadataCacheTTL: getEnvDuration("METADATA_CACHE_TTL", 30*time.Second),
+		CacheWarmup:      getEnvBool("CACHE_WARMUP", false),
+		CacheWarmupSize:  getEnvInt("CACHE_WARMUP_SIZE", 1000),
+
+		TokenHookEnvClaim: getEnv("TOKEN_HOOK_ENV_CLAIM", ""),
+		ClaimNamespace:    getEnv("CLAIM_NAMESPACE", ""),
+
+		ClaimSigningKeyFile:   getEnv("CLAIM_SIGNING_KEY_FILE", ""),
+		ClaimSigningKeyID:     getEnv("CLAIM_SIGNING_KEY_ID", ""),
+		EntitlementsClaimKeys: getEnv("ENTITLEMENTS_CLAIM_KEYS", ""),
+
+		ClaimCoerce: getEnv("CLAIM_COERCE", ""),
+
+		ClaimEnrichmentURL:        getEnv("CLAIM_ENRICHMENT_URL", ""),
+		ClaimEnrichmentTimeout:    getEnvDuration("CLAIM_ENRICHMENT_TIMEOUT", 200*time.Millisecond),
+		ClaimEnrichmentPrecedence: getEnv("CLAIM_ENRICHMENT_PRECEDENCE", enrichmentPrecedenceMetadata),
+
+		ClaimAllowlist: getEnv("CLAIM_ALLOWLIST", ""),
+		ClaimDenylist:  getEnv("CLAIM_DENYLIST", ""),
+
+		FlattenClaims: getEnv("FLATTEN_CLAIMS", claimValuesAsIs),
+
+		MaxClaimsBytes: getEnvInt("MAX_CLAIMS_BYTES", 0),
+		MaxClaimsMode:  getEnv("MAX_CLAIMS_MODE", claimsSizeModeDrop),
+
+		ResponseBufferMaxBytes: getEnvInt("RESPONSE_BUFFER_MAX_BYTES", 64<<10),
+
+		MaxRequestBodyBytes:     int64(getEnvInt("MAX_REQUEST_BODY_BYTES", 4<<20)),
+		MaxSyncRequestBodyBytes: int64(getEnvInt("MAX_SYNC_REQUEST_BODY_BYTES", 64<<20)),
+
+		ClockSkewTolerance: getEnvDuration("CLOCK_SKEW_TOLERANCE", 0),
+
+		TokenHookFailClosed: getEnvBool("TOKEN_HOOK_FAIL_CLOSED", false),
+		TokenHookRetryOn5xx: getEnvBool("TOKEN_HOOK_RETRY_ON_5XX", false),
+		ServeStaleOnError:   getEnvBool("SERVE_STALE_ON_ERROR", false),
+		ServeStaleMaxAge:    getEnvDuration("SERVE_STALE_MAX_AGE", 24*time.Hour),
+
+		TokenHookRateLimit:     getEnvFloat("TOKEN_HOOK_RATE_LIMIT", 0),
+		TokenHookRateLimitMode: getEnv("TOKEN_HOOK_RATE_LIMIT_MODE", hookRateLimitModeFallback),
+
+		VerifySecretRateLimit: getEnvFloat("VERIFY_SECRET_RATE_LIMIT", 0.1),
+
+		TokenHookInjectAllowedScopes: getEnvBool("TOKEN_HOOK_INJECT_ALLOWED_SCOPES", false),
+
+		LastKnownGoodMaxAge: getEnvDuration("LAST_KNOWN_GOOD_MAX_AGE", 0),
+
+		TokenHookDenyError:        getEnv("TOKEN_HOOK_DENY_ERROR", defaultDenyError),
+		TokenHookErrorExtraFields: getEnvBool("TOKEN_HOOK_ERROR_EXTRA_FIELDS", false),
+
+		AuthMethodDefaultsJSON: getEnv("AUTH_METHOD_DEFAULTS_JSON", ""),
+
+		DefaultClaimsJSON: getEnv("DEFAULT_CLAIMS", ""),
+
+		TierRateLimitsJSON: getEnv("TIER_RATE_LIMITS_JSON", ""),
+		TierRPMLimitsJSON:  getEnv("TIER_RPM_LIMITS_JSON", ""),
+
+		AuditLog:     getEnvBool("AUDIT_LOG", false),
+		AuditLogPath: getEnv("AUDIT_LOG_PATH", ""),
+
+		IdempotencyKeyTTL: getEnvDuration("IDEMPOTENCY_KEY_TTL", 0),
+
+		OTLPEndpoint: getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+
+		AdminAPIKey:       getEnv("ADMIN_API_KEY", ""),
+		ScopedAPIKeysJSON: getEnv("SCOPED_API_KEYS_JSON", ""),
+
+		CORSAllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", ""),
+
+		TLSCertFile:     getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:      getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile: getEnv("TLS_CLIENT_CA_FILE", ""),
+	}
+
+	if err := checkConfigFileKeys(configPath); err != nil {
+		log.Fatalf("CONFIG_FILE: %v", err)
 	}
 
 	if cfg.DatabaseURL == "" {
 		log.Fatal("DATABASE_URL is required")
 	}
 
+	if err := validateProbePaths(cfg); err != nil {
+		log.Fatalf("Invalid probe path configuration: %v", err)
+	}
+	if cfg.UsageTracking && cfg.UsageFlushInterval <= 0 {
+		log.Fatalf("USAGE_FLUSH_INTERVAL must be positive, got %s", cfg.UsageFlushInterval)
+	}
+
+	if (cfg.HydraAdminAuthHeader == "") != (cfg.HydraAdminAuthValue == "") {
+		log.Fatal("HYDRA_ADMIN_AUTH_HEADER and HYDRA_ADMIN_AUTH_VALUE must be set together")
+	}
+	if strings.ContainsAny(cfg.HydraAdminAuthHeader, " \t\r\n:") {
+		log.Fatalf("HYDRA_ADMIN_AUTH_HEADER must be a bare header name, got %q", cfg.HydraAdminAuthHeader)
+	}
+
+	if cfg.MaxClientLifetimeMode != lifetimeModeReject && cfg.MaxClientLifetimeMode != lifetimeModeClamp {
+		log.Fatalf("MAX_CLIENT_LIFETIME_MODE must be %q or %q, got %q",
+			lifetimeModeReject, lifetimeModeClamp, cfg.MaxClientLifetimeMode)
+	}
+	if cfg.ExpiryJitterSeconds < 0 {
+		log.Fatalf("EXPIRY_JITTER_SECONDS must be non-negative, got %d", cfg.ExpiryJitterSeconds)
+	}
+
+	switch cfg.FlattenClaims {
+	case claimValuesAsIs, claimValuesFlatten, claimValuesStrict:
+	default:
+		log.Fatalf("FLATTEN_CLAIMS must be %q, %q, or %q, got %q",
+			claimValuesAsIs, claimValuesFlatten, claimValuesStrict, cfg.FlattenClaims)
+	}
+
+	if cfg.MaxClaimsBytes < 0 {
+		log.Fatalf("MAX_CLAIMS_BYTES must not be negative, got %d", cfg.MaxClaimsBytes)
+	}
+	if cfg.MaxClaimsMode != claimsSizeModeDrop && cfg.MaxClaimsMode != claimsSizeModeReject {
+		log.Fatalf("MAX_CLAIMS_MODE must be %q or %q, got %q",
+			claimsSizeModeDrop, claimsSizeModeReject, cfg.MaxClaimsMode)
+	}
+
+	if cfg.MinSecretLengthMode != secretLengthModeWarn && cfg.MinSecretLengthMode != secretLengthModeFail {
+		log.Fatalf("MIN_SECRET_LENGTH_MODE must be %q or %q, got %q",
+			secretLengthModeWarn, secretLengthModeFail, cfg.MinSecretLengthMode)
+	}
+
+	for name, d := range map[string]time.Duration{
+		"SERVER_READ_TIMEOUT":  cfg.ServerReadTimeout,
+		"SERVER_WRITE_TIMEOUT": cfg.ServerWriteTimeout,
+		"SERVER_IDLE_TIMEOUT":  cfg.ServerIdleTimeout,
+	} {
+		if d <= 0 {
+			log.Fatalf("%s must be positive, got %s", name, d)
+		}
+	}
+
+	if cfg.ClockSkewTolerance < 0 {
+		log.Fatalf("CLOCK_SKEW_TOLERANCE must not be negative, got %s", cfg.ClockSkewTolerance)
+	}
+	if cfg.CacheWarmup && cfg.CacheWarmupSize < 1 {
+		log.Fatalf("CACHE_WARMUP_SIZE must be at least 1, got %d", cfg.CacheWarmupSize)
+	}
+	if cfg.NetworkRefreshInterval < 0 {
+		log.Fatalf("NETWORK_REFRESH_INTERVAL must not be negative, got %s", cfg.NetworkRefreshInterval)
+	}
+	if cfg.ServeStaleMaxAge < 0 {
+		log.Fatalf("SERVE_STALE_MAX_AGE must not be negative, got %s", cfg.ServeStaleMaxAge)
+	}
+	if cfg.LastKnownGoodMaxAge < 0 {
+		log.Fatalf("LAST_KNOWN_GOOD_MAX_AGE must not be negative, got %s", cfg.LastKnownGoodMaxAge)
+	}
+	if cfg.IdempotencyKeyTTL < 0 {
+		log.Fatalf("IDEMPOTENCY_KEY_TTL must not be negative, got %s", cfg.IdempotencyKeyTTL)
+	}
+	if cfg.DBMaxOpenConns < 1 {
+		log.Fatalf("DB_MAX_OPEN_CONNS must be at least 1, got %d", cfg.DBMaxOpenConns)
+	}
+	if cfg.DBMaxIdleConns < 0 || cfg.DBMaxIdleConns > cfg.DBMaxOpenConns {
+		log.Fatalf("DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS (%d), got %d", cfg.DBMaxOpenConns, cfg.DBMaxIdleConns)
+	}
+	if cfg.DBConnMaxLifetime < 0 {
+		log.Fatalf("DB_CONN_MAX_LIFETIME must not be negative, got %s", cfg.DBConnMaxLifetime)
+	}
+	if cfg.SyncConcurrency < 1 {
+		log.Fatalf("SYNC_CONCURRENCY must be at least 1, got %d", cfg.SyncConcurrency)
+	}
+	if cfg.MaxSyncDeleteRatio < 0 || cfg.MaxSyncDeleteRatio > 1 {
+		log.Fatalf("MAX_SYNC_DELETE_RATIO must be between 0 and 1, got %g", cfg.MaxSyncDeleteRatio)
+	}
+	if cfg.MaxRequestBodyBytes < 0 || cfg.MaxSyncRequestBodyBytes < 0 {
+		log.Fatalf("MAX_REQUEST_BODY_BYTES and MAX_SYNC_REQUEST_BODY_BYTES must not be negative")
+	}
+	if cfg.BcryptCost < 0 || cfg.Pbkdf2Iterations < 0 {
+		log.Fatalf("BCRYPT_COST and PBKDF2_ITERATIONS must not be negative")
+	}
+	if cfg.ClaimEnrichmentURL != "" {
+		if u, err := url.Parse(cfg.ClaimEnrichmentURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("CLAIM_ENRICHMENT_URL must be an absolute http(s) URL, got %q", cfg.ClaimEnrichmentURL)
+		}
+		if cfg.ClaimEnrichmentTimeout <= 0 {
+			log.Fatalf("CLAIM_ENRICHMENT_TIMEOUT must be positive, got %s", cfg.ClaimEnrichmentTimeout)
+		}
+	}
+	if cfg.ClaimEnrichmentPrecedence != enrichmentPrecedenceMetadata && cfg.ClaimEnrichmentPrecedence != enrichmentPrecedenceRemote {
+		log.Fatalf("CLAIM_ENRICHMENT_PRECEDENCE must be %q or %q, got %q",
+			enrichmentPrecedenceMetadata, enrichmentPrecedenceRemote, cfg.ClaimEnrichmentPrecedence)
+	}
+	if cfg.TokenHookRateLimit < 0 {
+		log.Fatalf("TOKEN_HOOK_RATE_LIMIT must not be negative, got %g", cfg.TokenHookRateLimit)
+	}
+	if cfg.TokenHookRateLimitMode != hookRateLimitModeFallback && cfg.TokenHookRateLimitMode != hookRateLimitModeReject {
+		log.Fatalf("TOKEN_HOOK_RATE_LIMIT_MODE must be %q or %q, got %q",
+			hookRateLimitModeFallback, hookRateLimitModeReject, cfg.TokenHookRateLimitMode)
+	}
+	if cfg.VerifySecretRateLimit <= 0 {
+		log.Fatalf("VERIFY_SECRET_RATE_LIMIT must be positive, got %g", cfg.VerifySecretRateLimit)
+	}
+	if cfg.HydraCircuitFailures < 0 {
+		log.Fatalf("HYDRA_CIRCUIT_FAILURES must not be negative, got %d", cfg.HydraCircuitFailures)
+	}
+	if cfg.HydraCircuitFailures > 0 && cfg.HydraCircuitCooldown <= 0 {
+		log.Fatalf("HYDRA_CIRCUIT_COOLDOWN must be positive, got %s", cfg.HydraCircuitCooldown)
+	}
+
+	if cfg.AdminAPIKey == "" && cfg.ScopedAPIKeysJSON == "" {
+		log.Printf("Warning: ADMIN_API_KEY is not set, /admin, /sync, /debug, and /capabilities endpoints are unauthenticated")
+	}
+
 	return cfg
 }
 
+// fixedRoutes are the non-probe paths registered by newMux
+var fixedRoutes = []string{
+	"/token-hook",
+	"/token-hook/preview/",
+	"/admin/clients",
+	"/admin/clients/",
+	"/admin/clients/rotate/",
+	"/admin/clients/restore/",
+	"/admin/clients/noncompliant",
+	"/admin/clients/count",
+	"/admin/clients/search",
+	"/admin/clients/deleted",
+	"/admin/clients/export",
+	"/admin/clients/cross-network-duplicates",
+	"/admin/clients/delete-batch",
+	"/sync/clients",
+	"/sync/clients/upsert",
+	"/sync/preflight",
+	"/sync/clients/diff",
+	"/version",
+	"/info",
+	"/debug/config",
+	"/metrics",
+	"/capabilities",
+	"/admin/audit/export",
+}
+
+// staleCacheMaxAge is how long expired cache entries are kept: only as long
+// as SERVE_STALE_ON_ERROR can serve them
+func staleCacheMaxAge(cfg Config) time.Duration {
+	if !cfg.ServeStaleOnError {
+		return 0
+	}
+	return cfg.ServeStaleMaxAge
+}
+
+// serverTLSConfig loads TLS_CERT_FILE and TLS_KEY_FILE, plus TLS_CLIENT_CA_FILE
+// for mTLS. It returns nil without a certificate (plaintext HTTP). Files are
+// read once, so a renewed certificate takes effect on restart.
+func serverTLSConfig(cfg Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS_CLIENT_CA_FILE: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("TLS_CLIENT_CA_FILE %s contains no PEM certificates", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// validateProbePaths rejects probe paths that would make http.ServeMux panic
+// at registration time (missing leading slash or duplicate patterns)
+func validateProbePaths(cfg Config) error {
+	for name, path := range map[string]string{"HEALTH_PATH": cfg.HealthPath, "READY_PATH": cfg.ReadyPath} {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("%s must start with '/', got %q", name, path)
+		}
+		for _, route := range fixedRoutes {
+			if path == route {
+				return fmt.Errorf("%s %q conflicts with an existing route", name, path)
+			}
+		}
+	}
+
+	if cfg.HealthPath == cfg.ReadyPath {
+		return fmt.Errorf("HEALTH_PATH and READY_PATH must differ, both are %q", cfg.HealthPath)
+	}
+
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
+	if value := lookupSetting(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q (expected true/false)", key, value)
+	}
+	return b
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q (expected an integer)", key, value)
+	}
+	return n
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q (expected a number)", key, value)
+	}
+	return f
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q (expected a duration like 30s)", key, value)
+	}
+	return d
+}
+
+// newMux registers all handlers on a new ServeMux
+func newMux(cfg Config, server *Server) *http.ServeMux {
+	mux := http.NewServeMux()
+	// handle registers a route inside its own trace span (probes and
+	// /metrics stay untraced to keep scrapes out of traces), with the
+	// route's request body limit
+	handle := func(pattern string, h http.HandlerFunc) {
+		mux.HandleFunc(pattern, traceHandler(pattern, limitBody(bodyLimitFor(cfg, pattern), h)))
+	}
+	handle("/token-hook", server.metrics.InstrumentTokenHook(server.handleTokenHook))
+	handle("/token-hook/preview/", server.handleTokenHookPreview) // GET /token-hook/preview/{id}
+	handle("/admin/clients", server.handleClients)
+	handle("/admin/audit/export", server.handleAuditExport)
+	handle("/admin/clients/", server.handleClientByID)            // GET/DELETE /admin/clients/{id}
+	handle("/admin/clients/rotate/", server.handleRotateClient)   // POST /admin/clients/rotate/{id}
+	handle("/admin/clients/restore/", server.handleRestoreClient) // POST /admin/clients/restore/{id}
+	handle("/admin/clients/noncompliant", server.handleNoncompliantClients)
+	handle("/admin/clients/count", server.handleCountClients)
+	handle("/admin/clients/search", server.handleSearchClients)
+	handle("/admin/clients/deleted", server.handleDeletedClients)
+	handle("/admin/clients/export", server.handleExportClients)
+	handle("/admin/clients/cross-network-duplicates", server.handleCrossNetworkDuplicates)
+	handle("/admin/clients/delete-batch", server.handleBatchDeleteClients)
+	handle("/sync/clients", server.handleSyncClients)
+	handle("/sync/clients/upsert", server.handleSyncClientsUpsert)
+	handle("/sync/preflight", server.handleSyncPreflight)
+	handle("/sync/clients/diff", server.handleSyncDiff)
+	handle("/version", server.handleVersion)
+	handle("/info", server.handleInfo)
+	handle("/debug/config", server.handleDebugConfig)
+	mux.HandleFunc("/metrics", server.handleMetrics)
+	handle("/capabilities", server.handleCapabilities)
+	mux.HandleFunc(cfg.HealthPath, server.handleHealth)
+	mux.HandleFunc(cfg.ReadyPath, server.handleReady)
+
+	// Optionally keep the legacy path alive for whichever probe was overridden,
+	// skipping it if the other probe already claimed it
+	if cfg.LegacyProbePaths {
+		registered := map[string]bool{cfg.HealthPath: true, cfg.ReadyPath: true}
+		if cfg.HealthPath != "/health" && !registered["/health"] {
+			mux.HandleFunc("/health", server.handleHealth)
+		}
+		if cfg.ReadyPath != "/ready" && !registered["/ready"] {
+			mux.HandleFunc("/ready", server.handleReady)
+		}
+	}
+
+	return mux
+}
+
 func main() {
 	cfg := loadConfig()
 
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set (no-op otherwise)
+	shutdownTracing, err := setupTracing(context.Background(), cfg.OTLPEndpoint)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
 	// Initialize database store
-	store, err := NewStore(cfg.DatabaseURL)
+	store, err := NewStore(cfg.DatabaseURL, StoreOptions{
+		QueryLogging:       cfg.DBQueryLogging,
+		SlowQueryThreshold: time.Duration(cfg.DBSlowQueryMS) * time.Millisecond,
+		MaxOpenConns:       cfg.DBMaxOpenConns,
+		MaxIdleConns:       cfg.DBMaxIdleConns,
+		ConnMaxLifetime:    cfg.DBConnMaxLifetime,
+		ReadURL:            cfg.DatabaseReadURL,
+		SoftDelete:         cfg.SoftDeleteEnabled,
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer store.Close()
 
-	// Get network ID at startup (single-tenant: one network)
+	// Sidecar-owned tables from the embedded migrations (never Hydra's)
+	if cfg.RunMigrations {
+		if err := store.RunMigrations(context.Background()); err != nil {
+			log.Fatalf("Failed to run migrations: %v", err)
+		}
+	}
+
+	// Get the default network ID at startup (used when a request selects no network)
 	nid, err := store.GetDefaultNetworkID(context.Background())
 	if err != nil {
 		log.Printf("Warning: Could not get network ID: %v (will be set on first sync)", err)
 	}
 
+	// Name -> network ID mappings for multi-network requests (X-Network-ID)
+	sidecarTableReady(store, "hydra_sidecar_networks", "network names")
+
+	// Parse claim templates up front so syntax errors fail fast
+	templates, err := parseClaimTemplates(cfg.ClaimTemplatesJSON)
+	if err != nil {
+		log.Fatalf("Invalid CLAIM_TEMPLATES_JSON: %v", err)
+	}
+
+	schema, err := parseMetadataSchema(cfg.MetadataSchemaJSON)
+	if err != nil {
+		log.Fatalf("Invalid METADATA_SCHEMA_JSON: %v", err)
+	}
+
+	clientIDPattern, err := compileClientIDPattern(cfg.ClientIDPattern)
+	if err != nil {
+		log.Fatalf("Invalid CLIENT_ID_PATTERN: %v", err)
+	}
+
+	authDefaults, err := parseAuthMethodDefaults(cfg.AuthMethodDefaultsJSON)
+	if err != nil {
+		log.Fatalf("Invalid AUTH_METHOD_DEFAULTS_JSON: %v", err)
+	}
+
+	defaultClaims, err := parseDefaultClaims(cfg.DefaultClaimsJSON)
+	if err != nil {
+		log.Fatalf("Invalid DEFAULT_CLAIMS: %v", err)
+	}
+
+	rateLimits, err := parseTierRateLimits(cfg.TierRateLimitsJSON)
+	if err != nil {
+		log.Fatalf("Invalid TIER_RATE_LIMITS_JSON: %v", err)
+	}
+	rpmLimits, err := parseTierRPMLimits(cfg.TierRPMLimitsJSON)
+	if err != nil {
+		log.Fatalf("Invalid TIER_RPM_LIMITS_JSON: %v", err)
+	}
+
+	coercions, err := parseClaimCoercions(cfg.ClaimCoerce)
+	if err != nil {
+		log.Fatalf("Invalid CLAIM_COERCE: %v", err)
+	}
+
+	signer, err := loadClaimSigner(cfg.ClaimSigningKeyFile, cfg.ClaimSigningKeyID, cfg.EntitlementsClaimKeys)
+	if err != nil {
+		log.Fatalf("Invalid CLAIM_SIGNING_KEY_FILE: %v", err)
+	}
+	if signer != nil {
+		log.Printf("Signing entitlements claims with %s key %s", signer.alg, signer.kid)
+	}
+
+	keys, err := newAPIKeys(cfg.AdminAPIKey, cfg.ScopedAPIKeysJSON)
+	if err != nil {
+		log.Fatalf("Invalid SCOPED_API_KEYS_JSON: %v", err)
+	}
+
+	// Metrics, with every Hydra Admin API call timed via the HTTP client's transport,
+	// behind the circuit breaker so calls it refuses aren't timed as Hydra calls
+	budget := newRetryBudget(cfg.RetryBudgetCapacity, cfg.RetryBudgetRefillPerSec)
+	breaker := newCircuitBreaker(cfg.HydraCircuitFailures, cfg.HydraCircuitCooldown)
+	metrics := NewMetrics(budget, breaker)
+
 	// Create server with dependencies
 	server := &Server{
 		store:           store,
 		hydraAdminURL:   cfg.HydraAdminURL,
 		hasherAlgorithm: cfg.HasherAlgorithm,
+		bcryptCost:      cfg.BcryptCost,
+		pbkdf2Iter:      cfg.Pbkdf2Iterations,
 		networkID:       nid,
-		httpClient:      &http.Client{Timeout: 30 * time.Second},
+		httpClient:      &http.Client{Timeout: 30 * time.Second, Transport: breaker.Transport(traceHydraTransport(metrics.InstrumentHydraTransport(nil)))},
+		hydraBreaker:    breaker,
+
+		maxClientLifetime:  cfg.MaxClientLifetime,
+		clientLifetimeMode: cfg.MaxClientLifetimeMode,
+		claimTemplates:     templates,
+		defaultClaims:      defaultClaims,
+		tierRateLimits:     rateLimits,
+		tierRPMLimits:      rpmLimits,
+		authMethodDefaults: authDefaults,
+
+		minSecretLength:     cfg.MinSecretLength,
+		minSecretLengthMode: cfg.MinSecretLengthMode,
+
+		config:     cfg,
+		hookSecret: cfg.TokenHookSecret,
+		startedAt:  time.Now(),
+
+		hydraRetry:     retryPolicy{attempts: cfg.HydraRetryAttempts, baseDelay: cfg.HydraRetryBaseDelay},
+		retryBudget:    budget,
+		metadataSchema: schema,
+
+		clientIDPattern: clientIDPattern,
+
+		syncMaxFailures: cfg.SyncMaxFailures,
+		syncConcurrency: cfg.SyncConcurrency,
+		clientCache:     newClientInfoCache(cfg.MetadataCacheTTL, staleCacheMaxAge(cfg)),
+		serveStale:      cfg.ServeStaleOnError,
+		envClaim:        cfg.TokenHookEnvClaim,
+		claimNamespace:  claimNamespace(cfg.ClaimNamespace),
+		claimFilter:     newClaimFilter(cfg.ClaimAllowlist, cfg.ClaimDenylist),
+		claimSigner:     signer,
+		claimCoercions:  coercions,
+		claimEnricher:   newClaimEnricher(cfg.ClaimEnrichmentURL, cfg.ClaimEnrichmentTimeout, cfg.ClaimEnrichmentPrecedence),
+		metrics:         metrics,
+		responsePool:    newEncoderPool(cfg.ResponseBufferMaxBytes),
+		clockSkew:       cfg.ClockSkewTolerance,
+		failClosed:      cfg.TokenHookFailClosed,
+		retryOn5xx:      cfg.TokenHookRetryOn5xx,
+		hookLimiter:     newClientRateLimiter(cfg.TokenHookRateLimit),
+		verifyLimiter:   newClientRateLimiter(cfg.VerifySecretRateLimit),
+		hookErrors:      hookErrorFormat{denyError: cfg.TokenHookDenyError, extraFields: cfg.TokenHookErrorExtraFields},
+
+		injectAllowedScopes: cfg.TokenHookInjectAllowedScopes,
 	}
 
-	// Register handlers
-	mux := http.NewServeMux()
-	mux.HandleFunc("/token-hook", server.handleTokenHook)
-	mux.HandleFunc("/admin/clients", server.handleCreateClient)
-	mux.HandleFunc("/admin/clients/", server.handleClientByID)       // GET/DELETE /admin/clients/{id}
-	mux.HandleFunc("/admin/clients/rotate/", server.handleRotateClient) // POST /admin/clients/rotate/{id}
-	mux.HandleFunc("/sync/clients", server.handleSyncClients)
-	mux.HandleFunc("/health", server.handleHealth)
-	mux.HandleFunc("/ready", server.handleReady)
+	// Cancelled when shutdown begins, so in-flight syncs stop at their next phase boundary
+	shuttingDown, beginShutdown := context.WithCancel(context.Background())
+	server.shuttingDown = shuttingDown
+
+	// Background context for workers, cancelled on shutdown
+	bgCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+
+	// Audit trail of client mutations (exported via /admin/audit/export)
+	if cfg.AuditLog {
+		// An audit trail that silently stops recording is worse than none
+		if !sidecarTableReady(store, "hydra_sidecar_audit_events", "AUDIT_LOG") {
+			log.Fatal("AUDIT_LOG requires the sidecar migrations")
+		}
+		server.audit = store
+	}
+	var auditLoggers auditLoggers
+	if server.audit != nil {
+		auditLoggers = append(auditLoggers, server.audit)
+	}
+	closeAuditLog := func() error { return nil }
+	if cfg.AuditLogPath != "" {
+		fileLogger, closeFile, err := openAuditLogFile(cfg.AuditLogPath)
+		if err != nil {
+			log.Fatalf("Failed to open AUDIT_LOG_PATH: %v", err)
+		}
+		auditLoggers = append(auditLoggers, fileLogger)
+		closeAuditLog = closeFile
+	}
+	switch len(auditLoggers) {
+	case 0:
+	case 1:
+		server.auditLogger = auditLoggers[0]
+	default:
+		server.auditLogger = auditLoggers
+	}
+
+	// Idempotency-Key replay of client creation
+	if cfg.IdempotencyKeyTTL > 0 && sidecarTableReady(store, "hydra_sidecar_idempotency_keys", "IDEMPOTENCY_KEY_TTL") {
+		server.idempotency = store
+	}
+
+	// Last-known-good client info for the token hook during Hydra outages
+	if cfg.LastKnownGoodMaxAge > 0 && sidecarTableReady(store, "hydra_sidecar_client_snapshots", "LAST_KNOWN_GOOD_MAX_AGE") {
+		server.snapshots = store
+	}
+
+	// Keep retrying the default network ID in the background if it wasn't available at startup
+	if nid == uuid.Nil && cfg.NetworkRefreshInterval > 0 {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			server.refreshNetworkID(bgCtx, store, cfg.NetworkRefreshInterval)
+		}()
+	}
+
+	// Token issuance tracking (flushed in batches to bound DB writes)
+	if cfg.UsageTracking && sidecarTableReady(store, "hydra_sidecar_client_usage", "USAGE_TRACKING") {
+		server.usage = NewUsageRecorder(store)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			server.usage.Run(bgCtx, cfg.UsageFlushInterval, server.defaultNetworkID)
+		}()
+	}
+
+	tlsConfig, err := serverTLSConfig(cfg)
+	if err != nil {
+		log.Fatalf("Invalid TLS configuration: %v", err)
+	}
 
 	// Create HTTP server
 	httpServer := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      mux,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  120 * time.Second,
+		Handler:      corsMiddleware(newCORSOrigins(cfg.CORSAllowedOrigins), adminAuth(keys, newMux(cfg, server))),
+		ReadTimeout:  cfg.ServerReadTimeout,
+		WriteTimeout: cfg.ServerWriteTimeout,
+		IdleTimeout:  cfg.ServerIdleTimeout,
+		TLSConfig:    tlsConfig,
+	}
+
+	// Detect the Hydra version before serving (non-fatal, bounded timeout)
+	server.probeHydraVersion(bgCtx)
+
+	// Preload the token hook cache before serving (non-fatal)
+	if cfg.CacheWarmup {
+		if server.clientCache == nil {
+			log.Printf("Warning: CACHE_WARMUP ignored because METADATA_CACHE_TTL disables the cache")
+		} else if n, err := server.warmClientCache(bgCtx, store, cfg.CacheWarmupSize, server.usage != nil); err != nil {
+			log.Printf("Warning: client cache warm-up failed: %v", err)
+		} else {
+			log.Printf("Warmed client cache with %d clients", n)
+		}
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Hydra sidecar starting on port %s", cfg.Port)
+		log.Printf("Hydra sidecar %s (%s) starting on port %s", version, commit, cfg.Port)
 		log.Printf("  Hasher algorithm: %s", cfg.HasherAlgorithm)
 		log.Printf("  Hydra Admin URL: %s", cfg.HydraAdminURL)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			log.Printf("  TLS: enabled (client certificates required: %t)", tlsConfig.ClientCAs != nil)
+			// The certificate is already in TLSConfig, so no files are passed
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -121,8 +1005,26 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	beginShutdown()
 	if err := httpServer.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Printf("Warning: server forced to shutdown: %v", err)
+	}
+	// Don't close the store under a sync that hasn't reached a checkpoint yet
+	if !server.waitForSyncs(ctx) {
+		log.Printf("Warning: syncs still running at the shutdown timeout, closing the store anyway")
+	}
+
+	// Stop background workers (flushes pending usage) before closing the store
+	stopWorkers()
+	workers.Wait()
+
+	if err := closeAuditLog(); err != nil {
+		log.Printf("Error closing audit log: %v", err)
+	}
+
+	// Flush buffered spans to the collector
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Error shutting down tracing: %v", err)
 	}
 
 	log.Println("Server exited")
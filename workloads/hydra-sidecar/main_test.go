@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func probeStatus(t *testing.T, mux *http.ServeMux, path string) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code
+}
+
+func TestNewMuxCustomHealthPath(t *testing.T) {
+	cfg := Config{HealthPath: "/healthz", ReadyPath: "/ready"}
+
+	mux := newMux(cfg, &Server{})
+	if got := probeStatus(t, mux, "/healthz"); got != http.StatusOK {
+		t.Errorf("GET /healthz = %d, want %d", got, http.StatusOK)
+	}
+	if got := probeStatus(t, mux, "/health"); got != http.StatusNotFound {
+		t.Errorf("GET /health without legacy paths = %d, want %d", got, http.StatusNotFound)
+	}
+
+	cfg.LegacyProbePaths = true
+	mux = newMux(cfg, &Server{})
+	if got := probeStatus(t, mux, "/health"); got != http.StatusOK {
+		t.Errorf("GET /health with legacy paths = %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestNewMuxSwappedProbePathsWithLegacy(t *testing.T) {
+	cfg := Config{HealthPath: "/ready", ReadyPath: "/health", LegacyProbePaths: true}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("newMux panicked with swapped probe paths: %v", r)
+		}
+	}()
+	mux := newMux(cfg, &Server{})
+
+	if got := probeStatus(t, mux, "/ready"); got != http.StatusOK {
+		t.Errorf("GET /ready (health handler) = %d, want %d", got, http.StatusOK)
+	}
+}
+
+func TestValidateProbePaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		health  string
+		ready   string
+		wantErr bool
+	}{
+		{"defaults", "/health", "/ready", false},
+		{"kubernetes style", "/healthz", "/readyz", false},
+		{"swapped", "/ready", "/health", false},
+		{"empty", "", "/ready", true},
+		{"missing slash", "healthz", "/ready", true},
+		{"same path", "/probe", "/probe", true},
+		{"conflicts with token hook", "/token-hook", "/ready", true},
+		{"conflicts with sync", "/health", "/sync/clients", true},
+		{"conflicts with admin", "/admin/clients", "/ready", true},
+		{"conflicts with token hook preview", "/health", "/token-hook/preview/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProbePaths(Config{HealthPath: tt.health, ReadyPath: tt.ready})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateProbePaths(%q, %q) error = %v, wantErr %v", tt.health, tt.ready, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeTestCert writes a self-signed certificate and its key as PEM files
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "hydra-sidecar"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "tls.crt")
+	keyFile = filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	if tlsConfig, err := serverTLSConfig(Config{}); tlsConfig != nil || err != nil {
+		t.Errorf("no files: got %v, %v, want plaintext", tlsConfig, err)
+	}
+
+	tlsConfig, err := serverTLSConfig(Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("cert and key: %v", err)
+	}
+	if len(tlsConfig.Certificates) != 1 || tlsConfig.ClientAuth != tls.NoClientCert {
+		t.Errorf("cert and key: got %d certificates, client auth %v, want TLS without client certs", len(tlsConfig.Certificates), tlsConfig.ClientAuth)
+	}
+
+	tlsConfig, err = serverTLSConfig(Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: certFile})
+	if err != nil {
+		t.Fatalf("mTLS: %v", err)
+	}
+	if tlsConfig.ClientAuth != tls.RequireAndVerifyClientCert || tlsConfig.ClientCAs == nil {
+		t.Errorf("mTLS: client auth %v, want RequireAndVerifyClientCert with a CA pool", tlsConfig.ClientAuth)
+	}
+
+	for name, cfg := range map[string]Config{
+		"cert without key": {TLSCertFile: certFile},
+		"CA without cert":  {TLSClientCAFile: certFile},
+		"key is not a key": {TLSCertFile: certFile, TLSKeyFile: certFile},
+		"CA is not PEM":    {TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: notPEM},
+	} {
+		if _, err := serverTLSConfig(cfg); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestLoadConfigServerTimeouts(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://hydra@localhost/hydra")
+
+	cfg := loadConfig()
+	if cfg.ServerReadTimeout != 15*time.Second || cfg.ServerWriteTimeout != 60*time.Second || cfg.ServerIdleTimeout != 120*time.Second {
+		t.Errorf("default timeouts = %s/%s/%s, want 15s/1m0s/2m0s", cfg.ServerReadTimeout, cfg.ServerWriteTimeout, cfg.ServerIdleTimeout)
+	}
+
+	t.Setenv("SERVER_WRITE_TIMEOUT", "5m")
+	if cfg := loadConfig(); cfg.ServerWriteTimeout != 5*time.Minute {
+		t.Errorf("SERVER_WRITE_TIMEOUT=5m gave %s", cfg.ServerWriteTimeout)
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	doc := `{
+		"DATABASE_URL": "postgres://hydra@db/hydra",
+		"SERVER_WRITE_TIMEOUT": "5m",
+		"USAGE_TRACKING": true,
+		"SYNC_CONCURRENCY": 8,
+		"CLAIM_TEMPLATES_JSON": {"tenant": "{{.org_id}}"},
+		"PORT": null
+	}`
+	if err := os.WriteFile(path, []byte(doc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SYNC_CONCURRENCY", "2")
+	t.Cleanup(func() { configFileSettings, configKeysRead = nil, nil })
+
+	cfg := loadConfig()
+	if cfg.DatabaseURL != "postgres://hydra@db/hydra" || cfg.ServerWriteTimeout != 5*time.Minute || !cfg.UsageTracking {
+		t.Errorf("file values not loaded: %+v", cfg)
+	}
+	if cfg.ClaimTemplatesJSON != `{"tenant": "{{.org_id}}"}` {
+		t.Errorf("CLAIM_TEMPLATES_JSON = %q, want the inline object's JSON text", cfg.ClaimTemplatesJSON)
+	}
+	// Env vars win over the file; a null leaves the default
+	if cfg.SyncConcurrency != 2 || cfg.Port != "8080" {
+		t.Errorf("SyncConcurrency = %d, Port = %q, want 2 and the default 8080", cfg.SyncConcurrency, cfg.Port)
+	}
+}
+
+func TestConfigFileRejectsBadInput(t *testing.T) {
+	dir := t.TempDir()
+	notObject := filepath.Join(dir, "list.json")
+	if err := os.WriteFile(notObject, []byte(`["PORT"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for name, path := range map[string]string{"missing": filepath.Join(dir, "missing.json"), "not an object": notObject} {
+		if _, err := loadConfigFile(path); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+
+	// Keys loadConfig never reads are typos, reported by name
+	configFileSettings = map[string]string{"PORT": "9090", "SYNC_CONCURENCY": "8"}
+	configKeysRead = map[string]bool{"PORT": true}
+	t.Cleanup(func() { configFileSettings, configKeysRead = nil, nil })
+	if err := checkConfigFileKeys("config.json"); err == nil || !strings.Contains(err.Error(), "SYNC_CONCURENCY") || strings.Contains(err.Error(), "PORT") {
+		t.Errorf("checkConfigFileKeys = %v, want only SYNC_CONCURENCY reported", err)
+	}
+}
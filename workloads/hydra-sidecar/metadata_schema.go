@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// metadataSchema maps allowed metadata keys to their constraints (METADATA_SCHEMA_JSON)
+type metadataSchema map[string]metadataField
+
+// metadataField constrains a single metadata key
+type metadataField struct {
+	// JSON type: string, number, boolean, object, or array
+	Type string `json:"type"`
+	// Allowed values (optional)
+	Enum []any `json:"enum,omitempty"`
+}
+
+var metadataTypes = []string{"string", "number", "boolean", "object", "array"}
+
+// parseMetadataSchema parses a JSON object of metadata key -> {type, enum}.
+// An empty string disables validation.
+func parseMetadataSchema(raw string) (metadataSchema, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var schema metadataSchema
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	for key, field := range schema {
+		if !slices.Contains(metadataTypes, field.Type) {
+			return nil, fmt.Errorf("key %q: type must be one of %s", key, strings.Join(metadataTypes, ", "))
+		}
+		for _, v := range field.Enum {
+			if jsonType(v) != field.Type {
+				return nil, fmt.Errorf("key %q: enum value %v is not a %s", key, v, field.Type)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// jsonType returns the schema type name of a decoded JSON value
+func jsonType(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		return "null"
+	}
+}
+
+// validateKey checks a single metadata value against the schema
+func (ms metadataSchema) validateKey(key string, value any) error {
+	field, ok := ms[key]
+	if !ok {
+		return fmt.Errorf("metadata key %q is not allowed", key)
+	}
+	if got := jsonType(value); got != field.Type {
+		return fmt.Errorf("metadata key %q must be a %s, got %s", key, field.Type, got)
+	}
+	if len(field.Enum) > 0 && !slices.Contains(field.Enum, value) {
+		return fmt.Errorf("metadata key %q must be one of %s", key, formatEnum(field.Enum))
+	}
+	return nil
+}
+
+// validate checks a full metadata object. Keys are checked in sorted order so
+// the reported violation is deterministic.
+func (ms metadataSchema) validate(metadata map[string]any) error {
+	if ms == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := ms.validateKey(k, metadata[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateClientBody validates the metadata of a client create request body
+func (ms metadataSchema) validateClientBody(body []byte) error {
+	if ms == nil || len(body) == 0 {
+		return nil
+	}
+	var req struct {
+		Metadata json.RawMessage `json:"metadata"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return ms.validateRaw(req.Metadata)
+}
+
+// validateRaw validates a raw JSON metadata value (absent or null is allowed)
+func (ms metadataSchema) validateRaw(raw json.RawMessage) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var metadata map[string]any
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return fmt.Errorf("metadata must be a JSON object")
+	}
+	return ms.validate(metadata)
+}
+
+// validatePatch validates metadata changes in a JSON Patch (RFC 6902) body.
+// Operations on /metadata replace the whole object; operations on
+// /metadata/{key} set or remove a single key.
+func (ms metadataSchema) validatePatch(body []byte) error {
+	if ms == nil {
+		return nil
+	}
+	var ops []JSONPatchOperation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return fmt.Errorf("invalid JSON Patch: %w", err)
+	}
+	for _, op := range ops {
+		if op.Op != "add" && op.Op != "replace" {
+			continue
+		}
+		if op.Path == "/metadata" {
+			if err := ms.validateRaw(op.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		key, ok := strings.CutPrefix(op.Path, "/metadata/")
+		if !ok {
+			continue
+		}
+		// Unescape JSON Pointer (RFC 6901); nested paths validate the top-level key only
+		key, _, nested := strings.Cut(key, "/")
+		key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+		if nested {
+			if _, ok := ms[key]; !ok {
+				return fmt.Errorf("metadata key %q is not allowed", key)
+			}
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return fmt.Errorf("invalid value for %s", op.Path)
+		}
+		if err := ms.validateKey(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatEnum(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprint(v)
+	}
+	return strings.Join(parts, ", ")
+}
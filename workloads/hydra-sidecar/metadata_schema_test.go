@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testMetadataSchema = `{"org_id":{"type":"string"},"tier":{"type":"string","enum":["free","pro","enterprise"]},"seats":{"type":"number"}}`
+
+func TestMetadataSchemaValid(t *testing.T) {
+	ms, err := parseMetadataSchema(testMetadataSchema)
+	if err != nil {
+		t.Fatalf("parseMetadataSchema() error = %v", err)
+	}
+
+	body := []byte(`{"client_name":"svc","metadata":{"org_id":"acme","tier":"pro","seats":5}}`)
+	if err := ms.validateClientBody(body); err != nil {
+		t.Errorf("validateClientBody() error = %v, want nil", err)
+	}
+	if err := ms.validateClientBody([]byte(`{"client_name":"svc"}`)); err != nil {
+		t.Errorf("validateClientBody() without metadata error = %v, want nil", err)
+	}
+}
+
+func TestMetadataSchemaViolations(t *testing.T) {
+	ms, err := parseMetadataSchema(testMetadataSchema)
+	if err != nil {
+		t.Fatalf("parseMetadataSchema() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"enum", `{"metadata":{"tier":"gold"}}`, "must be one of free, pro, enterprise"},
+		{"type", `{"metadata":{"seats":"five"}}`, "must be a number"},
+		{"unknown key", `{"metadata":{"region":"eu"}}`, "not allowed"},
+		{"not an object", `{"metadata":"acme"}`, "must be a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ms.validateClientBody([]byte(tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validateClientBody() error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataSchemaPatch(t *testing.T) {
+	ms, _ := parseMetadataSchema(testMetadataSchema)
+
+	if err := ms.validatePatch([]byte(`[{"op":"replace","path":"/metadata/tier","value":"enterprise"},{"op":"remove","path":"/metadata/org_id"}]`)); err != nil {
+		t.Errorf("validatePatch() valid ops error = %v", err)
+	}
+	if err := ms.validatePatch([]byte(`[{"op":"add","path":"/metadata","value":{"tier":"gold"}}]`)); err == nil {
+		t.Error("validatePatch() accepted enum violation in /metadata")
+	}
+	if err := ms.validatePatch([]byte(`[{"op":"replace","path":"/metadata/tier","value":"gold"}]`)); err == nil {
+		t.Error("validatePatch() accepted enum violation in /metadata/tier")
+	}
+}
+
+func TestParseMetadataSchemaInvalid(t *testing.T) {
+	if _, err := parseMetadataSchema(`{"tier":{"type":"text"}}`); err == nil {
+		t.Error("expected error for unknown type")
+	}
+	if _, err := parseMetadataSchema(`{"tier":{"type":"string","enum":[1]}}`); err == nil {
+		t.Error("expected error for enum value of the wrong type")
+	}
+}
+
+func TestCreateClientRejectsSchemaViolation(t *testing.T) {
+	ms, _ := parseMetadataSchema(testMetadataSchema)
+	hydraCalled := false
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hydraCalled = true
+	}))
+	defer hydra.Close()
+
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), metadataSchema: ms}
+	rec := httptest.NewRecorder()
+	s.handleCreateClient(rec, httptest.NewRequest(http.MethodPost, "/admin/clients",
+		strings.NewReader(`{"grant_types":["client_credentials"],"metadata":{"tier":"gold"}}`)))
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "tier") {
+		t.Errorf("got %d %s, want 400 for the tier metadata", rec.Code, rec.Body)
+	}
+	if hydraCalled {
+		t.Error("invalid metadata was forwarded to Hydra")
+	}
+}
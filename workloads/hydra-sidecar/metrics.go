@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "hydra_sidecar"
+
+// Client operations counted by hydra_sidecar_client_operations_total
+const (
+	clientOpCreated  = "created"
+	clientOpRotated  = "rotated"
+	clientOpDeleted  = "deleted"
+	clientOpRestored = "restored"
+)
+
+// Metrics holds the sidecar's Prometheus collectors on a private registry.
+// Methods are safe to call on a nil *Metrics (metrics disabled, e.g. in tests).
+type Metrics struct {
+	registry *prometheus.Registry
+
+	tokenHooks       *prometheus.CounterVec
+	tokenHookLatency *prometheus.HistogramVec
+	tokenHook5xx     prometheus.Counter
+	tokenHookLKG     prometheus.Counter
+	tokenHookLimited prometheus.Counter
+	clientOps        *prometheus.CounterVec
+	syncOps          *prometheus.CounterVec
+	hydraLatency     *prometheus.HistogramVec
+}
+
+// NewMetrics registers the sidecar collectors, plus Go runtime and process
+// metrics and the shared retry budget and circuit breaker gauges
+func NewMetrics(budget *retryBudget, breaker *circuitBreaker) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		tokenHooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_hooks_total",
+			Help:      "Token hook requests served, by HTTP status code.",
+		}, []string{"code"}),
+		tokenHookLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_hook_duration_seconds",
+			Help:      "Token hook handler latency.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"code"}),
+		tokenHook5xx: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_hook_backend_5xx_total",
+			Help:      "Token hook client info fetches that ended in a Hydra 5xx, after retries.",
+		}),
+		tokenHookLKG: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_hook_last_known_good_total",
+			Help:      "Token hook calls served from a last-known-good client info snapshot because Hydra was unavailable.",
+		}),
+		tokenHookLimited: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "token_hook_rate_limited_total",
+			Help:      "Token hook calls over a client's TOKEN_HOOK_RATE_LIMIT, served without a Hydra lookup or rejected.",
+		}),
+		clientOps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "client_operations_total",
+			Help:      "Clients created, rotated, or deleted through the admin API.",
+		}, []string{"operation"}),
+		syncOps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "sync_operations_total",
+			Help:      "Per-client bulk sync outcomes: created, updated, deleted, or failed.",
+		}, []string{"result"}),
+		hydraLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "hydra_admin_request_duration_seconds",
+			Help:      "Hydra Admin API call latency, by method and HTTP status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "code"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.tokenHooks,
+		m.tokenHookLatency,
+		m.tokenHook5xx,
+		m.tokenHookLKG,
+		m.tokenHookLimited,
+		m.clientOps,
+		m.syncOps,
+		m.hydraLatency,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "retry_budget_remaining",
+			Help:      "Retries to Hydra currently available in the shared budget.",
+		}, func() float64 { return float64(budget.Remaining()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "hydra_circuit_state",
+			Help:      "Hydra Admin circuit breaker state: 0 closed, 1 half-open, 2 open.",
+		}, func() float64 { return circuitStateValue(breaker.State()) }),
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// InstrumentTokenHook counts token hook requests and observes their latency
+func (m *Metrics) InstrumentTokenHook(next http.HandlerFunc) http.HandlerFunc {
+	if m == nil {
+		return next
+	}
+	return promhttp.InstrumentHandlerCounter(m.tokenHooks,
+		promhttp.InstrumentHandlerDuration(m.tokenHookLatency, next))
+}
+
+// InstrumentHydraTransport observes the latency of every Hydra Admin API call
+func (m *Metrics) InstrumentHydraTransport(next http.RoundTripper) http.RoundTripper {
+	if m == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return promhttp.InstrumentRoundTripperDuration(m.hydraLatency, next)
+}
+
+// TokenHookBackend5xx counts a token hook client info fetch that ended in a
+// Hydra 5xx
+func (m *Metrics) TokenHookBackend5xx() {
+	if m == nil {
+		return
+	}
+	m.tokenHook5xx.Inc()
+}
+
+// TokenHookLastKnownGood counts a token hook call served from a last-known-good
+// snapshot
+func (m *Metrics) TokenHookLastKnownGood() {
+	if m == nil {
+		return
+	}
+	m.tokenHookLKG.Inc()
+}
+
+// TokenHookRateLimited counts a token hook call over TOKEN_HOOK_RATE_LIMIT
+func (m *Metrics) TokenHookRateLimited() {
+	if m == nil {
+		return
+	}
+	m.tokenHookLimited.Inc()
+}
+
+// ClientOperation counts a successful admin create/rotate/delete
+func (m *Metrics) ClientOperation(op string) {
+	if m == nil {
+		return
+	}
+	m.clientOps.WithLabelValues(op).Inc()
+}
+
+// SyncCompleted adds a bulk sync's per-client outcomes. A rolled back
+// atomic sync applied nothing, so only its failures are counted.
+func (m *Metrics) SyncCompleted(r *SyncResult) {
+	if m == nil || r == nil {
+		return
+	}
+	if !r.RolledBack {
+		m.syncOps.WithLabelValues("created").Add(float64(r.CreatedCount))
+		m.syncOps.WithLabelValues("updated").Add(float64(r.UpdatedCount))
+		m.syncOps.WithLabelValues("deleted").Add(float64(r.DeletedCount))
+	}
+	m.syncOps.WithLabelValues("failed").Add(float64(r.FailedCount))
+}
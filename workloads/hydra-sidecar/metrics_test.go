@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsTokenHookAndHydraLatency(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme"}}`)
+	m := NewMetrics(nil, nil)
+	httpClient := hydra.Client()
+	httpClient.Transport = m.InstrumentHydraTransport(httpClient.Transport)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: httpClient, metrics: m}
+
+	mux := newMux(Config{HealthPath: "/health", ReadyPath: "/ready"}, s)
+	for i := 0; i < 3; i++ {
+		body := `{"session":{"client_id":"svc-a"},"request":{"client_id":"svc-a"}}`
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/token-hook", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("token hook status = %d, want 200", rec.Code)
+		}
+	}
+
+	if got := testutil.ToFloat64(m.tokenHooks.WithLabelValues("200")); got != 3 {
+		t.Errorf("token_hooks_total{code=200} = %v, want 3", got)
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	out := rec.Body.String()
+	for _, want := range []string{
+		"hydra_sidecar_token_hook_duration_seconds_count",
+		"hydra_sidecar_hydra_admin_request_duration_seconds_count",
+		"hydra_sidecar_retry_budget_remaining 0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("/metrics missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestMetricsSyncCompleted(t *testing.T) {
+	m := NewMetrics(nil, nil)
+	m.SyncCompleted(&SyncResult{CreatedCount: 2, UpdatedCount: 1, DeletedCount: 1, FailedCount: 1})
+	m.SyncCompleted(&SyncResult{CreatedCount: 5, FailedCount: 1, RolledBack: true})
+
+	for result, want := range map[string]float64{"created": 2, "updated": 1, "deleted": 1, "failed": 2} {
+		if got := testutil.ToFloat64(m.syncOps.WithLabelValues(result)); got != want {
+			t.Errorf("sync_operations_total{result=%s} = %v, want %v", result, got, want)
+		}
+	}
+}
+
+func TestNilMetricsIsDisabled(t *testing.T) {
+	var m *Metrics
+	m.ClientOperation(clientOpCreated)
+	m.SyncCompleted(&SyncResult{})
+
+	rec := httptest.NewRecorder()
+	(&Server{}).handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /metrics with metrics disabled = %d, want 404", rec.Code)
+	}
+}
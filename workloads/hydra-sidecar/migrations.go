@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+
+	"github.com/gobuffalo/pop/v6"
+)
+
+// sidecarMigrations holds the schema of the sidecar-owned tables, applied by
+// RunMigrations. Every migration must only touch hydra_sidecar_* objects;
+// Hydra's own schema belongs to `hydra migrate sql`.
+//
+//go:embed migrations/*.sql
+var sidecarMigrations embed.FS
+
+// sidecarMigrationTable records applied sidecar migrations, apart from
+// Hydra's schema_migration so neither migrator sees the other's versions
+const sidecarMigrationTable = "hydra_sidecar_schema_migration"
+
+// RunMigrations applies pending sidecar migrations with pop's migrator
+// (RUN_MIGRATIONS). It is the only place the sidecar issues DDL. The
+// migrations use IF NOT EXISTS, so tables created by earlier versions at
+// startup are adopted as they are.
+func (s *Store) RunMigrations(ctx context.Context) error {
+	return s.timed("RunMigrations", func() error {
+		dir, err := fs.Sub(sidecarMigrations, "migrations")
+		if err != nil {
+			return err
+		}
+		box, err := pop.NewMigrationBox(dir, s.conn)
+		if err != nil {
+			return fmt.Errorf("failed to load migrations: %w", err)
+		}
+		if err := box.Up(); err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		return nil
+	})
+}
+
+// tableChecker is the subset of Store used to check for sidecar tables
+type tableChecker interface {
+	TableExists(ctx context.Context, table string) (bool, error)
+}
+
+// sidecarTableReady reports whether a feature's table from the sidecar
+// migrations exists, logging that the feature is disabled when it doesn't
+func sidecarTableReady(db tableChecker, table, feature string) bool {
+	exists, err := db.TableExists(context.Background(), table)
+	if err != nil {
+		log.Printf("Warning: Could not check for table %s: %v (%s disabled)", table, err, feature)
+		return false
+	}
+	if !exists {
+		log.Printf("Warning: Table %s does not exist, %s disabled (apply the sidecar migrations, e.g. with RUN_MIGRATIONS=true)", table, feature)
+		return false
+	}
+	return true
+}
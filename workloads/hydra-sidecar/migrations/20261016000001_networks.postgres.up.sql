@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS hydra_sidecar_networks (
+	name VARCHAR(255) PRIMARY KEY,
+	nid UUID NOT NULL
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS hydra_sidecar_client_usage;
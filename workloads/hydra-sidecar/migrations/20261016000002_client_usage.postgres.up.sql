@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS hydra_sidecar_client_usage (
+	client_id VARCHAR(255) NOT NULL,
+	nid UUID NOT NULL,
+	issued_count BIGINT NOT NULL DEFAULT 0,
+	last_issued_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (client_id, nid)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS hydra_sidecar_audit_events (
+	id BIGSERIAL PRIMARY KEY,
+	occurred_at TIMESTAMP NOT NULL,
+	operation VARCHAR(64) NOT NULL,
+	client_id VARCHAR(255) NOT NULL DEFAULT '',
+	nid VARCHAR(36) NOT NULL DEFAULT '',
+	detail TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE hydra_sidecar_audit_events
+	ADD COLUMN IF NOT EXISTS caller VARCHAR(255) NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS outcome VARCHAR(16) NOT NULL DEFAULT 'success';
+
+CREATE INDEX IF NOT EXISTS hydra_sidecar_audit_events_occurred_at_idx
+	ON hydra_sidecar_audit_events (occurred_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS hydra_sidecar_idempotency_keys (
+	idempotency_key VARCHAR(255) NOT NULL,
+	caller VARCHAR(255) NOT NULL,
+	nid UUID NOT NULL,
+	request_hash CHAR(64) NOT NULL,
+	client_id VARCHAR(255) NOT NULL DEFAULT '',
+	response TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (idempotency_key, caller, nid)
+);
+
+CREATE INDEX IF NOT EXISTS hydra_sidecar_idempotency_keys_created_at_idx
+	ON hydra_sidecar_idempotency_keys (created_at);
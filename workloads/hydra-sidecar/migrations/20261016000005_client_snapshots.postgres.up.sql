@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS hydra_sidecar_client_snapshots (
+	client_id VARCHAR(255) PRIMARY KEY,
+	info TEXT NOT NULL,
+	fetched_at TIMESTAMP NOT NULL
+);
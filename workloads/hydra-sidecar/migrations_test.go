@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// popMigrationName is the file name pattern pop's migrator loads
+var popMigrationName = regexp.MustCompile(`^(\d{14})_([^.]+)\.postgres\.(up|down)\.sql$`)
+
+// migrationTargets finds the tables and indexes a migration writes to
+var migrationTargets = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:CREATE|ALTER|DROP|TRUNCATE)\s+TABLE\s+(?:IF\s+(?:NOT\s+)?EXISTS\s+)?(\w+)`),
+	regexp.MustCompile(`(?i)\b(?:CREATE|DROP)\s+(?:UNIQUE\s+)?INDEX\s+(?:IF\s+(?:NOT\s+)?EXISTS\s+)?(\w+)`),
+	regexp.MustCompile(`(?i)\bINDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?\w+\s+ON\s+(\w+)`),
+	regexp.MustCompile(`(?i)\b(?:INSERT\s+INTO|UPDATE|DELETE\s+FROM)\s+(\w+)`),
+}
+
+func TestMigrationsOnlyTouchSidecarTables(t *testing.T) {
+	files, err := fs.Glob(sidecarMigrations, "migrations/*")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no embedded migrations (err = %v)", err)
+	}
+
+	ups := map[string]bool{}
+	downs := map[string]bool{}
+	for _, path := range files {
+		name := strings.TrimPrefix(path, "migrations/")
+		m := popMigrationName.FindStringSubmatch(name)
+		if m == nil {
+			t.Errorf("%s: pop won't load it, want <timestamp>_<name>.postgres.(up|down).sql", name)
+			continue
+		}
+		if m[3] == "up" {
+			ups[m[1]+"_"+m[2]] = true
+		} else {
+			downs[m[1]+"_"+m[2]] = true
+		}
+
+		body, err := fs.ReadFile(sidecarMigrations, path)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		targets := 0
+		for _, re := range migrationTargets {
+			for _, match := range re.FindAllStringSubmatch(string(body), -1) {
+				targets++
+				if !strings.HasPrefix(strings.ToLower(match[1]), "hydra_sidecar_") {
+					t.Errorf("%s writes to %s, want only hydra_sidecar_* objects", name, match[1])
+				}
+			}
+		}
+		if targets == 0 {
+			t.Errorf("%s: found no statements to check", name)
+		}
+	}
+	for version := range ups {
+		if !downs[version] {
+			t.Errorf("%s has no down migration", version)
+		}
+	}
+}
+
+// fakeTables is a tableChecker over a fixed set of tables
+type fakeTables struct {
+	tables map[string]bool
+	err    error
+}
+
+func (f fakeTables) TableExists(_ context.Context, table string) (bool, error) {
+	return f.tables[table], f.err
+}
+
+func TestSidecarTableReady(t *testing.T) {
+	buf := captureLog(t)
+	db := fakeTables{tables: map[string]bool{"hydra_sidecar_client_usage": true}}
+
+	if !sidecarTableReady(db, "hydra_sidecar_client_usage", "USAGE_TRACKING") {
+		t.Error("existing table reported missing")
+	}
+	if sidecarTableReady(db, "hydra_sidecar_client_snapshots", "LAST_KNOWN_GOOD_MAX_AGE") {
+		t.Error("missing table reported ready")
+	}
+	if out := buf.String(); !strings.Contains(out, "hydra_sidecar_client_snapshots does not exist, LAST_KNOWN_GOOD_MAX_AGE disabled") {
+		t.Errorf("missing table not logged, log output: %q", out)
+	}
+	if sidecarTableReady(fakeTables{err: errors.New("connection refused")}, "hydra_sidecar_client_usage", "USAGE_TRACKING") {
+		t.Error("table reported ready when the lookup failed")
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/ory/hydra/v2/client"
 )
 
@@ -25,6 +28,11 @@ type ClientData struct {
 	//   Required. Must contain the stored hash value.
 	//   Note: client_secret is ignored in sync requests (use this field instead).
 	ClientSecretHash string `json:"client_secret_hash,omitempty"`
+
+	// Algorithm of client_secret_hash in sync requests: "pbkdf2" or "bcrypt".
+	// Optional; defaults to HASHER_ALGORITHM. Lets a batch mix formats while
+	// migrating from one hasher to the other.
+	HashAlgorithm string `json:"hash_algorithm,omitempty"`
 }
 
 // SyncClientsRequest is the request body for bulk client sync.
@@ -35,6 +43,22 @@ type SyncClientsRequest struct {
 	// Each client must have client_secret_hash set to the stored hash value.
 	// The client_secret field is ignored (use client_secret_hash instead).
 	Clients []ClientData `json:"clients"`
+
+	// Network to sync into: a network UUID or a name from hydra_sidecar_networks.
+	// Falls back to the X-Network-ID header, then the default network.
+	NetworkID string `json:"network_id,omitempty"`
+}
+
+// BatchDeleteRequest is the request body for bulk client deletion.
+//
+// swagger:model batchDeleteRequest
+type BatchDeleteRequest struct {
+	// Client IDs to delete (duplicates are ignored)
+	ClientIDs []string `json:"client_ids"`
+
+	// Network the clients must belong to: a network UUID or a name from hydra_sidecar_networks.
+	// Falls back to the X-Network-ID header, then the default network.
+	NetworkID string `json:"network_id,omitempty"`
 }
 
 // RotateClientRequest is the optional request body for secret rotation.
@@ -49,6 +73,12 @@ type RotateClientRequest struct {
 //
 // swagger:model syncResult
 type SyncResult struct {
+	// Overall outcome: "success", "partial" (some operations failed), "failed" (all failed),
+	// or "rolled_back" (atomic sync discarded the batch)
+	Status string `json:"status"`
+	// True when an atomic sync was rolled back; counts and results then describe the
+	// attempted batch, none of which was applied
+	RolledBack bool `json:"rolled_back,omitempty"`
 	// Number of clients created
 	CreatedCount int `json:"created_count"`
 	// Number of clients updated
@@ -57,6 +87,10 @@ type SyncResult struct {
 	DeletedCount int `json:"deleted_count"`
 	// Number of operations that failed
 	FailedCount int `json:"failed_count"`
+	// Wall time of the whole sync in milliseconds
+	DurationMS float64 `json:"duration_ms"`
+	// Per-client operations (created, updated, deleted, or failed) per second of DurationMS
+	ClientsPerSecond float64 `json:"clients_per_second"`
 	// Per-client operation results
 	Results []ClientResult `json:"results"`
 }
@@ -67,10 +101,343 @@ type SyncResult struct {
 type ClientResult struct {
 	// Client ID
 	ClientID string `json:"client_id"`
+	// Sync phase that produced this result: "upsert" or "delete"
+	Operation string `json:"operation,omitempty"`
 	// Operation status: "created", "updated", "deleted", or "failed"
 	Status string `json:"status"`
 	// Error message if status is "failed"
 	Error *string `json:"error,omitempty"`
+	// True when an updated client's new hash uses a different algorithm than its stored hash
+	HashAlgorithmChanged bool `json:"hash_algorithm_changed,omitempty"`
+}
+
+// SyncProgress is one client's result, sent as a progress event while a sync
+// streams with Accept: text/event-stream.
+//
+// swagger:model syncProgress
+type SyncProgress struct {
+	ClientResult
+	// Operations finished so far, including this one
+	Processed int `json:"processed"`
+	// Operations the sync runs: one upsert per client, plus a full sync's deletes
+	Total int `json:"total"`
+}
+
+// BatchDeleteResult is the response from bulk client deletion.
+//
+// swagger:model batchDeleteResult
+type BatchDeleteResult struct {
+	// Overall outcome: "success", "partial" (some deletes failed), or "failed" (all failed)
+	Status string `json:"status"`
+	// Number of clients deleted
+	DeletedCount int `json:"deleted_count"`
+	// Number of deletes that failed
+	FailedCount int `json:"failed_count"`
+	// Per-client results in request order, each with operation "delete"
+	Results []ClientResult `json:"results"`
+}
+
+// SyncDiff reports drift between a desired client set and the database.
+//
+// swagger:model syncDiff
+type SyncDiff struct {
+	// True if the database matches the desired set exactly
+	InSync bool `json:"in_sync"`
+	// Desired client IDs not in the database (a sync would create them)
+	Missing []string `json:"missing"`
+	// Stored client IDs not in the desired set (a full sync would delete them)
+	Extra []string `json:"extra"`
+	// Client IDs whose stored secret hash differs from client_secret_hash
+	HashMismatch []string `json:"hash_mismatch"`
+}
+
+// PreflightReport is the response from the sync preflight check.
+//
+// swagger:model preflightReport
+type PreflightReport struct {
+	// True if every check passed
+	Passed bool `json:"passed"`
+	// Individual check results
+	Checks []PreflightCheck `json:"checks"`
+}
+
+// PreflightCheck is the result of a single preflight check.
+//
+// swagger:model preflightCheck
+type PreflightCheck struct {
+	// Check name: "database", "network_id", "hasher", or "payload"
+	Name string `json:"name"`
+	// Whether the check passed
+	Passed bool `json:"passed"`
+	// Failure reason if the check failed
+	Error *string `json:"error,omitempty"`
+}
+
+// AuditEvent is one audit log entry. Field names are stable for SIEM ingestion.
+//
+// swagger:model auditEvent
+type AuditEvent struct {
+	// Monotonic event ID (database-backed events only)
+	ID int64 `json:"id,omitempty" db:"id"`
+	// When the operation completed (UTC)
+	Timestamp time.Time `json:"timestamp" db:"occurred_at"`
+	// "client.create", "client.patch", "client.rotate", "client.delete", "client.restore",
+	// "client.verify_secret", or "clients.sync"
+	Operation string `json:"operation" db:"operation"`
+	// Affected client (empty for syncs)
+	ClientID string `json:"client_id,omitempty" db:"client_id"`
+	// Network the operation targeted, when known
+	NetworkID string `json:"network_id,omitempty" db:"nid"`
+	// Operation-specific summary, e.g. sync counts or the failure reason
+	Detail string `json:"detail,omitempty" db:"detail"`
+	// Who made the call: the API key fingerprint from admin auth, or "anonymous"
+	Caller string `json:"caller,omitempty" db:"caller"`
+	// "success", "partial" (sync with failed operations), or "failure"
+	Outcome string `json:"outcome,omitempty" db:"outcome"`
+	// Clients a sync touched (JSON lines only; the database table keeps the counts in detail)
+	ClientIDs []string `json:"client_ids,omitempty" db:"-"`
+}
+
+// ReadinessDiagnostics is the verbose readiness report (GET /ready?verbose=true).
+//
+// swagger:model readinessDiagnostics
+type ReadinessDiagnostics struct {
+	// True if the sidecar is ready to serve (database, and Hydra if checked, reachable)
+	Ready bool `json:"ready"`
+	// Dependencies that failed: "database" and/or "hydra" (omitted when ready)
+	Failed []string `json:"failed,omitempty"`
+	// Database connectivity
+	Database DatabaseDiagnostics `json:"database"`
+	// Hydra Admin reachability (omitted when READINESS_CHECK_HYDRA is disabled)
+	Hydra *HydraDiagnostics `json:"hydra,omitempty"`
+	// When the last sync completed with status "success" (omitted if none since startup)
+	LastSuccessfulSync *time.Time `json:"last_successful_sync,omitempty"`
+	// Default network resolution
+	Network NetworkDiagnostics `json:"network"`
+	// Shared Hydra retry budget; zero remaining means Hydra calls fail fast without retries
+	RetryBudget RetryBudgetDiagnostics `json:"retry_budget"`
+	// Hydra Admin circuit breaker
+	HydraCircuit CircuitDiagnostics `json:"hydra_circuit"`
+}
+
+// DatabaseDiagnostics reports the readiness database ping.
+//
+// swagger:model databaseDiagnostics
+type DatabaseDiagnostics struct {
+	// Whether the ping succeeded
+	OK bool `json:"ok"`
+	// Ping round-trip time in milliseconds
+	LatencyMS float64 `json:"latency_ms"`
+	// Ping failure reason
+	Error *string `json:"error,omitempty"`
+}
+
+// HydraDiagnostics reports the readiness check of Hydra Admin's /health/alive.
+//
+// swagger:model hydraDiagnostics
+type HydraDiagnostics struct {
+	// Whether Hydra answered 200
+	OK bool `json:"ok"`
+	// Request round-trip time in milliseconds
+	LatencyMS float64 `json:"latency_ms"`
+	// Failure reason
+	Error *string `json:"error,omitempty"`
+}
+
+// ReadinessFailure is the plain readiness probe's 503 body.
+//
+// swagger:model readinessFailure
+type ReadinessFailure struct {
+	// Always false
+	Ready bool `json:"ready"`
+	// Dependencies that failed: "database" and/or "hydra"
+	Failed []string `json:"failed"`
+}
+
+// NetworkDiagnostics reports whether the default network ID is known.
+//
+// swagger:model networkDiagnostics
+type NetworkDiagnostics struct {
+	// "resolved" or "unresolved" (looked up again on first use)
+	Status string `json:"status"`
+	// Default network ID, when resolved
+	ID string `json:"id,omitempty"`
+}
+
+// RetryBudgetDiagnostics reports the shared Hydra retry budget.
+//
+// swagger:model retryBudgetDiagnostics
+type RetryBudgetDiagnostics struct {
+	// Retries currently available
+	Remaining int `json:"remaining"`
+	// Maximum burst of retries (RETRY_BUDGET_CAPACITY)
+	Capacity int `json:"capacity"`
+	// "ok", or "exhausted" when no retries are available
+	State string `json:"state"`
+}
+
+// CircuitDiagnostics reports the Hydra Admin circuit breaker.
+//
+// swagger:model circuitDiagnostics
+type CircuitDiagnostics struct {
+	// Whether HYDRA_CIRCUIT_FAILURES enables the breaker
+	Enabled bool `json:"enabled"`
+	// "closed", "open" (Hydra calls fail fast), or "half_open" (probing Hydra)
+	State string `json:"state"`
+}
+
+// VersionInfo reports the Hydra version the sidecar is talking to.
+//
+// swagger:model versionInfo
+type VersionInfo struct {
+	// Hydra version detected at startup ("unknown" if the probe failed)
+	HydraVersion string `json:"hydra_version"`
+	// Whether the Hydra version is in the known-compatible range
+	HydraCompatible bool `json:"hydra_compatible"`
+	// Lowest compatible Hydra version (inclusive)
+	CompatibleRangeMin string `json:"compatible_range_min"`
+	// Upper bound of compatible Hydra versions (exclusive)
+	CompatibleRangeMaxExc string `json:"compatible_range_max_exclusive"`
+}
+
+// BuildInfo reports the sidecar's own build and uptime.
+//
+// swagger:model buildInfo
+type BuildInfo struct {
+	// Build version from -ldflags ("dev" for local builds)
+	Version string `json:"version"`
+	// Git commit from -ldflags ("unknown" for local builds)
+	Commit string `json:"commit"`
+	// Go toolchain the binary was built with
+	GoVersion string `json:"go_version"`
+	// When the process started
+	StartedAt time.Time `json:"started_at"`
+	// Whole seconds since the process started
+	UptimeSeconds int64 `json:"uptime_seconds"`
+}
+
+// NoncompliantClientsReport lists clients missing required metadata.
+//
+// swagger:model noncompliantClientsReport
+type NoncompliantClientsReport struct {
+	// Metadata keys that were required
+	Required []string `json:"required"`
+	// Clients missing at least one required key
+	Clients []NoncompliantClient `json:"clients"`
+}
+
+// ClientCount is the number of clients in a network.
+//
+// swagger:model clientCount
+type ClientCount struct {
+	// Clients in the network
+	Count int `json:"count"`
+	// Network the clients were counted in
+	NetworkID string `json:"network_id"`
+}
+
+// TokenHookPreview is the claims the token hook would issue for a client.
+//
+// swagger:model tokenHookPreview
+type TokenHookPreview struct {
+	// Client the claims were built for
+	ClientID string `json:"client_id"`
+	// Where the client info came from: "hydra", "stale_cache", "last_known_good",
+	// or "none" when the lookup failed and the hook would fall back
+	Source string `json:"source"`
+	// Why the client info lookup failed, if it did
+	LookupError string `json:"lookup_error,omitempty"`
+	// The hook would refuse the token: the client has expired or was deleted
+	Expired bool `json:"expired"`
+	// Why the token would be refused
+	DenialReason string `json:"denial_reason,omitempty"`
+	// MAX_CLAIMS_MODE=reject would refuse the token for its claims' size
+	ClaimsTooLarge bool `json:"claims_too_large,omitempty"`
+	// Access token claims the hook would add
+	Claims map[string]any `json:"claims"`
+}
+
+// VerifySecretRequest is a plaintext secret to check against a client's stored hash.
+//
+// swagger:model verifySecretRequest
+type VerifySecretRequest struct {
+	// Plaintext secret to check (never logged or stored)
+	// required: true
+	ClientSecret string `json:"client_secret"`
+}
+
+// VerifySecretResult reports whether a secret matches a client's stored hash.
+//
+// swagger:model verifySecretResult
+type VerifySecretResult struct {
+	// The secret matches the stored hash
+	Valid bool `json:"valid"`
+}
+
+// ClientSearchResult is one page of clients matching a metadata search.
+//
+// swagger:model clientSearchResult
+type ClientSearchResult struct {
+	// Matching clients in client ID order
+	Clients []ClientSearchMatch `json:"clients"`
+	// Pass as page_token to get the next page (omitted on the last page)
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// ClientSearchMatch is a client whose metadata matched a search.
+//
+// swagger:model clientSearchMatch
+type ClientSearchMatch struct {
+	// Client ID
+	ClientID string `json:"client_id"`
+	// The client's full metadata
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+// DeletedClientsReport lists a network's soft-deleted clients.
+//
+// swagger:model deletedClientsReport
+type DeletedClientsReport struct {
+	// Soft-deleted clients in client ID order
+	Clients []DeletedClient `json:"clients"`
+}
+
+// DeletedClient is a soft-deleted client.
+//
+// swagger:model deletedClient
+type DeletedClient struct {
+	// Client ID
+	ClientID string `json:"client_id" db:"id"`
+	// When the client was soft-deleted (RFC 3339)
+	DeletedAt string `json:"deleted_at" db:"deleted_at"`
+}
+
+// NoncompliantClient is a client missing required metadata.
+//
+// swagger:model noncompliantClient
+type NoncompliantClient struct {
+	// Client ID
+	ClientID string `json:"client_id"`
+	// Required metadata keys the client lacks
+	MissingKeys []string `json:"missing_keys"`
+}
+
+// CrossNetworkDuplicatesReport lists client IDs registered in several networks.
+//
+// swagger:model crossNetworkDuplicatesReport
+type CrossNetworkDuplicatesReport struct {
+	// Client IDs present in more than one network
+	Duplicates []CrossNetworkDuplicate `json:"duplicates"`
+}
+
+// CrossNetworkDuplicate is a client ID and the networks it appears in.
+//
+// swagger:model crossNetworkDuplicate
+type CrossNetworkDuplicate struct {
+	// Client ID
+	ClientID string `json:"client_id"`
+	// Network IDs (nid) containing the client, sorted
+	Networks []string `json:"networks"`
 }
 
 // TokenHookRequest represents the incoming request from Hydra token hook.
@@ -99,10 +466,26 @@ type TokenHookResponse struct {
 //
 // swagger:model tokenHookErrorResponse
 type TokenHookErrorResponse struct {
-	// Error code (e.g., "access_denied")
+	// Error code (e.g., "access_denied", configurable with TOKEN_HOOK_DENY_ERROR)
 	Error string `json:"error"`
 	// Human-readable error description
 	ErrorDescription string `json:"error_description"`
+	// Remediation hint (only with TOKEN_HOOK_ERROR_EXTRA_FIELDS=true)
+	ErrorHint string `json:"error_hint,omitempty"`
+	// HTTP status code (only with TOKEN_HOOK_ERROR_EXTRA_FIELDS=true)
+	StatusCode int `json:"status_code,omitempty"`
+}
+
+// ClientUsage is the token issuance history for a client.
+//
+// swagger:model clientUsage
+type ClientUsage struct {
+	// Client ID
+	ClientID string `json:"client_id" db:"client_id"`
+	// Number of tokens issued via the token hook
+	IssuedCount int64 `json:"issued_count" db:"issued_count"`
+	// When the last token was issued (omitted if never)
+	LastIssuedAt *time.Time `json:"last_issued_at,omitempty" db:"last_issued_at"`
 }
 
 // ClientInfo holds client metadata and expiration info from Hydra.
@@ -110,20 +493,46 @@ type TokenHookErrorResponse struct {
 type ClientInfo struct {
 	Metadata              map[string]any `json:"metadata"`
 	ClientSecretExpiresAt int64          `json:"client_secret_expires_at"`
+	// Space-separated scopes the client may request
+	Scope string `json:"scope"`
+}
+
+// Capabilities lists the sidecar's optional features.
+//
+// swagger:model capabilities
+type Capabilities struct {
+	// Feature name -> status and settings
+	Features map[string]Capability `json:"features"`
+}
+
+// Capability is the status of one optional feature.
+//
+// swagger:model capability
+type Capability struct {
+	// Whether the feature is enabled in this deployment
+	Enabled bool `json:"enabled"`
+	// Key non-secret settings (e.g. TTLs, modes, configured names)
+	Settings map[string]any `json:"settings,omitempty"`
 }
 
 // ==== Swagger Response Wrappers ====
 
+// APIError is the body of every error response the sidecar produces itself.
+//
+// swagger:model apiError
+type APIError struct {
+	// Machine-readable error code, e.g. "invalid_request" or "upstream_error"
+	Error string `json:"error"`
+	// Human-readable description
+	ErrorDescription string `json:"error_description"`
+}
+
 // ErrorResponse represents an error response.
 //
 // swagger:response errorResponse
 type ErrorResponse struct {
-	// The error message
 	// in: body
-	Body struct {
-		// Error message
-		Error string `json:"error"`
-	}
+	Body APIError
 }
 
 // NoContentResponse represents a 204 No Content response.
@@ -141,6 +550,15 @@ type HealthResponse struct {
 	Body string
 }
 
+// MetricsResponse represents the Prometheus metrics exposition.
+//
+// swagger:response metricsResponse
+type MetricsResponse struct {
+	// Metrics in the Prometheus text format
+	// in: body
+	Body string
+}
+
 // ClientDataResponse wraps ClientData for swagger response.
 //
 // swagger:response clientDataResponse
@@ -149,6 +567,16 @@ type ClientDataResponse struct {
 	Body ClientData
 }
 
+// ClientListResponse is a page of clients with their hashes.
+//
+// swagger:response clientListResponse
+type ClientListResponse struct {
+	// Pagination links from Hydra (rel="next", rel="prev", ...)
+	Link string
+	// in: body
+	Body []ClientData
+}
+
 // SyncResultResponse wraps SyncResult for swagger response.
 //
 // swagger:response syncResultResponse
@@ -157,6 +585,218 @@ type SyncResultResponse struct {
 	Body SyncResult
 }
 
+// SyncDeleteRefusedError is the 409 body of a full sync refused by
+// MAX_SYNC_DELETE_RATIO
+type SyncDeleteRefusedError struct {
+	APIError
+	// Clients the sync would have deleted
+	ClientIDs []string `json:"client_ids"`
+	// Clients in the network before the sync
+	ExistingCount int `json:"existing_count"`
+}
+
+// SyncInterruptedError is the 503 body of a sync stopped by shutdown at a
+// phase boundary
+type SyncInterruptedError struct {
+	APIError
+	// The phases completed before the interruption, with status "partial";
+	// omitted when nothing was applied
+	Result *SyncResult `json:"result,omitempty"`
+}
+
+// SyncValidationError is the 400 body of a sync batch that failed
+// validation; nothing was synced
+type SyncValidationError struct {
+	APIError
+	// Client IDs with at least one failure, in request order
+	ClientIDs []string `json:"client_ids"`
+	// Every failure in the batch
+	Errors []SyncClientError `json:"errors"`
+}
+
+// SyncClientError is one validation failure in a sync batch
+type SyncClientError struct {
+	// Empty for an entry without a client_id
+	ClientID string `json:"client_id"`
+	Error    string `json:"error"`
+}
+
+// SyncValidationErrorResponse wraps SyncValidationError for swagger response.
+//
+// swagger:response syncValidationErrorResponse
+type SyncValidationErrorResponse struct {
+	// in: body
+	Body SyncValidationError
+}
+
+// SyncInterruptedResponse wraps SyncInterruptedError for swagger response.
+//
+// swagger:response syncInterruptedResponse
+type SyncInterruptedResponse struct {
+	// in: body
+	Body SyncInterruptedError
+}
+
+// SyncDeleteRefusedResponse wraps SyncDeleteRefusedError for swagger response.
+//
+// swagger:response syncDeleteRefusedResponse
+type SyncDeleteRefusedResponse struct {
+	// in: body
+	Body SyncDeleteRefusedError
+}
+
+// BatchDeleteResultResponse wraps BatchDeleteResult for swagger response.
+//
+// swagger:response batchDeleteResultResponse
+type BatchDeleteResultResponse struct {
+	// in: body
+	Body BatchDeleteResult
+}
+
+// ClientUsageResponse wraps ClientUsage for swagger response.
+//
+// swagger:response clientUsageResponse
+type ClientUsageResponse struct {
+	// in: body
+	Body ClientUsage
+}
+
+// SyncDiffResponse wraps SyncDiff for swagger response.
+//
+// swagger:response syncDiffResponse
+type SyncDiffResponse struct {
+	// in: body
+	Body SyncDiff
+}
+
+// PreflightReportResponse wraps PreflightReport for swagger response.
+//
+// swagger:response preflightReportResponse
+type PreflightReportResponse struct {
+	// in: body
+	Body PreflightReport
+}
+
+// AuditEventsResponse is a stream of audit events, one JSON object per line.
+//
+// swagger:response auditEventsResponse
+type AuditEventsResponse struct {
+	// in: body
+	Body []AuditEvent
+}
+
+// ReadinessDiagnosticsResponse wraps ReadinessDiagnostics for swagger response.
+//
+// swagger:response readinessDiagnosticsResponse
+type ReadinessDiagnosticsResponse struct {
+	// in: body
+	Body ReadinessDiagnostics
+}
+
+// ReadinessFailureResponse wraps ReadinessFailure for swagger response.
+//
+// swagger:response readinessFailureResponse
+type ReadinessFailureResponse struct {
+	// in: body
+	Body ReadinessFailure
+}
+
+// VersionResponse wraps VersionInfo for swagger response.
+//
+// swagger:response versionResponse
+type VersionResponse struct {
+	// in: body
+	Body VersionInfo
+}
+
+// InfoResponse wraps BuildInfo for swagger response.
+//
+// swagger:response infoResponse
+type InfoResponse struct {
+	// in: body
+	Body BuildInfo
+}
+
+// DebugConfigResponse is the effective configuration with secrets redacted.
+//
+// swagger:response debugConfigResponse
+type DebugConfigResponse struct {
+	// in: body
+	Body map[string]any
+}
+
+// CapabilitiesResponse wraps Capabilities for swagger response.
+//
+// swagger:response capabilitiesResponse
+type CapabilitiesResponse struct {
+	// in: body
+	Body Capabilities
+}
+
+// NoncompliantClientsResponse wraps NoncompliantClientsReport for swagger response.
+//
+// swagger:response noncompliantClientsResponse
+type NoncompliantClientsResponse struct {
+	// in: body
+	Body NoncompliantClientsReport
+}
+
+// ExportClientsResponse wraps SyncClientsRequest for swagger response.
+//
+// swagger:response exportClientsResponse
+type ExportClientsResponse struct {
+	// in: body
+	Body SyncClientsRequest
+}
+
+// ClientCountResponse wraps ClientCount for swagger response.
+//
+// swagger:response clientCountResponse
+type ClientCountResponse struct {
+	// in: body
+	Body ClientCount
+}
+
+// TokenHookPreviewResponse wraps TokenHookPreview for swagger response.
+//
+// swagger:response tokenHookPreviewResponse
+type TokenHookPreviewResponse struct {
+	// in: body
+	Body TokenHookPreview
+}
+
+// VerifySecretResultResponse wraps VerifySecretResult for swagger response.
+//
+// swagger:response verifySecretResultResponse
+type VerifySecretResultResponse struct {
+	// in: body
+	Body VerifySecretResult
+}
+
+// ClientSearchResponse wraps ClientSearchResult for swagger response.
+//
+// swagger:response clientSearchResponse
+type ClientSearchResponse struct {
+	// in: body
+	Body ClientSearchResult
+}
+
+// DeletedClientsResponse wraps DeletedClientsReport for swagger response.
+//
+// swagger:response deletedClientsResponse
+type DeletedClientsResponse struct {
+	// in: body
+	Body DeletedClientsReport
+}
+
+// CrossNetworkDuplicatesResponse wraps CrossNetworkDuplicatesReport for swagger response.
+//
+// swagger:response crossNetworkDuplicatesResponse
+type CrossNetworkDuplicatesResponse struct {
+	// in: body
+	Body CrossNetworkDuplicatesReport
+}
+
 // TokenHookResponseWrapper wraps TokenHookResponse for swagger.
 //
 // swagger:response tokenHookResponseWrapper
@@ -177,7 +817,23 @@ type TokenHookErrorResponseWrapper struct {
 // These types are used by go-swagger to generate API documentation.
 // They are intentionally not referenced in Go code.
 
-// swagger:parameters getClient deleteClient
+// JSONPatchOperation is a single RFC 6902 JSON Patch operation.
+//
+// swagger:model jsonPatchOperation
+type JSONPatchOperation struct {
+	// Operation: add, remove, replace, move, copy, or test
+	// example: replace
+	Op string `json:"op"`
+	// JSON Pointer to the target field
+	// example: /metadata/tier
+	Path string `json:"path"`
+	// Value for add/replace/test
+	Value json.RawMessage `json:"value,omitempty"`
+	// Source pointer for move/copy
+	From string `json:"from,omitempty"`
+}
+
+// swagger:parameters getClient deleteClient getClientUsage restoreClient
 type clientIDPathParam struct {
 	// Client ID
 	// in: path
@@ -185,33 +841,186 @@ type clientIDPathParam struct {
 	ClientID string `json:"client_id"`
 }
 
+// swagger:parameters previewTokenHook
+type previewTokenHookParams struct {
+	// Client ID
+	// in: path
+	// required: true
+	ClientID string `json:"client_id"`
+	// Granted scopes to build the claims for, space-separated or repeated
+	// in: query
+	Scope []string `json:"scope"`
+}
+
 // swagger:parameters rotateClient
 type rotateClientParams struct {
 	// Client ID
 	// in: path
 	// required: true
 	ClientID string `json:"client_id"`
+	// Network UUID or name (default: the single default network)
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
 	// Optional rotation settings
 	// in: body
 	Body RotateClientRequest
 }
 
+// swagger:parameters verifyClientSecret
+type verifyClientSecretParams struct {
+	// Client ID
+	// in: path
+	// required: true
+	ClientID string `json:"client_id"`
+	// Network UUID or name (default: the single default network)
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
+	// Secret to check
+	// in: body
+	// required: true
+	Body VerifySecretRequest
+}
+
+// swagger:parameters exportAuditEvents
+type exportAuditEventsParams struct {
+	// Export format (only "jsonl")
+	// in: query
+	Format string `json:"format"`
+	// Earliest event time, inclusive (RFC 3339 or Unix seconds)
+	// in: query
+	Since string `json:"since"`
+	// Latest event time, exclusive (RFC 3339 or Unix seconds)
+	// in: query
+	Until string `json:"until"`
+}
+
+// swagger:parameters readinessCheck
+type readinessParams struct {
+	// Return JSON diagnostics instead of plain text
+	// in: query
+	Verbose bool `json:"verbose"`
+}
+
+// swagger:parameters listClients
+type listClientsParams struct {
+	// Items per page (passed through to Hydra)
+	// in: query
+	PageSize int `json:"page_size"`
+	// Page token from the previous response's Link header
+	// in: query
+	PageToken string `json:"page_token"`
+	// Network UUID or name for the client_secret_hash lookup
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
+}
+
+// swagger:parameters patchClient
+type patchClientParams struct {
+	// Client ID
+	// in: path
+	// required: true
+	ClientID string `json:"client_id"`
+	// JSON Patch operations (passed through to Hydra), or a JSON Merge Patch object
+	// in: body
+	// required: true
+	Body []JSONPatchOperation
+	// Network UUID or name for the client_secret_hash lookup
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
+}
+
 // swagger:parameters createClient
 type createClientParams struct {
+	// Demo only: partially mask client_secret in the response
+	// in: query
+	MaskSecret bool `json:"mask_secret"`
+	// Network UUID or name (default: the single default network)
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
+	// Client-chosen key (at most 255 characters) that makes retries of this create safe
+	// in: header
+	IdempotencyKey string `json:"Idempotency-Key"`
 	// OAuth2 client configuration (passed through to Hydra)
 	// in: body
 	// required: true
 	Body client.Client
 }
 
-// swagger:parameters syncClients
+// swagger:parameters listNoncompliantClients
+type noncompliantClientsParams struct {
+	// Comma-separated metadata keys every client must have (e.g. org_id,tier)
+	// in: query
+	// required: true
+	Require string `json:"require"`
+}
+
+// swagger:parameters countClients
+type countClientsParams struct {
+	// Network UUID or name (default: X-Network-ID, else the default network)
+	// in: query
+	NetworkID string `json:"network_id"`
+}
+
+// swagger:parameters exportClients
+type exportClientsParams struct {
+	// Network UUID or name (default: X-Network-ID, else the default network)
+	// in: query
+	NetworkID string `json:"network_id"`
+}
+
+// swagger:parameters searchClients
+type searchClientsParams struct {
+	// Clients per page (default 100, at most 500)
+	// in: query
+	PageSize int `json:"page_size"`
+	// next_page_token from the previous page
+	// in: query
+	PageToken string `json:"page_token"`
+	// Network UUID or name (default: the default network)
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
+}
+
+// swagger:parameters syncClients syncClientsUpsert syncPreflight syncClientsDiff
 type syncClientsParams struct {
+	// Apply the batch in one transaction, rolled back if any delete fails or more than
+	// SYNC_MAX_FAILURES operations fail (syncClients only)
+	// in: query
+	Atomic bool `json:"atomic"`
+	// "full" (default) deletes clients missing from the request; "upsert" only creates and
+	// updates, never deleting (syncClients only)
+	// in: query
+	// enum: full,upsert
+	Mode string `json:"mode"`
+	// Apply a full sync even if it deletes more than MAX_SYNC_DELETE_RATIO of the
+	// network's clients (syncClients only)
+	// in: query
+	Force bool `json:"force"`
+	// How to handle repeats of a client_id whose data differs: "reject" (default, 400) or
+	// "last_wins" (keep the last entry) (syncClients and syncPreflight only)
+	// in: query
+	// enum: reject,last_wins
+	Duplicates string `json:"duplicates"`
+	// Network UUID or name, used when the body has no network_id
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
 	// Clients to sync (client_secret_hash must contain the stored hash)
 	// in: body
 	// required: true
 	Body SyncClientsRequest
 }
 
+// swagger:parameters batchDeleteClients
+type batchDeleteClientsParams struct {
+	// Network UUID or name, used when the body has no network_id
+	// in: header
+	NetworkID string `json:"X-Network-ID"`
+	// Clients to delete
+	// in: body
+	// required: true
+	Body BatchDeleteRequest
+}
+
 // swagger:parameters tokenHook
 type tokenHookParams struct {
 	// Token hook request from Hydra
@@ -227,4 +1036,12 @@ var (
 	_ = createClientParams{}
 	_ = syncClientsParams{}
 	_ = tokenHookParams{}
+	_ = noncompliantClientsParams{}
+	_ = countClientsParams{}
+	_ = exportClientsParams{}
+	_ = searchClientsParams{}
+	_ = patchClientParams{}
+	_ = listClientsParams{}
+	_ = readinessParams{}
+	_ = exportAuditEventsParams{}
 )
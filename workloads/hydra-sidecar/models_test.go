@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	sidecar "github.com/example/hydra-sidecar/client"
+	"github.com/ory/hydra/v2/client"
+)
+
+// roundTrip decodes the JSON of in into out and re-encodes it, so fields the
+// Go client package doesn't know about show up as a difference
+func roundTrip(t *testing.T, in, out any) {
+	t.Helper()
+	want, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if err := json.Unmarshal(want, out); err != nil {
+		t.Fatalf("unmarshal into %T: %v", out, err)
+	}
+	got, err := json.Marshal(out)
+	if err != nil {
+		t.Fatalf("marshal %T: %v", out, err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%T JSON = %s, want %s", out, got, want)
+	}
+}
+
+func TestClientPackageMatchesModels(t *testing.T) {
+	errStr := "boom"
+	roundTrip(t, SyncResult{
+		Status: syncStatusPartial, RolledBack: true, CreatedCount: 1, UpdatedCount: 2, DeletedCount: 3, FailedCount: 4,
+		DurationMS: 1.5, ClientsPerSecond: 2.5,
+		Results: []ClientResult{{ClientID: "a", Operation: syncOpUpsert, Status: "failed", Error: &errStr, HashAlgorithmChanged: true}},
+	}, &sidecar.SyncResult{})
+	roundTrip(t, SyncClientsRequest{
+		Clients:   []ClientData{{Client: client.Client{ID: "a", Secret: "s"}, ClientSecretHash: "h"}},
+		NetworkID: "tenant-b",
+	}, &sidecar.SyncClientsRequest{})
+	roundTrip(t, SyncValidationError{
+		APIError:  APIError{Error: errCodeInvalidRequest, ErrorDescription: "invalid"},
+		ClientIDs: []string{"a"},
+		Errors:    []SyncClientError{{ClientID: "a", Error: "bad hash"}, {Error: "clients[1]: client_id is required"}},
+	}, &sidecar.Error{})
+	roundTrip(t, SyncDeleteRefusedError{
+		APIError:  APIError{Error: errCodeConflict, ErrorDescription: "refused"},
+		ClientIDs: []string{"a"},
+	}, &struct {
+		sidecar.Error
+		ExistingCount int `json:"existing_count"`
+	}{})
+}
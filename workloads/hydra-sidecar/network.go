@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// networkIDHeader selects the network when the request body doesn't
+const networkIDHeader = "X-Network-ID"
+
+// errUnknownNetwork is returned when a requested network name has no mapping
+var errUnknownNetwork = errors.New("unknown network")
+
+// errNetworkNotAllowed is returned when a scoped API key requests another network
+var errNetworkNotAllowed = errors.New("API key is not authorized for this network")
+
+// errNotHydraNetwork is returned when a route that passes through to Hydra's
+// Admin API selects a network other than Hydra's own
+var errNotHydraNetwork = errors.New("network is not served by Hydra")
+
+// networkLookup is the subset of Store used to resolve networks
+type networkLookup interface {
+	GetDefaultNetworkID(ctx context.Context) (uuid.UUID, error)
+	GetNetworkIDByName(ctx context.Context, name string) (uuid.UUID, error)
+}
+
+// requestedNetwork returns the network selected by the request: the body
+// value if set, otherwise the X-Network-ID header (empty = default network)
+func requestedNetwork(r *http.Request, bodyValue string) string {
+	if bodyValue != "" {
+		return bodyValue
+	}
+	return r.Header.Get(networkIDHeader)
+}
+
+// checkNetworkScope rejects a requested network outside the API key's scope.
+// adminAuth already matched X-Network-ID; this also covers body values.
+func checkNetworkScope(ctx context.Context, requested string) error {
+	if scope := networkScope(ctx); scope != "" && requested != scope {
+		return fmt.Errorf("%w: %q", errNetworkNotAllowed, requested)
+	}
+	return nil
+}
+
+// networkFor resolves the network for a request, see requestedNetwork
+func (s *Server) networkFor(r *http.Request, bodyValue string) (uuid.UUID, error) {
+	requested := requestedNetwork(r, bodyValue)
+	if err := checkNetworkScope(r.Context(), requested); err != nil {
+		return uuid.Nil, err
+	}
+	return s.resolveNetwork(r.Context(), s.store, requested)
+}
+
+// hydraNetworkFor resolves the network for a route that passes through to
+// Hydra's Admin API. Hydra only serves its own network, the default one, so
+// another network is rejected instead of acting on Hydra's clients: as
+// errNetworkNotAllowed for a network-scoped API key, else errNotHydraNetwork.
+func (s *Server) hydraNetworkFor(r *http.Request, bodyValue string) (uuid.UUID, error) {
+	nid, err := s.networkFor(r, bodyValue)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	hydraNID, err := s.resolveNetwork(r.Context(), s.store, "")
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if nid != hydraNID {
+		requested := requestedNetwork(r, bodyValue)
+		if networkScope(r.Context()) != "" {
+			return uuid.Nil, fmt.Errorf("%w: %q is not Hydra's network", errNetworkNotAllowed, requested)
+		}
+		return uuid.Nil, fmt.Errorf("%w: %q", errNotHydraNetwork, requested)
+	}
+	return nid, nil
+}
+
+// resolveNetwork maps a requested network to its ID. A UUID is used as-is and
+// anything else is looked up by name. An empty value falls back to the
+// default network, which is cached on first use (single-tenant behavior).
+func (s *Server) resolveNetwork(ctx context.Context, db networkLookup, requested string) (uuid.UUID, error) {
+	if requested != "" {
+		if nid, err := uuid.FromString(requested); err == nil {
+			return nid, nil
+		}
+		return db.GetNetworkIDByName(ctx, requested)
+	}
+
+	if nid := s.defaultNetworkID(); nid != uuid.Nil {
+		return nid, nil
+	}
+	nid, err := db.GetDefaultNetworkID(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	s.setDefaultNetworkID(nid)
+	return nid, nil
+}
+
+// defaultNetworkID returns the cached default network ID (uuid.Nil = not yet resolved)
+func (s *Server) defaultNetworkID() uuid.UUID {
+	s.networkMu.RLock()
+	defer s.networkMu.RUnlock()
+	return s.networkID
+}
+
+// setDefaultNetworkID caches the default network ID for every handler
+func (s *Server) setDefaultNetworkID(nid uuid.UUID) {
+	s.networkMu.Lock()
+	defer s.networkMu.Unlock()
+	s.networkID = nid
+}
+
+// refreshNetworkID retries GetDefaultNetworkID every interval until the
+// default network ID is resolved, by this loop or by a request, or ctx is
+// cancelled. Started when the lookup fails at startup, e.g. before the
+// first Hydra migration has created the network row.
+func (s *Server) refreshNetworkID(ctx context.Context, db networkLookup, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if s.defaultNetworkID() != uuid.Nil {
+			return
+		}
+		nid, err := db.GetDefaultNetworkID(ctx)
+		if err != nil {
+			log.Printf("Warning: Could not get network ID: %v (retrying in %s)", err, interval)
+			continue
+		}
+		s.setDefaultNetworkID(nid)
+		log.Printf("Resolved default network ID %s", nid)
+		return
+	}
+}
+
+// writeNetworkError reports a network resolution failure: 403 for a network
+// outside the API key's scope, 400 for an unknown network name or one Hydra
+// doesn't serve, 500 otherwise
+func writeNetworkError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNetworkNotAllowed) {
+		writeJSONError(w, http.StatusForbidden, errCodeForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, errUnknownNetwork) || errors.Is(err, errNotHydraNetwork) {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+	log.Printf("Error getting network ID: %v", err)
+	writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "no network ID available")
+}
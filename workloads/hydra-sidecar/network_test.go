@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type fakeNetworkLookup struct {
+	defaultNID   uuid.UUID
+	names        map[string]uuid.UUID
+	defaultCalls int
+}
+
+func (f *fakeNetworkLookup) GetDefaultNetworkID(context.Context) (uuid.UUID, error) {
+	f.defaultCalls++
+	if f.defaultNID == uuid.Nil {
+		return uuid.Nil, errors.New("no networks")
+	}
+	return f.defaultNID, nil
+}
+
+func (f *fakeNetworkLookup) GetNetworkIDByName(_ context.Context, name string) (uuid.UUID, error) {
+	if nid, ok := f.names[name]; ok {
+		return nid, nil
+	}
+	return uuid.Nil, fmt.Errorf("%w %q", errUnknownNetwork, name)
+}
+
+func TestResolveNetwork(t *testing.T) {
+	defaultNID := uuid.Must(uuid.NewV4())
+	tenantNID := uuid.Must(uuid.NewV4())
+	explicitNID := uuid.Must(uuid.NewV4())
+	db := &fakeNetworkLookup{defaultNID: defaultNID, names: map[string]uuid.UUID{"tenant-b": tenantNID}}
+	s := &Server{}
+	ctx := context.Background()
+
+	if nid, err := s.resolveNetwork(ctx, db, "tenant-b"); err != nil || nid != tenantNID {
+		t.Errorf("resolveNetwork(name) = %v, %v; want %v", nid, err, tenantNID)
+	}
+	if nid, err := s.resolveNetwork(ctx, db, explicitNID.String()); err != nil || nid != explicitNID {
+		t.Errorf("resolveNetwork(uuid) = %v, %v; want %v", nid, err, explicitNID)
+	}
+	if _, err := s.resolveNetwork(ctx, db, "nope"); !errors.Is(err, errUnknownNetwork) {
+		t.Errorf("resolveNetwork(unknown) error = %v, want errUnknownNetwork", err)
+	}
+
+	// Default network is looked up once and cached
+	for i := 0; i < 2; i++ {
+		if nid, err := s.resolveNetwork(ctx, db, ""); err != nil || nid != defaultNID {
+			t.Errorf("resolveNetwork(\"\") = %v, %v; want %v", nid, err, defaultNID)
+		}
+	}
+	if db.defaultCalls != 1 {
+		t.Errorf("GetDefaultNetworkID called %d times, want 1", db.defaultCalls)
+	}
+}
+
+func TestRequestedNetworkPrecedence(t *testing.T) {
+	r := httptest.NewRequest("POST", "/sync/clients", nil)
+	r.Header.Set(networkIDHeader, "from-header")
+
+	if got := requestedNetwork(r, "from-body"); got != "from-body" {
+		t.Errorf("requestedNetwork() = %q, want body value", got)
+	}
+	if got := requestedNetwork(r, ""); got != "from-header" {
+		t.Errorf("requestedNetwork() = %q, want header value", got)
+	}
+}
+
+func TestHydraNetworkForRejectsOtherNetworks(t *testing.T) {
+	hydraNID := uuid.Must(uuid.NewV4())
+	otherNID := uuid.Must(uuid.NewV4())
+	s := &Server{}
+	s.setDefaultNetworkID(hydraNID)
+
+	request := func(network, scope string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/admin/clients", nil)
+		if network != "" {
+			r.Header.Set(networkIDHeader, network)
+		}
+		if scope != "" {
+			r = r.WithContext(context.WithValue(r.Context(), networkScopeKey{}, scope))
+		}
+		return r
+	}
+
+	if nid, err := s.hydraNetworkFor(request("", ""), ""); err != nil || nid != hydraNID {
+		t.Errorf("hydraNetworkFor(default) = %v, %v; want %v", nid, err, hydraNID)
+	}
+	if nid, err := s.hydraNetworkFor(request(hydraNID.String(), ""), ""); err != nil || nid != hydraNID {
+		t.Errorf("hydraNetworkFor(Hydra's UUID) = %v, %v; want %v", nid, err, hydraNID)
+	}
+	if _, err := s.hydraNetworkFor(request(otherNID.String(), ""), ""); !errors.Is(err, errNotHydraNetwork) {
+		t.Errorf("hydraNetworkFor(other) error = %v, want errNotHydraNetwork", err)
+	}
+	// A scoped key's own network is still not Hydra's
+	if _, err := s.hydraNetworkFor(request(otherNID.String(), otherNID.String()), ""); !errors.Is(err, errNetworkNotAllowed) {
+		t.Errorf("hydraNetworkFor(scoped) error = %v, want errNetworkNotAllowed", err)
+	}
+
+	rec := httptest.NewRecorder()
+	writeNetworkError(rec, fmt.Errorf("%w: %q", errNotHydraNetwork, "tenant-b"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("writeNetworkError(errNotHydraNetwork) = %d, want 400", rec.Code)
+	}
+}
+
+// flakyNetworkLookup fails GetDefaultNetworkID until failures run out
+type flakyNetworkLookup struct {
+	fakeNetworkLookup
+	failures int
+}
+
+func (f *flakyNetworkLookup) GetDefaultNetworkID(ctx context.Context) (uuid.UUID, error) {
+	if f.failures > 0 {
+		f.failures--
+		f.defaultCalls++
+		return uuid.Nil, errors.New("network table missing")
+	}
+	return f.fakeNetworkLookup.GetDefaultNetworkID(ctx)
+}
+
+func TestRefreshNetworkIDRetriesUntilResolved(t *testing.T) {
+	nid := uuid.Must(uuid.NewV4())
+	db := &flakyNetworkLookup{fakeNetworkLookup: fakeNetworkLookup{defaultNID: nid}, failures: 2}
+	s := &Server{}
+
+	done := make(chan struct{})
+	go func() {
+		s.refreshNetworkID(context.Background(), db, time.Millisecond)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refreshNetworkID did not return after the network resolved")
+	}
+
+	if got := s.defaultNetworkID(); got != nid {
+		t.Errorf("defaultNetworkID = %v, want %v", got, nid)
+	}
+	if db.defaultCalls != 3 {
+		t.Errorf("GetDefaultNetworkID called %d times, want 3", db.defaultCalls)
+	}
+	// Handlers now get the refreshed ID without another lookup
+	if got, err := s.resolveNetwork(context.Background(), db, ""); err != nil || got != nid || db.defaultCalls != 3 {
+		t.Errorf("resolveNetwork = %v, %v after %d lookups; want cached %v", got, err, db.defaultCalls, nid)
+	}
+}
+
+func TestRefreshNetworkIDStops(t *testing.T) {
+	// Already resolved by a request: the loop exits without a lookup
+	resolved := uuid.Must(uuid.NewV4())
+	db := &fakeNetworkLookup{}
+	s := &Server{networkID: resolved}
+	s.refreshNetworkID(context.Background(), db, time.Millisecond)
+	if db.defaultCalls != 0 || s.defaultNetworkID() != resolved {
+		t.Errorf("lookups = %d, network = %v; want 0 and %v", db.defaultCalls, s.defaultNetworkID(), resolved)
+	}
+
+	// Shutdown stops a loop that never resolves
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		(&Server{}).refreshNetworkID(ctx, &fakeNetworkLookup{}, time.Millisecond)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("refreshNetworkID kept running after its context was cancelled")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gofrs/uuid"
+)
+
+// mergePatchContentType selects JSON Merge Patch (RFC 7396) on PATCH
+const mergePatchContentType = "application/merge-patch+json"
+
+// secretHashLookup is the subset of Store used to enrich client responses
+type secretHashLookup interface {
+	GetHashedSecret(ctx context.Context, clientID string, nid uuid.UUID) (string, error)
+}
+
+// isMergePatch reports whether a PATCH body is a JSON Merge Patch: either
+// declared by Content-Type or, for plain application/json, a JSON object
+// (a JSON Patch is always an array)
+func isMergePatch(contentType string, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case mergePatchContentType:
+			return true
+		case "application/json-patch+json":
+			return false
+		}
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("{"))
+}
+
+// mergePatchToJSONPatch translates a JSON Merge Patch against the current
+// document into equivalent JSON Patch operations, since Hydra's PATCH only
+// accepts RFC 6902. Objects present on both sides are merged key by key;
+// null removes a member; anything else is set with "add", which replaces
+// existing members.
+func mergePatchToJSONPatch(current, patch map[string]any) ([]JSONPatchOperation, error) {
+	var ops []JSONPatchOperation
+	if err := appendMergeOps(&ops, "", current, patch); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+func appendMergeOps(ops *[]JSONPatchOperation, prefix string, current, patch map[string]any) error {
+	// Sorted for deterministic operation order
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := patch[key]
+		path := prefix + "/" + escapeJSONPointer(key)
+		existing, exists := current[key]
+
+		if value == nil {
+			if exists {
+				*ops = append(*ops, JSONPatchOperation{Op: "remove", Path: path})
+			}
+			continue
+		}
+
+		patchObj, isObj := value.(map[string]any)
+		currentObj, currentIsObj := existing.(map[string]any)
+		if isObj && currentIsObj {
+			if err := appendMergeOps(ops, path, currentObj, patchObj); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, err := json.Marshal(withoutNulls(value))
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", path, err)
+		}
+		*ops = append(*ops, JSONPatchOperation{Op: "add", Path: path, Value: raw})
+	}
+	return nil
+}
+
+// withoutNulls drops null members from objects, which is how a merge patch
+// applies to a member that isn't already an object
+func withoutNulls(value any) any {
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return value
+	}
+	out := make(map[string]any, len(obj))
+	for k, v := range obj {
+		if v != nil {
+			out[k] = withoutNulls(v)
+		}
+	}
+	return out
+}
+
+// escapeJSONPointer escapes a key for use in a JSON Pointer (RFC 6901)
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// getHydraClient fetches a client from Hydra, returning the status and raw body
+func (s *Server) getHydraClient(ctx context.Context, clientID string) (int, []byte, error) {
+	req, err := s.newHydraRequest(ctx, http.MethodGet, "/admin/clients/"+clientID, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	resp, err := s.doHydra(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type fakeSecretHashes map[string]string
+
+func (f fakeSecretHashes) GetHashedSecret(_ context.Context, clientID string, _ uuid.UUID) (string, error) {
+	return f[clientID], nil
+}
+
+// fakePatchHydra serves a single client document, applying "add" and
+// "remove" JSON Patch operations on top-level and /metadata/ paths
+func fakePatchHydra(t *testing.T, doc map[string]any, gotPatch *[]JSONPatchOperation) *httptest.Server {
+	t.Helper()
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(doc)
+		case http.MethodPatch:
+			body, _ := io.ReadAll(r.Body)
+			var ops []JSONPatchOperation
+			if err := json.Unmarshal(body, &ops); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_request","error_description":"not a JSON Patch"}`))
+				return
+			}
+			*gotPatch = ops
+			for _, op := range ops {
+				target, key := doc, strings.TrimPrefix(op.Path, "/")
+				if k, ok := strings.CutPrefix(op.Path, "/metadata/"); ok {
+					target, key = doc["metadata"].(map[string]any), k
+				}
+				if op.Op == "remove" {
+					delete(target, key)
+					continue
+				}
+				var v any
+				json.Unmarshal(op.Value, &v)
+				target[key] = v
+			}
+			json.NewEncoder(w).Encode(doc)
+		}
+	}))
+	t.Cleanup(hydra.Close)
+	return hydra
+}
+
+func TestMergePatchToJSONPatch(t *testing.T) {
+	current := map[string]any{
+		"client_name": "old",
+		"metadata":    map[string]any{"org_id": "acme", "legacy": "x"},
+		"owner":       "team-a",
+	}
+	patch := map[string]any{
+		"client_name": "new",
+		"metadata":    map[string]any{"tier": "pro", "legacy": nil},
+		"owner":       nil,
+		"missing":     nil,
+		"contacts":    map[string]any{"email": "a@example.com", "phone": nil},
+		"a/b":         "escaped",
+	}
+
+	ops, err := mergePatchToJSONPatch(current, patch)
+	if err != nil {
+		t.Fatalf("mergePatchToJSONPatch() error = %v", err)
+	}
+	got, _ := json.Marshal(ops)
+	want := `[{"op":"add","path":"/a~1b","value":"escaped"},` +
+		`{"op":"add","path":"/client_name","value":"new"},` +
+		`{"op":"add","path":"/contacts","value":{"email":"a@example.com"}},` +
+		`{"op":"remove","path":"/metadata/legacy"},` +
+		`{"op":"add","path":"/metadata/tier","value":"pro"},` +
+		`{"op":"remove","path":"/owner"}]`
+	if string(got) != want {
+		t.Errorf("ops =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestIsMergePatch(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+		want              bool
+	}{
+		{"application/merge-patch+json", `{}`, true},
+		{"application/json-patch+json", `{}`, false},
+		{"application/json", ` {"metadata":{}}`, true},
+		{"application/json", `[{"op":"remove","path":"/owner"}]`, false},
+		{"", `[]`, false},
+	}
+	for _, tt := range tests {
+		if got := isMergePatch(tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("isMergePatch(%q, %q) = %t, want %t", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestPatchClientMergePatchEnrichesResponse(t *testing.T) {
+	var gotPatch []JSONPatchOperation
+	hydra := fakePatchHydra(t, map[string]any{
+		"client_id": "svc-a",
+		"metadata":  map[string]any{"org_id": "acme", "tier": "free"},
+	}, &gotPatch)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: uuid.Must(uuid.NewV4())}
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/clients/svc-a", strings.NewReader(`{"metadata":{"tier":"pro"}}`))
+	req.Header.Set("Content-Type", mergePatchContentType)
+	rec := httptest.NewRecorder()
+	s.servePatchClient(rec, req, "svc-a", fakeSecretHashes{"svc-a": "$pbkdf2-sha256$i=1000,l=32$abc$def"})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if len(gotPatch) != 1 || gotPatch[0].Op != "add" || gotPatch[0].Path != "/metadata/tier" {
+		t.Errorf("Hydra received %+v, want one add of /metadata/tier", gotPatch)
+	}
+
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp["client_secret_hash"] != "$pbkdf2-sha256$i=1000,l=32$abc$def" {
+		t.Errorf("client_secret_hash = %v", resp["client_secret_hash"])
+	}
+	metadata, _ := resp["metadata"].(map[string]any)
+	if metadata["tier"] != "pro" || metadata["org_id"] != "acme" {
+		t.Errorf("metadata = %v, want tier merged and org_id kept", metadata)
+	}
+}
+
+func TestPatchClientPassesHydraErrorsThrough(t *testing.T) {
+	const hydraErr = `{"error":"invalid_request","error_description":"bad path"}`
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(hydraErr))
+	}))
+	defer hydra.Close()
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: uuid.Must(uuid.NewV4())}
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/clients/svc-a", strings.NewReader(`[{"op":"remove","path":"/nope"}]`))
+	rec := httptest.NewRecorder()
+	s.servePatchClient(rec, req, "svc-a", fakeSecretHashes{})
+
+	if rec.Code != http.StatusBadRequest || rec.Body.String() != hydraErr {
+		t.Errorf("got %d %q, want Hydra's 400 body", rec.Code, rec.Body.String())
+	}
+}
+
+func TestPatchClientEnforcesMaxLifetime(t *testing.T) {
+	beyond := time.Now().Add(48 * time.Hour).Unix()
+	patch := func(s *Server, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/admin/clients/svc-a", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		s.servePatchClient(rec, req, "svc-a", fakeSecretHashes{})
+		return rec
+	}
+	newServer := func(mode string) (*Server, *[]JSONPatchOperation) {
+		var gotPatch []JSONPatchOperation
+		hydra := fakePatchHydra(t, map[string]any{"client_id": "svc-a", "client_secret_expires_at": 0}, &gotPatch)
+		return &Server{
+			hydraAdminURL:      hydra.URL,
+			httpClient:         hydra.Client(),
+			maxClientLifetime:  24 * time.Hour,
+			clientLifetimeMode: mode,
+			networkID:          uuid.Must(uuid.NewV4()),
+		}, &gotPatch
+	}
+
+	s, gotPatch := newServer(lifetimeModeReject)
+	rec := patch(s, "application/json-patch+json", fmt.Sprintf(`[{"op":"replace","path":"/client_secret_expires_at","value":%d}]`, beyond))
+	if rec.Code != http.StatusBadRequest || *gotPatch != nil {
+		t.Errorf("reject: status = %d, Hydra received %+v; want 400 and no patch", rec.Code, *gotPatch)
+	}
+
+	s, gotPatch = newServer(lifetimeModeClamp)
+	before := time.Now()
+	rec = patch(s, mergePatchContentType, fmt.Sprintf(`{"client_secret_expires_at":%d}`, beyond))
+	if rec.Code != http.StatusOK || len(*gotPatch) != 1 {
+		t.Fatalf("clamp: status = %d, Hydra received %+v; want 200 and one op", rec.Code, *gotPatch)
+	}
+	var expiresAt int64
+	json.Unmarshal((*gotPatch)[0].Value, &expiresAt)
+	if latest := time.Now().Add(24 * time.Hour).Unix(); expiresAt < before.Add(24*time.Hour).Unix() || expiresAt > latest {
+		t.Errorf("clamp: Hydra received client_secret_expires_at %d, want now + MAX_CLIENT_LIFETIME", expiresAt)
+	}
+}
+
+func TestPatchClientRecordsAudit(t *testing.T) {
+	var gotPatch []JSONPatchOperation
+	hydra := fakePatchHydra(t, map[string]any{"client_id": "svc-a"}, &gotPatch)
+	nid := uuid.Must(uuid.NewV4())
+	store := &fakeAuditStore{}
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: nid, auditLogger: store}
+
+	req := httptest.NewRequest(http.MethodPatch, "/admin/clients/svc-a", strings.NewReader(`[{"op":"add","path":"/client_name","value":"svc"}]`))
+	s.servePatchClient(httptest.NewRecorder(), req, "svc-a", fakeSecretHashes{})
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":"not_found"}`))
+	}))
+	defer failing.Close()
+	s.hydraAdminURL, s.httpClient = failing.URL, failing.Client()
+	req = httptest.NewRequest(http.MethodPatch, "/admin/clients/svc-b", strings.NewReader(`[{"op":"remove","path":"/owner"}]`))
+	s.servePatchClient(httptest.NewRecorder(), req, "svc-b", fakeSecretHashes{})
+
+	if len(store.events) != 2 {
+		t.Fatalf("events = %+v, want one per patch", store.events)
+	}
+	ok, failed := store.events[0], store.events[1]
+	if ok.Operation != auditOpPatch || ok.ClientID != "svc-a" || ok.Outcome != auditOutcomeSuccess || ok.NetworkID != nid.String() {
+		t.Errorf("success event = %+v, want client.patch success for svc-a in %s", ok, nid)
+	}
+	if failed.Operation != auditOpPatch || failed.ClientID != "svc-b" || failed.Outcome != auditOutcomeFailure || failed.Detail != "hydra status 404" {
+		t.Errorf("failure event = %+v, want client.patch failure with hydra status 404", failed)
+	}
+}
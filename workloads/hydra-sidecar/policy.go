@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Client lifetime modes for MAX_CLIENT_LIFETIME_MODE
+const (
+	lifetimeModeReject = "reject"
+	lifetimeModeClamp  = "clamp"
+)
+
+// checkClientExpiry reports whether a client whose secret expires at expiresAt
+// (Unix seconds) is expired at now, allowing up to skew of clock difference
+// before rejecting. withinSkew is set when the decision falls inside the skew
+// window, i.e. a clock off by skew could have flipped it.
+func checkClientExpiry(expiresAt int64, now time.Time, skew time.Duration) (expired, withinSkew bool) {
+	// Whole seconds, matching the resolution of client_secret_expires_at
+	now = now.Truncate(time.Second)
+	expiry := time.Unix(expiresAt, 0)
+	expired = now.After(expiry.Add(skew))
+	withinSkew = skew > 0 && !now.Before(expiry.Add(-skew)) && !now.After(expiry.Add(skew))
+	return expired, withinSkew
+}
+
+// applyClientLifetime enforces the maximum client lifetime on a create request
+// body, see clampClientExpiry. Other fields pass through untouched. Returns
+// the (possibly rewritten) body.
+func applyClientLifetime(body []byte, maxLifetime time.Duration, mode string, now time.Time) ([]byte, error) {
+	if maxLifetime <= 0 {
+		return body, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+
+	var expiresAt int64
+	if raw, ok := fields["client_secret_expires_at"]; ok {
+		if err := json.Unmarshal(raw, &expiresAt); err != nil {
+			return nil, fmt.Errorf("client_secret_expires_at must be a Unix timestamp")
+		}
+	}
+	expiresAt, err := clampClientExpiry(expiresAt, maxLifetime, mode, now)
+	if err != nil {
+		return nil, err
+	}
+
+	fields["client_secret_expires_at"] = json.RawMessage(fmt.Sprintf("%d", expiresAt))
+	return json.Marshal(fields)
+}
+
+// clampClientExpiry enforces the maximum client lifetime on a
+// client_secret_expires_at (Unix seconds). 0 (never expires) becomes now+max;
+// a later expiry is rejected or clamped depending on mode.
+func clampClientExpiry(expiresAt int64, maxLifetime time.Duration, mode string, now time.Time) (int64, error) {
+	if maxLifetime <= 0 {
+		return expiresAt, nil
+	}
+	maxExpiresAt := now.Add(maxLifetime).Unix()
+	switch {
+	case expiresAt == 0:
+		return maxExpiresAt, nil
+	case expiresAt > maxExpiresAt && mode == lifetimeModeClamp:
+		return maxExpiresAt, nil
+	case expiresAt > maxExpiresAt:
+		return 0, fmt.Errorf("client_secret_expires_at %d exceeds maximum client lifetime of %s (latest allowed: %d)",
+			expiresAt, maxLifetime, maxExpiresAt)
+	}
+	return expiresAt, nil
+}
+
+// applyPatchLifetime enforces the maximum client lifetime on the JSON Patch
+// operations in body that set client_secret_expires_at (add or replace), see
+// clampClientExpiry. Returns body unchanged when there are none.
+func applyPatchLifetime(body []byte, maxLifetime time.Duration, mode string, now time.Time) ([]byte, error) {
+	if maxLifetime <= 0 {
+		return body, nil
+	}
+
+	var ops []JSONPatchOperation
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, fmt.Errorf("invalid JSON Patch: %w", err)
+	}
+	changed := false
+	for i, op := range ops {
+		if (op.Op != "add" && op.Op != "replace") || op.Path != "/client_secret_expires_at" {
+			continue
+		}
+		var expiresAt int64
+		if err := json.Unmarshal(op.Value, &expiresAt); err != nil {
+			return nil, fmt.Errorf("client_secret_expires_at must be a Unix timestamp")
+		}
+		expiresAt, err := clampClientExpiry(expiresAt, maxLifetime, mode, now)
+		if err != nil {
+			return nil, err
+		}
+		ops[i].Value = json.RawMessage(fmt.Sprintf("%d", expiresAt))
+		changed = true
+	}
+	if !changed {
+		return body, nil
+	}
+	return json.Marshal(ops)
+}
+
+// jitterExpiry moves a client_secret_expires_at (Unix seconds) earlier by a
+// random 0 to window seconds (EXPIRY_JITTER_SECONDS), so clients created or
+// rotated in one batch don't all expire together. Earlier rather than later,
+// so it never passes the requested expiry or MAX_CLIENT_LIFETIME; nor does it
+// move the expiry to now or before. 0 (never expires) is left as is.
+func jitterExpiry(expiresAt int64, window int, now time.Time) int64 {
+	span := min(int64(window), expiresAt-now.Unix()-1)
+	if expiresAt <= 0 || span <= 0 {
+		return expiresAt
+	}
+	return expiresAt - rand.Int64N(span+1)
+}
+
+// applyExpiryJitter applies jitterExpiry to a create request body's
+// client_secret_expires_at, if set. Other fields pass through untouched.
+func applyExpiryJitter(body []byte, window int, now time.Time) ([]byte, error) {
+	expiresAt := secretExpiresAt(body)
+	if window <= 0 || expiresAt <= 0 {
+		return body, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	fields["client_secret_expires_at"] = json.RawMessage(fmt.Sprintf("%d", jitterExpiry(expiresAt, window, now)))
+	return json.Marshal(fields)
+}
+
+// Secret length modes for MIN_SECRET_LENGTH_MODE
+const (
+	secretLengthModeWarn = "warn"
+	secretLengthModeFail = "fail"
+)
+
+// checkSecretLength verifies a Hydra-generated plaintext secret meets the
+// configured minimum length (0 disables the check)
+func checkSecretLength(secret string, minLength int) error {
+	if minLength <= 0 || len(secret) >= minLength {
+		return nil
+	}
+	return fmt.Errorf("secret length %d is below minimum of %d", len(secret), minLength)
+}
+
+// maskSecret partially masks a plaintext secret for display, keeping the first
+// and last few characters. Short secrets are fully masked.
+func maskSecret(secret string) string {
+	const visible = 4
+	if len(secret) <= visible*2 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:visible] + strings.Repeat("*", len(secret)-visible*2) + secret[len(secret)-visible:]
+}
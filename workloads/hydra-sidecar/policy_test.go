@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func decodeExpiresAt(t *testing.T, body []byte) int64 {
+	t.Helper()
+	var fields struct {
+		ExpiresAt int64  `json:"client_secret_expires_at"`
+		Name      string `json:"client_name"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("rewritten body is not valid JSON: %v", err)
+	}
+	return fields.ExpiresAt
+}
+
+func TestApplyClientLifetime(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	maxLifetime := 24 * time.Hour
+	maxExpiresAt := now.Add(maxLifetime).Unix()
+
+	t.Run("injects default expiry", func(t *testing.T) {
+		body, err := applyClientLifetime([]byte(`{"client_name":"svc"}`), maxLifetime, lifetimeModeReject, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := decodeExpiresAt(t, body); got != maxExpiresAt {
+			t.Errorf("client_secret_expires_at = %d, want %d", got, maxExpiresAt)
+		}
+	})
+
+	t.Run("rejects expiry beyond max", func(t *testing.T) {
+		body := []byte(`{"client_secret_expires_at":` + jsonInt(maxExpiresAt+1) + `}`)
+		if _, err := applyClientLifetime(body, maxLifetime, lifetimeModeReject, now); err == nil {
+			t.Fatal("expected rejection, got nil error")
+		}
+	})
+
+	t.Run("clamps expiry beyond max", func(t *testing.T) {
+		body := []byte(`{"client_secret_expires_at":` + jsonInt(maxExpiresAt+3600) + `,"client_name":"svc"}`)
+		out, err := applyClientLifetime(body, maxLifetime, lifetimeModeClamp, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := decodeExpiresAt(t, out); got != maxExpiresAt {
+			t.Errorf("client_secret_expires_at = %d, want clamped %d", got, maxExpiresAt)
+		}
+	})
+
+	t.Run("keeps expiry within max", func(t *testing.T) {
+		within := now.Add(time.Hour).Unix()
+		body := []byte(`{"client_secret_expires_at":` + jsonInt(within) + `}`)
+		out, err := applyClientLifetime(body, maxLifetime, lifetimeModeReject, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := decodeExpiresAt(t, out); got != within {
+			t.Errorf("client_secret_expires_at = %d, want %d", got, within)
+		}
+	})
+
+	t.Run("disabled passes body through", func(t *testing.T) {
+		body := []byte(`{"client_name":"svc"}`)
+		out, err := applyClientLifetime(body, 0, lifetimeModeReject, now)
+		if err != nil || string(out) != string(body) {
+			t.Errorf("applyClientLifetime() = %s, %v; want unchanged body", out, err)
+		}
+	})
+}
+
+func TestClampClientExpiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	maxLifetime := 24 * time.Hour
+	maxExpiresAt := now.Add(maxLifetime).Unix()
+	within := now.Add(time.Hour).Unix()
+
+	tests := []struct {
+		name      string
+		expiresAt int64
+		mode      string
+		want      int64
+		wantErr   bool
+	}{
+		{"never expires", 0, lifetimeModeReject, maxExpiresAt, false},
+		{"within max", within, lifetimeModeReject, within, false},
+		{"beyond max, reject", maxExpiresAt + 1, lifetimeModeReject, 0, true},
+		{"beyond max, clamp", maxExpiresAt + 3600, lifetimeModeClamp, maxExpiresAt, false},
+	}
+	for _, tt := range tests {
+		got, err := clampClientExpiry(tt.expiresAt, maxLifetime, tt.mode, now)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: clampClientExpiry() = %d, %v; want %d (error %t)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+	if got, err := clampClientExpiry(maxExpiresAt+1, 0, lifetimeModeReject, now); err != nil || got != maxExpiresAt+1 {
+		t.Errorf("disabled: clampClientExpiry() = %d, %v; want unchanged", got, err)
+	}
+}
+
+func TestRotateClientRejectsExpiryBeyondMaxLifetime(t *testing.T) {
+	var hydraCalls int
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hydraCalls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"client_id":"svc-a","client_secret":"0123456789abcdef0123456789abcdef"}`))
+	}))
+	t.Cleanup(hydra.Close)
+	s := &Server{
+		hydraAdminURL:      hydra.URL,
+		httpClient:         hydra.Client(),
+		maxClientLifetime:  24 * time.Hour,
+		clientLifetimeMode: lifetimeModeReject,
+		networkID:          uuid.Must(uuid.NewV4()),
+	}
+
+	body := fmt.Sprintf(`{"client_secret_expires_at":%d}`, time.Now().Add(48*time.Hour).Unix())
+	rec := httptest.NewRecorder()
+	s.handleRotateClient(rec, httptest.NewRequest(http.MethodPost, "/admin/clients/rotate/svc-a", strings.NewReader(body)))
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "maximum client lifetime") {
+		t.Errorf("status = %d, body = %s; want 400 for an expiry beyond MAX_CLIENT_LIFETIME", rec.Code, rec.Body.String())
+	}
+	if hydraCalls != 0 {
+		t.Errorf("Hydra called %d times, want the secret left unrotated", hydraCalls)
+	}
+}
+
+func jsonInt(v int64) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestJitterExpiry(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	expiresAt := now.Add(24 * time.Hour).Unix()
+
+	seen := map[int64]bool{}
+	for range 200 {
+		got := jitterExpiry(expiresAt, 60, now)
+		if got > expiresAt || got < expiresAt-60 {
+			t.Fatalf("jitterExpiry = %d, want within [%d, %d]", got, expiresAt-60, expiresAt)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("jitterExpiry returned one value over 200 calls, want a spread")
+	}
+
+	// Never reaches now, and leaves "never expires" and a disabled window alone
+	soon := now.Unix() + 5
+	for range 50 {
+		if got := jitterExpiry(soon, 3600, now); got <= now.Unix() || got > soon {
+			t.Fatalf("jitterExpiry(now+5) = %d, want within (%d, %d]", got, now.Unix(), soon)
+		}
+	}
+	if got := jitterExpiry(0, 60, now); got != 0 {
+		t.Errorf("jitterExpiry(0) = %d, want 0", got)
+	}
+	if got := jitterExpiry(expiresAt, 0, now); got != expiresAt {
+		t.Errorf("jitterExpiry with no window = %d, want %d", got, expiresAt)
+	}
+}
+
+func TestApplyExpiryJitter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	expiresAt := now.Add(time.Hour).Unix()
+
+	body, err := applyExpiryJitter([]byte(`{"client_secret_expires_at":`+jsonInt(expiresAt)+`,"client_name":"svc"}`), 600, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := decodeExpiresAt(t, body); got > expiresAt || got < expiresAt-600 {
+		t.Errorf("client_secret_expires_at = %d, want within [%d, %d]", got, expiresAt-600, expiresAt)
+	}
+	if !strings.Contains(string(body), `"client_name":"svc"`) {
+		t.Errorf("other fields lost: %s", body)
+	}
+
+	// No expiry: the body passes through untouched
+	in := []byte(`{"client_name":"svc"}`)
+	if body, err := applyExpiryJitter(in, 600, now); err != nil || string(body) != string(in) {
+		t.Errorf("applyExpiryJitter without expiry = (%s, %v), want body unchanged", body, err)
+	}
+}
+
+func TestCheckSecretLength(t *testing.T) {
+	if err := checkSecretLength("short", 0); err != nil {
+		t.Errorf("disabled check returned %v", err)
+	}
+	if err := checkSecretLength("0123456789abcdef", 16); err != nil {
+		t.Errorf("secret at minimum returned %v", err)
+	}
+	if err := checkSecretLength("short", 16); err == nil {
+		t.Error("short secret returned nil error")
+	}
+}
+
+func TestRotateClientRejectsShortSecret(t *testing.T) {
+	hydra := newFakeHydra(t, `{"client_id":"svc-a","client_secret":"abc"}`)
+	s := &Server{
+		hydraAdminURL:       hydra.URL,
+		httpClient:          hydra.Client(),
+		minSecretLength:     32,
+		minSecretLengthMode: secretLengthModeFail,
+		networkID:           uuid.Must(uuid.NewV4()),
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleRotateClient(rec, httptest.NewRequest(http.MethodPost, "/admin/clients/rotate/svc-a", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadGateway)
+	}
+	if strings.Contains(rec.Body.String(), "abc") {
+		t.Error("response leaked the short secret")
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	tests := map[string]string{
+		"abcdefghijklmnop": "abcd********mnop",
+		"123456789":        "1234*6789",
+		"12345678":         "********",
+		"abc":              "***",
+		"":                 "",
+	}
+	for secret, want := range tests {
+		if got := maskSecret(secret); got != want {
+			t.Errorf("maskSecret(%q) = %q, want %q", secret, got, want)
+		}
+	}
+}
+
+func TestCheckClientExpiry(t *testing.T) {
+	const expiresAt = 1700000000
+	at := func(offset time.Duration) time.Time { return time.Unix(expiresAt, 0).Add(offset) }
+
+	tests := []struct {
+		name           string
+		now            time.Time
+		skew           time.Duration
+		wantExpired    bool
+		wantWithinSkew bool
+	}{
+		{"no skew, at expiry", at(0), 0, false, false},
+		{"no skew, within the expiry second", at(900 * time.Millisecond), 0, false, false},
+		{"no skew, one second past", at(time.Second), 0, true, false},
+		{"skew, well before expiry", at(-time.Minute), 5 * time.Second, false, false},
+		{"skew, at lower boundary", at(-5 * time.Second), 5 * time.Second, false, true},
+		{"skew, just past expiry", at(time.Second), 5 * time.Second, false, true},
+		{"skew, at upper boundary", at(5 * time.Second), 5 * time.Second, false, true},
+		{"skew, one second past boundary", at(6 * time.Second), 5 * time.Second, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expired, withinSkew := checkClientExpiry(expiresAt, tt.now, tt.skew)
+			if expired != tt.wantExpired || withinSkew != tt.wantWithinSkew {
+				t.Errorf("got expired=%t withinSkew=%t, want %t %t", expired, withinSkew, tt.wantExpired, tt.wantWithinSkew)
+			}
+		})
+	}
+}
+
+func TestTokenHookClockSkewTolerance(t *testing.T) {
+	// Expired 2s ago: rejected without tolerance, allowed (with a warning) within it
+	expiresAt := time.Now().Add(-2 * time.Second).Unix()
+	hydra := newFakeHydra(t, fmt.Sprintf(`{"client_secret_expires_at":%d}`, expiresAt))
+
+	strict := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+	if rec := callTokenHook(t, strict, "svc-a"); rec.Code != http.StatusForbidden {
+		t.Errorf("without tolerance: status = %d, want 403", rec.Code)
+	}
+
+	tolerant := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), clockSkew: time.Minute}
+	logs := captureLog(t)
+	rec := callTokenHook(t, tolerant, "svc-a")
+	if rec.Code != http.StatusOK {
+		t.Errorf("with tolerance: status = %d, want 200", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "clock skew window") {
+		t.Errorf("expected skew warning, got logs: %s", logs)
+	}
+}
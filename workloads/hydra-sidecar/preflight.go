@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Preflight check names
+const (
+	preflightDatabase  = "database"
+	preflightNetworkID = "network_id"
+	preflightHasher    = "hasher"
+	preflightPayload   = "payload"
+)
+
+// preflightStore is the read-only subset of Store used by preflight checks
+type preflightStore interface {
+	networkLookup
+	Ping(ctx context.Context) error
+}
+
+// swagger:route POST /sync/preflight clients syncPreflight
+//
+// Preflight check for bulk sync.
+//
+// Validates database connectivity, network ID availability, hasher configuration, and the
+// sync payload (same body and ?duplicates= as /sync/clients) without mutating anything.
+// The payload check reports every failure /sync/clients would reject the body for.
+// Returns 200 unless ?duplicates= is invalid (400); check "passed" for the overall outcome.
+//
+//	Consumes:
+//	- application/json
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: preflightReportResponse
+//	  400: errorResponse
+func (s *Server) handleSyncPreflight(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	lastWins, err := parseSyncDuplicates(r.URL.Query().Get("duplicates"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	// A malformed payload is a failed check, not a request error
+	var req SyncClientsRequest
+	decodeErr := json.NewDecoder(r.Body).Decode(&req)
+	var tooLarge *http.MaxBytesError
+	if errors.As(decodeErr, &tooLarge) {
+		writeBodyError(w, decodeErr, "")
+		return
+	}
+	req.NetworkID = requestedNetwork(r, req.NetworkID)
+	if err := checkNetworkScope(r.Context(), req.NetworkID); err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	report := s.runPreflight(ctx, s.store, &req, decodeErr, lastWins)
+	log.Printf("Sync preflight completed: passed=%t", report.Passed)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding preflight report: %v", err)
+	}
+}
+
+// runPreflight executes every check and reports each one independently.
+// lastWins is the ?duplicates=last_wins setting the payload is checked under.
+func (s *Server) runPreflight(ctx context.Context, db preflightStore, req *SyncClientsRequest, decodeErr error, lastWins bool) *PreflightReport {
+	report := &PreflightReport{Passed: true}
+	add := func(name string, err error) {
+		check := PreflightCheck{Name: name, Passed: err == nil}
+		if err != nil {
+			msg := err.Error()
+			check.Error = &msg
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	// Database connectivity
+	if err := db.Ping(ctx); err != nil {
+		add(preflightDatabase, fmt.Errorf("database ping failed: %w", err))
+		add(preflightNetworkID, fmt.Errorf("skipped: database unavailable"))
+	} else {
+		add(preflightDatabase, nil)
+
+		// Network ID (requested network, or the default one)
+		_, err := s.resolveNetwork(ctx, db, req.NetworkID)
+		add(preflightNetworkID, err)
+	}
+
+	// Hasher configuration
+	switch s.hasherAlgorithm {
+	case "pbkdf2", "bcrypt":
+		add(preflightHasher, nil)
+	default:
+		add(preflightHasher, fmt.Errorf("unknown hasher algorithm: %s", s.hasherAlgorithm))
+	}
+
+	// Payload validity
+	add(preflightPayload, s.validatePreflightPayload(req, decodeErr, lastWins))
+
+	return report
+}
+
+// validatePreflightPayload applies the same validation as /sync/clients,
+// joining every failure validateSyncClients finds into one error
+func (s *Server) validatePreflightPayload(req *SyncClientsRequest, decodeErr error, lastWins bool) error {
+	if decodeErr != nil {
+		return fmt.Errorf("invalid JSON: %w", decodeErr)
+	}
+	if len(req.Clients) == 0 {
+		return fmt.Errorf("clients array is empty")
+	}
+	_, _, failures := s.validateSyncClients(req.Clients, lastWins)
+	if len(failures) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(failures))
+	for i, f := range failures {
+		msgs[i] = f.Error
+		if f.ClientID != "" {
+			msgs[i] = fmt.Sprintf("client %s: %s", f.ClientID, f.Error)
+		}
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
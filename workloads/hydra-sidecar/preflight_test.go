@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/ory/hydra/v2/client"
+)
+
+type fakePreflightStore struct {
+	pingErr error
+	nid     uuid.UUID
+}
+
+func (f *fakePreflightStore) Ping(context.Context) error { return f.pingErr }
+
+func (f *fakePreflightStore) GetDefaultNetworkID(context.Context) (uuid.UUID, error) {
+	if f.nid == uuid.Nil {
+		return uuid.Nil, errors.New("no networks")
+	}
+	return f.nid, nil
+}
+
+func (f *fakePreflightStore) GetNetworkIDByName(_ context.Context, name string) (uuid.UUID, error) {
+	return uuid.Nil, fmt.Errorf("%w %q", errUnknownNetwork, name)
+}
+
+func preflightCheck(t *testing.T, report *PreflightReport, name string) PreflightCheck {
+	t.Helper()
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("check %q missing from report", name)
+	return PreflightCheck{}
+}
+
+func TestPreflightFailingHash(t *testing.T) {
+	s := &Server{hasherAlgorithm: "pbkdf2"}
+	db := &fakePreflightStore{nid: uuid.Must(uuid.NewV4())}
+	req := &SyncClientsRequest{Clients: []ClientData{{ClientSecretHash: "$2a$10$notpbkdf2"}}}
+
+	report := s.runPreflight(context.Background(), db, req, nil, false)
+
+	if report.Passed {
+		t.Error("report passed with an invalid hash")
+	}
+	if c := preflightCheck(t, report, preflightPayload); c.Passed {
+		t.Error("payload check passed with a bcrypt hash under pbkdf2")
+	}
+	if c := preflightCheck(t, report, preflightDatabase); !c.Passed {
+		t.Errorf("database check failed: %v", *c.Error)
+	}
+}
+
+func TestPreflightDatabaseDown(t *testing.T) {
+	s := &Server{hasherAlgorithm: "pbkdf2"}
+	db := &fakePreflightStore{pingErr: errors.New("connection refused")}
+	req := &SyncClientsRequest{Clients: []ClientData{{Client: client.Client{ID: "svc-a"}, ClientSecretHash: "$pbkdf2-sha256$i=10000,l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGQ"}}}
+
+	report := s.runPreflight(context.Background(), db, req, nil, false)
+
+	if report.Passed {
+		t.Error("report passed with database down")
+	}
+	if c := preflightCheck(t, report, preflightDatabase); c.Passed {
+		t.Error("database check passed with ping error")
+	}
+	if c := preflightCheck(t, report, preflightNetworkID); c.Passed {
+		t.Error("network_id check passed with database down")
+	}
+	if c := preflightCheck(t, report, preflightPayload); !c.Passed {
+		t.Errorf("payload check failed: %v", *c.Error)
+	}
+}
+
+func TestPreflightMatchesSyncValidation(t *testing.T) {
+	const hash = "$pbkdf2-sha256$i=10000,l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGQ"
+	s := &Server{hasherAlgorithm: "pbkdf2"}
+	db := &fakePreflightStore{nid: uuid.Must(uuid.NewV4())}
+	clients := []ClientData{
+		{Client: client.Client{ID: "svc-a", Name: "first"}, ClientSecretHash: hash},
+		{Client: client.Client{ID: "svc-a", Name: "second"}, ClientSecretHash: hash},
+		{ClientSecretHash: hash},
+	}
+
+	report := s.runPreflight(context.Background(), db, &SyncClientsRequest{Clients: clients}, nil, false)
+	c := preflightCheck(t, report, preflightPayload)
+	if c.Passed {
+		t.Fatal("payload check passed with a conflicting repeat and a missing client_id")
+	}
+	for _, want := range []string{"client svc-a: conflicting entries", "clients[2]: client_id is required"} {
+		if !strings.Contains(*c.Error, want) {
+			t.Errorf("payload error = %q, want it to contain %q", *c.Error, want)
+		}
+	}
+
+	// last_wins accepts the repeat, as /sync/clients does, but not the missing ID
+	report = s.runPreflight(context.Background(), db, &SyncClientsRequest{Clients: clients}, nil, true)
+	c = preflightCheck(t, report, preflightPayload)
+	if c.Passed || strings.Contains(*c.Error, "conflicting") || !strings.Contains(*c.Error, "client_id is required") {
+		t.Errorf("last_wins payload check = %+v, want only the missing client_id reported", c)
+	}
+
+	report = s.runPreflight(context.Background(), db, &SyncClientsRequest{Clients: clients[:2]}, nil, true)
+	if c := preflightCheck(t, report, preflightPayload); !c.Passed {
+		t.Errorf("last_wins payload check failed: %v", *c.Error)
+	}
+}
+
+func TestPreflightRejectsUnknownDuplicatesMode(t *testing.T) {
+	s := &Server{hasherAlgorithm: "pbkdf2"}
+	rec := httptest.NewRecorder()
+	s.handleSyncPreflight(rec, httptest.NewRequest(http.MethodPost, "/sync/preflight?duplicates=first_wins", strings.NewReader(`{"clients":[]}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
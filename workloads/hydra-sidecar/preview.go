@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// String form of each clientInfoSource in a token hook preview
+var clientInfoSourceNames = map[clientInfoSource]string{
+	infoFromHydra:         "hydra",
+	infoFromStaleCache:    "stale_cache",
+	infoFromLastKnownGood: "last_known_good",
+}
+
+// swagger:route GET /token-hook/preview/{client_id} hooks previewTokenHook
+//
+// Preview a client's token claims.
+//
+// Runs the token hook's client lookup and claim building for a client and returns the claims
+// it would add, without a Hydra token request and without recording usage. ?scope= sets the
+// granted scopes, for scoped claims and templates. An expired or deleted client still gets
+// its claims, with expired=true. Diagnostic only; requires an API key.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: tokenHookPreviewResponse
+//	  400: errorResponse
+//	  401: errorResponse
+func (s *Server) handleTokenHookPreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	clientID := strings.TrimPrefix(r.URL.Path, "/token-hook/preview/")
+	if clientID == "" || strings.Contains(clientID, "/") {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "missing client_id")
+		return
+	}
+	if _, err := s.hydraNetworkFor(r, ""); err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+	var scopes []string
+	for _, scope := range r.URL.Query()["scope"] {
+		scopes = append(scopes, strings.Fields(scope)...)
+	}
+
+	preview := TokenHookPreview{ClientID: clientID}
+	remoteClaims := s.claimEnricher.start(r.Context(), clientID)
+	clientInfo, source, err := s.clientInfo(r.Context(), clientID)
+	if err != nil {
+		// The hook falls back to no metadata claims (or fails closed)
+		preview.Source = "none"
+		preview.LookupError = err.Error()
+		clientInfo = nil
+	} else {
+		preview.Source = clientInfoSourceNames[source]
+	}
+
+	if hint := s.tokenDenial(clientID, clientInfo); hint != "" {
+		preview.Expired = true
+		preview.DenialReason = hint
+	}
+	claims, metadataKeys := s.buildTokenClaims(clientID, clientInfo, source, scopes, remoteClaims())
+	if _, ok := s.limitClaimsSize(clientID, claims, metadataKeys); !ok {
+		preview.ClaimsTooLarge = true
+	}
+	preview.Claims = claims
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(preview); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
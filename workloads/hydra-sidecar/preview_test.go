@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+func previewTokenHook(t *testing.T, s *Server, target string) TokenHookPreview {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.handleTokenHookPreview(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var preview TokenHookPreview
+	if err := json.NewDecoder(rec.Body).Decode(&preview); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return preview
+}
+
+func TestTokenHookPreview(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","plan":"pro","claims_scope_map":{"plan":"billing"}},"client_secret_expires_at":1}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), envClaim: "staging", networkID: uuid.Must(uuid.NewV4())}
+
+	preview := previewTokenHook(t, s, "/token-hook/preview/svc-a?scope=read+billing")
+	if preview.ClientID != "svc-a" || preview.Source != "hydra" {
+		t.Errorf("preview = %+v, want svc-a from hydra", preview)
+	}
+	if preview.Claims["org_id"] != "acme" || preview.Claims["plan"] != "pro" || preview.Claims[envClaimName] != "staging" {
+		t.Errorf("claims = %v, want metadata, the billing scoped claim, and env", preview.Claims)
+	}
+	// Expired clients are flagged, with their claims still shown
+	if !preview.Expired || preview.DenialReason == "" {
+		t.Errorf("expired = %v, reason = %q, want the expiry reported", preview.Expired, preview.DenialReason)
+	}
+
+	// Without the scope the scoped claim is held back, as in the hook
+	if preview := previewTokenHook(t, s, "/token-hook/preview/svc-a"); preview.Claims["plan"] != nil {
+		t.Errorf("claims without scope = %v, want no plan", preview.Claims)
+	}
+}
+
+func TestTokenHookPreviewLookupFailure(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer hydra.Close()
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), networkID: uuid.Must(uuid.NewV4())}
+
+	preview := previewTokenHook(t, s, "/token-hook/preview/missing")
+	if preview.Source != "none" || preview.LookupError == "" || preview.Expired || len(preview.Claims) != 0 {
+		t.Errorf("preview = %+v, want the fallback with the lookup error", preview)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleTokenHookPreview(rec, httptest.NewRequest(http.MethodGet, "/token-hook/preview/", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing client_id: status = %d, want 400", rec.Code)
+	}
+	if !requiresAdminAuth("/token-hook/preview/svc-a") || requiresAdminAuth("/token-hook") {
+		t.Error("the preview should need an API key and the hook should not")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// rateLimitClaimName is the access token claim carrying the client's tier limit
+const rateLimitClaimName = "rate_limit"
+
+// rateLimitTierKey is the client metadata key naming its tier
+const rateLimitTierKey = "tier"
+
+// RateLimit is a request quota hint in APISIX limit-count terms
+type RateLimit struct {
+	Count      int `json:"count"`
+	TimeWindow int `json:"time_window"`
+}
+
+// tierRateLimits maps metadata tiers to the rate_limit claim given to their
+// clients (TIER_RATE_LIMITS_JSON)
+type tierRateLimits map[string]RateLimit
+
+// parseTierRateLimits parses a JSON object of tier -> {"count", "time_window"}
+func parseTierRateLimits(raw string) (tierRateLimits, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var limits tierRateLimits
+	if err := json.Unmarshal([]byte(raw), &limits); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	for tier, limit := range limits {
+		if limit.Count <= 0 || limit.TimeWindow <= 0 {
+			return nil, fmt.Errorf("tier %q: count and time_window must be positive", tier)
+		}
+	}
+	return limits, nil
+}
+
+// forMetadata returns the rate limit for a client's metadata tier. Clients
+// without a tier, or with one that isn't configured, get none.
+func (l tierRateLimits) forMetadata(metadata map[string]any) (RateLimit, bool) {
+	tier, ok := metadata[rateLimitTierKey].(string)
+	if !ok {
+		return RateLimit{}, false
+	}
+	limit, ok := l[tier]
+	return limit, ok
+}
+
+// rateLimitRPMClaimName is the access token claim carrying the client's tier
+// limit in requests per minute (TIER_RPM_LIMITS_JSON)
+const rateLimitRPMClaimName = "rate_limit_rpm"
+
+// Known client tiers; anything else is treated as defaultTier
+const (
+	tierFree       = "free"
+	tierPro        = "pro"
+	tierEnterprise = "enterprise"
+	defaultTier    = tierFree
+)
+
+var knownTiers = []string{tierFree, tierPro, tierEnterprise}
+
+// tierRPMLimits maps each known tier to its requests-per-minute limit
+type tierRPMLimits map[string]int
+
+// parseTierRPMLimits parses a JSON object of tier -> requests per minute.
+// Every known tier needs a positive limit, since unknown tiers fall back to
+// free and every token then carries a number.
+func parseTierRPMLimits(raw string) (tierRPMLimits, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var limits tierRPMLimits
+	if err := json.Unmarshal([]byte(raw), &limits); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	for tier := range limits {
+		if !slices.Contains(knownTiers, tier) {
+			return nil, fmt.Errorf("unknown tier %q, want one of %v", tier, knownTiers)
+		}
+	}
+	for _, tier := range knownTiers {
+		if limits[tier] <= 0 {
+			return nil, fmt.Errorf("tier %q needs a positive limit", tier)
+		}
+	}
+	return limits, nil
+}
+
+// normalizeTier returns the client's metadata tier trimmed and lowercased, or
+// defaultTier when it is missing or not a known tier. valid is false only for
+// a tier that is set but unknown, which the caller should warn about.
+func normalizeTier(metadata map[string]any) (tier string, valid bool) {
+	raw, ok := metadata[rateLimitTierKey]
+	if !ok {
+		return defaultTier, true
+	}
+	s, ok := raw.(string)
+	if !ok {
+		return defaultTier, false
+	}
+	tier = strings.ToLower(strings.TrimSpace(s))
+	if !slices.Contains(knownTiers, tier) {
+		return defaultTier, false
+	}
+	return tier, true
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testTierRateLimits = `{"free":{"count":100,"time_window":60},"pro":{"count":1000,"time_window":60},"enterprise":{"count":10000,"time_window":1}}`
+
+func TestTierRateLimitsForMetadata(t *testing.T) {
+	limits, err := parseTierRateLimits(testTierRateLimits)
+	if err != nil {
+		t.Fatalf("parseTierRateLimits() error = %v", err)
+	}
+
+	tests := []struct {
+		tier any
+		want RateLimit
+		ok   bool
+	}{
+		{"free", RateLimit{Count: 100, TimeWindow: 60}, true},
+		{"pro", RateLimit{Count: 1000, TimeWindow: 60}, true},
+		{"enterprise", RateLimit{Count: 10000, TimeWindow: 1}, true},
+		{"platinum", RateLimit{}, false},
+		{42, RateLimit{}, false},
+		{nil, RateLimit{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := limits.forMetadata(map[string]any{"tier": tt.tier})
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("forMetadata(tier=%v) = %+v, %t; want %+v, %t", tt.tier, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseTierRateLimitsRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{`[]`, `{"free":{"count":0,"time_window":60}}`, `{"free":{"count":10}}`} {
+		if _, err := parseTierRateLimits(raw); err == nil {
+			t.Errorf("parseTierRateLimits(%s) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestTokenHookInjectsTierRateLimit(t *testing.T) {
+	limits, _ := parseTierRateLimits(testTierRateLimits)
+
+	for tier, want := range limits {
+		hydra := newFakeHydra(t, `{"metadata":{"tier":"`+tier+`","rate_limit":"spoofed"}}`)
+		s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), tierRateLimits: limits}
+
+		rec := callTokenHook(t, s, "svc-a")
+		var resp struct {
+			Session struct {
+				AccessToken map[string]json.RawMessage `json:"access_token"`
+			} `json:"session"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		var got RateLimit
+		if err := json.Unmarshal(resp.Session.AccessToken[rateLimitClaimName], &got); err != nil || got != want {
+			t.Errorf("tier %s: rate_limit = %s, want %+v", tier, resp.Session.AccessToken[rateLimitClaimName], want)
+		}
+	}
+
+	// An unmapped tier gets no claim (and metadata can't supply one)
+	hydra := newFakeHydra(t, `{"metadata":{"tier":"platinum"}}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), tierRateLimits: limits}
+	var resp TokenHookResponse
+	if err := json.NewDecoder(callTokenHook(t, s, "svc-a").Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if _, ok := resp.Session.AccessToken[rateLimitClaimName]; ok {
+		t.Errorf("unmapped tier got rate_limit claim: %v", resp.Session.AccessToken)
+	}
+}
+
+const testTierRPMLimits = `{"free":60,"pro":600,"enterprise":6000}`
+
+func TestParseTierRPMLimitsRejectsInvalid(t *testing.T) {
+	for _, raw := range []string{
+		`[]`,
+		`{"free":60,"pro":600}`,
+		`{"free":60,"pro":600,"enterprise":0}`,
+		`{"free":60,"pro":600,"enterprise":6000,"platinum":1}`,
+	} {
+		if _, err := parseTierRPMLimits(raw); err == nil {
+			t.Errorf("parseTierRPMLimits(%s) succeeded, want error", raw)
+		}
+	}
+}
+
+func TestNormalizeTier(t *testing.T) {
+	for _, tc := range []struct {
+		metadata  map[string]any
+		wantTier  string
+		wantValid bool
+	}{
+		{map[string]any{"tier": "pro"}, tierPro, true},
+		{map[string]any{"tier": " Enterprise "}, tierEnterprise, true},
+		{map[string]any{"tier": "platinum"}, tierFree, false},
+		{map[string]any{"tier": 3.0}, tierFree, false},
+		{map[string]any{}, tierFree, true},
+		{nil, tierFree, true},
+	} {
+		tier, valid := normalizeTier(tc.metadata)
+		if tier != tc.wantTier || valid != tc.wantValid {
+			t.Errorf("normalizeTier(%v) = %q, %v, want %q, %v", tc.metadata, tier, valid, tc.wantTier, tc.wantValid)
+		}
+	}
+}
+
+func TestTokenHookInjectsTierRPM(t *testing.T) {
+	limits, err := parseTierRPMLimits(testTierRPMLimits)
+	if err != nil {
+		t.Fatalf("parseTierRPMLimits() error = %v", err)
+	}
+
+	for _, tc := range []struct {
+		metadata string
+		wantTier string
+		wantRPM  float64
+		wantWarn bool
+	}{
+		{`{"tier":"PRO","rate_limit_rpm":1000000}`, tierPro, 600, false},
+		{`{"tier":"platinum"}`, tierFree, 60, true},
+		{`{}`, tierFree, 60, false},
+	} {
+		logs := captureLog(t)
+		hydra := newFakeHydra(t, `{"metadata":`+tc.metadata+`}`)
+		s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), tierRPMLimits: limits}
+
+		var resp TokenHookResponse
+		if err := json.NewDecoder(callTokenHook(t, s, "svc-a").Body).Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		claims := resp.Session.AccessToken
+		if claims[rateLimitTierKey] != tc.wantTier || claims[rateLimitRPMClaimName] != tc.wantRPM {
+			t.Errorf("metadata %s: tier = %v, rate_limit_rpm = %v, want %s and %v",
+				tc.metadata, claims[rateLimitTierKey], claims[rateLimitRPMClaimName], tc.wantTier, tc.wantRPM)
+		}
+		if warned := strings.Contains(logs.String(), "unknown tier"); warned != tc.wantWarn {
+			t.Errorf("metadata %s: warned = %v, want %v", tc.metadata, warned, tc.wantWarn)
+		}
+	}
+}
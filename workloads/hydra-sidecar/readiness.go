@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// States reported by verbose readiness diagnostics
+const (
+	networkResolved      = "resolved"
+	networkUnresolved    = "unresolved"
+	retryBudgetOK        = "ok"
+	retryBudgetExhausted = "exhausted"
+)
+
+// Dependencies named in a failed readiness response
+const (
+	readinessDependencyDatabase = "database"
+	readinessDependencyHydra    = "hydra"
+)
+
+// hydraReadinessTimeout bounds the Hydra check so a hung Hydra fails the
+// probe well within the overall readiness timeout
+const hydraReadinessTimeout = 2 * time.Second
+
+// pinger is the subset of Store used by the readiness probe
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// recordSuccessfulSync notes when a sync last completed with status success
+func (s *Server) recordSuccessfulSync(result *SyncResult, now time.Time) {
+	if result.Status == syncStatusSuccess {
+		s.lastSuccessfulSync.Store(now.UnixNano())
+	}
+}
+
+// serveReady answers the readiness probe against db (and Hydra, with
+// READINESS_CHECK_HYDRA): plain "OK" or a 503 naming the failed dependencies
+// by default, JSON diagnostics with ?verbose=true (same status codes)
+func (s *Server) serveReady(w http.ResponseWriter, r *http.Request, db pinger) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	diag := s.readinessDiagnostics(ctx, db)
+	if !diag.Ready {
+		log.Printf("Readiness check failed: %s not ready", strings.Join(diag.Failed, ", "))
+	}
+
+	if r.URL.Query().Get("verbose") != "true" {
+		if !diag.Ready {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(ReadinessFailure{Ready: false, Failed: diag.Failed})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !diag.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(diag); err != nil {
+		log.Printf("Error encoding readiness diagnostics: %v", err)
+	}
+}
+
+// readinessDiagnostics pings the database (and Hydra, if enabled) and
+// snapshots sync, network, retry budget, and circuit breaker state
+func (s *Server) readinessDiagnostics(ctx context.Context, db pinger) ReadinessDiagnostics {
+	var diag ReadinessDiagnostics
+
+	start := time.Now()
+	err := db.Ping(ctx)
+	diag.Database.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+	diag.Database.OK = err == nil
+	if err != nil {
+		msg := err.Error()
+		diag.Database.Error = &msg
+		diag.Failed = append(diag.Failed, readinessDependencyDatabase)
+	}
+
+	if s.config.ReadinessCheckHydra {
+		diag.Hydra = &HydraDiagnostics{}
+		start := time.Now()
+		err := s.pingHydra(ctx)
+		diag.Hydra.LatencyMS = float64(time.Since(start).Microseconds()) / 1000
+		diag.Hydra.OK = err == nil
+		if err != nil {
+			msg := err.Error()
+			diag.Hydra.Error = &msg
+			diag.Failed = append(diag.Failed, readinessDependencyHydra)
+		}
+	}
+	diag.Ready = len(diag.Failed) == 0
+
+	if ns := s.lastSuccessfulSync.Load(); ns != 0 {
+		t := time.Unix(0, ns).UTC()
+		diag.LastSuccessfulSync = &t
+	}
+
+	diag.Network.Status = networkUnresolved
+	if nid := s.defaultNetworkID(); nid != uuid.Nil {
+		diag.Network.Status = networkResolved
+		diag.Network.ID = nid.String()
+	}
+
+	diag.RetryBudget.Remaining = s.retryBudget.Remaining()
+	diag.RetryBudget.Capacity = s.config.RetryBudgetCapacity
+	diag.RetryBudget.State = retryBudgetOK
+	if diag.RetryBudget.Remaining == 0 {
+		diag.RetryBudget.State = retryBudgetExhausted
+	}
+
+	diag.HydraCircuit.Enabled = s.hydraBreaker != nil
+	diag.HydraCircuit.State = s.hydraBreaker.State()
+	return diag
+}
+
+// pingHydra checks that Hydra Admin answers /health/alive. It calls Hydra
+// once, without retries, so a probe never spends the shared retry budget.
+func (s *Server) pingHydra(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, hydraReadinessTimeout)
+	defer cancel()
+
+	req, err := s.newHydraRequest(ctx, http.MethodGet, "/health/alive", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
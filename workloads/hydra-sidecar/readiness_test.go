@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type fakePinger struct{ err error }
+
+func (f fakePinger) Ping(context.Context) error { return f.err }
+
+func TestReadyVerboseIncludesDiagnostics(t *testing.T) {
+	nid := uuid.Must(uuid.NewV4())
+	s := &Server{networkID: nid, retryBudget: newRetryBudget(5, 1), config: Config{RetryBudgetCapacity: 5}}
+	synced := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.recordSuccessfulSync(&SyncResult{Status: syncStatusSuccess}, synced)
+
+	rec := httptest.NewRecorder()
+	s.serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready?verbose=true", nil), fakePinger{})
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var db map[string]any
+	json.Unmarshal(raw["database"], &db)
+	if _, ok := db["latency_ms"]; !ok {
+		t.Errorf("database diagnostics missing latency_ms: %s", raw["database"])
+	}
+
+	var diag ReadinessDiagnostics
+	json.Unmarshal(rec.Body.Bytes(), &diag)
+	if !diag.Ready || !diag.Database.OK {
+		t.Errorf("diag = %+v, want ready", diag)
+	}
+	if diag.LastSuccessfulSync == nil || !diag.LastSuccessfulSync.Equal(synced) {
+		t.Errorf("last_successful_sync = %v, want %v", diag.LastSuccessfulSync, synced)
+	}
+	if diag.Network.Status != networkResolved || diag.Network.ID != nid.String() {
+		t.Errorf("network = %+v", diag.Network)
+	}
+	if diag.RetryBudget.Remaining != 5 || diag.RetryBudget.State != retryBudgetOK {
+		t.Errorf("retry_budget = %+v", diag.RetryBudget)
+	}
+}
+
+func TestReadyVerboseReportsFailure(t *testing.T) {
+	s := &Server{}
+	s.recordSuccessfulSync(&SyncResult{Status: syncStatusPartial}, time.Now())
+
+	rec := httptest.NewRecorder()
+	s.serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready?verbose=true", nil), fakePinger{err: errors.New("connection refused")})
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	var diag ReadinessDiagnostics
+	if err := json.Unmarshal(rec.Body.Bytes(), &diag); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if diag.Ready || diag.Database.Error == nil || !strings.Contains(*diag.Database.Error, "connection refused") {
+		t.Errorf("database = %+v, want failure with error", diag.Database)
+	}
+	if diag.LastSuccessfulSync != nil {
+		t.Errorf("last_successful_sync = %v, want none after a partial sync", diag.LastSuccessfulSync)
+	}
+	if diag.Network.Status != networkUnresolved || diag.RetryBudget.State != retryBudgetExhausted {
+		t.Errorf("network = %+v, retry_budget = %+v", diag.Network, diag.RetryBudget)
+	}
+}
+
+func TestReadyPlainByDefault(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&Server{}).serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready", nil), fakePinger{})
+	if rec.Code != http.StatusOK || rec.Body.String() != "OK" {
+		t.Errorf("got %d %q, want 200 OK", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReadyChecksHydra(t *testing.T) {
+	status := http.StatusOK
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health/alive" {
+			t.Errorf("path = %s, want /health/alive", r.URL.Path)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(hydra.Close)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), config: Config{ReadinessCheckHydra: true}}
+
+	rec := httptest.NewRecorder()
+	s.serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready?verbose=true", nil), fakePinger{})
+	var diag ReadinessDiagnostics
+	json.Unmarshal(rec.Body.Bytes(), &diag)
+	if rec.Code != http.StatusOK || diag.Hydra == nil || !diag.Hydra.OK {
+		t.Fatalf("healthy Hydra: %d %s", rec.Code, rec.Body)
+	}
+
+	status = http.StatusServiceUnavailable
+	rec = httptest.NewRecorder()
+	s.serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready", nil), fakePinger{})
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	var failure ReadinessFailure
+	if err := json.Unmarshal(rec.Body.Bytes(), &failure); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if failure.Ready || strings.Join(failure.Failed, ",") != readinessDependencyHydra {
+		t.Errorf("body = %s, want only hydra failed", rec.Body)
+	}
+}
+
+func TestReadyNamesFailedDependencies(t *testing.T) {
+	// Nothing listens on this URL, and the database is down too
+	s := &Server{hydraAdminURL: "http://127.0.0.1:1", httpClient: http.DefaultClient, config: Config{ReadinessCheckHydra: true}}
+
+	rec := httptest.NewRecorder()
+	s.serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready", nil), fakePinger{err: errors.New("connection refused")})
+
+	var failure ReadinessFailure
+	json.Unmarshal(rec.Body.Bytes(), &failure)
+	if rec.Code != http.StatusServiceUnavailable || strings.Join(failure.Failed, ",") != "database,hydra" {
+		t.Errorf("got %d %s, want 503 naming database and hydra", rec.Code, rec.Body)
+	}
+
+	// With the Hydra check off, only the database counts
+	s.config.ReadinessCheckHydra = false
+	rec = httptest.NewRecorder()
+	s.serveReady(rec, httptest.NewRequest(http.MethodGet, "/ready", nil), fakePinger{})
+	if rec.Code != http.StatusOK {
+		t.Errorf("status without Hydra check = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// retryBudget is a token bucket shared by all in-flight requests that caps
+// the total rate of retries to Hydra, so a brownout can't turn into a retry
+// storm. Each retry consumes one token; tokens refill at a fixed rate.
+type retryBudget struct {
+	mu         sync.Mutex
+	capacity   float64
+	tokens     float64
+	refillRate float64 // tokens per second
+	last       time.Time
+	now        func() time.Time
+}
+
+// newRetryBudget creates a full budget. A capacity of 0 disables retries.
+func newRetryBudget(capacity int, refillPerSecond float64) *retryBudget {
+	return &retryBudget{
+		capacity:   float64(capacity),
+		tokens:     float64(capacity),
+		refillRate: refillPerSecond,
+		last:       time.Now(),
+		now:        time.Now,
+	}
+}
+
+// refill adds tokens for the time elapsed since the last call (mu held)
+func (b *retryBudget) refill() {
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.refillRate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// TryAcquire consumes a retry token, returning false if the budget is spent
+func (b *retryBudget) TryAcquire() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Remaining returns the number of whole retries currently available
+func (b *retryBudget) Remaining() int {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	return int(b.tokens)
+}
+
+// retryPolicy controls retries of Hydra Admin API calls
+type retryPolicy struct {
+	// Total attempts per call, including the first (<= 1 disables retries)
+	attempts int
+	// Delay before the first retry; doubles on each further retry
+	baseDelay time.Duration
+}
+
+// backoff returns the delay before retry n (1-based): exponential in n, with
+// jitter in [d/2, d] so concurrent callers don't retry in lockstep
+func (p retryPolicy) backoff(n int) time.Duration {
+	d := p.baseDelay << (n - 1)
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// doHydra sends a request to the Hydra Admin API, retrying connection errors
+// and 5xx responses (never 4xx) with exponential backoff while attempts and
+// the shared retry budget allow. Requests with a body must be built with
+// http.NewRequest from a bytes.Reader (or otherwise set GetBody) so the body
+// can be replayed.
+func (s *Server) doHydra(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := s.httpClient.Do(req)
+		if err == nil && resp.StatusCode < 500 {
+			return resp, nil
+		}
+		// An open circuit breaker fails every attempt at once, so don't wait for it
+		if errors.Is(err, errHydraCircuitOpen) || attempt >= s.hydraRetry.attempts || !s.retryBudget.TryAcquire() {
+			return resp, err
+		}
+
+		// Drain and close the failed response so the connection can be reused
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			err = fmt.Errorf("Hydra returned %d", resp.StatusCode)
+		}
+
+		delay := s.hydraRetry.backoff(attempt)
+		log.Printf("Retrying Hydra %s %s in %s (attempt %d/%d): %v",
+			req.Method, req.URL.Path, delay, attempt+1, s.hydraRetry.attempts, err)
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, fmt.Errorf("cannot retry %s %s: request body is not replayable", req.Method, req.URL.Path)
+			}
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", bodyErr)
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestRetryBudgetRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newRetryBudget(2, 1)
+	b.now = func() time.Time { return now }
+	b.last = now
+
+	if !b.TryAcquire() || !b.TryAcquire() {
+		t.Fatal("full budget refused a retry")
+	}
+	if b.TryAcquire() {
+		t.Fatal("exhausted budget granted a retry")
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if got := b.Remaining(); got != 1 {
+		t.Errorf("Remaining() after 1.5s = %d, want 1", got)
+	}
+}
+
+func TestExhaustedRetryBudgetSkipsRetries(t *testing.T) {
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		hydraRetry:    retryPolicy{attempts: 3, baseDelay: time.Millisecond},
+		retryBudget:   newRetryBudget(1, 0),
+	}
+	s.metrics = NewMetrics(s.retryBudget, nil)
+
+	s.fetchClientInfo(context.Background(), "svc-a")
+	if got := hits.Load(); got != 2 {
+		t.Fatalf("first fetch made %d Hydra calls, want 2 (one retry)", got)
+	}
+
+	s.fetchClientInfo(context.Background(), "svc-a")
+	if got := hits.Load(); got != 3 {
+		t.Errorf("second fetch made %d Hydra calls, want 1 (budget exhausted)", got-2)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), "hydra_sidecar_retry_budget_remaining 0") {
+		t.Errorf("metrics missing exhausted budget gauge:\n%s", rec.Body.String())
+	}
+}
+
+func TestDoHydraRetriesTransientFailures(t *testing.T) {
+	var hits atomic.Int32
+	var bodies []string
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if hits.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		httpClient:  hydra.Client(),
+		hydraRetry:  retryPolicy{attempts: 3, baseDelay: time.Millisecond},
+		retryBudget: newRetryBudget(10, 0),
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, hydra.URL+"/admin/clients", strings.NewReader(`{"client_name":"svc"}`))
+	resp, err := s.doHydra(req)
+	if err != nil {
+		t.Fatalf("doHydra() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated || hits.Load() != 3 {
+		t.Errorf("status %d after %d calls, want 201 after 3", resp.StatusCode, hits.Load())
+	}
+	for i, b := range bodies {
+		if b != `{"client_name":"svc"}` {
+			t.Errorf("attempt %d body = %q, want the original body replayed", i+1, b)
+		}
+	}
+}
+
+func TestClientPassthroughsRetryHydra5xx(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.Method]++
+		first := calls[r.Method] == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"client_id":"svc-a"}`))
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		hydraRetry:    retryPolicy{attempts: 3, baseDelay: time.Millisecond},
+		retryBudget:   newRetryBudget(10, 0),
+		networkID:     uuid.Must(uuid.NewV4()),
+	}
+
+	rec := httptest.NewRecorder()
+	s.getClient(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/svc-a", nil), "svc-a")
+	if rec.Code != http.StatusOK {
+		t.Errorf("get: status = %d, want 200 after a retry", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	patch := httptest.NewRequest(http.MethodPatch, "/admin/clients/svc-a", strings.NewReader(`[{"op":"add","path":"/client_name","value":"svc"}]`))
+	s.servePatchClient(rec, patch, "svc-a", fakeSecretHashes{})
+	if rec.Code != http.StatusOK {
+		t.Errorf("patch: status = %d, want 200 after a retry", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.deleteClient(rec, httptest.NewRequest(http.MethodDelete, "/admin/clients/svc-a", nil), "svc-a")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete: status = %d, want 204 after a retry", rec.Code)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		if calls[method] < 2 {
+			t.Errorf("%s reached Hydra %d times, want a retry", method, calls[method])
+		}
+	}
+}
+
+func TestDoHydraDoesNotRetryClientErrors(t *testing.T) {
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusConflict)
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		httpClient:  hydra.Client(),
+		hydraRetry:  retryPolicy{attempts: 5, baseDelay: time.Millisecond},
+		retryBudget: newRetryBudget(10, 0),
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, hydra.URL+"/admin/clients/svc-a", nil)
+	resp, err := s.doHydra(req)
+	if err != nil {
+		t.Fatalf("doHydra() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict || hits.Load() != 1 {
+		t.Errorf("status %d after %d calls, want 409 after 1", resp.StatusCode, hits.Load())
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := retryPolicy{baseDelay: 100 * time.Millisecond}
+	for n, max := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond} {
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(n); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %s, want within [%s, %s]", n, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestTokenHookRetriesOnceOnHydra5xx(t *testing.T) {
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"metadata":{"org_id":"acme"}}`))
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		retryBudget:   newRetryBudget(1, 0),
+		retryOn5xx:    true,
+	}
+	s.metrics = NewMetrics(s.retryBudget, nil)
+
+	rec := callTokenHook(t, s, "svc-a")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"org_id":"acme"`) {
+		t.Fatalf("got %d %s, want metadata from the retried fetch", rec.Code, rec.Body)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("Hydra calls = %d, want 2 (500 then 200)", got)
+	}
+
+	metrics := httptest.NewRecorder()
+	s.handleMetrics(metrics, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(metrics.Body.String(), "hydra_sidecar_token_hook_backend_5xx_total 1") {
+		t.Errorf("metrics missing one backend 5xx:\n%s", metrics.Body.String())
+	}
+}
+
+func TestTokenHookFallsBackOnHydra5xxWithoutRetry(t *testing.T) {
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer hydra.Close()
+
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client(), retryBudget: newRetryBudget(1, 0)}
+	if _, _, err := s.clientInfo(context.Background(), "svc-a"); !errors.Is(err, errHydra5xx) {
+		t.Errorf("err = %v, want errHydra5xx", err)
+	}
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "org_id") {
+		t.Errorf("got %d %s, want fallback without metadata", rec.Code, rec.Body)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("Hydra calls = %d, want 1 per lookup", got)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gofrs/uuid"
+)
+
+// Page sizes for /admin/clients/search
+const (
+	defaultSearchPageSize = 100
+	maxSearchPageSize     = 500
+)
+
+// clientSearcher finds clients by metadata
+type clientSearcher interface {
+	SearchClientsByMetadata(ctx context.Context, nid uuid.UUID, filters map[string]string, afterID string, limit int) ([]ClientMetadata, error)
+}
+
+// swagger:route GET /admin/clients/search clients searchClients
+//
+// Search clients by metadata.
+//
+// Every query parameter other than page_size and page_token is a metadata filter, e.g.
+// ?org_id=acme&tier=pro. A client matches when all filters equal its metadata values
+// (compared as text). Results are in client ID order; follow next_page_token for more.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: clientSearchResponse
+//	  400: errorResponse
+//	  403: errorResponse
+//	  500: errorResponse
+func (s *Server) handleSearchClients(w http.ResponseWriter, r *http.Request) {
+	s.serveSearchClients(w, r, s.store)
+}
+
+// serveSearchClients implements handleSearchClients against the given store
+func (s *Server) serveSearchClients(w http.ResponseWriter, r *http.Request, db clientSearcher) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	pageSize := defaultSearchPageSize
+	if raw := query.Get("page_size"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxSearchPageSize {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest,
+				fmt.Sprintf("page_size must be between 1 and %d", maxSearchPageSize))
+			return
+		}
+		pageSize = n
+	}
+
+	filters := make(map[string]string)
+	for key, values := range query {
+		if key == "page_size" || key == "page_token" {
+			continue
+		}
+		if len(values) > 1 {
+			writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, fmt.Sprintf("metadata filter %q given more than once", key))
+			return
+		}
+		filters[key] = values[0]
+	}
+	if len(filters) == 0 {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "at least one metadata filter is required, e.g. ?org_id=acme")
+		return
+	}
+
+	nid, err := s.networkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	// One extra row tells whether another page follows
+	rows, err := db.SearchClientsByMetadata(r.Context(), nid, filters, query.Get("page_token"), pageSize+1)
+	if err != nil {
+		log.Printf("Error searching clients: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+
+	result := ClientSearchResult{Clients: make([]ClientSearchMatch, 0, min(len(rows), pageSize))}
+	if len(rows) > pageSize {
+		rows = rows[:pageSize]
+		result.NextPageToken = rows[pageSize-1].ID
+	}
+	for _, row := range rows {
+		match := ClientSearchMatch{ClientID: row.ID}
+		if len(row.Metadata) > 0 {
+			match.Metadata = json.RawMessage(row.Metadata)
+		}
+		result.Clients = append(result.Clients, match)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
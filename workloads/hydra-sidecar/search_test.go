@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/ory/x/sqlxx"
+)
+
+// fakeClientSearcher filters in-memory metadata like SearchClientsByMetadata
+type fakeClientSearcher struct {
+	metadata map[string]map[string]string
+	filters  map[string]string
+}
+
+func (f *fakeClientSearcher) SearchClientsByMetadata(_ context.Context, _ uuid.UUID, filters map[string]string, afterID string, limit int) ([]ClientMetadata, error) {
+	f.filters = filters
+	ids := make([]string, 0, len(f.metadata))
+	for id := range f.metadata {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var rows []ClientMetadata
+	for _, id := range ids {
+		if id <= afterID || len(rows) == limit {
+			continue
+		}
+		matches := true
+		for k, v := range filters {
+			if f.metadata[id][k] != v {
+				matches = false
+			}
+		}
+		if matches {
+			raw, _ := json.Marshal(f.metadata[id])
+			rows = append(rows, ClientMetadata{ID: id, Metadata: sqlxx.JSONRawMessage(raw)})
+		}
+	}
+	return rows, nil
+}
+
+func searchClients(t *testing.T, db clientSearcher, target string) (int, ClientSearchResult) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	(&Server{networkID: uuid.Must(uuid.NewV4())}).serveSearchClients(rec, httptest.NewRequest(http.MethodGet, target, nil), db)
+	var result ClientSearchResult
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return rec.Code, result
+}
+
+func TestSearchClientsPaginates(t *testing.T) {
+	db := &fakeClientSearcher{metadata: map[string]map[string]string{
+		"a": {"org_id": "acme", "tier": "pro"},
+		"b": {"org_id": "acme", "tier": "free"},
+		"c": {"org_id": "acme", "tier": "pro"},
+		"d": {"org_id": "acme", "tier": "pro"},
+		"e": {"org_id": "other", "tier": "pro"},
+	}}
+
+	var got []string
+	token := ""
+	for page := 0; page < 5; page++ {
+		code, result := searchClients(t, db, "/admin/clients/search?org_id=acme&tier=pro&page_size=2&page_token="+token)
+		if code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", code)
+		}
+		for _, c := range result.Clients {
+			got = append(got, c.ClientID)
+		}
+		if token = result.NextPageToken; token == "" {
+			break
+		}
+	}
+
+	if want := []string{"a", "c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("clients = %v, want %v", got, want)
+	}
+	if want := map[string]string{"org_id": "acme", "tier": "pro"}; !reflect.DeepEqual(db.filters, want) {
+		t.Errorf("filters = %v, want %v", db.filters, want)
+	}
+}
+
+func TestSearchClientsReturnsMetadata(t *testing.T) {
+	db := &fakeClientSearcher{metadata: map[string]map[string]string{"a": {"org_id": "acme"}}}
+	_, result := searchClients(t, db, "/admin/clients/search?org_id=acme")
+	if len(result.Clients) != 1 || string(result.Clients[0].Metadata) != `{"org_id":"acme"}` || result.NextPageToken != "" {
+		t.Errorf("result = %+v, want client a with its metadata and no next page", result)
+	}
+}
+
+func TestSearchClientsRejectsBadRequests(t *testing.T) {
+	for _, target := range []string{
+		"/admin/clients/search",
+		"/admin/clients/search?page_size=10",
+		"/admin/clients/search?org_id=acme&page_size=0",
+		"/admin/clients/search?org_id=acme&page_size=501",
+		"/admin/clients/search?org_id=acme&org_id=other",
+	} {
+		if code, _ := searchClients(t, &fakeClientSearcher{}, target); code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", target, code)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// hookSignatureHeader carries the HMAC-SHA256 of the token hook request body
+const hookSignatureHeader = "X-Hydra-Signature"
+
+// signHookBody returns the hex HMAC-SHA256 of body under secret
+func signHookBody(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyHookSignature checks a hex HMAC-SHA256 signature (optionally prefixed
+// with "sha256=") against body in constant time
+func verifyHookSignature(body []byte, signature, secret string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	if signature == "" {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(signHookBody(body, secret))
+	return hmac.Equal(got, want)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTokenHookSignature(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme"}}`)
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		hookSecret:    "hook-secret",
+	}
+	body := `{"request":{"client_id":"svc-a"}}`
+
+	tests := []struct {
+		name      string
+		signature string
+		want      int
+	}{
+		{"valid", signHookBody([]byte(body), "hook-secret"), http.StatusOK},
+		{"valid with prefix", "sha256=" + signHookBody([]byte(body), "hook-secret"), http.StatusOK},
+		{"wrong secret", signHookBody([]byte(body), "other-secret"), http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+		{"not hex", "zzzz", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/token-hook", strings.NewReader(body))
+			if tt.signature != "" {
+				req.Header.Set(hookSignatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			s.handleTokenHook(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenHookWithoutSecretSkipsVerification(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{}}`)
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 when no secret is configured", rec.Code)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// softDeletedAtKey is the metadata entry marking a soft-deleted client
+// (SOFT_DELETE_ENABLED). It holds the RFC 3339 deletion time and is never
+// injected as a claim.
+const softDeletedAtKey = "sidecar_deleted_at"
+
+// errNotSoftDeleted rejects restoring a client that isn't soft-deleted
+var errNotSoftDeleted = errors.New("client is not deleted")
+
+// softDeletedAt returns when a client was soft-deleted, if it was
+func softDeletedAt(metadata map[string]any) (string, bool) {
+	deletedAt, ok := metadata[softDeletedAtKey].(string)
+	return deletedAt, ok
+}
+
+// softDeleteHydraClient marks a client deleted in its Hydra metadata instead
+// of deleting it. A 404 wraps errClientNotFound.
+func (s *Server) softDeleteHydraClient(ctx context.Context, clientID string) error {
+	info, err := s.fetchClientInfo(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if _, deleted := softDeletedAt(info.Metadata); deleted {
+		return nil
+	}
+
+	deletedAt, _ := json.Marshal(time.Now().UTC().Format(time.RFC3339))
+	op := JSONPatchOperation{Op: "add", Path: "/metadata/" + softDeletedAtKey, Value: deletedAt}
+	if info.Metadata == nil {
+		// A null metadata document has no members to add to
+		op = JSONPatchOperation{Op: "add", Path: "/metadata", Value: json.RawMessage(`{"` + softDeletedAtKey + `":` + string(deletedAt) + `}`)}
+	}
+	return s.patchHydraClient(ctx, clientID, []JSONPatchOperation{op})
+}
+
+// restoreHydraClient clears a client's soft-delete mark. A 404 wraps
+// errClientNotFound; a client that isn't soft-deleted gets errNotSoftDeleted.
+func (s *Server) restoreHydraClient(ctx context.Context, clientID string) error {
+	info, err := s.fetchClientInfo(ctx, clientID)
+	if err != nil {
+		return err
+	}
+	if _, deleted := softDeletedAt(info.Metadata); !deleted {
+		return errNotSoftDeleted
+	}
+	return s.patchHydraClient(ctx, clientID, []JSONPatchOperation{{Op: "remove", Path: "/metadata/" + softDeletedAtKey}})
+}
+
+// patchHydraClient applies JSON Patch operations to a client through the
+// Hydra Admin API
+func (s *Server) patchHydraClient(ctx context.Context, clientID string, ops []JSONPatchOperation) error {
+	body, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+	req, err := s.newHydraRequest(ctx, http.MethodPatch, "/admin/clients/"+clientID, body)
+	if err != nil {
+		return err
+	}
+	resp, err := s.doHydra(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errClientNotFound
+	case resp.StatusCode >= 400:
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Hydra returned %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// softDeleteClient is deleteClient with SOFT_DELETE_ENABLED
+func (s *Server) softDeleteClient(w http.ResponseWriter, r *http.Request, nid uuid.UUID, clientID string) {
+	log.Printf("Soft-deleting client: %s", clientID)
+
+	err := s.softDeleteHydraClient(r.Context(), clientID)
+	if errors.Is(err, errClientNotFound) {
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "soft delete: not found"})
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "client not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Error soft-deleting client %s: %v", clientID, err)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "soft delete: " + err.Error()})
+		writeHydraCallError(w, err, "failed to delete client in Hydra")
+		return
+	}
+
+	log.Printf("Client %s soft-deleted successfully", clientID)
+	s.clientCache.Invalidate(clientID)
+	s.forgetSnapshot(r.Context(), clientID)
+	s.metrics.ClientOperation(clientOpDeleted)
+	s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpDelete, ClientID: clientID, Outcome: auditOutcomeSuccess, Detail: "soft delete"})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// swagger:route POST /admin/clients/restore/{client_id} clients restoreClient
+//
+// Restore a soft-deleted client.
+//
+// Clears the soft-delete mark set while SOFT_DELETE_ENABLED was on, so the client can get
+// tokens again. Returns 409 if the client isn't soft-deleted.
+//
+//	Responses:
+//	  204: noContent
+//	  403: errorResponse
+//	  404: errorResponse
+//	  409: errorResponse
+//	  502: errorResponse
+//	  503: errorResponse
+func (s *Server) handleRestoreClient(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	clientID := strings.TrimPrefix(r.URL.Path, "/admin/clients/restore/")
+	if clientID == "" {
+		writeJSONError(w, http.StatusBadRequest, errCodeInvalidRequest, "missing client_id")
+		return
+	}
+	nid, err := s.hydraNetworkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	err = s.restoreHydraClient(r.Context(), clientID)
+	switch {
+	case errors.Is(err, errClientNotFound):
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRestore, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "not found"})
+		writeJSONError(w, http.StatusNotFound, errCodeNotFound, "client not found")
+		return
+	case errors.Is(err, errNotSoftDeleted):
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRestore, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: "not deleted"})
+		writeJSONError(w, http.StatusConflict, errCodeConflict, "client is not deleted")
+		return
+	case err != nil:
+		log.Printf("Error restoring client %s: %v", clientID, err)
+		s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRestore, ClientID: clientID, Outcome: auditOutcomeFailure, Detail: err.Error()})
+		writeHydraCallError(w, err, "failed to restore client in Hydra")
+		return
+	}
+
+	log.Printf("Client %s restored", clientID)
+	s.clientCache.Invalidate(clientID)
+	s.metrics.ClientOperation(clientOpRestored)
+	s.recordAudit(r.Context(), nid, AuditEvent{Operation: auditOpRestore, ClientID: clientID, Outcome: auditOutcomeSuccess})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// softDeletedLister lists a network's soft-deleted clients
+type softDeletedLister interface {
+	ListSoftDeletedClients(ctx context.Context, nid uuid.UUID) ([]DeletedClient, error)
+}
+
+// swagger:route GET /admin/clients/deleted clients listDeletedClients
+//
+// List soft-deleted clients.
+//
+// Returns the clients in the selected network (X-Network-ID, else the default network) that
+// are soft-deleted, with when they were deleted, in client ID order.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: deletedClientsResponse
+//	  403: errorResponse
+//	  500: errorResponse
+func (s *Server) handleDeletedClients(w http.ResponseWriter, r *http.Request) {
+	s.serveDeletedClients(w, r, s.store)
+}
+
+// serveDeletedClients implements handleDeletedClients against the given store
+func (s *Server) serveDeletedClients(w http.ResponseWriter, r *http.Request, db softDeletedLister) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	nid, err := s.networkFor(r, "")
+	if err != nil {
+		writeNetworkError(w, err)
+		return
+	}
+
+	clients, err := db.ListSoftDeletedClients(r.Context(), nid)
+	if err != nil {
+		log.Printf("Error listing deleted clients: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, errCodeInternal, "internal error")
+		return
+	}
+	if clients == nil {
+		clients = []DeletedClient{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(DeletedClientsReport{Clients: clients}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+// newFakeHydraPatch serves one client's info and records the JSON Patch
+// bodies sent to it
+func newFakeHydraPatch(t *testing.T, info string) (*httptest.Server, func() [][]JSONPatchOperation) {
+	t.Helper()
+	var mu sync.Mutex
+	var patches [][]JSONPatchOperation
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/admin/clients/") != "svc-a" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			var ops []JSONPatchOperation
+			if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+				t.Errorf("decode patch: %v", err)
+			}
+			mu.Lock()
+			patches = append(patches, ops)
+			mu.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(info))
+	}))
+	t.Cleanup(hydra.Close)
+	return hydra, func() [][]JSONPatchOperation {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][]JSONPatchOperation(nil), patches...)
+	}
+}
+
+func softDeleteServer(hydra *httptest.Server) *Server {
+	return &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		config:        Config{SoftDeleteEnabled: true},
+		networkID:     uuid.Must(uuid.NewV4()),
+	}
+}
+
+func TestSoftDeleteClientMarksMetadata(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		info     string
+		wantPath string
+	}{
+		{"existing metadata", `{"metadata":{"org_id":"acme"}}`, "/metadata/" + softDeletedAtKey},
+		{"null metadata", `{"metadata":null}`, "/metadata"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hydra, patches := newFakeHydraPatch(t, tc.info)
+			s := softDeleteServer(hydra)
+
+			rec := httptest.NewRecorder()
+			s.deleteClient(rec, httptest.NewRequest(http.MethodDelete, "/admin/clients/svc-a", nil), "svc-a")
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, want 204: %s", rec.Code, rec.Body.String())
+			}
+			got := patches()
+			if len(got) != 1 || len(got[0]) != 1 || got[0][0].Op != "add" || got[0][0].Path != tc.wantPath {
+				t.Errorf("patches = %+v, want one add on %s", got, tc.wantPath)
+			}
+		})
+	}
+}
+
+func TestSoftDeleteAlreadyDeletedClientIsNoop(t *testing.T) {
+	hydra, patches := newFakeHydraPatch(t, `{"metadata":{"sidecar_deleted_at":"2026-01-02T03:04:05Z"}}`)
+	s := softDeleteServer(hydra)
+
+	if err := s.deleteHydraClient(context.Background(), "svc-a"); err != nil {
+		t.Fatalf("deleteHydraClient: %v", err)
+	}
+	if got := patches(); len(got) != 0 {
+		t.Errorf("patches = %+v, want none", got)
+	}
+	if err := s.deleteHydraClient(context.Background(), "missing"); !errors.Is(err, errClientNotFound) {
+		t.Errorf("missing client: err = %v, want errClientNotFound", err)
+	}
+}
+
+func TestRestoreClient(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		info       string
+		id         string
+		wantStatus int
+		wantPatch  bool
+	}{
+		{"deleted", `{"metadata":{"org_id":"acme","sidecar_deleted_at":"2026-01-02T03:04:05Z"}}`, "svc-a", http.StatusNoContent, true},
+		{"not deleted", `{"metadata":{"org_id":"acme"}}`, "svc-a", http.StatusConflict, false},
+		{"missing", `{}`, "missing", http.StatusNotFound, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hydra, patches := newFakeHydraPatch(t, tc.info)
+			s := softDeleteServer(hydra)
+
+			rec := httptest.NewRecorder()
+			s.handleRestoreClient(rec, httptest.NewRequest(http.MethodPost, "/admin/clients/restore/"+tc.id, nil))
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			got := patches()
+			if !tc.wantPatch {
+				if len(got) != 0 {
+					t.Errorf("patches = %+v, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0][0].Op != "remove" || got[0][0].Path != "/metadata/"+softDeletedAtKey {
+				t.Errorf("patches = %+v, want one remove of the mark", got)
+			}
+		})
+	}
+}
+
+func TestTokenHookDeniesSoftDeletedClient(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme","sidecar_deleted_at":"2026-01-02T03:04:05Z"}}`)
+	s := softDeleteServer(hydra)
+
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusForbidden {
+		t.Errorf("soft delete enabled: status = %d, want 403: %s", rec.Code, rec.Body.String())
+	}
+
+	// With soft delete off the mark is ignored, but still never becomes a claim
+	s.config.SoftDeleteEnabled = false
+	claims := tokenHookClaims(t, s, "svc-a")
+	if claims["org_id"] != "acme" {
+		t.Errorf("org_id claim = %v, want acme", claims["org_id"])
+	}
+	if _, ok := claims[softDeletedAtKey]; ok {
+		t.Errorf("claims include %s: %v", softDeletedAtKey, claims)
+	}
+}
+
+// fakeDeletedLister returns fixed soft-deleted clients per network
+type fakeDeletedLister map[uuid.UUID][]DeletedClient
+
+func (f fakeDeletedLister) ListSoftDeletedClients(_ context.Context, nid uuid.UUID) ([]DeletedClient, error) {
+	return f[nid], nil
+}
+
+func TestServeDeletedClients(t *testing.T) {
+	nid := uuid.Must(uuid.NewV4())
+	s := &Server{networkID: nid}
+	db := fakeDeletedLister{nid: {{ClientID: "svc-a", DeletedAt: "2026-01-02T03:04:05Z"}}}
+
+	rec := httptest.NewRecorder()
+	s.serveDeletedClients(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/deleted", nil), db)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var got DeletedClientsReport
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(got.Clients) != 1 || got.Clients[0] != db[nid][0] {
+		t.Errorf("clients = %+v, want %+v", got.Clients, db[nid])
+	}
+
+	// No deleted clients encodes as an empty list, not null
+	rec = httptest.NewRecorder()
+	s.serveDeletedClients(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/deleted", nil), fakeDeletedLister{})
+	if body := strings.TrimSpace(rec.Body.String()); body != `{"clients":[]}` {
+		t.Errorf("empty body = %s", body)
+	}
+}
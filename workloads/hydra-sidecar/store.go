@@ -2,66 +2,273 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/pop/v6/columns"
 	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
 	"github.com/ory/hydra/v2/client"
+	"github.com/ory/x/sqlxx"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Store handles database operations using pop (same ORM as Hydra)
 type Store struct {
 	conn *pop.Connection
+	// read serves the hot read path; the replica from StoreOptions.ReadURL, else conn
+	read *pop.Connection
+	opts StoreOptions
+	// databaseURL is kept only to redact it from driver errors
+	databaseURL string
 }
 
-// NewStore creates a new database store
-func NewStore(databaseURL string) (*Store, error) {
+// StoreOptions tunes store behavior
+type StoreOptions struct {
+	// QueryLogging logs store operations with their duration
+	QueryLogging bool
+	// SlowQueryThreshold limits query logging to operations at least this slow (0 = log all)
+	SlowQueryThreshold time.Duration
+
+	// Connection pool limits: open and idle connections, and how long a
+	// connection is reused before being replaced (0 = forever)
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// ReadURL is a read replica for hash lookups, client ID lists, and
+	// counts; writes always use the primary (empty = primary for everything)
+	ReadURL string
+
+	// SoftDelete marks deleted clients in their metadata (softDeletedAtKey)
+	// instead of deleting the row, and leaves marked clients out of
+	// GetAllClientIDs so syncs don't delete them again
+	SoftDelete bool
+}
+
+// NewStore creates a new database store, with a second connection when
+// opts.ReadURL names a read replica. Errors never contain either password.
+func NewStore(databaseURL string, opts StoreOptions) (*Store, error) {
+	conn, err := openConnection(databaseURL, opts)
+	if err != nil {
+		return nil, err
+	}
+	store := &Store{conn: conn, read: conn, opts: opts, databaseURL: databaseURL}
+
+	if opts.ReadURL != "" {
+		read, err := openConnection(opts.ReadURL, opts)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("read replica: %w", err)
+		}
+		store.read = read
+	}
+	return store, nil
+}
+
+// openConnection opens a pop connection to databaseURL with the pool limits
+// from opts
+func openConnection(databaseURL string, opts StoreOptions) (*pop.Connection, error) {
 	// Create connection details from URL
 	details := &pop.ConnectionDetails{
-		URL: databaseURL,
+		URL:             databaseURL,
+		Pool:            opts.MaxOpenConns,
+		IdlePool:        opts.MaxIdleConns,
+		ConnMaxLifetime: opts.ConnMaxLifetime,
+		Options:         map[string]string{"migration_table_name": sidecarMigrationTable},
 	}
 
 	conn, err := pop.NewConnection(details)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create connection: %w", err)
+		return nil, fmt.Errorf("failed to create connection: %w", sanitizeDatabaseError(err, databaseURL))
 	}
 
 	if err := conn.Open(); err != nil {
-		return nil, fmt.Errorf("failed to open connection: %w", err)
+		return nil, fmt.Errorf("failed to open connection: %w", sanitizeDatabaseError(err, databaseURL))
+	}
+	// pop's store embeds the *sql.DB, whose pool setters it promotes
+	if db, ok := conn.Store.(connPool); ok {
+		applyPoolSettings(db, opts)
+	} else {
+		log.Printf("Warning: Database store %T has no pool setters, DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS and DB_CONN_MAX_LIFETIME apply only as far as pop maps them", conn.Store)
 	}
+	return conn, nil
+}
+
+// connPool is the pool configuration of a *sql.DB
+type connPool interface {
+	SetMaxOpenConns(n int)
+	SetMaxIdleConns(n int)
+	SetConnMaxLifetime(d time.Duration)
+}
+
+// applyPoolSettings sets the pool limits on the underlying *sql.DB, so they
+// hold however pop's Open maps ConnectionDetails onto the pool
+func applyPoolSettings(db connPool, opts StoreOptions) {
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+}
+
+// timed runs a store operation, logging its duration when query logging is
+// enabled and the operation meets the slow query threshold. Errors are
+// sanitized so the database password never reaches logs or responses.
+func (s *Store) timed(op string, fn func() error) error {
+	if !s.opts.QueryLogging {
+		return s.sanitize(fn())
+	}
+
+	start := time.Now()
+	err := s.sanitize(fn())
+	elapsed := time.Since(start)
+
+	if elapsed >= s.opts.SlowQueryThreshold {
+		if err != nil {
+			log.Printf("DB query %s took %s (error: %v)", op, elapsed, err)
+		} else {
+			log.Printf("DB query %s took %s", op, elapsed)
+		}
+	}
+	return err
+}
 
-	return &Store{conn: conn}, nil
+// sanitize redacts the primary and read replica URLs from err
+func (s *Store) sanitize(err error) error {
+	return sanitizeDatabaseError(sanitizeDatabaseError(err, s.databaseURL), s.opts.ReadURL)
 }
 
-// Close closes the database connection
+// Close closes the database connections
 func (s *Store) Close() error {
+	if s.read != s.conn {
+		if err := s.read.Close(); err != nil {
+			log.Printf("Warning: closing read replica connection: %v", s.sanitize(err))
+		}
+	}
 	return s.conn.Close()
 }
 
 // GetDefaultNetworkID retrieves the single network ID for single-tenant deployments
 func (s *Store) GetDefaultNetworkID(ctx context.Context) (uuid.UUID, error) {
 	var nid uuid.UUID
-	err := s.conn.RawQuery("SELECT id FROM networks LIMIT 1").First(&nid)
+	err := s.timed("GetDefaultNetworkID", func() error {
+		return s.conn.RawQuery("SELECT id FROM networks LIMIT 1").First(&nid)
+	})
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to get network ID: %w", err)
 	}
 	return nid, nil
 }
 
-// GetHashedSecret retrieves the hashed secret for a client
+// TableExists reports whether a table is visible on the connection's
+// search path. The sidecar's own tables come only from RunMigrations, so
+// features check for theirs at startup.
+func (s *Store) TableExists(ctx context.Context, table string) (bool, error) {
+	var exists bool
+	err := s.timed("TableExists", func() error {
+		return s.conn.RawQuery("SELECT to_regclass(?) IS NOT NULL", table).First(&exists)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", table, err)
+	}
+	return exists, nil
+}
+
+// GetNetworkIDByName retrieves the network ID mapped to a name in hydra_sidecar_networks
+func (s *Store) GetNetworkIDByName(ctx context.Context, name string) (uuid.UUID, error) {
+	var nid uuid.UUID
+	err := s.timed("GetNetworkIDByName", func() error {
+		return s.conn.RawQuery("SELECT nid FROM hydra_sidecar_networks WHERE name = ?", name).First(&nid)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, fmt.Errorf("%w %q", errUnknownNetwork, name)
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to get network %q: %w", name, err)
+	}
+	return nid, nil
+}
+
+// GetHashedSecret retrieves the hashed secret for a client; see
+// GetHashedSecrets. A missing client is an error wrapping sql.ErrNoRows.
 func (s *Store) GetHashedSecret(ctx context.Context, clientID string, nid uuid.UUID) (string, error) {
-	var c client.Client
-	err := s.conn.Where("id = ? AND nid = ?", clientID, nid).First(&c)
+	hashes, err := s.GetHashedSecrets(ctx, []string{clientID}, nid)
 	if err != nil {
-		return "", fmt.Errorf("failed to get client: %w", err)
+		return "", err
+	}
+	hash, ok := hashes[clientID]
+	if !ok {
+		return "", fmt.Errorf("failed to get client: %w", sql.ErrNoRows)
+	}
+	return hash, nil
+}
+
+// GetHashedSecrets retrieves the hashed secrets for a set of clients in one
+// query on the read connection, keyed by client ID (clients not found are
+// omitted). Clients the replica doesn't have yet, such as ones Hydra has just
+// created, are looked up again on the primary in a second query.
+func (s *Store) GetHashedSecrets(ctx context.Context, clientIDs []string, nid uuid.UUID) (map[string]string, error) {
+	hashes := make(map[string]string, len(clientIDs))
+	if len(clientIDs) == 0 {
+		return hashes, nil
+	}
+
+	err := s.timed("GetHashedSecrets", func() error {
+		if err := selectHashes(s.read, clientIDs, nid, hashes); err != nil {
+			return err
+		}
+		if missing := missingClientIDs(clientIDs, hashes); s.read != s.conn && len(missing) > 0 {
+			return selectHashes(s.conn, missing, nid, hashes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clients: %w", err)
+	}
+	return hashes, nil
+}
+
+// selectHashes adds the stored secret hashes of clientIDs in nid to hashes
+func selectHashes(conn *pop.Connection, clientIDs []string, nid uuid.UUID, hashes map[string]string) error {
+	var clients []client.Client
+	if err := conn.Where("nid = ?", nid).Where("id IN (?)", clientIDs).Select(clientHashColumns...).All(&clients); err != nil {
+		return err
 	}
-	return c.Secret, nil
+	for _, c := range clients {
+		hashes[c.ID] = c.Secret
+	}
+	return nil
 }
 
-// GetAllClientIDs retrieves all client IDs for a network
+// missingClientIDs returns the IDs in clientIDs without an entry in hashes
+func missingClientIDs(clientIDs []string, hashes map[string]string) []string {
+	var missing []string
+	for _, id := range clientIDs {
+		if _, ok := hashes[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// GetAllClientIDs retrieves all client IDs for a network from the read
+// connection, minus soft-deleted clients when StoreOptions.SoftDelete is set
 func (s *Store) GetAllClientIDs(ctx context.Context, nid uuid.UUID) ([]string, error) {
 	var clients []client.Client
-	err := s.conn.Where("nid = ?", nid).Select("id").All(&clients)
+	err := s.timed("GetAllClientIDs", func() error {
+		q := s.read.Where("nid = ?", nid)
+		if s.opts.SoftDelete {
+			q = q.Where("(metadata::jsonb ->> ?) IS NULL", softDeletedAtKey)
+		}
+		return q.Select("id").All(&clients)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client IDs: %w", err)
 	}
@@ -73,104 +280,624 @@ func (s *Store) GetAllClientIDs(ctx context.Context, nid uuid.UUID) ([]string, e
 	return ids, nil
 }
 
-// UpsertClient creates or updates a client in the database
-func (s *Store) UpsertClient(ctx context.Context, c *client.Client) error {
-	// Check if client exists
-	existing := &client.Client{}
-	err := s.conn.Where("id = ? AND nid = ?", c.ID, c.NID).First(existing)
+// ListClients returns up to limit clients in a network with IDs after
+// afterID, in ID order, minus soft-deleted clients when StoreOptions.SoftDelete
+// is set. Secret holds the stored hash.
+func (s *Store) ListClients(ctx context.Context, nid uuid.UUID, afterID string, limit int) ([]client.Client, error) {
+	var clients []client.Client
+	err := s.timed("ListClients", func() error {
+		q := s.conn.Where("nid = ? AND id > ?", nid, afterID)
+		if s.opts.SoftDelete {
+			q = q.Where("(metadata::jsonb ->> ?) IS NULL", softDeletedAtKey)
+		}
+		return q.Order("id").Limit(limit).All(&clients)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clients: %w", err)
+	}
+	return clients, nil
+}
+
+// IterateClients calls fn with each page of up to pageSize clients in a
+// network, in ID order and with the same filtering as ListClients, so
+// callers can walk every client without holding them all in memory. It stops
+// at the first error from the store or fn and returns it.
+func (s *Store) IterateClients(ctx context.Context, nid uuid.UUID, pageSize int, fn func(page []client.Client) error) error {
+	return iterateClientPages(ctx, s, nid, pageSize, fn)
+}
+
+// clientPager lists a network's clients one keyset page at a time
+type clientPager interface {
+	ListClients(ctx context.Context, nid uuid.UUID, afterID string, limit int) ([]client.Client, error)
+}
+
+// iterateClientPages implements IterateClients against any clientPager,
+// asking for the page after the last ID seen until a short page
+func iterateClientPages(ctx context.Context, db clientPager, nid uuid.UUID, pageSize int, fn func(page []client.Client) error) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+	afterID := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := db.ListClients(ctx, nid, afterID, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// CountClients returns the number of clients in a network, from the read
+// connection, minus soft-deleted clients when StoreOptions.SoftDelete is set
+func (s *Store) CountClients(ctx context.Context, nid uuid.UUID) (int, error) {
+	var count int
+	err := s.timed("CountClients", func() error {
+		if s.opts.SoftDelete {
+			return s.read.RawQuery("SELECT count(*) FROM hydra_client WHERE nid = ? AND (metadata::jsonb ->> ?) IS NULL",
+				nid, softDeletedAtKey).First(&count)
+		}
+		return s.read.RawQuery("SELECT count(*) FROM hydra_client WHERE nid = ?", nid).First(&count)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count clients: %w", err)
+	}
+	return count, nil
+}
 
+// clientHashColumns are the hydra_client columns selected to read client IDs
+// and their stored secret hashes
+var clientHashColumns = []string{"id", "client_secret"}
+
+// GetClientSecretHashes retrieves the stored secret hash of every client in a network
+func (s *Store) GetClientSecretHashes(ctx context.Context, nid uuid.UUID) (map[string]string, error) {
+	var clients []client.Client
+	err := s.timed("GetClientSecretHashes", func() error {
+		return s.conn.Where("nid = ?", nid).Select(clientHashColumns...).All(&clients)
+	})
 	if err != nil {
-		// Client doesn't exist, create it
-		return s.conn.Create(c)
+		return nil, fmt.Errorf("failed to get client secrets: %w", err)
+	}
+
+	hashes := make(map[string]string, len(clients))
+	for _, c := range clients {
+		hashes[c.ID] = c.Secret
+	}
+	return hashes, nil
+}
+
+// upsertClientSQL writes every hydra_client column pop would on Create, in
+// one statement, so concurrent syncs of a client can't both try to insert it.
+// On conflict it updates all but the key and created_at; xmax is 0 only for a
+// row this statement inserted.
+var upsertClientSQL = buildUpsertClientSQL(clientColumns())
+
+// clientColumns lists the writeable hydra_client columns of client.Client.
+// pop leaves the primary key out of Writeable and adds it itself on Create,
+// so it is added here too.
+func clientColumns() []string {
+	names := []string{"id"}
+	for name := range columns.ForStruct(&client.Client{}, "hydra_client", "id").Writeable().Cols {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
+
+// buildUpsertClientSQL builds the upsert for the given columns, with a
+// :column named parameter for each
+func buildUpsertClientSQL(cols []string) string {
+	var updates []string
+	for _, col := range cols {
+		switch col {
+		case "id", "nid", "created_at":
+		default:
+			updates = append(updates, col+" = EXCLUDED."+col)
+		}
+	}
+	return "INSERT INTO hydra_client (" + strings.Join(cols, ", ") + ")\n" +
+		"VALUES (:" + strings.Join(cols, ", :") + ")\n" +
+		"ON CONFLICT (id, nid) DO UPDATE SET " + strings.Join(updates, ", ") + "\n" +
+		"RETURNING created_at, (xmax = 0) AS created"
+}
+
+// upsertedClient is what upsertClientSQL returns
+type upsertedClient struct {
+	CreatedAt time.Time `db:"created_at"`
+	Created   bool      `db:"created"`
+}
+
+// UpsertClient creates or updates a client in the database in a single
+// INSERT ... ON CONFLICT statement (Postgres), reporting whether it created
+// the row. Updates keep the existing created_at, which is copied back into c;
+// see stampClientTimestamps.
+func (s *Store) UpsertClient(ctx context.Context, c *client.Client) (created bool, err error) {
+	_, span := tracer.Start(ctx, "UpsertClient", trace.WithAttributes(attribute.String("client_id", c.ID)))
+	defer func() { endSpan(span, err) }()
+
+	err = s.timed("UpsertClient", func() error {
+		// Hydra fills in defaults for its NOT NULL columns here, as pop's Create did
+		if saver, ok := any(c).(interface{ BeforeSave(*pop.Connection) error }); ok {
+			if err := saver.BeforeSave(s.conn); err != nil {
+				return err
+			}
+		}
+		stampClientTimestamps(c, time.Now())
+
+		query, args, err := sqlx.Named(upsertClientSQL, c)
+		if err != nil {
+			return fmt.Errorf("failed to bind client columns: %w", err)
+		}
+		var row upsertedClient
+		if err := s.conn.RawQuery(query, args...).First(&row); err != nil {
+			return err
+		}
+		c.CreatedAt = row.CreatedAt
+		created = row.Created
+		return nil
+	})
+	return created, err
+}
 
-	// Client exists, update it
-	return s.conn.Update(c)
+// stampClientTimestamps sets created_at/updated_at for a synced client so
+// reconciliation doesn't rewrite history: created_at is set to now unless the
+// payload imports one, and updated_at always advances to now. On update the
+// upsert's ON CONFLICT SET list leaves created_at out, so the existing row
+// keeps its own.
+func stampClientTimestamps(c *client.Client, now time.Time) {
+	now = now.UTC().Truncate(time.Microsecond)
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	c.UpdatedAt = now
 }
 
-// DeleteClient deletes a client by ID
+// DeleteClient deletes a client by ID, or with StoreOptions.SoftDelete marks
+// it deleted in its metadata. Requires Postgres JSONB for soft deletes.
 func (s *Store) DeleteClient(ctx context.Context, clientID string, nid uuid.UUID) error {
-	return s.conn.RawQuery("DELETE FROM hydra_client WHERE id = ? AND nid = ?", clientID, nid).Exec()
+	if s.opts.SoftDelete {
+		return s.timed("SoftDeleteClient", func() error {
+			return s.conn.RawQuery(`UPDATE hydra_client SET updated_at = ?,
+				metadata = jsonb_set(CASE WHEN jsonb_typeof(metadata::jsonb) = 'object' THEN metadata::jsonb ELSE '{}'::jsonb END,
+					ARRAY[?], to_jsonb(?::text))::text
+				WHERE id = ? AND nid = ? AND (metadata::jsonb ->> ?) IS NULL`,
+				time.Now().UTC(), softDeletedAtKey, time.Now().UTC().Format(time.RFC3339), clientID, nid, softDeletedAtKey).Exec()
+		})
+	}
+	return s.timed("DeleteClient", func() error {
+		return s.conn.RawQuery("DELETE FROM hydra_client WHERE id = ? AND nid = ?", clientID, nid).Exec()
+	})
 }
 
-// Ping checks database connectivity
-func (s *Store) Ping(ctx context.Context) error {
-	return s.conn.RawQuery("SELECT 1").Exec()
+// ListSoftDeletedClients returns the network's soft-deleted clients in ID
+// order. Requires Postgres JSONB.
+func (s *Store) ListSoftDeletedClients(ctx context.Context, nid uuid.UUID) ([]DeletedClient, error) {
+	var clients []DeletedClient
+	err := s.timed("ListSoftDeletedClients", func() error {
+		return s.conn.RawQuery(`SELECT id, metadata::jsonb ->> ? AS deleted_at FROM hydra_client
+			WHERE nid = ? AND (metadata::jsonb ->> ?) IS NOT NULL ORDER BY id`,
+			softDeletedAtKey, nid, softDeletedAtKey).All(&clients)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deleted clients: %w", err)
+	}
+	return clients, nil
 }
 
-// SyncClients performs full reconciliation of clients
-func (s *Store) SyncClients(ctx context.Context, clients []client.Client, nid uuid.UUID) (*SyncResult, error) {
-	result := &SyncResult{
-		Results: make([]ClientResult, 0),
+// ClientMetadata is a client ID with its raw metadata column
+type ClientMetadata struct {
+	ID       string               `db:"id"`
+	Metadata sqlxx.JSONRawMessage `db:"metadata"`
+}
+
+// GetClientsMissingMetadata returns clients lacking any of the required
+// metadata keys (absent or null), minus soft-deleted clients when
+// StoreOptions.SoftDelete is set. Requires Postgres JSONB.
+func (s *Store) GetClientsMissingMetadata(ctx context.Context, nid uuid.UUID, required []string) ([]ClientMetadata, error) {
+	if len(required) == 0 {
+		return nil, nil
+	}
+
+	where := "nid = ?"
+	args := []any{nid}
+	if s.opts.SoftDelete {
+		where += " AND (metadata::jsonb ->> ?) IS NULL"
+		args = append(args, softDeletedAtKey)
+	}
+	conds := make([]string, len(required))
+	for i, key := range required {
+		conds[i] = "COALESCE(metadata::jsonb -> ?, 'null'::jsonb) = 'null'::jsonb"
+		args = append(args, key)
 	}
+	query := fmt.Sprintf("SELECT id, metadata FROM hydra_client WHERE %s AND (%s) ORDER BY id",
+		where, strings.Join(conds, " OR "))
 
-	// 1. Get all existing client IDs
-	existingIDs, err := s.GetAllClientIDs(ctx, nid)
+	var rows []ClientMetadata
+	err := s.timed("GetClientsMissingMetadata", func() error {
+		return s.conn.RawQuery(query, args...).All(&rows)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get existing clients: %w", err)
+		return nil, fmt.Errorf("failed to query client metadata: %w", err)
+	}
+	return rows, nil
+}
+
+// SearchClientsByMetadata returns up to limit clients after afterID, in ID
+// order, whose metadata matches every filter. Values are compared as text, so
+// "42" matches both 42 and "42". Soft-deleted clients are left out when
+// StoreOptions.SoftDelete is set. Requires Postgres JSONB.
+func (s *Store) SearchClientsByMetadata(ctx context.Context, nid uuid.UUID, filters map[string]string, afterID string, limit int) ([]ClientMetadata, error) {
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	existingMap := make(map[string]bool)
-	for _, id := range existingIDs {
-		existingMap[id] = true
+	query := "SELECT id, metadata FROM hydra_client WHERE nid = ? AND id > ?"
+	args := []any{nid, afterID}
+	if s.opts.SoftDelete {
+		query += " AND (metadata::jsonb ->> ?) IS NULL"
+		args = append(args, softDeletedAtKey)
 	}
+	for _, key := range keys {
+		query += " AND metadata::jsonb ->> ? = ?"
+		args = append(args, key, filters[key])
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, limit)
 
-	// 2. Track which IDs are in the sync request
-	syncedIDs := make(map[string]bool)
+	var rows []ClientMetadata
+	err := s.timed("SearchClientsByMetadata", func() error {
+		return s.conn.RawQuery(query, args...).All(&rows)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search client metadata: %w", err)
+	}
+	return rows, nil
+}
 
-	// 3. Upsert each client
-	for _, c := range clients {
-		c.NID = nid
-		syncedIDs[c.ID] = true
-
-		wasExisting := existingMap[c.ID]
-
-		if err := s.UpsertClient(ctx, &c); err != nil {
-			errStr := err.Error()
-			result.Results = append(result.Results, ClientResult{
-				ClientID: c.ID,
-				Status:   "failed",
-				Error:    &errStr,
-			})
-			result.FailedCount++
-			continue
-		}
-
-		if wasExisting {
-			result.Results = append(result.Results, ClientResult{
-				ClientID: c.ID,
-				Status:   "updated",
-			})
-			result.UpdatedCount++
-		} else {
-			result.Results = append(result.Results, ClientResult{
-				ClientID: c.ID,
-				Status:   "created",
-			})
-			result.CreatedCount++
-		}
-	}
-
-	// 4. Delete clients not in sync request
-	for _, id := range existingIDs {
-		if !syncedIDs[id] {
-			if err := s.DeleteClient(ctx, id, nid); err != nil {
-				errStr := err.Error()
-				result.Results = append(result.Results, ClientResult{
-					ClientID: id,
-					Status:   "failed",
-					Error:    &errStr,
-				})
-				result.FailedCount++
-				continue
+// ClientNetwork is a client ID paired with the network it belongs to
+type ClientNetwork struct {
+	ID  string    `db:"id"`
+	NID uuid.UUID `db:"nid"`
+}
+
+// GetCrossNetworkClientIDs returns every (id, nid) pair whose client ID is
+// registered in more than one network, ordered by id then nid. With
+// StoreOptions.SoftDelete set, soft-deleted clients neither count nor show.
+func (s *Store) GetCrossNetworkClientIDs(ctx context.Context) ([]ClientNetwork, error) {
+	live, outerLive := "", ""
+	var args []any
+	if s.opts.SoftDelete {
+		live, outerLive = " WHERE (metadata::jsonb ->> ?) IS NULL", " AND (metadata::jsonb ->> ?) IS NULL"
+		args = append(args, softDeletedAtKey, softDeletedAtKey)
+	}
+	query := fmt.Sprintf(`SELECT id, nid FROM hydra_client WHERE id IN (
+			SELECT id FROM hydra_client%s GROUP BY id HAVING COUNT(DISTINCT nid) > 1
+		)%s ORDER BY id, nid`, live, outerLive)
+
+	var rows []ClientNetwork
+	err := s.timed("GetCrossNetworkClientIDs", func() error {
+		return s.conn.RawQuery(query, args...).All(&rows)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cross-network client IDs: %w", err)
+	}
+	return rows, nil
+}
+
+// ClientInfoRow is the token hook's view of a client, read straight from hydra_client
+type ClientInfoRow struct {
+	ID                    string               `db:"id"`
+	Metadata              sqlxx.JSONRawMessage `db:"metadata"`
+	ClientSecretExpiresAt int64                `db:"client_secret_expires_at"`
+	Scope                 string               `db:"scope"`
+}
+
+// GetRecentClientInfo returns up to limit clients of a network, most recently
+// active first: by last token issuance when byUsage is set (requires the usage
+// table), otherwise by last update. Soft-deleted clients are left out when
+// StoreOptions.SoftDelete is set.
+func (s *Store) GetRecentClientInfo(ctx context.Context, nid uuid.UUID, limit int, byUsage bool) ([]ClientInfoRow, error) {
+	live := ""
+	args := []any{nid}
+	if s.opts.SoftDelete {
+		live = " AND (c.metadata::jsonb ->> ?) IS NULL"
+		args = append(args, softDeletedAtKey)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`SELECT c.id, c.metadata, c.client_secret_expires_at, c.scope FROM hydra_client c
+		WHERE c.nid = ?%s ORDER BY c.updated_at DESC LIMIT ?`, live)
+	if byUsage {
+		query = fmt.Sprintf(`SELECT c.id, c.metadata, c.client_secret_expires_at, c.scope FROM hydra_client c
+			LEFT JOIN hydra_sidecar_client_usage u ON u.client_id = c.id AND u.nid = c.nid
+			WHERE c.nid = ?%s ORDER BY u.last_issued_at DESC NULLS LAST, c.updated_at DESC LIMIT ?`, live)
+	}
+
+	var rows []ClientInfoRow
+	err := s.timed("GetRecentClientInfo", func() error {
+		return s.conn.RawQuery(query, args...).All(&rows)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recent clients: %w", err)
+	}
+	return rows, nil
+}
+
+// RecordClientUsage adds a batch of usage deltas in a single transaction
+func (s *Store) RecordClientUsage(ctx context.Context, nid uuid.UUID, deltas map[string]usageDelta) error {
+	return s.timed("RecordClientUsage", func() error {
+		return s.recordClientUsage(nid, deltas)
+	})
+}
+
+func (s *Store) recordClientUsage(nid uuid.UUID, deltas map[string]usageDelta) error {
+	return s.conn.Transaction(func(tx *pop.Connection) error {
+		for clientID, d := range deltas {
+			err := tx.RawQuery(`INSERT INTO hydra_sidecar_client_usage (client_id, nid, issued_count, last_issued_at)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT (client_id, nid) DO UPDATE SET
+					issued_count = hydra_sidecar_client_usage.issued_count + EXCLUDED.issued_count,
+					last_issued_at = GREATEST(hydra_sidecar_client_usage.last_issued_at, EXCLUDED.last_issued_at)`,
+				clientID, nid, d.Count, d.LastIssuedAt.UTC()).Exec()
+			if err != nil {
+				return fmt.Errorf("failed to record usage for %s: %w", clientID, err)
 			}
-			result.Results = append(result.Results, ClientResult{
-				ClientID: id,
-				Status:   "deleted",
-			})
-			result.DeletedCount++
 		}
+		return nil
+	})
+}
+
+// RecordAuditEvent appends an audit event
+func (s *Store) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	return s.timed("RecordAuditEvent", func() error {
+		return s.conn.RawQuery(`INSERT INTO hydra_sidecar_audit_events (occurred_at, operation, client_id, nid, detail, caller, outcome)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			event.Timestamp, event.Operation, event.ClientID, event.NetworkID, event.Detail, event.Caller, event.Outcome).Exec()
+	})
+}
+
+// ListAuditEvents returns up to filter.Limit events after filter.AfterID
+// within the time range, in ID order
+func (s *Store) ListAuditEvents(ctx context.Context, filter auditFilter) ([]AuditEvent, error) {
+	query := `SELECT id, occurred_at, operation, client_id, nid, detail, caller, outcome
+		FROM hydra_sidecar_audit_events WHERE id > ?`
+	args := []interface{}{filter.AfterID}
+	if !filter.Since.IsZero() {
+		query += " AND occurred_at >= ?"
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query += " AND occurred_at < ?"
+		args = append(args, filter.Until)
+	}
+	query += " ORDER BY id LIMIT ?"
+	args = append(args, filter.Limit)
+
+	var events []AuditEvent
+	err := s.timed("ListAuditEvents", func() error {
+		return s.conn.RawQuery(query, args...).All(&events)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, nil
+}
+
+// ReserveIdempotencyKey inserts rec unless an unexpired record holds its key,
+// in which case that record is returned. Expired keys are purged first.
+func (s *Store) ReserveIdempotencyKey(ctx context.Context, rec *idempotencyRecord, expiredBefore time.Time) (*idempotencyRecord, error) {
+	var existing *idempotencyRecord
+	err := s.timed("ReserveIdempotencyKey", func() error {
+		err := s.conn.RawQuery("DELETE FROM hydra_sidecar_idempotency_keys WHERE created_at < ?", expiredBefore).Exec()
+		if err != nil {
+			return fmt.Errorf("failed to purge expired idempotency keys: %w", err)
+		}
+		inserted, err := s.conn.RawQuery(`INSERT INTO hydra_sidecar_idempotency_keys
+			(idempotency_key, caller, nid, request_hash, created_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (idempotency_key, caller, nid) DO NOTHING`,
+			rec.Key, rec.Caller, rec.NID, rec.RequestHash, rec.CreatedAt).ExecWithCount()
+		if err != nil {
+			return fmt.Errorf("failed to reserve idempotency key: %w", err)
+		}
+		if inserted == 1 {
+			return nil
+		}
+		existing = &idempotencyRecord{}
+		err = s.conn.RawQuery(`SELECT idempotency_key, caller, nid, request_hash, client_id, response, created_at
+			FROM hydra_sidecar_idempotency_keys WHERE idempotency_key = ? AND caller = ? AND nid = ?`,
+			rec.Key, rec.Caller, rec.NID).First(existing)
+		if err != nil {
+			return fmt.Errorf("failed to get idempotency key: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// CompleteIdempotencyKey records the client created under a reserved key
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, rec *idempotencyRecord) error {
+	return s.timed("CompleteIdempotencyKey", func() error {
+		return s.conn.RawQuery(`UPDATE hydra_sidecar_idempotency_keys SET client_id = ?, response = ?
+			WHERE idempotency_key = ? AND caller = ? AND nid = ?`,
+			rec.ClientID, rec.Response, rec.Key, rec.Caller, rec.NID).Exec()
+	})
+}
+
+// ReleaseIdempotencyKey deletes a reserved key whose create didn't complete
+func (s *Store) ReleaseIdempotencyKey(ctx context.Context, rec *idempotencyRecord) error {
+	return s.timed("ReleaseIdempotencyKey", func() error {
+		return s.conn.RawQuery(`DELETE FROM hydra_sidecar_idempotency_keys
+			WHERE idempotency_key = ? AND caller = ? AND nid = ? AND response = ''`,
+			rec.Key, rec.Caller, rec.NID).Exec()
+	})
+}
+
+// SaveClientSnapshot stores client info fetched from Hydra, replacing the client's previous snapshot
+func (s *Store) SaveClientSnapshot(ctx context.Context, clientID string, info *ClientInfo, fetchedAt time.Time) error {
+	encoded, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode client info: %w", err)
+	}
+	return s.timed("SaveClientSnapshot", func() error {
+		return s.conn.RawQuery(`INSERT INTO hydra_sidecar_client_snapshots (client_id, info, fetched_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (client_id) DO UPDATE SET info = EXCLUDED.info, fetched_at = EXCLUDED.fetched_at`,
+			clientID, string(encoded), fetchedAt).Exec()
+	})
+}
+
+// GetClientSnapshot returns a client's snapshot if it was fetched after
+// fetchedAfter, or nil info if there is none
+func (s *Store) GetClientSnapshot(ctx context.Context, clientID string, fetchedAfter time.Time) (*ClientInfo, time.Time, error) {
+	var row struct {
+		Info      string    `db:"info"`
+		FetchedAt time.Time `db:"fetched_at"`
+	}
+	err := s.timed("GetClientSnapshot", func() error {
+		return s.conn.RawQuery(`SELECT info, fetched_at FROM hydra_sidecar_client_snapshots
+			WHERE client_id = ? AND fetched_at > ?`, clientID, fetchedAfter).First(&row)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get client snapshot: %w", err)
+	}
+	var info ClientInfo
+	if err := json.Unmarshal([]byte(row.Info), &info); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode client snapshot: %w", err)
+	}
+	return &info, row.FetchedAt, nil
+}
+
+// DeleteClientSnapshot drops a client's snapshot
+func (s *Store) DeleteClientSnapshot(ctx context.Context, clientID string) error {
+	return s.timed("DeleteClientSnapshot", func() error {
+		return s.conn.RawQuery("DELETE FROM hydra_sidecar_client_snapshots WHERE client_id = ?", clientID).Exec()
+	})
+}
+
+// GetClientUsage retrieves the persisted usage record for a client.
+// Returns a zero-count record if the client has never been seen by the hook.
+func (s *Store) GetClientUsage(ctx context.Context, clientID string, nid uuid.UUID) (*ClientUsage, error) {
+	usage := &ClientUsage{ClientID: clientID}
+	err := s.timed("GetClientUsage", func() error {
+		return s.conn.RawQuery(`SELECT client_id, issued_count, last_issued_at
+			FROM hydra_sidecar_client_usage WHERE client_id = ? AND nid = ?`, clientID, nid).First(usage)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client usage: %w", err)
 	}
+	return usage, nil
+}
+
+// Ping checks database connectivity, of the read replica too when configured
+func (s *Store) Ping(ctx context.Context) error {
+	return s.timed("Ping", func() error {
+		if err := s.conn.RawQuery("SELECT 1").Exec(); err != nil {
+			return err
+		}
+		if s.read != s.conn {
+			if err := s.read.RawQuery("SELECT 1").Exec(); err != nil {
+				return fmt.Errorf("read replica: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// SyncClients performs full reconciliation of clients, best-effort or
+// atomically depending on opts, passing each client's result to
+// opts.Progress when set
+func (s *Store) SyncClients(ctx context.Context, clients []client.Client, nid uuid.UUID, opts SyncOptions) (result *SyncResult, err error) {
+	ctx, span := tracer.Start(ctx, "SyncClients", trace.WithAttributes(
+		attribute.Int("sync.client_count", len(clients)),
+		attribute.Bool("sync.atomic", opts.Atomic),
+	))
+	defer func() {
+		if result != nil {
+			span.SetAttributes(
+				attribute.Int("sync.created_count", result.CreatedCount),
+				attribute.Int("sync.updated_count", result.UpdatedCount),
+				attribute.Int("sync.deleted_count", result.DeletedCount),
+				attribute.Int("sync.failed_count", result.FailedCount),
+				attribute.String("sync.status", result.Status),
+			)
+		}
+		endSpan(span, err)
+	}()
+
+	return timedSync(func() (*SyncResult, error) {
+		if !opts.Atomic {
+			return syncClients(ctx, s, clients, nid, opts)
+		}
+		return syncClientsAtomic(ctx, s.inTransaction, clients, nid, opts)
+	})
+}
 
-	return result, nil
+// inTransaction runs fn in a database transaction. Writes are isolated by
+// savepoints so one failed statement doesn't abort the transaction (Postgres
+// rejects every later statement otherwise) and fn decides whether to commit.
+// Reads go through the transaction too, so they see its own writes.
+func (s *Store) inTransaction(fn func(w clientWriter) error) error {
+	return s.conn.Transaction(func(tx *pop.Connection) error {
+		return fn(&savepointWriter{Store: s.withConnection(tx)})
+	})
+}
+
+// withConnection returns a copy of the store that reads and writes through
+// conn, keeping its options and the URLs it redacts from errors
+func (s *Store) withConnection(conn *pop.Connection) *Store {
+	c := *s
+	c.conn, c.read = conn, conn
+	return &c
+}
+
+// savepointWriter wraps each write of a transactional Store in a savepoint
+type savepointWriter struct {
+	*Store
+}
+
+func (w *savepointWriter) UpsertClient(ctx context.Context, c *client.Client) (created bool, err error) {
+	err = w.savepoint(func() error {
+		created, err = w.Store.UpsertClient(ctx, c)
+		return err
+	})
+	return created, err
+}
+
+func (w *savepointWriter) DeleteClient(ctx context.Context, clientID string, nid uuid.UUID) error {
+	return w.savepoint(func() error { return w.Store.DeleteClient(ctx, clientID, nid) })
+}
+
+// savepoint runs fn, undoing only its own statements if it fails
+func (w *savepointWriter) savepoint(fn func() error) error {
+	if err := w.conn.RawQuery("SAVEPOINT sync_op").Exec(); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := fn(); err != nil {
+		if rbErr := w.conn.RawQuery("ROLLBACK TO SAVEPOINT sync_op").Exec(); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	return w.conn.RawQuery("RELEASE SAVEPOINT sync_op").Exec()
 }
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gobuffalo/pop/v6/columns"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/ory/hydra/v2/client"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func TestTimedLogsSlowQueries(t *testing.T) {
+	buf := captureLog(t)
+	s := &Store{opts: StoreOptions{QueryLogging: true, SlowQueryThreshold: 20 * time.Millisecond}}
+
+	s.timed("FastQuery", func() error { return nil })
+	s.timed("SlowQuery", func() error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+
+	out := buf.String()
+	if !strings.Contains(out, "DB query SlowQuery took") {
+		t.Errorf("slow query not logged, log output: %q", out)
+	}
+	if strings.Contains(out, "FastQuery") {
+		t.Errorf("fast query logged below threshold, log output: %q", out)
+	}
+}
+
+func TestTimedDisabled(t *testing.T) {
+	buf := captureLog(t)
+	s := &Store{}
+
+	s.timed("SlowQuery", func() error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+
+	if buf.Len() != 0 {
+		t.Errorf("query logged with logging disabled: %q", buf.String())
+	}
+}
+
+func TestStampClientTimestamps(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := &client.Client{ID: "svc-a"}
+	stampClientTimestamps(c, t0)
+	if !c.CreatedAt.Equal(t0) || !c.UpdatedAt.Equal(t0) {
+		t.Errorf("created_at=%v updated_at=%v, want both %v", c.CreatedAt, c.UpdatedAt, t0)
+	}
+}
+
+func TestUpsertClientSQLPreservesCreatedAt(t *testing.T) {
+	// On update created_at stays unchanged and updated_at advances
+	_, set, ok := strings.Cut(upsertClientSQL, "DO UPDATE SET ")
+	if !ok {
+		t.Fatalf("upsertClientSQL has no ON CONFLICT SET list:\n%s", upsertClientSQL)
+	}
+	set, _, _ = strings.Cut(set, "\n")
+	updates := strings.Split(set, ", ")
+	if slices.Contains(updates, "created_at = EXCLUDED.created_at") {
+		t.Errorf("SET list %q overwrites created_at", set)
+	}
+	if !slices.Contains(updates, "updated_at = EXCLUDED.updated_at") {
+		t.Errorf("SET list %q doesn't advance updated_at", set)
+	}
+}
+
+func TestBuildUpsertClientSQL(t *testing.T) {
+	got := buildUpsertClientSQL([]string{"client_name", "created_at", "id", "nid", "updated_at"})
+	want := "INSERT INTO hydra_client (client_name, created_at, id, nid, updated_at)\n" +
+		"VALUES (:client_name, :created_at, :id, :nid, :updated_at)\n" +
+		"ON CONFLICT (id, nid) DO UPDATE SET client_name = EXCLUDED.client_name, updated_at = EXCLUDED.updated_at\n" +
+		"RETURNING created_at, (xmax = 0) AS created"
+	if got != want {
+		t.Errorf("buildUpsertClientSQL() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Every column pop would write is bound, and the key is among them
+	cols := clientColumns()
+	if !slices.Contains(cols, "id") || !slices.Contains(cols, "nid") || !slices.Contains(cols, "client_secret") {
+		t.Errorf("clientColumns() = %v, want id, nid, and client_secret", cols)
+	}
+	if _, _, err := sqlx.Named(upsertClientSQL, &client.Client{ID: "svc-a"}); err != nil {
+		t.Errorf("binding a client: %v", err)
+	}
+}
+
+func TestClientHashColumnsExist(t *testing.T) {
+	cols := columns.ForStruct(&client.Client{}, "hydra_client", "id").Cols
+	for _, name := range clientHashColumns {
+		if _, ok := cols[name]; !ok {
+			t.Errorf("clientHashColumns selects %q, not a client.Client column", name)
+		}
+	}
+}
+
+func TestStampClientTimestampsKeepsImportedCreatedAt(t *testing.T) {
+	imported := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := &client.Client{ID: "svc-a", CreatedAt: imported}
+	stampClientTimestamps(c, time.Now())
+	if !c.CreatedAt.Equal(imported) {
+		t.Errorf("created_at = %v, want imported %v", c.CreatedAt, imported)
+	}
+}
+
+// poolTestDriver hands out no-op connections so sql.DB pooling can be observed
+type poolTestDriver struct{}
+
+type poolTestConn struct{}
+
+func (poolTestDriver) Open(string) (driver.Conn, error)       { return poolTestConn{}, nil }
+func (poolTestConn) Prepare(string) (driver.Stmt, error)      { return nil, errors.New("not supported") }
+func (poolTestConn) Close() error                             { return nil }
+func (poolTestConn) Begin() (driver.Tx, error)                { return nil, errors.New("not supported") }
+func (poolTestConn) ResetSession(context.Context) error       { return nil }
+func (poolTestConn) IsValid() bool                            { return true }
+func (poolTestConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func init() { sql.Register("pooltest", poolTestDriver{}) }
+
+func TestApplyPoolSettings(t *testing.T) {
+	db, err := sql.Open("pooltest", "")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	applyPoolSettings(db, StoreOptions{MaxOpenConns: 3, MaxIdleConns: 1, ConnMaxLifetime: time.Millisecond})
+
+	if got := db.Stats().MaxOpenConnections; got != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", got)
+	}
+
+	// Check out three connections, then return them: only one stays idle
+	ctx := context.Background()
+	var conns []*sql.Conn
+	for range 3 {
+		c, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("conn: %v", err)
+		}
+		conns = append(conns, c)
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	if stats := db.Stats(); stats.Idle != 1 || stats.MaxIdleClosed != 2 {
+		t.Errorf("idle = %d, max idle closed = %d, want 1 and 2", stats.Idle, stats.MaxIdleClosed)
+	}
+
+	// The idle connection outlives its lifetime and is replaced on next use
+	time.Sleep(5 * time.Millisecond)
+	c, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("conn: %v", err)
+	}
+	c.Close()
+	if got := db.Stats().MaxLifetimeClosed; got < 1 {
+		t.Errorf("MaxLifetimeClosed = %d, want the expired connection closed", got)
+	}
+}
+
+func TestPopStoreExposesConnPool(t *testing.T) {
+	conn, err := pop.NewConnection(&pop.ConnectionDetails{URL: "postgres://user@localhost/hydra", Driver: "pooltest"})
+	if err != nil {
+		t.Fatalf("new connection: %v", err)
+	}
+	if err := conn.Open(); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer conn.Close()
+
+	// openConnection only warns for a store without the setters, so check pop still has them
+	if _, ok := conn.Store.(connPool); !ok {
+		t.Errorf("pop store %T does not expose the *sql.DB pool settings", conn.Store)
+	}
+}
+
+// txTestDriver serves "SELECT ... id" queries with fixed IDs, recording each
+// query, its arguments, and whether it ran inside a transaction
+type txTestDriver struct {
+	mu      sync.Mutex
+	queries []txTestQuery
+}
+
+type txTestQuery struct {
+	sql  string
+	args []any
+	inTx bool
+}
+
+type txTestConn struct {
+	d    *txTestDriver
+	inTx bool
+}
+
+type txTestTx struct{ c *txTestConn }
+
+type txTestRows struct{ ids []string }
+
+var txTest = &txTestDriver{}
+
+func init() { sql.Register("txtest", txTest) }
+
+func (d *txTestDriver) Open(string) (driver.Conn, error) { return &txTestConn{d: d}, nil }
+
+func (c *txTestConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *txTestConn) Close() error                        { return nil }
+func (c *txTestConn) Begin() (driver.Tx, error)           { c.inTx = true; return txTestTx{c}, nil }
+
+func (c *txTestConn) QueryContext(_ context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	args := make([]any, len(named))
+	for i, v := range named {
+		args[i] = v.Value
+	}
+	c.d.mu.Lock()
+	c.d.queries = append(c.d.queries, txTestQuery{sql: query, args: args, inTx: c.inTx})
+	c.d.mu.Unlock()
+	return &txTestRows{ids: []string{"svc-a", "svc-b"}}, nil
+}
+
+func (t txTestTx) Commit() error   { t.c.inTx = false; return nil }
+func (t txTestTx) Rollback() error { t.c.inTx = false; return nil }
+
+func (r *txTestRows) Columns() []string { return []string{"id"} }
+func (r *txTestRows) Close() error      { return nil }
+func (r *txTestRows) Next(dest []driver.Value) error {
+	if len(r.ids) == 0 {
+		return io.EOF
+	}
+	dest[0], r.ids = r.ids[0], r.ids[1:]
+	return nil
+}
+
+func TestInTransactionReadsThroughTransaction(t *testing.T) {
+	conn, err := pop.NewConnection(&pop.ConnectionDetails{URL: "postgres://user@localhost/hydra", Driver: "txtest"})
+	if err != nil {
+		t.Fatalf("new connection: %v", err)
+	}
+	if err := conn.Open(); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer conn.Close()
+
+	// No replica is wired in, so a read that escapes the transaction panics
+	s := &Store{conn: conn}
+	var ids []string
+	err = s.inTransaction(func(w clientWriter) error {
+		ids, err = w.GetAllClientIDs(context.Background(), uuid.Nil)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("GetAllClientIDs in transaction: %v", err)
+	}
+	if want := []string{"svc-a", "svc-b"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+
+	txTest.mu.Lock()
+	defer txTest.mu.Unlock()
+	if len(txTest.queries) != 1 || !txTest.queries[0].inTx {
+		t.Errorf("queries = %+v, want one run inside the transaction", txTest.queries)
+	}
+}
+
+func TestInTransactionRedactsDatabaseURL(t *testing.T) {
+	conn, err := pop.NewConnection(&pop.ConnectionDetails{URL: "postgres://user@localhost/hydra", Driver: "txtest"})
+	if err != nil {
+		t.Fatalf("new connection: %v", err)
+	}
+	if err := conn.Open(); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer conn.Close()
+
+	const databaseURL = "postgres://hydra:s3cret-pw@db:5432/hydra"
+	s := &Store{conn: conn, databaseURL: databaseURL}
+	var sanitized error
+	err = s.inTransaction(func(w clientWriter) error {
+		sanitized = w.(*savepointWriter).sanitize(errors.New("dial " + databaseURL + ": refused"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("inTransaction: %v", err)
+	}
+	if strings.Contains(sanitized.Error(), "s3cret-pw") {
+		t.Errorf("error in transaction = %q, want the password redacted", sanitized)
+	}
+}
+
+func TestStoreQueriesSkipSoftDeletedClients(t *testing.T) {
+	conn, err := pop.NewConnection(&pop.ConnectionDetails{URL: "postgres://user@localhost/hydra", Driver: "txtest"})
+	if err != nil {
+		t.Fatalf("new connection: %v", err)
+	}
+	if err := conn.Open(); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer conn.Close()
+
+	ctx := context.Background()
+	queries := map[string]func(s *Store) error{
+		"GetClientsMissingMetadata": func(s *Store) error {
+			_, err := s.GetClientsMissingMetadata(ctx, uuid.Nil, []string{"org_id"})
+			return err
+		},
+		"SearchClientsByMetadata": func(s *Store) error {
+			_, err := s.SearchClientsByMetadata(ctx, uuid.Nil, map[string]string{"tier": "pro"}, "", 10)
+			return err
+		},
+		"GetCrossNetworkClientIDs": func(s *Store) error {
+			_, err := s.GetCrossNetworkClientIDs(ctx)
+			return err
+		},
+		"GetRecentClientInfo": func(s *Store) error {
+			_, err := s.GetRecentClientInfo(ctx, uuid.Nil, 10, false)
+			return err
+		},
+		"GetRecentClientInfo by usage": func(s *Store) error {
+			_, err := s.GetRecentClientInfo(ctx, uuid.Nil, 10, true)
+			return err
+		},
+	}
+	for name, query := range queries {
+		for _, softDelete := range []bool{false, true} {
+			txTest.mu.Lock()
+			txTest.queries = nil
+			txTest.mu.Unlock()
+
+			s := &Store{conn: conn, read: conn, opts: StoreOptions{SoftDelete: softDelete}}
+			if err := query(s); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+
+			txTest.mu.Lock()
+			q := txTest.queries[len(txTest.queries)-1]
+			txTest.mu.Unlock()
+			filtered := strings.Contains(q.sql, "metadata::jsonb ->> $") && slices.Contains(q.args, any(softDeletedAtKey))
+			if filtered != softDelete {
+				t.Errorf("%s with SoftDelete=%t: query %q args %v, filtered = %t", name, softDelete, q.sql, q.args, filtered)
+			}
+		}
+	}
+}
+
+func TestMissingClientIDs(t *testing.T) {
+	hashes := map[string]string{"svc-a": "hash-a", "svc-c": ""}
+	got := missingClientIDs([]string{"svc-a", "svc-b", "svc-c", "svc-d"}, hashes)
+	if want := []string{"svc-b", "svc-d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missingClientIDs() = %v, want %v", got, want)
+	}
+	if got := missingClientIDs([]string{"svc-a"}, hashes); got != nil {
+		t.Errorf("missingClientIDs() = %v, want nil", got)
+	}
+}
+
+func TestIterateClientPages(t *testing.T) {
+	db := &fakeExporter{clients: exportFixture(4)}
+	var pages [][]client.Client
+	err := iterateClientPages(context.Background(), db, uuid.Nil, 2, func(page []client.Client) error {
+		pages = append(pages, page)
+		return nil
+	})
+	// An exact multiple of the page size needs one more, empty, page to end
+	if err != nil || len(pages) != 2 || db.calls != 3 || pages[1][1].ID != "svc-0003" {
+		t.Errorf("err = %v, %d pages in %d queries, want 2 pages in 3 queries", err, len(pages), db.calls)
+	}
+
+	stop := errors.New("stop")
+	db = &fakeExporter{clients: exportFixture(5)}
+	err = iterateClientPages(context.Background(), db, uuid.Nil, 2, func([]client.Client) error { return stop })
+	if !errors.Is(err, stop) || db.calls != 1 {
+		t.Errorf("err = %v after %d queries, want fn's error after the first page", err, db.calls)
+	}
+
+	db = &fakeExporter{clients: exportFixture(5), failAt: 2}
+	err = iterateClientPages(context.Background(), db, uuid.Nil, 2, func([]client.Client) error { return nil })
+	if err == nil {
+		t.Error("store failure on the second page not returned")
+	}
+}
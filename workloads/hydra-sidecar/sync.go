@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ory/hydra/v2/client"
+)
+
+// Overall sync status values for SyncResult.Status
+const (
+	syncStatusSuccess = "success"
+	syncStatusPartial = "partial"
+	syncStatusFailed  = "failed"
+	// Atomic sync exceeded its failure threshold and nothing was applied
+	syncStatusRolledBack = "rolled_back"
+)
+
+// Sync modes for SyncOptions.Mode (?mode=)
+const (
+	// Full reconciliation: upsert the given clients, delete all others
+	syncModeFull = "full"
+	// Additive: upsert the given clients, never delete
+	syncModeUpsert = "upsert"
+)
+
+// Handling of repeated client IDs in a sync request (?duplicates=)
+const (
+	// Reject the request when repeats of a client_id differ
+	syncDuplicatesReject = "reject"
+	// Keep the last entry for each client_id and log a warning
+	syncDuplicatesLastWins = "last_wins"
+)
+
+// parseSyncDuplicates reads a ?duplicates= value, reporting whether repeats
+// of a client_id keep the last entry
+func parseSyncDuplicates(value string) (lastWins bool, err error) {
+	switch value {
+	case "", syncDuplicatesReject:
+		return false, nil
+	case syncDuplicatesLastWins:
+		return true, nil
+	}
+	return false, fmt.Errorf("duplicates must be %q or %q", syncDuplicatesReject, syncDuplicatesLastWins)
+}
+
+// SyncOptions controls how SyncClients applies a batch
+type SyncOptions struct {
+	// Mode is syncModeFull (the default when empty) or syncModeUpsert
+	Mode string
+	// Atomic applies the batch in one transaction that is rolled back if any
+	// delete fails or more than MaxFailures operations fail. When false,
+	// operations are applied best-effort.
+	Atomic bool
+	// MaxFailures is the number of failed upserts tolerated in atomic mode
+	MaxFailures int
+	// Concurrency is the number of clients upserted or deleted in parallel
+	// (values below 1 mean serial). Atomic syncs always run serially since a
+	// transaction is a single connection.
+	Concurrency int
+	// MaxDeleteRatio refuses a full sync that would delete more than this
+	// fraction of the network's existing clients (0 = no limit)
+	MaxDeleteRatio float64
+	// Force applies the sync even past MaxDeleteRatio
+	Force bool
+	// Progress, when set, is called with each client's result as soon as its
+	// upsert or delete finishes, so completion order rather than request
+	// order. Calls never overlap. In an atomic sync the results may still be
+	// rolled back.
+	Progress func(SyncProgress)
+}
+
+// dedupeSyncClients collapses request entries sharing a client_id, keeping
+// each ID at its first position. Identical repeats are dropped. Repeats whose
+// data differs are reported in conflicts; with lastWins the last entry's data
+// is kept, otherwise the caller should reject the request.
+func dedupeSyncClients(clients []ClientData, lastWins bool) (deduped []ClientData, conflicts []string) {
+	index := make(map[string]int, len(clients))
+	encoded := make(map[string][]byte, len(clients))
+	conflicted := make(map[string]bool)
+	deduped = make([]ClientData, 0, len(clients))
+	for _, c := range clients {
+		data, _ := json.Marshal(c)
+		i, seen := index[c.ID]
+		if !seen {
+			index[c.ID] = len(deduped)
+			encoded[c.ID] = data
+			deduped = append(deduped, c)
+			continue
+		}
+		if bytes.Equal(encoded[c.ID], data) {
+			continue
+		}
+		if !conflicted[c.ID] {
+			conflicted[c.ID] = true
+			conflicts = append(conflicts, c.ID)
+		}
+		if lastWins {
+			encoded[c.ID] = data
+			deduped[i] = c
+		}
+	}
+	return deduped, conflicts
+}
+
+// validateSyncClients checks a whole sync batch and returns the clients to
+// sync, the conflicting repeats, and every failure in request order, so a
+// caller can fix them all in one pass. Failures are entries without a
+// client_id, repeats of a client_id with different data (unless lastWins keeps
+// the last of each), and client_secret_hash values that don't match their
+// hash_algorithm (HASHER_ALGORITHM when unset).
+func (s *Server) validateSyncClients(clients []ClientData, lastWins bool) (deduped []ClientData, conflicts []string, failures []SyncClientError) {
+	// Entries without an ID can't be deduplicated, so they're checked on their own
+	withID := make([]ClientData, 0, len(clients))
+	for _, c := range clients {
+		if c.ID != "" {
+			withID = append(withID, c)
+		}
+	}
+	// Repeated client IDs would otherwise be upserted twice, leaving whichever came last
+	deduped, conflicts = dedupeSyncClients(withID, lastWins)
+
+	conflicted := make(map[string]bool, len(conflicts))
+	if !lastWins {
+		for _, id := range conflicts {
+			conflicted[id] = true
+		}
+	}
+	kept := make(map[string]ClientData, len(deduped))
+	for _, c := range deduped {
+		kept[c.ID] = c
+	}
+
+	for i, c := range clients {
+		if c.ID == "" {
+			failures = append(failures, SyncClientError{Error: fmt.Sprintf("clients[%d]: client_id is required", i)})
+			if err := s.validateClientHash(c); err != nil {
+				failures = append(failures, SyncClientError{Error: fmt.Sprintf("clients[%d]: %v", i, err)})
+			}
+			continue
+		}
+		// Each ID is checked once, at its first entry, against the entry kept
+		entry, first := kept[c.ID]
+		if !first {
+			continue
+		}
+		delete(kept, c.ID)
+		c = entry
+
+		if conflicted[c.ID] {
+			failures = append(failures, SyncClientError{ClientID: c.ID, Error: fmt.Sprintf(
+				"conflicting entries in clients array; send one entry per client_id or use ?duplicates=%s", syncDuplicatesLastWins)})
+		}
+		// client_secret would hold a plaintext secret (it's shown once at
+		// creation); sync stores client_secret_hash, so it's ignored
+		if c.Secret != "" {
+			log.Printf("Warning: client %s has client_secret populated in sync request, ignoring (use client_secret_hash)", c.ID)
+		}
+		if err := s.validateClientHash(c); err != nil {
+			failures = append(failures, SyncClientError{ClientID: c.ID, Error: err.Error()})
+		}
+	}
+	return deduped, conflicts, failures
+}
+
+// validateClientHash checks a sync client's hash against the hash_algorithm
+// it declares, or HASHER_ALGORITHM when it declares none, so a batch can mix
+// formats during a hasher migration
+func (s *Server) validateClientHash(c ClientData) error {
+	switch c.HashAlgorithm {
+	case "":
+		return s.validateHash(c.ClientSecretHash)
+	case "pbkdf2", "bcrypt":
+		return s.validateHashAs(c.ClientSecretHash, c.HashAlgorithm)
+	}
+	return fmt.Errorf("unknown hash_algorithm %q, want pbkdf2 or bcrypt", c.HashAlgorithm)
+}
+
+// exceedsDeleteRatio reports whether deleting n of existing clients needs Force
+func (o SyncOptions) exceedsDeleteRatio(n, existing int) bool {
+	if o.Force || o.MaxDeleteRatio <= 0 || n == 0 {
+		return false
+	}
+	return float64(n) > o.MaxDeleteRatio*float64(existing)
+}
+
+// deleteRatioError refuses a full sync past SyncOptions.MaxDeleteRatio
+// before anything is written
+type deleteRatioError struct {
+	// ClientIDs would have been deleted
+	ClientIDs []string
+	Existing  int
+	MaxRatio  float64
+}
+
+func (e *deleteRatioError) Error() string {
+	return fmt.Sprintf("sync would delete %d of %d existing clients, more than MAX_SYNC_DELETE_RATIO %g allows; retry with ?force=true if intended",
+		len(e.ClientIDs), e.Existing, e.MaxRatio)
+}
+
+// shouldRollback reports whether an atomic batch must be discarded
+func (o SyncOptions) shouldRollback(r *SyncResult) bool {
+	if r.FailedCount > o.MaxFailures {
+		return true
+	}
+	for _, cr := range r.Results {
+		if cr.Operation == syncOpDelete && cr.Status == "failed" {
+			return true
+		}
+	}
+	return false
+}
+
+// errSyncInterrupted stops a sync at a phase boundary once its context is
+// cancelled, so shutdown never cuts a phase short
+var errSyncInterrupted = errors.New("sync interrupted")
+
+// checkpoint returns errSyncInterrupted if ctx is done, before starting phase
+func checkpoint(ctx context.Context, phase string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w before the %s phase: %v", errSyncInterrupted, phase, err)
+	}
+	return nil
+}
+
+// errSyncRolledBack aborts the sync transaction after a failed batch
+var errSyncRolledBack = errors.New("sync rolled back")
+
+// txRunner runs fn against a transactional clientWriter, committing if fn
+// returns nil and rolling back otherwise
+type txRunner func(fn func(w clientWriter) error) error
+
+// clientWriter is the subset of Store used by reconciliation
+type clientWriter interface {
+	GetAllClientIDs(ctx context.Context, nid uuid.UUID) ([]string, error)
+	GetClientSecretHashes(ctx context.Context, nid uuid.UUID) (map[string]string, error)
+	UpsertClient(ctx context.Context, c *client.Client) (created bool, err error)
+	DeleteClient(ctx context.Context, clientID string, nid uuid.UUID) error
+}
+
+// syncClients reconciles the network's clients against the desired set:
+// upserts every given client, then (unless opts.Mode is syncModeUpsert)
+// deletes clients not in the set.
+// A failure in either phase is recorded per client and never discards the
+// results of operations that already succeeded.
+func syncClients(ctx context.Context, w clientWriter, clients []client.Client, nid uuid.UUID, opts SyncOptions) (*SyncResult, error) {
+	result := &SyncResult{
+		Results: make([]ClientResult, 0),
+	}
+
+	// 1. Get all existing client IDs
+	existingIDs, err := w.GetAllClientIDs(ctx, nid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing clients: %w", err)
+	}
+
+	// Stored hashes, to detect a hash algorithm change for existing clients
+	storedHashes, err := w.GetClientSecretHashes(ctx, nid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing client secrets: %w", err)
+	}
+
+	// 2. Track which IDs are in the sync request. Repeats of an ID are
+	// upserted in order by one worker so they can't race each other.
+	syncedIDs := make(map[string]bool)
+	byID := make(map[string][]int)
+	var ids []string
+	for i, c := range clients {
+		if !syncedIDs[c.ID] {
+			syncedIDs[c.ID] = true
+			ids = append(ids, c.ID)
+		}
+		byID[c.ID] = append(byID[c.ID], i)
+	}
+
+	// Clients a full sync would delete, checked against opts.MaxDeleteRatio
+	// so a truncated request can't wipe the network
+	var stale []string
+	if opts.Mode != syncModeUpsert {
+		for _, id := range existingIDs {
+			if !syncedIDs[id] {
+				stale = append(stale, id)
+			}
+		}
+		if opts.exceedsDeleteRatio(len(stale), len(existingIDs)) {
+			return nil, &deleteRatioError{ClientIDs: stale, Existing: len(existingIDs), MaxRatio: opts.MaxDeleteRatio}
+		}
+	}
+
+	// Each result goes to opts.Progress as it finishes, one call at a time
+	var progressMu sync.Mutex
+	processed, total := 0, len(clients)+len(stale)
+	report := func(r ClientResult) {
+		if opts.Progress == nil {
+			return
+		}
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		processed++
+		opts.Progress(SyncProgress{ClientResult: r, Processed: processed, Total: total})
+	}
+
+	// 3. Upsert each client, up to opts.Concurrency at a time. Results land in
+	// the client's own slot, so they keep the request order.
+	if err := checkpoint(ctx, syncOpUpsert); err != nil {
+		return nil, err
+	}
+	upserts := make([]ClientResult, len(clients))
+	forEachBounded(len(ids), opts.Concurrency, func(n int) {
+		for _, i := range byID[ids[n]] {
+			c := clients[i]
+			c.NID = nid
+			created, err := w.UpsertClient(ctx, &c)
+			upserts[i] = upsertResult(c, created, storedHashes[c.ID], err)
+			report(upserts[i])
+		}
+	})
+	for _, r := range upserts {
+		result.add(r)
+	}
+
+	// 4. Delete clients not in sync request (additive syncs stop here)
+	if opts.Mode == syncModeUpsert {
+		result.Status = result.overallStatus()
+		return result, nil
+	}
+	if err := checkpoint(ctx, syncOpDelete); err != nil {
+		// The upserts stay applied; report them with the interruption
+		result.Status = syncStatusPartial
+		return result, err
+	}
+	deletes := make([]ClientResult, len(stale))
+	forEachBounded(len(stale), opts.Concurrency, func(i int) {
+		deletes[i] = ClientResult{ClientID: stale[i], Operation: syncOpDelete, Status: "deleted"}
+		if err := w.DeleteClient(ctx, stale[i], nid); err != nil {
+			deletes[i] = syncFailure(stale[i], syncOpDelete, err)
+		}
+		report(deletes[i])
+	})
+	for _, r := range deletes {
+		result.add(r)
+	}
+
+	result.Status = result.overallStatus()
+	return result, nil
+}
+
+// forEachBounded calls fn(0..n-1) on at most limit goroutines and waits for
+// all calls to return. A limit below 2 runs the calls serially in order.
+func forEachBounded(n, limit int, fn func(i int)) {
+	if limit < 2 || n < 2 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(limit, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+}
+
+// syncClientsAtomic runs syncClients inside a transaction and rolls the whole
+// batch back when opts.shouldRollback says so. The returned result still lists
+// every per-client outcome so callers can see why the batch was discarded.
+func syncClientsAtomic(ctx context.Context, runTx txRunner, clients []client.Client, nid uuid.UUID, opts SyncOptions) (*SyncResult, error) {
+	// A transaction is one connection, so its statements can't run in parallel
+	opts.Concurrency = 1
+
+	var result *SyncResult
+	err := runTx(func(w clientWriter) error {
+		var err error
+		result, err = syncClients(ctx, w, clients, nid, opts)
+		if err != nil {
+			return err
+		}
+		if opts.shouldRollback(result) {
+			return errSyncRolledBack
+		}
+		return nil
+	})
+	if errors.Is(err, errSyncRolledBack) {
+		result.RolledBack = true
+		result.Status = syncStatusRolledBack
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// timedSync runs a sync and records its wall time and throughput on the result
+func timedSync(run func() (*SyncResult, error)) (*SyncResult, error) {
+	start := time.Now()
+	result, err := run()
+	if result != nil {
+		result.setDuration(time.Since(start))
+	}
+	return result, err
+}
+
+// setDuration sets DurationMS and ClientsPerSecond from the sync's wall time
+func (r *SyncResult) setDuration(d time.Duration) {
+	r.DurationMS = float64(d) / float64(time.Millisecond)
+	if d > 0 {
+		r.ClientsPerSecond = float64(len(r.Results)) / d.Seconds()
+	}
+}
+
+// Sync operations recorded in ClientResult.Operation
+const (
+	syncOpUpsert = "upsert"
+	syncOpDelete = "delete"
+)
+
+// upsertResult is the result of upserting c. The write itself says whether it
+// created the row, which stays right when another sync creates the client
+// after the existing IDs were read.
+func upsertResult(c client.Client, created bool, storedHash string, err error) ClientResult {
+	switch {
+	case err != nil:
+		return syncFailure(c.ID, syncOpUpsert, err)
+	case created:
+		return ClientResult{ClientID: c.ID, Operation: syncOpUpsert, Status: "created"}
+	}
+	drift := hashAlgorithmChanged(storedHash, c.Secret)
+	if drift {
+		log.Printf("Warning: client %s hash algorithm changed from %s to %s during sync",
+			c.ID, hashAlgorithm(storedHash), hashAlgorithm(c.Secret))
+	}
+	return ClientResult{ClientID: c.ID, Operation: syncOpUpsert, Status: "updated", HashAlgorithmChanged: drift}
+}
+
+// syncFailure is the result of a failed per-client operation
+func syncFailure(clientID, operation string, err error) ClientResult {
+	errStr := err.Error()
+	return ClientResult{
+		ClientID:  clientID,
+		Operation: operation,
+		Status:    "failed",
+		Error:     &errStr,
+	}
+}
+
+// add records a per-client result and counts it by status
+func (r *SyncResult) add(res ClientResult) {
+	r.Results = append(r.Results, res)
+	switch res.Status {
+	case "created":
+		r.CreatedCount++
+	case "updated":
+		r.UpdatedCount++
+	case "deleted":
+		r.DeletedCount++
+	case "failed":
+		r.FailedCount++
+	}
+}
+
+// overallStatus summarizes the result: success when nothing failed, failed
+// when nothing succeeded, partial otherwise
+func (r *SyncResult) overallStatus() string {
+	succeeded := r.CreatedCount + r.UpdatedCount + r.DeletedCount
+	switch {
+	case r.FailedCount == 0:
+		return syncStatusSuccess
+	case succeeded == 0:
+		return syncStatusFailed
+	default:
+		return syncStatusPartial
+	}
+}
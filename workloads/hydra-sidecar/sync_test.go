@@ -0,0 +1,670 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ory/hydra/v2/client"
+)
+
+// fakeClientWriter is an in-memory clientWriter with injectable failures
+type fakeClientWriter struct {
+	mu         sync.Mutex
+	clients    map[string]client.Client
+	failUpsert map[string]bool
+	failDelete map[string]bool
+}
+
+func newFakeClientWriter(ids ...string) *fakeClientWriter {
+	f := &fakeClientWriter{
+		clients:    make(map[string]client.Client),
+		failUpsert: make(map[string]bool),
+		failDelete: make(map[string]bool),
+	}
+	for _, id := range ids {
+		f.clients[id] = client.Client{ID: id}
+	}
+	return f
+}
+
+func (f *fakeClientWriter) GetAllClientIDs(context.Context, uuid.UUID) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, 0, len(f.clients))
+	for id := range f.clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (f *fakeClientWriter) GetClientSecretHashes(context.Context, uuid.UUID) (map[string]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	hashes := make(map[string]string, len(f.clients))
+	for id, c := range f.clients {
+		hashes[id] = c.Secret
+	}
+	return hashes, nil
+}
+
+func (f *fakeClientWriter) UpsertClient(_ context.Context, c *client.Client) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failUpsert[c.ID] {
+		return false, errors.New("upsert failed")
+	}
+	_, existed := f.clients[c.ID]
+	f.clients[c.ID] = *c
+	return !existed, nil
+}
+
+func (f *fakeClientWriter) DeleteClient(_ context.Context, id string, _ uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failDelete[id] {
+		return errors.New("delete failed")
+	}
+	delete(f.clients, id)
+	return nil
+}
+
+func findResult(results []ClientResult, id string) (ClientResult, bool) {
+	for _, r := range results {
+		if r.ClientID == id {
+			return r, true
+		}
+	}
+	return ClientResult{}, false
+}
+
+func TestSyncClientsDeleteFailureKeepsUpsertResults(t *testing.T) {
+	w := newFakeClientWriter("existing", "stale-ok", "stale-bad")
+	w.failDelete["stale-bad"] = true
+
+	desired := []client.Client{{ID: "existing"}, {ID: "new"}}
+	result, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{})
+	if err != nil {
+		t.Fatalf("syncClients() error = %v", err)
+	}
+
+	if result.CreatedCount != 1 || result.UpdatedCount != 1 || result.DeletedCount != 1 || result.FailedCount != 1 {
+		t.Errorf("counts = created %d, updated %d, deleted %d, failed %d; want 1,1,1,1",
+			result.CreatedCount, result.UpdatedCount, result.DeletedCount, result.FailedCount)
+	}
+	if result.Status != syncStatusPartial {
+		t.Errorf("status = %q, want %q", result.Status, syncStatusPartial)
+	}
+
+	for id, want := range map[string]string{"existing": "updated", "new": "created", "stale-ok": "deleted"} {
+		if r, ok := findResult(result.Results, id); !ok || r.Status != want {
+			t.Errorf("result for %s = %+v, want status %q", id, r, want)
+		}
+	}
+
+	failed, ok := findResult(result.Results, "stale-bad")
+	if !ok || failed.Status != "failed" || failed.Operation != syncOpDelete || failed.Error == nil {
+		t.Errorf("result for stale-bad = %+v, want failed delete with error", failed)
+	}
+}
+
+func TestSyncClientsOverallStatus(t *testing.T) {
+	w := newFakeClientWriter()
+	result, _ := syncClients(context.Background(), w, []client.Client{{ID: "a"}}, uuid.Nil, SyncOptions{})
+	if result.Status != syncStatusSuccess {
+		t.Errorf("status = %q, want %q", result.Status, syncStatusSuccess)
+	}
+
+	w = newFakeClientWriter()
+	w.failUpsert["a"] = true
+	result, _ = syncClients(context.Background(), w, []client.Client{{ID: "a"}}, uuid.Nil, SyncOptions{})
+	if result.Status != syncStatusFailed {
+		t.Errorf("status = %q, want %q", result.Status, syncStatusFailed)
+	}
+}
+
+// tx runs fn against the fake and restores the previous clients if fn fails
+func (f *fakeClientWriter) tx(fn func(w clientWriter) error) error {
+	f.mu.Lock()
+	snapshot := make(map[string]client.Client, len(f.clients))
+	for id, c := range f.clients {
+		snapshot[id] = c
+	}
+	f.mu.Unlock()
+
+	if err := fn(f); err != nil {
+		f.mu.Lock()
+		f.clients = snapshot
+		f.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func TestSyncClientsAtomicRollsBackOnDeleteFailure(t *testing.T) {
+	w := newFakeClientWriter("existing", "stale-bad")
+	w.failDelete["stale-bad"] = true
+
+	desired := []client.Client{{ID: "existing"}, {ID: "new"}}
+	result, err := syncClientsAtomic(context.Background(), w.tx, desired, uuid.Nil, SyncOptions{Atomic: true, MaxFailures: 5})
+	if err != nil {
+		t.Fatalf("syncClientsAtomic() error = %v", err)
+	}
+
+	if !result.RolledBack || result.Status != syncStatusRolledBack {
+		t.Errorf("result = rolled_back %t, status %q; want rolled back", result.RolledBack, result.Status)
+	}
+	if _, ok := w.clients["new"]; ok {
+		t.Error("created client survived rollback")
+	}
+	if len(w.clients) != 2 {
+		t.Errorf("clients after rollback = %d, want 2 (pre-sync state)", len(w.clients))
+	}
+}
+
+func TestSyncClientsAtomicFailureThreshold(t *testing.T) {
+	desired := []client.Client{{ID: "a"}, {ID: "b"}}
+
+	w := newFakeClientWriter()
+	w.failUpsert["b"] = true
+	result, err := syncClientsAtomic(context.Background(), w.tx, desired, uuid.Nil, SyncOptions{Atomic: true, MaxFailures: 1})
+	if err != nil {
+		t.Fatalf("syncClientsAtomic() error = %v", err)
+	}
+	if result.RolledBack || result.Status != syncStatusPartial {
+		t.Errorf("within threshold: rolled_back %t, status %q; want committed partial", result.RolledBack, result.Status)
+	}
+	if _, ok := w.clients["a"]; !ok {
+		t.Error("successful upsert not committed within threshold")
+	}
+
+	w = newFakeClientWriter()
+	w.failUpsert["b"] = true
+	result, _ = syncClientsAtomic(context.Background(), w.tx, desired, uuid.Nil, SyncOptions{Atomic: true})
+	if !result.RolledBack {
+		t.Error("failure over threshold did not roll back")
+	}
+	if len(w.clients) != 0 {
+		t.Errorf("clients after rollback = %d, want 0", len(w.clients))
+	}
+}
+
+func TestSyncClientsReportsHashAlgorithmChange(t *testing.T) {
+	const bcryptHash = "$2a$10$abcdefghijklmnopqrstuv"
+	const pbkdf2Hash = "$pbkdf2-sha256$i=10000,l=32$c2FsdA$ZGlnZXN0"
+
+	w := newFakeClientWriter()
+	w.clients["drifted"] = client.Client{ID: "drifted", Secret: bcryptHash}
+	w.clients["same"] = client.Client{ID: "same", Secret: pbkdf2Hash}
+
+	desired := []client.Client{
+		{ID: "drifted", Secret: pbkdf2Hash},
+		{ID: "same", Secret: pbkdf2Hash},
+		{ID: "new", Secret: pbkdf2Hash},
+	}
+	result, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{})
+	if err != nil {
+		t.Fatalf("syncClients() error = %v", err)
+	}
+
+	if r, _ := findResult(result.Results, "drifted"); !r.HashAlgorithmChanged || r.Status != "updated" {
+		t.Errorf("result for drifted = %+v, want updated with hash_algorithm_changed", r)
+	}
+	for _, id := range []string{"same", "new"} {
+		if r, _ := findResult(result.Results, id); r.HashAlgorithmChanged {
+			t.Errorf("result for %s flagged hash_algorithm_changed", id)
+		}
+	}
+}
+
+// racingClientWriter has another sync create raced before each upsert, after
+// the existing IDs were read
+type racingClientWriter struct {
+	*fakeClientWriter
+	raced string
+}
+
+func (r *racingClientWriter) UpsertClient(ctx context.Context, c *client.Client) (bool, error) {
+	r.mu.Lock()
+	if _, ok := r.clients[r.raced]; !ok {
+		r.clients[r.raced] = client.Client{ID: r.raced}
+	}
+	r.mu.Unlock()
+	return r.fakeClientWriter.UpsertClient(ctx, c)
+}
+
+func TestSyncClientsReportsWhatTheUpsertDid(t *testing.T) {
+	w := &racingClientWriter{fakeClientWriter: newFakeClientWriter(), raced: "raced"}
+
+	desired := []client.Client{{ID: "raced"}, {ID: "new"}}
+	result, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{Mode: syncModeUpsert})
+	if err != nil {
+		t.Fatalf("syncClients() error = %v", err)
+	}
+	if result.CreatedCount != 1 || result.UpdatedCount != 1 || result.FailedCount != 0 {
+		t.Errorf("counts = created %d, updated %d, failed %d; want 1,1,0",
+			result.CreatedCount, result.UpdatedCount, result.FailedCount)
+	}
+	if r, _ := findResult(result.Results, "raced"); r.Status != "updated" {
+		t.Errorf("raced client status = %q, want updated", r.Status)
+	}
+}
+
+func TestSyncClientsReportsProgress(t *testing.T) {
+	w := &slowClientWriter{fakeClientWriter: newFakeClientWriter("existing", "stale-a", "stale-b")}
+	w.failUpsert["bad"] = true
+
+	var events []SyncProgress
+	desired := []client.Client{{ID: "existing"}, {ID: "new"}, {ID: "bad"}}
+	result, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{
+		Concurrency: 4,
+		Progress:    func(p SyncProgress) { events = append(events, p) },
+	})
+	if err != nil {
+		t.Fatalf("syncClients() error = %v", err)
+	}
+
+	if len(events) != len(result.Results) {
+		t.Fatalf("%d progress events, want one per result (%d)", len(events), len(result.Results))
+	}
+	statuses := map[string]string{}
+	for i, p := range events {
+		if p.Processed != i+1 || p.Total != 5 {
+			t.Errorf("event %d: processed %d of %d, want %d of 5", i, p.Processed, p.Total, i+1)
+		}
+		statuses[p.ClientID] = p.Status
+	}
+	for id, want := range map[string]string{"existing": "updated", "new": "created", "bad": "failed", "stale-a": "deleted", "stale-b": "deleted"} {
+		if statuses[id] != want {
+			t.Errorf("progress for %s = %q, want %q", id, statuses[id], want)
+		}
+	}
+	// Deletes start only after every upsert was reported
+	for _, p := range events[:3] {
+		if p.Operation != syncOpUpsert {
+			t.Errorf("early event %+v, want the upserts first", p)
+		}
+	}
+}
+
+func TestSyncClientsUpsertModeNeverDeletes(t *testing.T) {
+	w := newFakeClientWriter("existing", "other-a", "other-b")
+
+	desired := []client.Client{{ID: "existing"}, {ID: "new"}}
+	result, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{Mode: syncModeUpsert})
+	if err != nil {
+		t.Fatalf("syncClients() error = %v", err)
+	}
+
+	if result.CreatedCount != 1 || result.UpdatedCount != 1 || result.DeletedCount != 0 {
+		t.Errorf("counts = created %d, updated %d, deleted %d; want 1,1,0",
+			result.CreatedCount, result.UpdatedCount, result.DeletedCount)
+	}
+	for _, id := range []string{"existing", "new", "other-a", "other-b"} {
+		if _, ok := w.clients[id]; !ok {
+			t.Errorf("client %s missing after upsert sync", id)
+		}
+	}
+	for _, r := range result.Results {
+		if r.Operation == syncOpDelete {
+			t.Errorf("unexpected delete result %+v", r)
+		}
+	}
+}
+
+func TestSyncClientsRefusesDeletesPastRatio(t *testing.T) {
+	w := newFakeClientWriter("keep", "svc-b", "svc-c", "svc-d")
+	desired := []client.Client{{ID: "keep"}, {ID: "new"}}
+
+	_, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{MaxDeleteRatio: 0.5})
+	var refused *deleteRatioError
+	if !errors.As(err, &refused) {
+		t.Fatalf("syncClients() error = %v, want a deleteRatioError", err)
+	}
+	if got := strings.Join(refused.ClientIDs, ","); got != "svc-b,svc-c,svc-d" || refused.Existing != 4 {
+		t.Errorf("refused = %v of %d, want svc-b,svc-c,svc-d of 4", refused.ClientIDs, refused.Existing)
+	}
+	if len(w.clients) != 4 {
+		t.Errorf("clients after refused sync = %d, want the 4 untouched", len(w.clients))
+	}
+	if _, ok := w.clients["new"]; ok {
+		t.Error("refused sync still upserted")
+	}
+
+	for _, opts := range []SyncOptions{
+		{MaxDeleteRatio: 0.75},
+		{MaxDeleteRatio: 0.5, Force: true},
+		{MaxDeleteRatio: 0.5, Mode: syncModeUpsert},
+	} {
+		w := newFakeClientWriter("keep", "svc-b", "svc-c", "svc-d")
+		if _, err := syncClients(context.Background(), w, desired, uuid.Nil, opts); err != nil {
+			t.Errorf("syncClients(%+v) error = %v, want the sync applied", opts, err)
+		}
+	}
+}
+
+func TestWriteSyncDeleteRefused(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeSyncDeleteRefused(rec, &deleteRatioError{ClientIDs: []string{"svc-b"}, Existing: 1, MaxRatio: 0.5})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", rec.Code)
+	}
+	var body SyncDeleteRefusedError
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error != errCodeConflict || len(body.ClientIDs) != 1 || body.ClientIDs[0] != "svc-b" || body.ExistingCount != 1 {
+		t.Errorf("body = %+v, want conflict listing svc-b of 1", body)
+	}
+}
+
+func TestHandleSyncClientsRejectsUnknownMode(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&Server{}).handleSyncClients(rec, httptest.NewRequest(http.MethodPost, "/sync/clients?mode=merge", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleSyncClientsUpsertForcesUpsertMode(t *testing.T) {
+	s := &Server{}
+	tests := []struct {
+		target  string
+		wantMsg string
+	}{
+		// Reaches the sync handler with mode=upsert, which then finds no clients
+		{"/sync/clients/upsert", "clients array is empty"},
+		{"/sync/clients/upsert?mode=upsert", "clients array is empty"},
+		{"/sync/clients/upsert?mode=full", "on /sync/clients/upsert"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.handleSyncClientsUpsert(rec, httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(`{"clients":[]}`)))
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), tt.wantMsg) {
+			t.Errorf("%s: got %d %s, want 400 %q", tt.target, rec.Code, rec.Body, tt.wantMsg)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleSyncClientsUpsert(rec, httptest.NewRequest(http.MethodGet, "/sync/clients/upsert", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestDedupeSyncClients(t *testing.T) {
+	entry := func(id, hash string) ClientData {
+		return ClientData{Client: client.Client{ID: id}, ClientSecretHash: hash}
+	}
+	clients := []ClientData{entry("a", "$2a$first"), entry("b", "$2a$b"), entry("b", "$2a$b"), entry("a", "$2a$second")}
+
+	deduped, conflicts := dedupeSyncClients(clients, false)
+	if len(conflicts) != 1 || conflicts[0] != "a" {
+		t.Errorf("conflicts = %v, want [a] (identical b repeats are not a conflict)", conflicts)
+	}
+	if len(deduped) != 2 || deduped[0].ClientSecretHash != "$2a$first" {
+		t.Errorf("deduped = %+v, want a then b, keeping the first a", deduped)
+	}
+
+	deduped, conflicts = dedupeSyncClients(clients, true)
+	if len(conflicts) != 1 || len(deduped) != 2 || deduped[0].ID != "a" || deduped[0].ClientSecretHash != "$2a$second" {
+		t.Errorf("last wins: deduped = %+v, conflicts = %v, want a with the second secret first", deduped, conflicts)
+	}
+}
+
+func TestHandleSyncClientsRejectsConflictingDuplicates(t *testing.T) {
+	body := strings.NewReplacer("$first", syncTestBcrypt+"1", "$b", syncTestBcrypt+"2", "$second", syncTestBcrypt+"3").Replace(
+		`{"clients":[{"client_id":"a","client_secret_hash":"$first"},{"client_id":"b","client_secret_hash":"$b"},{"client_id":"a","client_secret_hash":"$second"}]}`)
+	rec := httptest.NewRecorder()
+	(&Server{hasherAlgorithm: "bcrypt"}).handleSyncClients(rec, httptest.NewRequest(http.MethodPost, "/sync/clients", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	var got SyncValidationError
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Error != errCodeInvalidRequest || len(got.ClientIDs) != 1 || got.ClientIDs[0] != "a" || len(got.Errors) != 1 {
+		t.Errorf("body = %+v, want invalid_request listing a", got)
+	}
+
+	rec = httptest.NewRecorder()
+	(&Server{}).handleSyncClients(rec, httptest.NewRequest(http.MethodPost, "/sync/clients?duplicates=first", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "duplicates must be") {
+		t.Errorf("unknown duplicates mode: status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+// syncTestBcrypt plus one more character is a well-formed BCrypt hash
+const syncTestBcrypt = "$2a$10$abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+func TestHandleSyncClientsReportsEveryValidationError(t *testing.T) {
+	body := `{"clients":[
+		{"client_id":"ok","client_secret_hash":"` + syncTestBcrypt + `0"},
+		{"client_id":"bad-hash","client_secret_hash":"$pbkdf2-sha256$i=1000,l=32$c2FsdA$ZGlnZXN0"},
+		{"client_secret_hash":"` + syncTestBcrypt + `1"},
+		{"client_id":"dup","client_secret_hash":"` + syncTestBcrypt + `2"},
+		{"client_id":"no-hash"},
+		{"client_id":"dup","client_secret_hash":"` + syncTestBcrypt + `3"}]}`
+	// No store: reaching it would panic, so a 400 also shows nothing was synced
+	rec := httptest.NewRecorder()
+	(&Server{hasherAlgorithm: "bcrypt"}).handleSyncClients(rec, httptest.NewRequest(http.MethodPost, "/sync/clients", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body.String())
+	}
+	var got SyncValidationError
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	failed := map[string]string{}
+	for _, e := range got.Errors {
+		failed[e.ClientID] = e.Error
+	}
+	if len(got.Errors) != 4 || !strings.Contains(failed[""], "clients[2]: client_id is required") ||
+		!strings.Contains(failed["dup"], "conflicting") || failed["bad-hash"] == "" || failed["no-hash"] == "" {
+		t.Errorf("errors = %+v, want the missing ID, the conflict, and both hash failures", got.Errors)
+	}
+	if _, ok := failed["ok"]; ok {
+		t.Errorf("valid client reported: %+v", got.Errors)
+	}
+	if want := []string{"bad-hash", "dup", "no-hash"}; !slices.Equal(got.ClientIDs, want) {
+		t.Errorf("client_ids = %v, want %v in request order", got.ClientIDs, want)
+	}
+}
+
+func TestValidateSyncClientsPerClientHashAlgorithm(t *testing.T) {
+	const pbkdf2Hash = "$pbkdf2-sha256$i=25000,l=32$c2FsdHNhbHRzYWx0c2FsdA$ZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGRkZGQ"
+	entry := func(id, hash, algorithm string) ClientData {
+		return ClientData{Client: client.Client{ID: id}, ClientSecretHash: hash, HashAlgorithm: algorithm}
+	}
+	clients := []ClientData{
+		entry("default", pbkdf2Hash, ""),
+		entry("migrated", syncTestBcrypt+"0", "bcrypt"),
+		entry("undeclared", syncTestBcrypt+"1", ""),
+		entry("mislabeled", pbkdf2Hash, "bcrypt"),
+		entry("unknown", pbkdf2Hash, "argon2"),
+	}
+
+	_, _, failures := (&Server{hasherAlgorithm: "pbkdf2"}).validateSyncClients(clients, false)
+	failed := map[string]string{}
+	for _, f := range failures {
+		failed[f.ClientID] = f.Error
+	}
+	if len(failures) != 3 || failed["undeclared"] == "" || failed["mislabeled"] == "" {
+		t.Errorf("failures = %+v, want undeclared, mislabeled, and unknown", failures)
+	}
+	if !strings.Contains(failed["unknown"], `unknown hash_algorithm "argon2"`) {
+		t.Errorf("unknown algorithm error = %q", failed["unknown"])
+	}
+}
+
+// cancelingClientWriter cancels the sync's context on its first upsert, as
+// shutdown would mid-phase
+type cancelingClientWriter struct {
+	*fakeClientWriter
+	cancel context.CancelFunc
+}
+
+func (c *cancelingClientWriter) UpsertClient(ctx context.Context, cl *client.Client) (bool, error) {
+	c.cancel()
+	return c.fakeClientWriter.UpsertClient(ctx, cl)
+}
+
+func TestSyncClientsStopsAtPhaseBoundaryWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancelingClientWriter{fakeClientWriter: newFakeClientWriter("stale"), cancel: cancel}
+	desired := []client.Client{{ID: "a"}, {ID: "b"}}
+
+	result, err := syncClients(ctx, w, desired, uuid.Nil, SyncOptions{})
+	if !errors.Is(err, errSyncInterrupted) || !strings.Contains(err.Error(), "before the delete phase") {
+		t.Fatalf("syncClients() error = %v, want interrupted before the delete phase", err)
+	}
+	// The completed upserts are reported with the interruption
+	if result == nil || result.Status != syncStatusPartial || result.CreatedCount != 2 || len(result.Results) != 2 {
+		t.Errorf("result = %+v, want the two upserts with status partial", result)
+	}
+	// The upsert phase ran to completion and no delete started
+	if _, ok := w.clients["b"]; !ok {
+		t.Error("upsert phase cut short")
+	}
+	if _, ok := w.clients["stale"]; !ok {
+		t.Error("stale client deleted after cancellation")
+	}
+
+	// Cancelled before any write, nothing is upserted
+	w = &cancelingClientWriter{fakeClientWriter: newFakeClientWriter(), cancel: func() {}}
+	if _, err := syncClients(ctx, w, desired, uuid.Nil, SyncOptions{}); !errors.Is(err, errSyncInterrupted) || len(w.clients) != 0 {
+		t.Errorf("cancelled up front: error = %v, %d clients written, want interrupted with none", err, len(w.clients))
+	}
+}
+
+// slowClientWriter delays each write and records the peak number in flight
+type slowClientWriter struct {
+	*fakeClientWriter
+	inFlight, peak atomic.Int32
+}
+
+func (s *slowClientWriter) track() func() {
+	n := s.inFlight.Add(1)
+	for {
+		p := s.peak.Load()
+		if n <= p || s.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return func() { s.inFlight.Add(-1) }
+}
+
+func (s *slowClientWriter) UpsertClient(ctx context.Context, c *client.Client) (bool, error) {
+	defer s.track()()
+	return s.fakeClientWriter.UpsertClient(ctx, c)
+}
+
+func (s *slowClientWriter) DeleteClient(ctx context.Context, id string, nid uuid.UUID) error {
+	defer s.track()()
+	return s.fakeClientWriter.DeleteClient(ctx, id, nid)
+}
+
+func TestSyncClientsConcurrentKeepsOrderAndCounts(t *testing.T) {
+	var existing []string
+	for i := 0; i < 10; i++ {
+		existing = append(existing, fmt.Sprintf("existing-%02d", i), fmt.Sprintf("stale-%02d", i))
+	}
+	w := &slowClientWriter{fakeClientWriter: newFakeClientWriter(existing...)}
+	w.failUpsert["new-03"] = true
+	w.failDelete["stale-07"] = true
+
+	var desired []client.Client
+	for i := 0; i < 10; i++ {
+		desired = append(desired, client.Client{ID: fmt.Sprintf("new-%02d", i)}, client.Client{ID: fmt.Sprintf("existing-%02d", i)})
+	}
+
+	result, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{Concurrency: 4})
+	if err != nil {
+		t.Fatalf("syncClients() error = %v", err)
+	}
+
+	if result.CreatedCount != 9 || result.UpdatedCount != 10 || result.DeletedCount != 9 || result.FailedCount != 2 {
+		t.Errorf("counts = created %d, updated %d, deleted %d, failed %d; want 9,10,9,2",
+			result.CreatedCount, result.UpdatedCount, result.DeletedCount, result.FailedCount)
+	}
+	if peak := w.peak.Load(); peak < 2 || peak > 4 {
+		t.Errorf("peak concurrent writes = %d, want 2..4", peak)
+	}
+
+	// Upserts in request order, then deletes in stored ID order
+	var got []string
+	for _, r := range result.Results {
+		got = append(got, r.ClientID)
+	}
+	var want []string
+	for _, c := range desired {
+		want = append(want, c.ID)
+	}
+	for i := 0; i < 10; i++ {
+		want = append(want, fmt.Sprintf("stale-%02d", i))
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("result order = %v, want %v", got, want)
+	}
+}
+
+func TestSyncClientsConcurrentDuplicateIDsUpsertInOrder(t *testing.T) {
+	w := newFakeClientWriter()
+	desired := []client.Client{{ID: "a", Secret: "first"}, {ID: "b"}, {ID: "a", Secret: "second"}}
+
+	if _, err := syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{Concurrency: 4}); err != nil {
+		t.Fatalf("syncClients() error = %v", err)
+	}
+	if got := w.clients["a"].Secret; got != "second" {
+		t.Errorf("client a secret = %q, want the later entry %q", got, "second")
+	}
+}
+
+func TestForEachBoundedCallsEveryIndexOnce(t *testing.T) {
+	for _, limit := range []int{0, 1, 3, 100} {
+		var calls [50]atomic.Int32
+		forEachBounded(len(calls), limit, func(i int) { calls[i].Add(1) })
+		for i := range calls {
+			if n := calls[i].Load(); n != 1 {
+				t.Errorf("limit %d: index %d called %d times, want 1", limit, i, n)
+			}
+		}
+	}
+}
+
+func TestTimedSyncReportsDurationAndThroughput(t *testing.T) {
+	w := &slowClientWriter{fakeClientWriter: newFakeClientWriter("stale")}
+	desired := []client.Client{{ID: "a"}, {ID: "b"}}
+
+	result, err := timedSync(func() (*SyncResult, error) {
+		return syncClients(context.Background(), w, desired, uuid.Nil, SyncOptions{})
+	})
+	if err != nil {
+		t.Fatalf("timedSync() error = %v", err)
+	}
+
+	// Three writes of at least 5ms each, run serially
+	if result.DurationMS < 15 {
+		t.Errorf("duration_ms = %v, want at least 15", result.DurationMS)
+	}
+	want := float64(len(result.Results)) / (result.DurationMS / 1000)
+	if result.ClientsPerSecond <= 0 || math.Abs(result.ClientsPerSecond-want) > 1e-6*want {
+		t.Errorf("clients_per_second = %v, want %v", result.ClientsPerSecond, want)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Server-Sent Events a streamed sync sends
+const (
+	syncEventProgress = "progress" // a SyncProgress per client
+	syncEventResult   = "result"   // the final SyncResult
+	syncEventError    = "error"    // an APIError ending the stream
+)
+
+// wantsEventStream reports whether the request's Accept header asks for
+// Server-Sent Events
+func wantsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			if mediaType, _, err := mime.ParseMediaType(part); err == nil && mediaType == "text/event-stream" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncEventStream writes a sync's progress as Server-Sent Events. The
+// response starts with the first event, so a sync refused or failed before
+// any client was processed still gets the usual JSON error. Write errors are
+// ignored: a caller disconnecting does not stop a sync.
+type syncEventStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	started bool
+}
+
+func newSyncEventStream(w http.ResponseWriter) *syncEventStream {
+	flusher, _ := w.(http.Flusher)
+	return &syncEventStream{w: w, flusher: flusher}
+}
+
+// send writes one event with data as its JSON payload and flushes it
+func (e *syncEventStream) send(event string, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding sync %s event: %v", event, err)
+		return
+	}
+	if !e.started {
+		e.w.Header().Set("Content-Type", "text/event-stream")
+		e.w.Header().Set("Cache-Control", "no-cache")
+		// Stops nginx and similar proxies from buffering the events
+		e.w.Header().Set("X-Accel-Buffering", "no")
+		e.w.WriteHeader(http.StatusOK)
+		e.started = true
+	}
+	fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, encoded)
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+}
+
+// progress sends a progress event; it is a SyncOptions.Progress callback
+func (e *syncEventStream) progress(p SyncProgress) {
+	e.send(syncEventProgress, p)
+}
+
+// fail reports a sync error: as an error event once the stream has started,
+// else (or with no stream) as a JSON error response
+func (e *syncEventStream) fail(w http.ResponseWriter, status int, errCode, msg string) {
+	if e == nil || !e.started {
+		writeJSONError(w, status, errCode, msg)
+		return
+	}
+	e.send(syncEventError, APIError{Error: errCode, ErrorDescription: msg})
+}
+
+// interrupted reports a sync stopped by shutdown, with the results of the
+// phases it completed: as an error event once the stream has started, else
+// as a 503 JSON response
+func (e *syncEventStream) interrupted(w http.ResponseWriter, body SyncInterruptedError) {
+	if e != nil && e.started {
+		e.send(syncEventError, body)
+		return
+	}
+	writeSyncInterrupted(w, body)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWantsEventStream(t *testing.T) {
+	for _, tc := range []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"text/event-stream", true},
+		{"application/json, text/event-stream;q=0.9", true},
+		{"TEXT/EVENT-STREAM", true},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/sync/clients", nil)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		if got := wantsEventStream(r); got != tc.want {
+			t.Errorf("Accept %q: wantsEventStream() = %v, want %v", tc.accept, got, tc.want)
+		}
+	}
+}
+
+func TestSyncEventStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	events := newSyncEventStream(rec)
+	events.progress(SyncProgress{ClientResult: ClientResult{ClientID: "svc-a", Operation: syncOpUpsert, Status: "created"}, Processed: 1, Total: 2})
+	events.fail(rec, http.StatusInternalServerError, errCodeInternal, "internal error during sync")
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("status = %d, Content-Type = %q, want 200 text/event-stream", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !rec.Flushed {
+		t.Error("events were not flushed")
+	}
+	want := "event: progress\n" +
+		`data: {"client_id":"svc-a","operation":"upsert","status":"created","processed":1,"total":2}` + "\n\n" +
+		"event: error\n" +
+		`data: {"error":"internal_error","error_description":"internal error during sync"}` + "\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSyncEventStreamFailsAsJSONBeforeFirstEvent(t *testing.T) {
+	for name, events := range map[string]*syncEventStream{
+		"not started": newSyncEventStream(httptest.NewRecorder()),
+		"no stream":   nil,
+	} {
+		rec := httptest.NewRecorder()
+		events.fail(rec, http.StatusServiceUnavailable, errCodeUnavailable, "sync interrupted")
+		if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"error":"unavailable"`) {
+			t.Errorf("%s: status = %d, body = %s, want a 503 JSON error", name, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSyncEventStreamInterrupted(t *testing.T) {
+	body := SyncInterruptedError{
+		APIError: APIError{Error: errCodeUnavailable, ErrorDescription: "sync interrupted"},
+		Result:   &SyncResult{Status: syncStatusPartial, CreatedCount: 1, Results: []ClientResult{{ClientID: "svc-a", Operation: syncOpUpsert, Status: "created"}}},
+	}
+
+	// Before the first event the partial result is the 503 body
+	rec := httptest.NewRecorder()
+	newSyncEventStream(rec).interrupted(rec, body)
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), `"result":{"status":"partial","created_count":1`) {
+		t.Errorf("status = %d, body = %s, want a 503 with the partial result", rec.Code, rec.Body.String())
+	}
+
+	// Once streaming, it ends the stream as the error event
+	rec = httptest.NewRecorder()
+	events := newSyncEventStream(rec)
+	events.progress(SyncProgress{ClientResult: body.Result.Results[0], Processed: 1, Total: 2})
+	events.interrupted(rec, body)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "event: error\ndata: {\"error\":\"unavailable\",\"error_description\":\"sync interrupted\",\"result\":{\"status\":\"partial\"") {
+		t.Errorf("status = %d, body = %s, want an error event with the partial result", rec.Code, rec.Body.String())
+	}
+}
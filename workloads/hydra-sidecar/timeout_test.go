@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newSlowHydra answers after delay, longer than the test client's timeout
+func newSlowHydra(t *testing.T, delay time.Duration) (*httptest.Server, *http.Client) {
+	t.Helper()
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+		}
+		w.Write([]byte(`{"metadata":{"org_id":"acme"}}`))
+	}))
+	t.Cleanup(hydra.Close)
+	client := hydra.Client()
+	client.Timeout = 20 * time.Millisecond
+	return hydra, client
+}
+
+func TestFetchClientInfoDistinguishesTimeoutAndNotFound(t *testing.T) {
+	slow, client := newSlowHydra(t, time.Second)
+	s := &Server{hydraAdminURL: slow.URL, httpClient: client}
+	if _, err := s.fetchClientInfo(context.Background(), "svc-a"); !errors.Is(err, errHydraTimeout) {
+		t.Errorf("slow Hydra: err = %v, want errHydraTimeout", err)
+	}
+
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	s = &Server{hydraAdminURL: missing.URL, httpClient: missing.Client()}
+	_, err := s.fetchClientInfo(context.Background(), "svc-a")
+	if !errors.Is(err, errClientNotFound) || errors.Is(err, errHydraTimeout) {
+		t.Errorf("404: err = %v, want errClientNotFound only", err)
+	}
+}
+
+func TestTokenHookFailsClosedOnTimeout(t *testing.T) {
+	slow, client := newSlowHydra(t, time.Second)
+
+	s := &Server{hydraAdminURL: slow.URL, httpClient: client, failClosed: true}
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("fail closed: status = %d, want 503", rec.Code)
+	}
+
+	// Default stays fail-open: token issued without metadata claims
+	s = &Server{hydraAdminURL: slow.URL, httpClient: client}
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK {
+		t.Errorf("fail open: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestTokenHookFailClosedStillFallsBackOnNotFound(t *testing.T) {
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	s := &Server{hydraAdminURL: missing.URL, httpClient: missing.Client(), failClosed: true}
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200 fallback for a 404", rec.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceHandlerPassesResponseThrough(t *testing.T) {
+	h := traceHandler("/admin/clients", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("body"))
+		w.(http.Flusher).Flush()
+	})
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/admin/clients", nil))
+	if rec.Code != http.StatusTeapot || rec.Body.String() != "body" {
+		t.Errorf("response = %d %q, want 418 \"body\"", rec.Code, rec.Body.String())
+	}
+	if !rec.Flushed {
+		t.Error("Flush did not reach the underlying writer")
+	}
+}
+
+func TestStatusWriterDefaultsToOK(t *testing.T) {
+	sw := &statusWriter{ResponseWriter: httptest.NewRecorder(), status: http.StatusOK}
+	sw.Write([]byte("x"))
+	if sw.status != http.StatusOK {
+		t.Errorf("status = %d, want 200", sw.status)
+	}
+}
+
+func TestSetupTracingDisabledWithoutEndpoint(t *testing.T) {
+	shutdown, err := setupTracing(context.Background(), "")
+	if err != nil {
+		t.Fatalf("setupTracing() error = %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown() error = %v", err)
+	}
+}
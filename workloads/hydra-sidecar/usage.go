@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// usageDelta is the not-yet-persisted token issuance activity for one client
+type usageDelta struct {
+	Count        int64
+	LastIssuedAt time.Time
+}
+
+// usageSink persists batched usage deltas (implemented by Store)
+type usageSink interface {
+	RecordClientUsage(ctx context.Context, nid uuid.UUID, deltas map[string]usageDelta) error
+}
+
+// UsageRecorder accumulates token issuance counts in memory and flushes them
+// to the store in batches, so the token hook never writes to the DB directly
+type UsageRecorder struct {
+	mu      sync.Mutex
+	pending map[string]usageDelta
+	sink    usageSink
+}
+
+// NewUsageRecorder creates a recorder that flushes into sink
+func NewUsageRecorder(sink usageSink) *UsageRecorder {
+	return &UsageRecorder{
+		pending: make(map[string]usageDelta),
+		sink:    sink,
+	}
+}
+
+// Record notes a token issuance for a client
+func (u *UsageRecorder) Record(clientID string, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	d := u.pending[clientID]
+	d.Count++
+	if at.After(d.LastIssuedAt) {
+		d.LastIssuedAt = at
+	}
+	u.pending[clientID] = d
+}
+
+// Pending returns the unflushed activity for a client
+func (u *UsageRecorder) Pending(clientID string) (usageDelta, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	d, ok := u.pending[clientID]
+	return d, ok
+}
+
+// Flush writes all pending deltas in one batch. On failure the deltas are
+// merged back so they are retried on the next flush.
+func (u *UsageRecorder) Flush(ctx context.Context, nid uuid.UUID) error {
+	u.mu.Lock()
+	if len(u.pending) == 0 {
+		u.mu.Unlock()
+		return nil
+	}
+	batch := u.pending
+	u.pending = make(map[string]usageDelta)
+	u.mu.Unlock()
+
+	if err := u.sink.RecordClientUsage(ctx, nid, batch); err != nil {
+		u.mu.Lock()
+		for id, d := range batch {
+			cur := u.pending[id]
+			cur.Count += d.Count
+			if d.LastIssuedAt.After(cur.LastIssuedAt) {
+				cur.LastIssuedAt = d.LastIssuedAt
+			}
+			u.pending[id] = cur
+		}
+		u.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Run flushes pending usage every interval until ctx is cancelled, then
+// performs a final flush. nid is looked up on each tick since the network ID
+// may only become available after startup.
+func (u *UsageRecorder) Run(ctx context.Context, interval time.Duration, nid func() uuid.UUID) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	flush := func(ctx context.Context) {
+		id := nid()
+		if id == uuid.Nil {
+			return
+		}
+		if err := u.Flush(ctx, id); err != nil {
+			log.Printf("Warning: Failed to flush client usage: %v", err)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Final flush with a fresh context so shutdown doesn't drop counts
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
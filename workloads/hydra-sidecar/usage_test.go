@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+type fakeUsageSink struct {
+	recorded map[string]usageDelta
+	err      error
+}
+
+func (f *fakeUsageSink) RecordClientUsage(_ context.Context, _ uuid.UUID, deltas map[string]usageDelta) error {
+	if f.err != nil {
+		return f.err
+	}
+	if f.recorded == nil {
+		f.recorded = make(map[string]usageDelta)
+	}
+	for id, d := range deltas {
+		cur := f.recorded[id]
+		cur.Count += d.Count
+		cur.LastIssuedAt = d.LastIssuedAt
+		f.recorded[id] = cur
+	}
+	return nil
+}
+
+// newFakeHydra serves GET /admin/clients/{id} with the given JSON body
+func newFakeHydra(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(hydra.Close)
+	return hydra
+}
+
+func callTokenHook(t *testing.T, s *Server, clientID string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := `{"session":{"client_id":"` + clientID + `"},"request":{"client_id":"` + clientID + `","granted_scopes":["read"]}}`
+	rec := httptest.NewRecorder()
+	s.handleTokenHook(rec, httptest.NewRequest(http.MethodPost, "/token-hook", strings.NewReader(body)))
+	return rec
+}
+
+func TestTokenHookRecordsUsage(t *testing.T) {
+	hydra := newFakeHydra(t, `{"metadata":{"org_id":"acme"}}`)
+	sink := &fakeUsageSink{}
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		usage:         NewUsageRecorder(sink),
+	}
+
+	for i := 0; i < 3; i++ {
+		if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK {
+			t.Fatalf("token hook status = %d, want 200", rec.Code)
+		}
+	}
+
+	d, ok := s.usage.Pending("svc-a")
+	if !ok || d.Count != 3 {
+		t.Fatalf("pending usage = %+v (ok=%v), want count 3", d, ok)
+	}
+
+	if err := s.usage.Flush(context.Background(), uuid.Must(uuid.NewV4())); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if got := sink.recorded["svc-a"].Count; got != 3 {
+		t.Errorf("flushed count = %d, want 3", got)
+	}
+	if sink.recorded["svc-a"].LastIssuedAt.IsZero() {
+		t.Error("flushed last_issued_at is zero")
+	}
+	if _, ok := s.usage.Pending("svc-a"); ok {
+		t.Error("pending usage not cleared after flush")
+	}
+
+	// A further call after flush starts a new batch
+	callTokenHook(t, s, "svc-a")
+	if d, _ := s.usage.Pending("svc-a"); d.Count != 1 {
+		t.Errorf("pending count after flush = %d, want 1", d.Count)
+	}
+}
+
+func TestUsageFlushFailureKeepsPending(t *testing.T) {
+	sink := &fakeUsageSink{err: errors.New("db down")}
+	u := NewUsageRecorder(sink)
+	u.Record("svc-a", time.Now())
+	u.Record("svc-a", time.Now())
+
+	if err := u.Flush(context.Background(), uuid.Must(uuid.NewV4())); err == nil {
+		t.Fatal("Flush() error = nil, want error")
+	}
+	if d, _ := u.Pending("svc-a"); d.Count != 2 {
+		t.Errorf("pending count after failed flush = %d, want 2", d.Count)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// storedHashes is a secretHashLookup where a missing client is sql.ErrNoRows
+type storedHashes map[string]string
+
+func (f storedHashes) GetHashedSecret(_ context.Context, clientID string, _ uuid.UUID) (string, error) {
+	hash, ok := f[clientID]
+	if !ok {
+		return "", fmt.Errorf("failed to get client: %w", sql.ErrNoRows)
+	}
+	return hash, nil
+}
+
+// testPbkdf2Hash hashes secret the way Hydra stores PBKDF2 secrets
+func testPbkdf2Hash(t *testing.T, secret string) string {
+	t.Helper()
+	salt := []byte("0123456789abcdef")
+	digest, err := pbkdf2.Key(sha256.New, secret, salt, 1000, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "$pbkdf2-sha256$i=1000,l=32$" + base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(digest)
+}
+
+func TestVerifySecret(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, hash string
+	}{
+		{"pbkdf2", testPbkdf2Hash(t, "s3cret")},
+		{"bcrypt", string(bcryptHash)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if ok, err := verifySecret("s3cret", tc.hash); err != nil || !ok {
+				t.Errorf("matching secret = %v, %v, want true", ok, err)
+			}
+			if ok, err := verifySecret("wrong", tc.hash); err != nil || ok {
+				t.Errorf("wrong secret = %v, %v, want false", ok, err)
+			}
+		})
+	}
+
+	if _, err := verifySecret("s3cret", "plaintext-not-a-hash"); err == nil {
+		t.Error("unknown hash format: want an error")
+	}
+	if _, err := verifySecret("s3cret", "$pbkdf2-sha256$i=1000,l=32$c2FsdA$ZGlnZXN0"); err == nil {
+		t.Error("malformed PBKDF2 hash: want an error")
+	}
+}
+
+func postVerifySecret(s *Server, db secretHashLookup, clientID, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/admin/clients/"+clientID+"/verify-secret", strings.NewReader(body))
+	s.serveVerifyClientSecret(rec, req, clientID, db)
+	return rec
+}
+
+func newVerifySecretServer(rate float64) *Server {
+	return &Server{
+		networkID:     uuid.Must(uuid.NewV4()),
+		config:        Config{VerifySecretRateLimit: rate},
+		verifyLimiter: newClientRateLimiter(rate),
+	}
+}
+
+func TestServeVerifyClientSecret(t *testing.T) {
+	s := newVerifySecretServer(100)
+	db := storedHashes{"svc-a": testPbkdf2Hash(t, "s3cret"), "public": ""}
+
+	for _, tc := range []struct {
+		name, clientID, body string
+		wantStatus           int
+		wantValid            bool
+	}{
+		{"match", "svc-a", `{"client_secret":"s3cret"}`, http.StatusOK, true},
+		{"no match", "svc-a", `{"client_secret":"guess"}`, http.StatusOK, false},
+		{"public client", "public", `{"client_secret":"s3cret"}`, http.StatusOK, false},
+		{"unknown client", "missing", `{"client_secret":"s3cret"}`, http.StatusNotFound, false},
+		{"empty secret", "svc-a", `{"client_secret":""}`, http.StatusBadRequest, false},
+		{"invalid JSON", "svc-a", `{`, http.StatusBadRequest, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := postVerifySecret(s, db, tc.clientID, tc.body)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.wantStatus, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var got VerifySecretResult
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got.Valid != tc.wantValid {
+				t.Errorf("valid = %v, want %v", got.Valid, tc.wantValid)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	s.serveVerifyClientSecret(rec, httptest.NewRequest(http.MethodGet, "/admin/clients/svc-a/verify-secret", nil), "svc-a", db)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", rec.Code)
+	}
+}
+
+func TestServeVerifyClientSecretRateLimit(t *testing.T) {
+	s := newVerifySecretServer(0.1)
+	db := storedHashes{"svc-a": testPbkdf2Hash(t, "s3cret"), "svc-b": testPbkdf2Hash(t, "other")}
+
+	if rec := postVerifySecret(s, db, "svc-a", `{"client_secret":"guess"}`); rec.Code != http.StatusOK {
+		t.Fatalf("first check: status = %d, want 200", rec.Code)
+	}
+	rec := postVerifySecret(s, db, "svc-a", `{"client_secret":"s3cret"}`)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second check: status = %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("Retry-After = %q, want 10", got)
+	}
+	if !strings.Contains(rec.Body.String(), errCodeTooManyRequests) {
+		t.Errorf("body = %s, want %s", rec.Body.String(), errCodeTooManyRequests)
+	}
+
+	// Other clients have their own budget
+	if rec := postVerifySecret(s, db, "svc-b", `{"client_secret":"guess"}`); rec.Code != http.StatusOK {
+		t.Errorf("other client: status = %d, want 200", rec.Code)
+	}
+}
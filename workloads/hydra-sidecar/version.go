@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sidecar build identity, set at build time:
+//
+//	go build -ldflags "-X main.version=v1.4.0 -X main.commit=$(git rev-parse --short HEAD)"
+var (
+	version = "dev"
+	commit  = "unknown"
+)
+
+// Hydra versions known to share the client.Client schema the sidecar embeds.
+// Hydra moved from v2.x to calendar-style v25.x tags with the same schema.
+const (
+	minCompatibleHydraVersion = "v2.2.0"
+	maxCompatibleHydraVersion = "v26.0.0" // exclusive
+)
+
+// fetchHydraVersion queries Hydra Admin's /version endpoint
+func (s *Server) fetchHydraVersion(ctx context.Context) (string, error) {
+	req, err := s.newHydraRequest(ctx, http.MethodGet, "/version", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var v struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return "", err
+	}
+	if v.Version == "" {
+		return "", fmt.Errorf("empty version in response")
+	}
+	return v.Version, nil
+}
+
+// probeHydraVersion fetches and logs the Hydra version at startup, warning
+// when it falls outside the known-compatible range. Failures are non-fatal.
+func (s *Server) probeHydraVersion(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	version, err := s.fetchHydraVersion(ctx)
+	if err != nil {
+		log.Printf("Warning: Could not determine Hydra version: %v", err)
+		return
+	}
+
+	s.hydraVersion = version
+	log.Printf("Hydra version: %s", version)
+	if !isCompatibleHydraVersion(version) {
+		log.Printf("Warning: Hydra %s is outside the known-compatible range [%s, %s)",
+			version, minCompatibleHydraVersion, maxCompatibleHydraVersion)
+	}
+}
+
+// isCompatibleHydraVersion reports whether version is within the tested range
+func isCompatibleHydraVersion(version string) bool {
+	v, ok := parseVersion(version)
+	if !ok {
+		return false
+	}
+	lo, _ := parseVersion(minCompatibleHydraVersion)
+	hi, _ := parseVersion(maxCompatibleHydraVersion)
+	return compareVersions(v, lo) >= 0 && compareVersions(v, hi) < 0
+}
+
+// parseVersion parses "v1.2.3" (pre-release/build suffixes ignored)
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	fields := strings.Split(version, ".")
+	if len(fields) != 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// swagger:route GET /version health version
+//
+// Hydra version.
+//
+// Returns the Hydra version detected at startup and whether it is in the known-compatible range.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: versionResponse
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	info := VersionInfo{
+		HydraVersion:          s.hydraVersion,
+		HydraCompatible:       isCompatibleHydraVersion(s.hydraVersion),
+		CompatibleRangeMin:    minCompatibleHydraVersion,
+		CompatibleRangeMaxExc: maxCompatibleHydraVersion,
+	}
+	if info.HydraVersion == "" {
+		info.HydraVersion = "unknown"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		log.Printf("Error encoding version response: %v", err)
+	}
+}
+
+// swagger:route GET /info health info
+//
+// Sidecar build and uptime.
+//
+// Returns the sidecar's build version and git commit, injected with -ldflags, and how long the process has been running.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: infoResponse
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	info := BuildInfo{
+		Version:       version,
+		Commit:        commit,
+		GoVersion:     runtime.Version(),
+		StartedAt:     s.startedAt.UTC(),
+		UptimeSeconds: int64(time.Since(s.startedAt).Seconds()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		log.Printf("Error encoding info response: %v", err)
+	}
+}
+
+// swagger:route GET /debug/config health debugConfig
+//
+// Effective configuration.
+//
+// Returns the sidecar's effective configuration with secrets redacted, plus the detected Hydra version.
+//
+//	Produces:
+//	- application/json
+//
+//	Responses:
+//	  200: debugConfigResponse
+func (s *Server) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	out := redactedConfig(s.config)
+	out["HydraVersion"] = s.hydraVersion
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(out); err != nil {
+		log.Printf("Error encoding debug config: %v", err)
+	}
+}
+
+// redactedConfig renders Config as a map, masking fields tagged debug:"redact"
+func redactedConfig(cfg Config) map[string]any {
+	out := make(map[string]any)
+	v := reflect.ValueOf(cfg)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Tag.Get("debug") == "redact" {
+			if !v.Field(i).IsZero() {
+				out[field.Name] = "[REDACTED]"
+			} else {
+				out[field.Name] = ""
+			}
+			continue
+		}
+		value := v.Field(i).Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		out[field.Name] = value
+	}
+	return out
+}
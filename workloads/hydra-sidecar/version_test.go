@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbeHydraVersion(t *testing.T) {
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version":"v25.4.0"}`))
+	}))
+	defer hydra.Close()
+
+	s := &Server{hydraAdminURL: hydra.URL, httpClient: hydra.Client()}
+	s.probeHydraVersion(context.Background())
+
+	if s.hydraVersion != "v25.4.0" {
+		t.Fatalf("hydraVersion = %q, want v25.4.0", s.hydraVersion)
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleVersion(rec, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	var info VersionInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("decode /version: %v", err)
+	}
+	if info.HydraVersion != "v25.4.0" || !info.HydraCompatible {
+		t.Errorf("/version = %+v, want v25.4.0 compatible", info)
+	}
+}
+
+func TestInfoReportsBuildAndUptime(t *testing.T) {
+	defer func(v, c string) { version, commit = v, c }(version, commit)
+	version, commit = "v1.4.0", "abc1234"
+
+	started := time.Now().Add(-90 * time.Second)
+	s := &Server{startedAt: started}
+	rec := httptest.NewRecorder()
+	s.handleInfo(rec, httptest.NewRequest(http.MethodGet, "/info", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var info BuildInfo
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("decode /info: %v", err)
+	}
+	if info.Version != "v1.4.0" || info.Commit != "abc1234" || info.GoVersion == "" {
+		t.Errorf("/info = %+v, want v1.4.0 at abc1234 with a Go version", info)
+	}
+	if !info.StartedAt.Equal(started.UTC()) {
+		t.Errorf("started_at = %v, want %v", info.StartedAt, started.UTC())
+	}
+	if info.UptimeSeconds < 90 || info.UptimeSeconds > 100 {
+		t.Errorf("uptime_seconds = %d, want about 90", info.UptimeSeconds)
+	}
+}
+
+func TestIsCompatibleHydraVersion(t *testing.T) {
+	tests := map[string]bool{
+		"v2.2.0":       true,
+		"v2.3.0":       true,
+		"v25.4.0":      true,
+		"v25.4.0-rc.1": true,
+		"v2.1.9":       false,
+		"v26.0.0":      false,
+		"unknown":      false,
+		"":             false,
+	}
+	for version, want := range tests {
+		if got := isCompatibleHydraVersion(version); got != want {
+			t.Errorf("isCompatibleHydraVersion(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestDebugConfigRedactsSecrets(t *testing.T) {
+	s := &Server{
+		config:       Config{DatabaseURL: "postgres://hydra:secret@db/hydra", HasherAlgorithm: "pbkdf2"},
+		hydraVersion: "v25.4.0",
+	}
+
+	rec := httptest.NewRecorder()
+	s.handleDebugConfig(rec, httptest.NewRequest(http.MethodGet, "/debug/config", nil))
+
+	var out map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatalf("decode /debug/config: %v", err)
+	}
+	if out["DatabaseURL"] != "[REDACTED]" {
+		t.Errorf("DatabaseURL = %v, want redacted", out["DatabaseURL"])
+	}
+	if out["HydraVersion"] != "v25.4.0" || out["HasherAlgorithm"] != "pbkdf2" {
+		t.Errorf("debug config = %v, missing expected fields", out)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/gofrs/uuid"
+)
+
+// warmupStore reads the clients to preload into the token hook cache
+type warmupStore interface {
+	GetRecentClientInfo(ctx context.Context, nid uuid.UUID, limit int, byUsage bool) ([]ClientInfoRow, error)
+}
+
+// warmClientCache preloads the token hook cache with up to limit of the
+// default network's most recently active clients (CACHE_WARMUP), so the first
+// token requests after startup don't each wait on Hydra. Entries expire after
+// METADATA_CACHE_TTL like any other. Returns the number of clients cached.
+func (s *Server) warmClientCache(ctx context.Context, db warmupStore, limit int, byUsage bool) (int, error) {
+	if s.clientCache == nil || limit <= 0 {
+		return 0, nil
+	}
+
+	rows, err := db.GetRecentClientInfo(ctx, s.defaultNetworkID(), limit, byUsage)
+	if err != nil {
+		return 0, err
+	}
+
+	cached := 0
+	for _, row := range rows[:min(len(rows), limit)] {
+		info := &ClientInfo{ClientSecretExpiresAt: row.ClientSecretExpiresAt, Scope: row.Scope}
+		if len(row.Metadata) > 0 {
+			if err := json.Unmarshal([]byte(row.Metadata), &info.Metadata); err != nil {
+				log.Printf("Warning: skipping cache warm-up for client %s: invalid metadata JSON: %v", row.ID, err)
+				continue
+			}
+		}
+		s.clientCache.Put(row.ID, info)
+		cached++
+	}
+	return cached, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ory/x/sqlxx"
+)
+
+// fakeWarmupStore returns fixed rows, honouring the limit like the SQL does
+type fakeWarmupStore struct {
+	rows    []ClientInfoRow
+	byUsage bool
+}
+
+func (f *fakeWarmupStore) GetRecentClientInfo(_ context.Context, _ uuid.UUID, limit int, byUsage bool) ([]ClientInfoRow, error) {
+	f.byUsage = byUsage
+	return f.rows[:min(len(f.rows), limit)], nil
+}
+
+func TestWarmClientCachePopulatesBeforeRequests(t *testing.T) {
+	var hits atomic.Int32
+	hydra := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{}`))
+	}))
+	defer hydra.Close()
+
+	s := &Server{
+		hydraAdminURL: hydra.URL,
+		httpClient:    hydra.Client(),
+		clientCache:   newClientInfoCache(time.Minute, 0),
+	}
+	db := &fakeWarmupStore{rows: []ClientInfoRow{
+		{ID: "svc-a", Metadata: sqlxx.JSONRawMessage(`{"org_id":"acme"}`), ClientSecretExpiresAt: 4102444800},
+		{ID: "svc-b", Metadata: sqlxx.JSONRawMessage(`not json`)},
+		{ID: "svc-c"},
+		{ID: "svc-d"},
+	}}
+
+	n, err := s.warmClientCache(context.Background(), db, 3, true)
+	if err != nil {
+		t.Fatalf("warmClientCache() error = %v", err)
+	}
+	if n != 2 || !db.byUsage {
+		t.Errorf("cached %d (byUsage %t), want 2 with usage ordering", n, db.byUsage)
+	}
+
+	info, ok := s.clientCache.Get("svc-a")
+	if !ok || info.Metadata["org_id"] != "acme" || info.ClientSecretExpiresAt != 4102444800 {
+		t.Fatalf("svc-a cache entry = %+v, %t; want warmed metadata and expiry", info, ok)
+	}
+	if _, ok := s.clientCache.Get("svc-b"); ok {
+		t.Error("client with invalid metadata was cached")
+	}
+	if _, ok := s.clientCache.Get("svc-d"); ok {
+		t.Error("client beyond the size cap was cached")
+	}
+
+	if rec := callTokenHook(t, s, "svc-a"); rec.Code != http.StatusOK {
+		t.Fatalf("token hook status = %d, want 200", rec.Code)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("token hook for a warmed client called Hydra %d times, want 0", hits.Load())
+	}
+}
+
+func TestWarmClientCacheDisabledCache(t *testing.T) {
+	db := &fakeWarmupStore{rows: []ClientInfoRow{{ID: "svc-a"}}}
+	if n, err := (&Server{}).warmClientCache(context.Background(), db, 10, false); n != 0 || err != nil {
+		t.Errorf("warmClientCache() = %d, %v; want 0, nil without a cache", n, err)
+	}
+}